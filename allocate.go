@@ -0,0 +1,120 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// Returned (wrapped) when Allocate's total can't be split at the requested
+// scale.
+var ErrorInvalidTotal = errors.New("Total cannot be allocated at this scale")
+
+// Splits total into len(weights) parts proportional to the weights, each a
+// whole multiple of 10^-scale (for example, scale 2 splits into cents), such
+// that the parts sum exactly to total. Any units left over after rounding every
+// share down are handed out one at a time to the parts with the largest
+// remainders (the largest remainder method), with ties going to the earlier
+// part. Splitting 100 three ways at scale 2 gives 33.34, 33.33, 33.33.
+//
+// Non-zero parts have the exponent -scale, so 33.30 keeps its trailing zero.
+// Parts that receive nothing are Zero().
+//
+// Fails with ErrorInvalidTotal if total is infinity or NaN, if total is not a
+// whole multiple of 10^-scale, or if a part would not fit into a DFloat.
+// Panics if any weight is negative, or if the weights sum to 0.
+func Allocate(total DFloat, weights []int64, scale int32) ([]DFloat, error) {
+	if total.IsInfinity() || total.IsNan() {
+		return nil, fmt.Errorf("%w: %v is not finite", ErrorInvalidTotal, total)
+	}
+
+	weightSum := new(big.Int)
+	for _, weight := range weights {
+		if weight < 0 {
+			panic("Allocate: weights cannot be negative")
+		}
+		weightSum.Add(weightSum, big.NewInt(weight))
+	}
+	if weightSum.Sign() == 0 {
+		panic("Allocate: weights must sum to more than 0")
+	}
+
+	units := new(big.Int)
+	if !total.IsZero() {
+		units.SetInt64(total.Coefficient)
+		shift := int64(total.Exponent) + int64(scale)
+		if shift > 18 {
+			return nil, fmt.Errorf("%w: %v has too many digits at scale %v to fit into a DFloat", ErrorInvalidTotal, total, scale)
+		}
+		if shift >= 0 {
+			units.Mul(units, pow10BigInt(shift))
+		} else if shift < -19 {
+			return nil, fmt.Errorf("%w: %v is not a whole multiple of 10^%v", ErrorInvalidTotal, total, -scale)
+		} else {
+			var remainder big.Int
+			if units.QuoRem(units, pow10BigInt(-shift), &remainder); remainder.Sign() != 0 {
+				return nil, fmt.Errorf("%w: %v is not a whole multiple of 10^%v", ErrorInvalidTotal, total, -scale)
+			}
+		}
+	}
+	if !units.IsInt64() {
+		return nil, fmt.Errorf("%w: %v has too many digits at scale %v to fit into a DFloat", ErrorInvalidTotal, total, scale)
+	}
+	negative := units.Sign() < 0
+	units.Abs(units)
+
+	shares := make([]big.Int, len(weights))
+	remainders := make([]big.Int, len(weights))
+	leftover := new(big.Int).Set(units)
+	for i, weight := range weights {
+		shares[i].Mul(units, big.NewInt(weight))
+		shares[i].QuoRem(&shares[i], weightSum, &remainders[i])
+		leftover.Sub(leftover, &shares[i])
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].Cmp(&remainders[order[b]]) > 0
+	})
+	for _, i := range order[:leftover.Int64()] {
+		shares[i].Add(&shares[i], big.NewInt(1))
+	}
+
+	parts := make([]DFloat, len(weights))
+	for i := range shares {
+		share := shares[i].Int64()
+		if share == 0 {
+			parts[i] = dfloatZero
+			continue
+		}
+		if negative {
+			share = -share
+		}
+		parts[i] = DFloat{Exponent: -scale, Coefficient: share}
+	}
+	return parts, nil
+}
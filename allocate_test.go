@@ -0,0 +1,103 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"testing"
+)
+
+func assertAllocate(t *testing.T, total string, weights []int64, scale int32, expected ...string) {
+	totalValue, err := DFloatFromString(total)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	parts, err := Allocate(totalValue, weights, scale)
+	if err != nil {
+		t.Errorf("Allocate %v %v: %v", total, weights, err)
+		return
+	}
+	if len(parts) != len(expected) {
+		t.Errorf("Allocate %v %v: Expected %v parts but got %v", total, weights, len(expected), len(parts))
+		return
+	}
+	var sum StatsAccumulator
+	for i, part := range parts {
+		sum.Add(part)
+		if actual := part.Text('f'); actual != expected[i] {
+			t.Errorf("Allocate %v %v: Expected part %v to be %v but got %v", total, weights, i, expected[i], actual)
+		}
+	}
+	var actualSum DFloat
+	if err := sum.Sum(&actualSum); err != nil {
+		t.Error(err)
+		return
+	}
+	if actualSum.minimized() != totalValue.minimized() && !(actualSum.IsZero() && totalValue.IsZero()) {
+		t.Errorf("Allocate %v %v: Parts sum to %v", total, weights, actualSum)
+	}
+}
+
+func assertAllocatePanics(t *testing.T, total DFloat, weights []int64, scale int32) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Allocate %v %v %v: Expected a panic", total, weights, scale)
+		}
+	}()
+	Allocate(total, weights, scale)
+}
+
+func assertAllocateFails(t *testing.T, total DFloat, weights []int64, scale int32) {
+	if parts, err := Allocate(total, weights, scale); !errors.Is(err, ErrorInvalidTotal) {
+		t.Errorf("Allocate %v %v %v: Expected ErrorInvalidTotal but got %v (%v)", total, weights, scale, parts, err)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	assertAllocate(t, "100", []int64{1, 1, 1}, 2, "33.34", "33.33", "33.33")
+	assertAllocate(t, "-100", []int64{1, 1, 1}, 2, "-33.34", "-33.33", "-33.33")
+	assertAllocate(t, "100", []int64{1, 1, 1}, 0, "34", "33", "33")
+	assertAllocate(t, "0.05", []int64{3, 7}, 2, "0.02", "0.03")
+	assertAllocate(t, "99.9", []int64{1, 1, 1}, 2, "33.30", "33.30", "33.30")
+	assertAllocate(t, "0.02", []int64{1, 1, 1}, 2, "0.01", "0.01", "0")
+	assertAllocate(t, "1", []int64{1, 0, 2}, 1, "0.3", "0", "0.7")
+	assertAllocate(t, "0", []int64{1, 1}, 2, "0", "0")
+	assertAllocate(t, "1e3", []int64{1, 2}, -2, "300", "700")
+	assertAllocate(t, "9223372036854775807", []int64{9223372036854775807, 9223372036854775807}, 0,
+		"4611686018427387904", "4611686018427387903")
+}
+
+func TestAllocateFails(t *testing.T) {
+	assertAllocateFails(t, Infinity(), []int64{1}, 2)
+	assertAllocateFails(t, NegativeInfinity(), []int64{1}, 2)
+	assertAllocateFails(t, QuietNaN(), []int64{1}, 2)
+	assertAllocateFails(t, DFloatValue(-3, 1), []int64{1}, 2)
+	assertAllocateFails(t, DFloatValue(-100, 1), []int64{1}, 2)
+	assertAllocateFails(t, DFloatValue(0, 9223372036854775807), []int64{1}, 2)
+	assertAllocateFails(t, DFloatValue(0, 1), []int64{1}, 100)
+}
+
+func TestAllocatePanics(t *testing.T) {
+	assertAllocatePanics(t, DFloatValue(0, 1), []int64{1, -1}, 2)
+	assertAllocatePanics(t, DFloatValue(0, 1), []int64{0, 0}, 2)
+	assertAllocatePanics(t, DFloatValue(0, 1), nil, 2)
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+var ErrorUnsupportedType = errors.New("Unsupported type for compact float encoding")
+
+// Encodes any supported numeric value to a writer, routing it through the
+// appropriate conversion. This saves serialization frameworks doing
+// reflection-driven encoding from having to reimplement the type switch.
+//
+// Supported types are float32, float64, all int and uint types, *big.Int,
+// *big.Float, *apd.Decimal, DFloat, DFloat128 (or any other Decimal), string
+// and json.Number. significantDigits applies only to the binary floating point
+// types (float32, float64, *big.Float), with the same meaning as in
+// DFloatFromFloat64(). Integers up to 127 bits are encoded exactly.
+//
+// As with EncodeFloat64(), if a conversion rounds, the rounded value is encoded
+// and the returned error will be RoundingError. Values of other types fail
+// with ErrorUnsupportedType.
+func EncodeAny(value interface{}, writer io.Writer, significantDigits int) (bytesEncoded int, err error) {
+	var asDecimal Decimal
+	var conversionErr error
+	switch v := value.(type) {
+	case Decimal:
+		asDecimal = v
+	case float64:
+		asDecimal, conversionErr = DFloatFromFloat64(v, significantDigits)
+	case float32:
+		asDecimal, conversionErr = dfloatFromFloat32(v, significantDigits)
+	case int:
+		asDecimal = DFloatValue(0, int64(v))
+	case int8:
+		asDecimal = DFloatValue(0, int64(v))
+	case int16:
+		asDecimal = DFloatValue(0, int64(v))
+	case int32:
+		asDecimal = DFloatValue(0, int64(v))
+	case int64:
+		asDecimal = DFloatValue(0, v)
+	case uint:
+		asDecimal = DFloat128Value(0, Int128{Low: uint64(v)})
+	case uint8:
+		asDecimal = DFloatValue(0, int64(v))
+	case uint16:
+		asDecimal = DFloatValue(0, int64(v))
+	case uint32:
+		asDecimal = DFloatValue(0, int64(v))
+	case uint64:
+		asDecimal = DFloat128Value(0, Int128{Low: v})
+	case *big.Int:
+		if v == nil {
+			break
+		}
+		var asDFloat128 DFloat128
+		conversionErr = asDFloat128.Pack(0, v)
+		asDecimal = asDFloat128.minimized()
+	case *big.Float:
+		if v == nil {
+			break
+		}
+		asDecimal, conversionErr = dfloatFromBigFloat(v, significantDigits)
+	case string:
+		asDecimal, conversionErr = DFloatFromString(v)
+	case json.Number:
+		asDecimal, conversionErr = DFloatFromJSONNumber(v)
+	default:
+		asDecimal = bigDecimalAdapter(value)
+	}
+
+	if asDecimal == nil {
+		err = fmt.Errorf("%w: %T", ErrorUnsupportedType, value)
+		return
+	}
+	if conversionErr != nil && !errors.Is(conversionErr, roundingError) {
+		err = conversionErr
+		return
+	}
+	if bytesEncoded, err = EncodeDecimal(asDecimal, writer); err == nil {
+		err = conversionErr
+	}
+	return
+}
+
+// Converts a float32 using its own shortest representation (rather than that
+// of the float64 it widens to).
+func dfloatFromFloat32(value float32, significantDigits int) (DFloat, error) {
+	asFloat64 := float64(value)
+	if asFloat64 == 0 || math.IsInf(asFloat64, 0) || math.IsNaN(asFloat64) {
+		return DFloatFromFloat64(asFloat64, significantDigits)
+	}
+	return DFloatFromStringWithDigits(strconv.FormatFloat(asFloat64, 'g', -1, 32), significantDigits)
+}
+
+func dfloatFromBigFloat(value *big.Float, significantDigits int) (DFloat, error) {
+	if significantDigits < 1 || value.IsInf() {
+		return DFloatFromBigFloat(value)
+	}
+	return DFloatFromStringWithDigits(bigFloatText(value), significantDigits)
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func assertEncodeAny(t *testing.T, value interface{}, significantDigits int, expected Decimal, expectedErr error) {
+	var buffer bytes.Buffer
+	bytesEncoded, err := EncodeAny(value, &buffer, significantDigits)
+	if expectedErr != nil {
+		if !errors.Is(err, expectedErr) {
+			t.Errorf("%T %v: Expected error %v but got %v", value, value, expectedErr, err)
+		}
+	} else if err != nil {
+		t.Errorf("%T %v: %v", value, value, err)
+		return
+	}
+	if expected == nil {
+		return
+	}
+	expectedEncoded := AppendEncodeDecimal(nil, expected)
+	if !bytes.Equal(buffer.Bytes(), expectedEncoded) || bytesEncoded != len(expectedEncoded) {
+		t.Errorf("%T %v: Expected %x but got %x (%v bytes)", value, value, expectedEncoded, buffer.Bytes(), bytesEncoded)
+	}
+}
+
+func TestEncodeAny(t *testing.T) {
+	assertEncodeAny(t, 1.5, 0, DFloat{Exponent: -1, Coefficient: 15}, nil)
+	assertEncodeAny(t, 0.1234567, 3, DFloat{Exponent: -3, Coefficient: 123}, RoundingError())
+	assertEncodeAny(t, math.Inf(-1), 0, NegativeInfinity(), nil)
+	assertEncodeAny(t, float32(0.1), 0, DFloat{Exponent: -1, Coefficient: 1}, nil)
+	assertEncodeAny(t, float32(1.2345), 2, DFloat{Exponent: -1, Coefficient: 12}, RoundingError())
+	assertEncodeAny(t, float32(math.Copysign(0, -1)), 0, NegativeZero(), nil)
+	assertEncodeAny(t, int(-100), 0, DFloat{Exponent: 2, Coefficient: -1}, nil)
+	assertEncodeAny(t, int8(-5), 0, DFloat{Coefficient: -5}, nil)
+	assertEncodeAny(t, int16(300), 0, DFloat{Exponent: 2, Coefficient: 3}, nil)
+	assertEncodeAny(t, int32(7), 0, DFloat{Coefficient: 7}, nil)
+	assertEncodeAny(t, int64(math.MinInt64), 0, DFloat{Coefficient: math.MinInt64}, nil)
+	assertEncodeAny(t, uint(7), 0, DFloat{Coefficient: 7}, nil)
+	assertEncodeAny(t, uint8(255), 0, DFloat{Coefficient: 255}, nil)
+	assertEncodeAny(t, uint16(65535), 0, DFloat{Coefficient: 65535}, nil)
+	assertEncodeAny(t, uint32(10), 0, DFloat{Exponent: 1, Coefficient: 1}, nil)
+	assertEncodeAny(t, uint64(math.MaxUint64), 0, DFloat128{Coefficient: Int128{Low: math.MaxUint64}}, nil)
+	assertEncodeAny(t, DFloat{Exponent: -2, Coefficient: 150}, 0, DFloat{Exponent: -2, Coefficient: 150}, nil)
+	assertEncodeAny(t, &DFloat{Exponent: 1, Coefficient: 5}, 0, DFloat{Exponent: 1, Coefficient: 5}, nil)
+	assertEncodeAny(t, "-1.25e10", 0, DFloat{Exponent: 8, Coefficient: -125}, nil)
+	assertEncodeAny(t, json.Number("0.5"), 0, DFloat{Exponent: -1, Coefficient: 5}, nil)
+
+	bigInt, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	assertEncodeAny(t, bigInt, 0, DFloat128{Exponent: 1, Coefficient: mustInt128(new(big.Int).Quo(bigInt, big.NewInt(10)))}, nil)
+	bigInt, _ = new(big.Int).SetString("12345678901234567890123456789012345678951", 10)
+	assertEncodeAny(t, bigInt, 0, nil, RoundingError())
+
+	bigFloat, _, _ := big.ParseFloat("1.5", 10, 100, big.ToNearestEven)
+	assertEncodeAny(t, bigFloat, 0, DFloat{Exponent: -1, Coefficient: 15}, nil)
+	bigFloat, _, _ = big.ParseFloat("1.2345", 10, 100, big.ToNearestEven)
+	assertEncodeAny(t, bigFloat, 3, DFloat{Exponent: -2, Coefficient: 123}, RoundingError())
+}
+
+func TestEncodeAnyUnsupported(t *testing.T) {
+	for _, value := range []interface{}{nil, true, []byte{1}, (*big.Int)(nil), (*big.Float)(nil), "not a number"} {
+		var buffer bytes.Buffer
+		if _, err := EncodeAny(value, &buffer, 0); err == nil {
+			t.Errorf("Expected %T %v to fail", value, value)
+		}
+		if buffer.Len() != 0 {
+			t.Errorf("Expected nothing to be written for %T %v", value, value)
+		}
+	}
+	if _, err := EncodeAny(true, &bytes.Buffer{}, 0); !errors.Is(err, ErrorUnsupportedType) {
+		t.Errorf("Expected ErrorUnsupportedType but got %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+)
+
+// Returned (wrapped) when dividing by zero. The result returned alongside it
+// is infinity with the sign of the quotient, or NaN when dividing zero by zero.
+var ErrorDivisionByZero = errors.New("Division by zero")
+
+// Returns this value plus n. Exact results are computed without constructing
+// a second DFloat or allocating. A result that doesn't fit into a DFloat is
+// rounded (half-to-even) and returned along with RoundingError. An exact sum
+// of zero is positive zero. Infinity and NaN are returned unchanged.
+func (this DFloat) AddInt(n int64) (DFloat, error) {
+	if this.IsZero() {
+		return DFloatValue(0, n), nil
+	}
+	if this.IsSpecial() {
+		return this, nil
+	}
+	if n == 0 {
+		return this.minimized(), nil
+	}
+
+	switch {
+	case this.Exponent == 0:
+		if sum, ok := addInt64(this.Coefficient, n); ok {
+			return DFloatValue(0, sum), nil
+		}
+	case this.Exponent > 0:
+		if scaled, ok := scaleInt64(this.Coefficient, int64(this.Exponent)); ok {
+			if sum, ok := addInt64(scaled, n); ok {
+				return DFloatValue(0, sum), nil
+			}
+		}
+	default:
+		if scaled, ok := scaleInt64(n, -int64(this.Exponent)); ok {
+			if sum, ok := addInt64(this.Coefficient, scaled); ok {
+				return DFloatValue(this.Exponent, sum), nil
+			}
+		}
+	}
+	return addBig(this.Coefficient, int64(this.Exponent), n, 0)
+}
+
+// Returns this value multiplied by n. Exact results are computed without
+// constructing a second DFloat or allocating. A result that doesn't fit into
+// a DFloat is rounded (half-to-even) and returned along with RoundingError.
+// Infinity multiplied by zero is NaN, and NaN is returned unchanged.
+func (this DFloat) MulInt(n int64) (DFloat, error) {
+	isNegative := hasNegativeSign(this) != (n < 0)
+	switch {
+	case this.IsNan():
+		return this, nil
+	case this.IsInfinity():
+		if n == 0 {
+			return dfloatNaN, nil
+		}
+		return infinityWithSign(isNegative), nil
+	case this.IsZero() || n == 0:
+		return dfloatZero.withSign(isNegative), nil
+	}
+
+	high, low := bits.Mul64(magnitudeInt64(this.Coefficient), magnitudeInt64(n))
+	if high == 0 && (low <= math.MaxInt64 || (low == 1<<63 && isNegative)) {
+		return DFloat{Exponent: this.Exponent, Coefficient: int64(low)}.withSign(isNegative), nil
+	}
+	product := new(big.Int).Mul(big.NewInt(this.Coefficient), big.NewInt(n))
+	return dfloatFromBigInt(product, int64(this.Exponent))
+}
+
+// Returns this value divided by n. Exact quotients are computed without
+// constructing a second DFloat or allocating. Otherwise the quotient is
+// rounded (half-to-even) to fit into a DFloat and returned along with
+// RoundingError. Dividing a finite value by zero fails with
+// ErrorDivisionByZero. Infinity divided by any integer is infinity, and NaN is
+// returned unchanged.
+func (this DFloat) DivInt(n int64) (DFloat, error) {
+	isNegative := hasNegativeSign(this) != (n < 0)
+	switch {
+	case this.IsNan():
+		return this, nil
+	case this.IsInfinity():
+		return infinityWithSign(isNegative), nil
+	case n == 0:
+		if this.IsZero() {
+			return dfloatNaN, fmt.Errorf("%w: %v / 0", ErrorDivisionByZero, this)
+		}
+		return infinityWithSign(isNegative), fmt.Errorf("%w: %v / 0", ErrorDivisionByZero, this)
+	case this.IsZero():
+		return dfloatZero.withSign(isNegative), nil
+	}
+
+	dividend, divisor := magnitudeInt64(this.Coefficient), magnitudeInt64(n)
+	if quotient := dividend / divisor; dividend%divisor == 0 && quotient <= math.MaxInt64 {
+		return DFloat{Exponent: this.Exponent, Coefficient: int64(quotient)}.withSign(isNegative), nil
+	}
+
+	// Scale the dividend so that the quotient has more digits than a DFloat
+	// can hold. If there's a remainder, append a non-zero digit so that the
+	// quotient rounds the same way the exact (infinite) quotient would.
+	const scaleDigits = 39
+	quotient, remainder := new(big.Int).QuoRem(
+		new(big.Int).Mul(new(big.Int).SetUint64(dividend), pow10BigInt(scaleDigits)),
+		new(big.Int).SetUint64(divisor),
+		new(big.Int))
+	exponent := int64(this.Exponent) - scaleDigits
+	if remainder.Sign() != 0 {
+		quotient.Mul(quotient, big.NewInt(10))
+		quotient.Add(quotient, big.NewInt(1))
+		exponent--
+	}
+	if isNegative {
+		quotient.Neg(quotient)
+	}
+	return dfloatFromBigInt(quotient, exponent)
+}
+
+// Adds two finite, non-zero terms using big.Int arithmetic.
+func addBig(coefficientA int64, exponentA int64, coefficientB int64, exponentB int64) (DFloat, error) {
+	if exponentA < exponentB {
+		coefficientA, exponentA, coefficientB, exponentB = coefficientB, exponentB, coefficientA, exponentA
+	}
+
+	// If the exponents are too far apart for the smaller term to affect any of
+	// the digits a DFloat can hold, all that matters when rounding is that it
+	// is non-zero and its sign, so replace it with a stand-in just below those
+	// digits.
+	const maxExponentGap = 40
+	if exponentA-exponentB > maxExponentGap {
+		exponentB = exponentA - maxExponentGap/2
+		if coefficientB < 0 {
+			coefficientB = -1
+		} else {
+			coefficientB = 1
+		}
+	}
+
+	sum := new(big.Int).Mul(big.NewInt(coefficientA), pow10BigInt(exponentA-exponentB))
+	sum.Add(sum, big.NewInt(coefficientB))
+	if sum.Sign() == 0 {
+		return dfloatZero, nil
+	}
+	return dfloatFromBigInt(sum, exponentB)
+}
+
+// Converts a non-zero big.Int coefficient and exponent to a minimized DFloat,
+// rounding (half-to-even) if it doesn't fit.
+func dfloatFromBigInt(coefficient *big.Int, exponent int64) (DFloat, error) {
+	coefficient, zeros := stripTrailingZeros(coefficient, 0)
+	exponent += int64(zeros)
+	if coefficient.IsInt64() && exponent >= -math.MaxInt32 && exponent <= math.MaxInt32 {
+		return DFloat{Exponent: int32(exponent), Coefficient: coefficient.Int64()}, nil
+	}
+	return DFloatFromString(fmt.Sprintf("%ve%v", coefficient, exponent))
+}
+
+// Returns value * 10^exponent, and whether it fit into an int64.
+func scaleInt64(value int64, exponent int64) (int64, bool) {
+	if exponent >= int64(len(exponentMultipliers)) {
+		return 0, false
+	}
+	multiplier := exponentMultipliers[exponent]
+	high, low := bits.Mul64(magnitudeInt64(value), multiplier)
+	if high != 0 || low > math.MaxInt64 {
+		return 0, false
+	}
+	if value < 0 {
+		return -int64(low), true
+	}
+	return int64(low), true
+}
+
+// Returns a + b, and whether it fit into an int64.
+func addInt64(a int64, b int64) (int64, bool) {
+	sum := a + b
+	// Overflow occurred if both operands have a different sign from the sum.
+	return sum, (a^sum)&(b^sum) >= 0
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func assertArithmetic(t *testing.T, name string, operation func(DFloat, int64) (DFloat, error), value DFloat, n int64, expected DFloat, expectedErr error) {
+	t.Helper()
+	actual, err := operation(value, n)
+	if actual != expected || !errors.Is(err, expectedErr) || (err != nil && expectedErr == nil) {
+		t.Errorf("%v %v %v: expected %v (%v) but got %v (%v)", value, name, n, expected, expectedErr, actual, err)
+	}
+}
+
+func TestAddInt(t *testing.T) {
+	add := func(t *testing.T, value DFloat, n int64, expected DFloat, expectedErr error) {
+		t.Helper()
+		assertArithmetic(t, "+", DFloat.AddInt, value, n, expected, expectedErr)
+	}
+	add(t, DFloatValue(-2, 150), 3, DFloatValue(-1, 45), nil)
+	add(t, DFloatValue(-2, -150), 1, DFloatValue(-1, -5), nil)
+	add(t, DFloatValue(2, 15), -500, DFloatValue(3, 1), nil)
+	add(t, DFloatValuePreserving(-3, 1500), 0, DFloatValue(-1, 15), nil)
+	add(t, DFloatValue(0, 7), -7, Zero(), nil)
+	add(t, DFloatValue(-2, 5), -5, DFloatValue(-2, -495), nil)
+	add(t, Zero(), 12, DFloatValue(0, 12), nil)
+	add(t, NegativeZero(), 0, Zero(), nil)
+	add(t, Infinity(), -5, Infinity(), nil)
+	add(t, NegativeSignalingNaN(), 5, NegativeSignalingNaN(), nil)
+
+	add(t, DFloatValue(0, math.MaxInt64), 1, DFloatValue(1, 922337203685477581), RoundingError())
+	add(t, DFloatValue(0, math.MinInt64), -1, DFloatValue(1, -922337203685477581), RoundingError())
+	add(t, DFloatValue(-18, 1), math.MaxInt64, DFloatValue(0, math.MaxInt64), RoundingError())
+	add(t, DFloatValue(-18, 9), math.MaxInt64-1, DFloatValue(0, math.MaxInt64-1), RoundingError())
+	add(t, DFloatValue(-1, 5), math.MaxInt64-2, DFloatValue(0, math.MaxInt64-1), RoundingError())
+	add(t, DFloatValue(10, 1), 1, DFloatValue(0, 10000000001), nil)
+	add(t, DFloatValue(20, 1), 1, DFloatValue(2, 1000000000000000000), RoundingError())
+	add(t, DFloatValue(-2000000000, 5), 3, DFloatValue(0, 3), RoundingError())
+	add(t, DFloatValue(2000000000, 5), -3, DFloatValue(2000000000, 5), RoundingError())
+	add(t, DFloatValue(2000000000, 1), -3, DFloatValue(2000000000, 1), RoundingError())
+	add(t, DFloatValue(2000000000, 1), math.MinInt64, DFloatValue(2000000000, 1), RoundingError())
+	add(t, DFloatValue(-2000000000, -1), 1, DFloatValue(0, 1), RoundingError())
+}
+
+func TestMulInt(t *testing.T) {
+	mul := func(t *testing.T, value DFloat, n int64, expected DFloat, expectedErr error) {
+		t.Helper()
+		assertArithmetic(t, "*", DFloat.MulInt, value, n, expected, expectedErr)
+	}
+	mul(t, DFloatValue(-2, 125), 4, DFloatValue(0, 5), nil)
+	mul(t, DFloatValue(-2, 125), -3, DFloatValue(-2, -375), nil)
+	mul(t, DFloatValue(5, 3), 7, DFloatValue(5, 21), nil)
+	mul(t, DFloatValue(0, 1<<62), -2, DFloatValue(0, math.MinInt64), nil)
+	mul(t, DFloatValue(0, 1<<62), 2, DFloatValue(1, 922337203685477581), RoundingError())
+	mul(t, DFloatValue(0, math.MinInt64), -1, DFloatValue(1, 922337203685477581), RoundingError())
+	mul(t, DFloatValue(0, math.MaxInt64), math.MaxInt64, DFloatValue(19, 8507059173023461585), RoundingError())
+	mul(t, DFloatValue(math.MaxInt32, 9), 10, DFloat{Exponent: math.MaxInt32, Coefficient: 90}, nil)
+	mul(t, DFloatValue(math.MaxInt32, 1<<62), 4, Zero(), ErrorExponentTooLarge)
+	mul(t, DFloatValue(-2, 5), 0, Zero(), nil)
+	mul(t, DFloatValue(-2, -5), 0, NegativeZero(), nil)
+	mul(t, Zero(), -3, NegativeZero(), nil)
+	mul(t, NegativeZero(), -3, Zero(), nil)
+	mul(t, Infinity(), -3, NegativeInfinity(), nil)
+	mul(t, NegativeInfinity(), 0, QuietNaN(), nil)
+	mul(t, SignalingNaN(), 2, SignalingNaN(), nil)
+
+	value := DFloatValue(-2, 1996)
+	allocs := testing.AllocsPerRun(100, func() {
+		value.MulInt(12)
+		value.AddInt(12)
+		value.DivInt(-4)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected exact integer arithmetic to not allocate but got %v allocations", allocs)
+	}
+}
+
+func TestDivInt(t *testing.T) {
+	div := func(t *testing.T, value DFloat, n int64, expected DFloat, expectedErr error) {
+		t.Helper()
+		assertArithmetic(t, "/", DFloat.DivInt, value, n, expected, expectedErr)
+	}
+	div(t, DFloatValue(-2, 1500), 5, DFloatValue(0, 3), nil)
+	div(t, DFloatValue(0, 1), 4, DFloatValue(-2, 25), nil)
+	div(t, DFloatValue(0, -1), 8, DFloatValue(-3, -125), nil)
+	div(t, DFloatValue(0, 1), 3, DFloatValue(-19, 3333333333333333333), RoundingError())
+	div(t, DFloatValue(0, 2), 3, DFloatValue(-19, 6666666666666666667), RoundingError())
+	div(t, DFloatValue(0, -2), 3, DFloatValue(-19, -6666666666666666667), RoundingError())
+	div(t, DFloatValue(5, 1), 7, DFloatValue(-14, 1428571428571428571), RoundingError())
+	div(t, DFloatValue(0, math.MinInt64), -1, DFloatValue(1, 922337203685477581), RoundingError())
+	div(t, DFloatValue(0, math.MinInt64), 1, DFloatValue(0, math.MinInt64), nil)
+	div(t, DFloatValue(-math.MaxInt32, 1), 3, Zero(), ErrorExponentTooLarge)
+	div(t, Zero(), -3, NegativeZero(), nil)
+	div(t, NegativeInfinity(), -3, Infinity(), nil)
+	div(t, Infinity(), 0, Infinity(), nil)
+	div(t, QuietNaN(), 0, QuietNaN(), nil)
+	div(t, DFloatValue(0, 5), 0, Infinity(), ErrorDivisionByZero)
+	div(t, DFloatValue(0, 5), 0, Infinity(), ErrorDivisionByZero)
+	div(t, DFloatValue(0, -5), 0, NegativeInfinity(), ErrorDivisionByZero)
+	div(t, NegativeZero(), 0, QuietNaN(), ErrorDivisionByZero)
+}
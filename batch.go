@@ -0,0 +1,95 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"io"
+	"runtime"
+	"sync"
+)
+
+// The number of values each worker encodes per chunk in EncodeBatch().
+const batchChunkSize = 4096
+
+// Encodes values to a writer back-to-back (with no count prefix), spreading
+// the work across parallelism goroutines. The output is identical to calling
+// Encode() on each value in order. If parallelism is less than 1, it defaults
+// to runtime.GOMAXPROCS(0).
+//
+// Values are encoded in rounds of one chunk per worker, with each round's
+// chunks written in order once they are all complete, so memory use is bounded
+// regardless of how many values there are.
+func EncodeBatch(values []DFloat, writer io.Writer, parallelism int) (bytesEncoded int, err error) {
+	if parallelism < 1 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	chunkCount := (len(values) + batchChunkSize - 1) / batchChunkSize
+	if parallelism > chunkCount {
+		parallelism = chunkCount
+	}
+	if parallelism <= 1 {
+		return writer.Write(appendEncodeAll(make([]byte, 0, len(values)*MaxEncodeLength()), values))
+	}
+
+	buffers := make([][]byte, parallelism)
+	for i := range buffers {
+		buffers[i] = make([]byte, 0, batchChunkSize*MaxEncodeLength())
+	}
+
+	var wg sync.WaitGroup
+	for len(values) > 0 {
+		roundSize := 0
+		for i := range buffers {
+			chunk := values
+			if len(chunk) > batchChunkSize {
+				chunk = chunk[:batchChunkSize]
+			}
+			values = values[len(chunk):]
+			if len(chunk) == 0 {
+				break
+			}
+			roundSize++
+			wg.Add(1)
+			go func(i int, chunk []DFloat) {
+				defer wg.Done()
+				buffers[i] = appendEncodeAll(buffers[i][:0], chunk)
+			}(i, chunk)
+		}
+		wg.Wait()
+
+		for _, buffer := range buffers[:roundSize] {
+			written, writeErr := writer.Write(buffer)
+			bytesEncoded += written
+			if writeErr != nil {
+				err = writeErr
+				return
+			}
+		}
+	}
+	return
+}
+
+func appendEncodeAll(dst []byte, values []DFloat) []byte {
+	for _, value := range values {
+		dst = AppendEncode(dst, value)
+	}
+	return dst
+}
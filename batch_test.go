@@ -0,0 +1,63 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"testing"
+)
+
+func assertEncodeBatch(t *testing.T, values []DFloat, parallelism int) {
+	expected := &bytes.Buffer{}
+	for _, value := range values {
+		if _, err := Encode(value, expected); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	actual := &bytes.Buffer{}
+	bytesEncoded, err := EncodeBatch(values, actual, parallelism)
+	if err != nil {
+		t.Errorf("Parallelism %v: Unexpected error %v", parallelism, err)
+		return
+	}
+	if bytesEncoded != expected.Len() {
+		t.Errorf("Parallelism %v: Expected %v bytes encoded but got %v", parallelism, expected.Len(), bytesEncoded)
+	}
+	if !bytes.Equal(actual.Bytes(), expected.Bytes()) {
+		t.Errorf("Parallelism %v: Batch encoding differs from sequential encoding", parallelism)
+	}
+}
+
+func TestEncodeBatch(t *testing.T) {
+	values := make([]DFloat, batchChunkSize*5+123)
+	for i := range values {
+		values[i] = DFloatValue(int32(i%50-25), int64(i*7919-1000000))
+	}
+	values[10] = Infinity()
+	values[batchChunkSize*3] = NegativeZero()
+
+	assertEncodeBatch(t, nil, 4)
+	assertEncodeBatch(t, values[:10], 4)
+	for _, parallelism := range []int{0, 1, 2, 3, 4, 16} {
+		assertEncodeBatch(t, values, parallelism)
+	}
+}
@@ -0,0 +1,519 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+package compact_float
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+
+	"github.com/cockroachdb/apd/v2"
+)
+
+// BigDecimal holds values that are too big to fit into a DFloat. In normal
+// builds it is apd.Decimal. Building with the compactfloat_nobig tag removes
+// the apd dependency, in which case such values can't be decoded (see
+// nobig.go).
+type BigDecimal = apd.Decimal
+
+// Builds the apd.Decimal for a decoded value whose coefficient doesn't fit
+// into an int64.
+func newBigValue(exponent int32, isNegative bool, asUint uint64, asBig *big.Int) (*BigDecimal, error) {
+	if asBig != nil {
+		bigValue := apd.NewWithBigInt(asBig, exponent)
+		bigValue.Negative = isNegative
+		return bigValue, nil
+	}
+
+	bigValue := &apd.Decimal{
+		Negative: isNegative,
+		Exponent: exponent,
+	}
+	bigValue.Coeff.SetBits(appendUint64Words(nil, asUint))
+	return bigValue, nil
+}
+
+// Parses text that Parse() couldn't fit into a DFloat (failing with parseErr)
+// as a big value. Values that fit after all (such as those with many trailing
+// zeros) are returned as a DFloat. If the text can't be represented as a big
+// value either, parseErr is returned.
+func parseBigValue(str string, parseErr error) (DFloat, *BigDecimal, error) {
+	bigValue, _, err := apd.NewFromString(str)
+	if err != nil {
+		return dfloatZero, nil, parseErr
+	}
+	reduced, _ := new(apd.Decimal).Reduce(bigValue)
+	if value, err := DFloatFromAPD(reduced); err == nil {
+		return value, nil, nil
+	}
+	return dfloatZero, bigValue, nil
+}
+
+// Reports whether a decoded big value is the same number as a DFloat.
+func bigValueMatches(bigValue *BigDecimal, value DFloat) bool {
+	return apdRoundTripMatches(bigValue, value.APD())
+}
+
+// Reports whether two apd.Decimal values are the same number, treating all
+// zeros of the same sign as equal, and NaNs as equal if they have the same
+// form and sign.
+func apdRoundTripMatches(a *apd.Decimal, b *apd.Decimal) bool {
+	if a.Form != b.Form || a.Negative != b.Negative {
+		return false
+	}
+	return a.Form != apd.Finite || a.Cmp(b) == 0
+}
+
+// Encodes an apd.Decimal, decodes it again, and checks that the result is the
+// same number, and that the canonical encoding property holds for it (see
+// VerifyRoundTrip()). Failures wrap ErrorRoundTripMismatch.
+func VerifyRoundTripBig(value *apd.Decimal) error {
+	encoded := AppendEncodeBig(nil, value)
+	if len(encoded) != EncodedSizeBig(value) {
+		return fmt.Errorf("%w: %v encoded to %v bytes, but EncodedSizeBig() reports %v",
+			ErrorRoundTripMismatch, value, len(encoded), EncodedSizeBig(value))
+	}
+
+	decoded, bigValue, err := DecodeExact(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: %v encoded to %x, which failed to decode: %v", ErrorRoundTripMismatch, value, encoded, err)
+	}
+	if bigValue == nil {
+		bigValue = decoded.APD()
+	}
+	if !apdRoundTripMatches(bigValue, value) {
+		return fmt.Errorf("%w: %v encoded to %x, which decoded to %v", ErrorRoundTripMismatch, value, encoded, bigValue)
+	}
+
+	canonical, _ := new(apd.Decimal).Reduce(value)
+	canonical.Negative = value.Negative
+	// Reduce() doesn't guard against exponent overflow, in which case the
+	// value has no canonical encoding.
+	hasCanonicalForm := value.Form != apd.Finite || canonical.Exponent >= value.Exponent
+	return verifyCanonicalEncoding(value, encoded, AppendEncodeBig(nil, canonical), hasCanonicalForm)
+}
+
+// Maximum number of bytes required to encode a particular apd.Decimal.
+// This is an estimate; it may be smaller, but never bigger. Use
+// EncodedSizeBig() to get the exact size.
+func MaxEncodeLengthBig(value *apd.Decimal) int {
+	return len(value.Coeff.Bits())*wordBits/7 + 1 + 5
+}
+
+// Encodes an apd.Decimal to a writer.
+func EncodeBig(value *apd.Decimal, writer io.Writer) (bytesEncoded int, err error) {
+	return EncodeBigWithBuffer(value, writer, nil)
+}
+
+// Encodes an apd.Decimal to a writer using the supplied scratch buffer (to
+// avoid extra allocations). A new buffer is only allocated if the supplied one
+// is smaller than EncodedSizeBig(value).
+func EncodeBigWithBuffer(value *apd.Decimal, writer io.Writer, buffer []byte) (bytesEncoded int, err error) {
+	if size := EncodedSizeBig(value); len(buffer) < size {
+		buffer = make([]byte, size)
+	}
+	bytesEncoded = EncodeBigToBytes(value, buffer)
+	return writer.Write(buffer[:bytesEncoded])
+}
+
+// Encodes an apd.Decimal to a buffer.
+// Assumes the buffer is big enough (see EncodedSizeBig()).
+func EncodeBigToBytes(value *apd.Decimal, buffer []byte) (bytesEncoded int) {
+	if value.IsZero() {
+		if value.Negative {
+			return EncodeNegativeZero(buffer)
+		}
+		return EncodeZero(buffer)
+	}
+	switch value.Form {
+	case apd.Infinite:
+		if value.Negative {
+			return EncodeNegativeInfinity(buffer)
+		}
+		return EncodeInfinity(buffer)
+	case apd.NaN:
+		if value.Negative {
+			return EncodeNegativeQuietNan(buffer)
+		}
+		return EncodeQuietNan(buffer)
+	case apd.NaNSignaling:
+		if value.Negative {
+			return EncodeNegativeSignalingNan(buffer)
+		}
+		return EncodeSignalingNan(buffer)
+	}
+
+	bytesEncoded = encodeULEB128Uint64(apdExponentField(value), buffer)
+	bytesEncoded += encodeULEB128(&value.Coeff, buffer[bytesEncoded:])
+	return
+}
+
+// Returns the exact number of bytes that an apd.Decimal will encode to.
+func EncodedSizeBig(value *apd.Decimal) int {
+	if value.IsZero() {
+		return 1
+	}
+	if value.Form != apd.Finite {
+		return 2
+	}
+
+	return encodedSizeULEB128Uint64(apdExponentField(value)) + encodedSizeULEB128(&value.Coeff)
+}
+
+// Appends the encoded form of an apd.Decimal to dst, growing it as needed, and
+// returns the extended slice.
+func AppendEncodeBig(dst []byte, value *apd.Decimal) []byte {
+	dst, buffer := growForAppend(dst, EncodedSizeBig(value))
+	bytesEncoded := EncodeBigToBytes(value, buffer)
+	return dst[:len(dst)+bytesEncoded]
+}
+
+// Encodes an apd.Decimal to a writer, keeping its exact coefficient and
+// exponent for scientific data where trailing zeros are meaningful. Non-zero
+// values are always encoded this way (EncodeBig() never minimizes them), so the
+// difference is that a positive zero with a non-zero exponent (such as 0.000)
+// is encoded as that exponent with a zero coefficient rather than as plain
+// zero. Such zeros are valid, but not canonical (see DecodeCanonical()).
+// Negative zero is always encoded as plain negative zero.
+//
+// DecodeInto() restores the original value, as does DFloat.APD() on the result
+// of Decode() (for values that fit into a DFloat).
+func EncodeBigPreserving(value *apd.Decimal, writer io.Writer) (bytesEncoded int, err error) {
+	return writer.Write(AppendEncodeBigPreserving(nil, value))
+}
+
+// Appends the exact encoded form of an apd.Decimal to dst (see
+// EncodeBigPreserving()), growing it as needed, and returns the extended slice.
+func AppendEncodeBigPreserving(dst []byte, value *apd.Decimal) []byte {
+	if value.IsZero() && !value.Negative && value.Form == apd.Finite && value.Exponent != 0 {
+		return append(appendULEB128(dst, apdExponentField(value)), 0)
+	}
+	return AppendEncodeBig(dst, value)
+}
+
+// Decode a float into an existing apd.Decimal, reusing its coefficient storage
+// where possible. This avoids allocating a new apd.Decimal for every value when
+// decoding many values.
+func DecodeInto(reader io.Reader, dst *apd.Decimal) (bytesDecoded int, err error) {
+	buffer := []byte{0}
+	exponentField, asBig, bytesDecoded, err := decodeULEB128Limited(reader, buffer, maxExponentFieldLength, ErrorExponentTooLarge)
+	if err != nil {
+		err = incompleteIfTruncated(err, bytesDecoded)
+		return
+	}
+	if asBig != nil {
+		err = fmt.Errorf("%w: %v", ErrorExponentTooLarge, asBig)
+		return
+	}
+
+	if value, isSpecial := decodeSpecialValue(exponentField, bytesDecoded); isSpecial {
+		setSpecialAPD(dst, value)
+		return
+	}
+
+	exponent, isNegative, err := decodeExponentField(exponentField)
+	if err != nil {
+		return
+	}
+
+	offset := bytesDecoded
+	bytesDecoded, err = decodeULEB128Into(reader, buffer, MaxCoefficientLength, &dst.Coeff)
+	bytesDecoded += offset
+	if err != nil {
+		err = incompleteIfTruncated(err, bytesDecoded)
+		return
+	}
+
+	dst.Form = apd.Finite
+	dst.Negative = isNegative
+	dst.Exponent = exponent
+	return
+}
+
+// Returns the encoded exponent field of a (non-special) apd.Decimal.
+func apdExponentField(value *apd.Decimal) uint64 {
+	exponent := value.Exponent
+	exponentSign := 0
+	if exponent < 0 {
+		exponent = -exponent
+		exponentSign = 1
+	}
+	significandSign := 0
+	if value.Negative {
+		significandSign = 1
+	}
+	return uint64(exponent)<<2 | uint64(exponentSign)<<1 | uint64(significandSign)
+}
+
+// Sets dst to a special DFloat value (zero, infinity, NaN).
+func setSpecialAPD(dst *apd.Decimal, value DFloat) {
+	dst.Coeff.SetInt64(0)
+	dst.Exponent = 0
+	dst.Form = apd.Finite
+	dst.Negative = false
+	switch value {
+	case dfloatNegativeZero:
+		dst.Negative = true
+	case dfloatInfinity:
+		dst.Form = apd.Infinite
+	case dfloatNegativeInfinity:
+		dst.Form = apd.Infinite
+		dst.Negative = true
+	case dfloatNaN:
+		dst.Form = apd.NaN
+	case dfloatSignalingNaN:
+		dst.Form = apd.NaNSignaling
+	case dfloatNegativeNaN:
+		dst.Form = apd.NaN
+		dst.Negative = true
+	case dfloatNegativeSignalingNaN:
+		dst.Form = apd.NaNSignaling
+		dst.Negative = true
+	}
+}
+
+// Convert an apd.Decimal to DFloat. If the value is too big to fit, its lower
+// significant digits will be rounded (half-to-even) and
+// RoundingError will be returned along with the rounded value.
+func DFloatFromAPD(value *apd.Decimal) (DFloat, error) {
+	if value.IsZero() {
+		if value.Negative {
+			return dfloatNegativeZero, nil
+		}
+		return dfloatZero, nil
+	}
+	switch value.Form {
+	case apd.Infinite:
+		if value.Negative {
+			return dfloatNegativeInfinity, nil
+		}
+		return dfloatInfinity, nil
+	case apd.NaN:
+		if value.Negative {
+			return dfloatNegativeNaN, nil
+		}
+		return dfloatNaN, nil
+	case apd.NaNSignaling:
+		if value.Negative {
+			return dfloatNegativeSignalingNaN, nil
+		}
+		return dfloatSignalingNaN, nil
+	}
+
+	// An exponent of math.MinInt32 is only usable if trailing zeros can bring
+	// it into range.
+	if value.Coeff.IsInt64() {
+		d := DFloat{
+			Exponent:    value.Exponent,
+			Coefficient: value.Coeff.Int64(),
+		}.minimized()
+		if d.Exponent != ExpSpecial {
+			if value.Negative {
+				d.Coefficient = -d.Coefficient
+			}
+			return d, nil
+		}
+	}
+
+	str := value.Text('g')
+	return DFloatFromString(str)
+}
+
+// Convert an apd.Decimal to DFloat (see DFloatFromAPD()).
+func (this Context) FromAPD(value *apd.Decimal) (DFloat, error) {
+	d, err := DFloatFromAPD(value)
+	if err != nil && !errors.Is(err, roundingError) {
+		return this.FromString(value.Text('g'))
+	}
+	return this.apply(d, err)
+}
+
+// Convert an apd.Decimal to DFloat without minimizing it, so that trailing
+// zeros in its coefficient (and thus its declared precision) are kept. Zero is
+// still converted to plain zero. If the value is too big to fit, its lower
+// significant digits will be rounded (half-to-even) and RoundingError will be
+// returned along with the rounded value.
+func DFloatFromAPDPreserving(value *apd.Decimal) (DFloat, error) {
+	if value.IsZero() || value.Form != apd.Finite {
+		return DFloatFromAPD(value)
+	}
+
+	// Only drop as many trailing zeros as needed to make the coefficient fit.
+	coefficient, exponent := &value.Coeff, value.Exponent
+	if !coefficient.IsInt64() {
+		coefficient = new(big.Int).Set(coefficient)
+		ten, quotient, remainder := big.NewInt(10), new(big.Int), new(big.Int)
+		for !coefficient.IsInt64() && exponent < math.MaxInt32 {
+			if quotient.QuoRem(coefficient, ten, remainder); remainder.Sign() != 0 {
+				break
+			}
+			coefficient, quotient = quotient, coefficient
+			exponent++
+		}
+	}
+	if coefficient.IsInt64() {
+		d := DFloatValuePreserving(exponent, coefficient.Int64())
+		if value.Negative {
+			d.Coefficient = -d.Coefficient
+		}
+		return d, nil
+	}
+	return DFloatFromStringPreserving(value.Text('g'))
+}
+
+// Convert an apd.Decimal to DFloat128. If the coefficient doesn't fit into 127
+// bits, it will be rounded (half-to-even) to 38 significant digits and
+// RoundingError will be returned along with the rounded value.
+func DFloat128FromAPD(value *apd.Decimal) (DFloat128, error) {
+	if value.IsZero() || value.Form != apd.Finite {
+		special, err := DFloatFromAPD(value)
+		return DFloat128FromDFloat(special), err
+	}
+
+	coefficient, exponent, digitsDropped, roundedAway, err := roundInt128Magnitude(&value.Coeff, value.Exponent)
+	if err != nil {
+		return DFloat128{}, fmt.Errorf("%w: %v", err, value)
+	}
+	asInt128, err := Int128FromBigInt(coefficient)
+	if err != nil {
+		return DFloat128{}, err
+	}
+	if value.Negative {
+		asInt128 = asInt128.Neg()
+	}
+	var roundingErr error
+	if digitsDropped > 0 {
+		roundingErr = newRoundingError(value.String(), digitsDropped, roundedAway)
+	}
+	return DFloat128{Exponent: exponent, Coefficient: asInt128}.minimized(), roundingErr
+}
+
+// Returns the apd.Decimal representation of this value. All DFloat128 values
+// can be represented as apd.Decimal.
+func (this DFloat128) APD() *apd.Decimal {
+	if this.IsSpecial() {
+		value := new(apd.Decimal)
+		setSpecialAPD(value, this.special())
+		return value
+	}
+	return apd.NewWithBigInt(this.Coefficient.BigInt(), this.Exponent)
+}
+
+// Returns the apd.Decimal representation of this value. All DFloat values can
+// be represented as apd.Decimal.
+func (this DFloat) APD() *apd.Decimal {
+	return this.APDInto(new(apd.Decimal))
+}
+
+// Store this value into dst, reusing its existing coefficient storage rather
+// than allocating a new apd.Decimal. Returns dst.
+func (this DFloat) APDInto(dst *apd.Decimal) *apd.Decimal {
+	if this.IsSpecial() {
+		setSpecialAPD(dst, this)
+		return dst
+	}
+	return dst.SetFinite(this.Coefficient, this.Exponent)
+}
+
+// Encode an apd.Decimal, returning the number of bytes it encoded to.
+func (this *Encoder) EncodeBig(value *apd.Decimal) (bytesEncoded int, err error) {
+	target := this.target()
+	start := len(*target)
+	*target = AppendEncodeBig(*target, value)
+	bytesEncoded = len(*target) - start
+	err = this.valueEncoded()
+	return
+}
+
+// Encodes an apd.Decimal to a writer using a pooled buffer.
+func (this *BufferPool) EncodeBig(value *apd.Decimal, writer io.Writer) (bytesEncoded int, err error) {
+	buffer := this.get(EncodedSizeBig(value))
+	bytesEncoded, err = EncodeBigWithBuffer(value, writer, *buffer)
+	this.put(buffer)
+	return
+}
+
+// Encodes an apd.Decimal as a length-prefixed frame.
+func EncodeFramedBig(value *apd.Decimal, writer io.Writer) (bytesEncoded int, err error) {
+	size := EncodedSizeBig(value)
+	buffer := make([]byte, 0, maxULEB128Uint64Length+size)
+	buffer = appendULEB128(buffer, uint64(size))
+	buffer = AppendEncodeBig(buffer, value)
+	return writer.Write(buffer)
+}
+
+// APDDecimal adapts apd.Decimal to the Decimal and MutableDecimal interfaces.
+// Convert with (*APDDecimal)(value).
+type APDDecimal apd.Decimal
+
+func (this *APDDecimal) Unpack(dst *big.Int) (exponent int32, coefficient *big.Int) {
+	value := (*apd.Decimal)(this)
+	if value.IsZero() || value.Form != apd.Finite {
+		special, _ := DFloatFromAPD(value)
+		return special.Exponent, dst.SetInt64(special.Coefficient)
+	}
+	dst.Set(&value.Coeff)
+	if value.Negative {
+		dst.Neg(dst)
+	}
+	return value.Exponent, dst
+}
+
+func (this *APDDecimal) EncodedSize() int {
+	return EncodedSizeBig((*apd.Decimal)(this))
+}
+
+func (this *APDDecimal) EncodeToBytes(buffer []byte) (bytesEncoded int) {
+	return EncodeBigToBytes((*apd.Decimal)(this), buffer)
+}
+
+// Sets this value from an exponent and coefficient. All values fit, so no
+// error is ever returned.
+func (this *APDDecimal) Pack(exponent int32, coefficient *big.Int) error {
+	value := (*apd.Decimal)(this)
+	if exponent == ExpSpecial {
+		setSpecialAPD(value, DFloat{Exponent: exponent, Coefficient: coefficient.Int64()})
+		return nil
+	}
+	value.Form = apd.Finite
+	value.Negative = coefficient.Sign() < 0
+	value.Coeff.Abs(coefficient)
+	value.Exponent = exponent
+	return nil
+}
+
+// Returns the Decimal adapter for apd values, or nil if value isn't one.
+func bigDecimalAdapter(value interface{}) Decimal {
+	switch v := value.(type) {
+	case *apd.Decimal:
+		if v != nil {
+			return (*APDDecimal)(v)
+		}
+	case apd.Decimal:
+		return (*APDDecimal)(&v)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/cockroachdb/apd/v2"
+)
+
+func TestAPDDecimalInterface(t *testing.T) {
+	for _, str := range []string{
+		"0",
+		"-0",
+		"Infinity",
+		"-NaN",
+		"sNaN",
+		"-1.50",
+		"-9.4452837206285466345998345667683453466347345e-5000",
+	} {
+		source, _, err := apd.NewFromString(str)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		var dst apd.Decimal
+		assertDecimalTranscode(t, (*APDDecimal)(source), (*APDDecimal)(&dst))
+		if dst.CmpTotal(source) != 0 && !source.IsZero() {
+			t.Errorf("Expected %v but got %v", source, &dst)
+		}
+
+		var asDFloat128 DFloat128
+		if _, err := DecodeDecimalFromBytes(AppendEncodeDecimal(nil, (*APDDecimal)(source)), &asDFloat128); err != nil && source.NumDigits() <= 38 {
+			t.Error(err)
+		}
+	}
+}
+
+func TestEncodeAnyAPD(t *testing.T) {
+	value, _, _ := apd.NewFromString("-9.4452837206285466345998345667683453466347345e-5000")
+	assertEncodeAny(t, value, 0, (*APDDecimal)(value), nil)
+	assertEncodeAny(t, *value, 0, (*APDDecimal)(value), nil)
+	assertEncodeAny(t, (*apd.Decimal)(nil), 0, nil, ErrorUnsupportedType)
+}
+
+func TestEncodeBigPreserving(t *testing.T) {
+	for _, str := range []string{"1.5000", "-1.5000", "1.50E+5", "0.000", "0E+3", "0", "-0", "Infinity",
+		"1.50000000000000000000000000000000000", "-1.2300E-5000"} {
+		value, _, err := apd.NewFromString(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buffer := &bytes.Buffer{}
+		if _, err = EncodeBigPreserving(value, buffer); err != nil {
+			t.Fatal(err)
+		}
+		encoded := append([]byte{}, buffer.Bytes()...)
+
+		var decoded apd.Decimal
+		if _, err = DecodeInto(buffer, &decoded); err != nil {
+			t.Errorf("%v: %v", str, err)
+			continue
+		}
+		if decoded.String() != value.String() {
+			t.Errorf("%v: DecodeInto() gave %v", str, decoded.String())
+		}
+
+		asDFloat, bigValue, _, err := DecodeFromBytes(encoded)
+		if err != nil {
+			t.Errorf("%v: %v", str, err)
+			continue
+		}
+		if bigValue == nil {
+			bigValue = asDFloat.APD()
+		}
+		if str != "-0" && bigValue.String() != value.String() {
+			t.Errorf("%v: Decode() gave %v", str, bigValue.String())
+		}
+	}
+}
+
+func TestDFloatFromAPDPreserving(t *testing.T) {
+	assertPreserving := func(str string, expected DFloat) {
+		value, _, err := apd.NewFromString(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := DFloatFromAPDPreserving(value)
+		if err != nil {
+			t.Errorf("%v: %v", str, err)
+			return
+		}
+		if actual != expected {
+			t.Errorf("%v: expected %#v but got %#v", str, expected, actual)
+		}
+	}
+	assertPreserving("1.5000", DFloatValuePreserving(-4, 15000))
+	assertPreserving("-1.5000", DFloatValuePreserving(-4, -15000))
+	assertPreserving("1.50E+5", DFloatValuePreserving(3, 150))
+	assertPreserving("100000000000000000000", DFloatValuePreserving(2, 1000000000000000000))
+	assertPreserving("1.0000000000000000000E+5", DFloatValuePreserving(-13, 1000000000000000000))
+	assertPreserving("0.000", Zero())
+	assertPreserving("-0", NegativeZero())
+}
+
+func TestContextFromAPD(t *testing.T) {
+	clamp := Context{ExponentOverflow: ExponentOverflowClamp}
+	tiny := apd.New(5, -2147483648)
+	if value, err := DFloatFromAPD(tiny); err == nil {
+		t.Errorf("Expected %v to fail but got %v", tiny, value)
+	}
+	if value, err := clamp.FromAPD(tiny); value != Zero() || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected clamping to zero but got %v (%v)", value, err)
+	}
+	if value, err := clamp.FromAPD(apd.New(-50, -2147483648)); value != DFloatValue(-2147483647, -5) || err != nil {
+		t.Errorf("Expected -5e-2147483647 but got %v (%v)", value, err)
+	}
+	if value, err := clamp.FromAPD(apd.New(15, -1)); value != DFloatValue(-1, 15) || err != nil {
+		t.Errorf("Expected 1.5 but got %v (%v)", value, err)
+	}
+}
+
+func TestParseAny(t *testing.T) {
+	assertDFloat := func(str string, expected DFloat) {
+		t.Helper()
+		value, bigValue, err := ParseAny(str)
+		if value != expected || bigValue != nil || err != nil {
+			t.Errorf("%q: expected %v but got %v, %v (%v)", str, expected, value, bigValue, err)
+		}
+	}
+	assertBig := func(str string, expected string) {
+		t.Helper()
+		value, bigValue, err := ParseAny(str)
+		if bigValue == nil || bigValue.Text('g') != expected || value != Zero() || err != nil {
+			t.Errorf("%q: expected big value %v but got %v, %v (%v)", str, expected, value, bigValue, err)
+		}
+	}
+	assertDFloat("1.5", DFloatValue(-1, 15))
+	assertDFloat("-9223372036854775807", DFloatValue(0, -9223372036854775807))
+	assertDFloat("1.00000000000000000000000000", DFloatValue(0, 1))
+	assertDFloat("-snan", NegativeSignalingNaN())
+	assertBig("9223372036854775808", "9223372036854775808")
+	assertBig("-1.2345678901234567890123", "-1.2345678901234567890123")
+	assertBig("+123456789012345678901e-5", "1234567890123456.78901")
+
+	if _, bigValue, err := ParseAny("1.2.3"); !errors.Is(err, ErrorSyntax) || bigValue != nil {
+		t.Errorf("Expected ErrorSyntax but got %v (%v)", bigValue, err)
+	}
+	if _, bigValue, err := ParseAny(""); !errors.Is(err, ErrorSyntax) || bigValue != nil {
+		t.Errorf("Expected ErrorSyntax but got %v (%v)", bigValue, err)
+	}
+	if _, bigValue, err := ParseAny("1e99999999999999"); !errors.Is(err, ErrorExponentTooLarge) || bigValue != nil {
+		t.Errorf("Expected ErrorExponentTooLarge but got %v (%v)", bigValue, err)
+	}
+}
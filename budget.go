@@ -0,0 +1,64 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Encodes value into at most maxBytes of buffer, dropping as many of its least
+// significant digits (rounding half to even) as necessary to make it fit. This
+// is for transports with hard size limits, where a less precise value is
+// better than none. rounded reports whether any non-zero digits were dropped.
+//
+// Returns ErrorTooLong if the value won't fit even when rounded to a single
+// significant digit. Zero, infinity and NaN are never rounded.
+// Assumes the buffer is big enough (see MaxEncodeLength()).
+func EncodeWithBudget(value DFloat, maxBytes int, buffer []byte) (bytesEncoded int, rounded bool, err error) {
+	if !value.IsZero() && !value.IsSpecial() {
+		value = value.minimized()
+	}
+	if EncodedSize(value) <= maxBytes {
+		bytesEncoded = EncodeToBytes(value, buffer)
+		return
+	}
+	if value.IsZero() || value.IsSpecial() {
+		err = fmt.Errorf("%w: %v cannot be encoded in %v bytes", ErrorTooLong, value, maxBytes)
+		return
+	}
+
+	digitCount := decimalDigitCount(uint64(value.Coefficient))
+	if value.Coefficient < 0 {
+		digitCount = decimalDigitCount(-uint64(value.Coefficient))
+	}
+	for significantDigits := digitCount - 1; significantDigits > 0; significantDigits-- {
+		candidate, roundingErr := roundToDigits(value, significantDigits, RoundHalfEven)
+		if roundingErr != nil && !errors.Is(roundingErr, roundingError) {
+			break
+		}
+		if EncodedSize(candidate) <= maxBytes {
+			return EncodeToBytes(candidate, buffer), true, nil
+		}
+	}
+	err = fmt.Errorf("%w: %v cannot be encoded in %v bytes", ErrorTooLong, value, maxBytes)
+	return
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEncodeWithBudget(t *testing.T) {
+	assertBudget := func(value DFloat, maxBytes int, expected DFloat, expectedRounded bool) {
+		buffer := make([]byte, MaxEncodeLength())
+		bytesEncoded, rounded, err := EncodeWithBudget(value, maxBytes, buffer)
+		if err != nil {
+			t.Errorf("%v in %v bytes: %v", value, maxBytes, err)
+			return
+		}
+		if bytesEncoded > maxBytes {
+			t.Errorf("%v in %v bytes: encoded to %v bytes", value, maxBytes, bytesEncoded)
+		}
+		decoded, _, _, err := DecodeFromBytes(buffer[:bytesEncoded])
+		if err != nil {
+			t.Errorf("%v in %v bytes: %v", value, maxBytes, err)
+			return
+		}
+		if decoded.minimized() != expected.minimized() || rounded != expectedRounded {
+			t.Errorf("%v in %v bytes: expected %v (%v) but got %v (%v)", value, maxBytes, expected, expectedRounded, decoded, rounded)
+		}
+	}
+	pi := DFloatValue(-8, 314159265)
+	assertBudget(pi, 10, pi, false)
+	assertBudget(pi, 6, pi, false)
+	assertBudget(pi, 5, DFloatValue(-7, 31415926), true)
+	assertBudget(pi, 4, DFloatValue(-5, 314159), true)
+	assertBudget(pi, 3, DFloatValue(-3, 3142), true)
+	assertBudget(pi, 2, DFloatValue(-1, 31), true)
+	assertBudget(DFloatValue(-3, -25), 2, DFloatValue(-3, -25), false)
+	assertBudget(DFloatValue(0, 1000000000), 2, DFloatValue(9, 1), false)
+	assertBudget(DFloatValue(0, 125), 2, DFloatValue(0, 125), false)
+	assertBudget(DFloatValue(0, 1250), 2, DFloatValue(1, 125), false)
+	assertBudget(DFloatValue(0, 12500001), 2, DFloatValue(5, 125), true)
+	assertBudget(DFloatValue(-1, 99999), 2, DFloatValue(4, 1), true)
+	assertBudget(DFloatValue(0, math.MinInt64), 2, DFloatValue(17, -92), true)
+	assertBudget(Zero(), 1, Zero(), false)
+	assertBudget(Infinity(), 2, Infinity(), false)
+
+	buffer := make([]byte, MaxEncodeLength())
+	for _, value := range []DFloat{DFloatValue(1000, 1), Infinity(), pi} {
+		if _, _, err := EncodeWithBudget(value, 1, buffer); !errors.Is(err, ErrorTooLong) {
+			t.Errorf("%v: expected ErrorTooLong but got %v", value, err)
+		}
+	}
+}
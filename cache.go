@@ -0,0 +1,73 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+// Precomputed encodings for frequently occurring values: specials, and small
+// coefficients with small exponents.
+
+const (
+	cachedMinExponent    = -4
+	cachedMaxExponent    = 4
+	cachedMaxCoefficient = 127
+	cachedCoefficients   = cachedMaxCoefficient*2 + 1
+)
+
+var (
+	cachedEncodings [cachedMaxExponent - cachedMinExponent + 1][cachedCoefficients][]byte
+	cachedSpecials  = map[DFloat][]byte{}
+)
+
+func init() {
+	storage := make([]byte, 0, len(cachedEncodings)*cachedCoefficients*3)
+	cache := func(value DFloat) []byte {
+		start := len(storage)
+		storage = AppendEncode(storage, value)
+		// Cap the slice so that appending to it can't clobber its neighbours.
+		return storage[start:len(storage):len(storage)]
+	}
+
+	for _, value := range []DFloat{dfloatNegativeZero, dfloatInfinity, dfloatNegativeInfinity,
+		dfloatNaN, dfloatSignalingNaN, dfloatNegativeNaN, dfloatNegativeSignalingNaN} {
+		cachedSpecials[value] = cache(value)
+	}
+	for exponent := cachedMinExponent; exponent <= cachedMaxExponent; exponent++ {
+		for coefficient := -cachedMaxCoefficient; coefficient <= cachedMaxCoefficient; coefficient++ {
+			value := DFloat{Exponent: int32(exponent), Coefficient: int64(coefficient)}
+			cachedEncodings[exponent-cachedMinExponent][coefficient+cachedMaxCoefficient] = cache(value)
+		}
+	}
+}
+
+// Returns the encoded form of a DFloat. Common values (specials, and
+// coefficients within ±127 with exponents from -4 to 4) are returned from a
+// precomputed table without any encoding work or allocation. The returned
+// slice may be shared, and MUST NOT be modified.
+func EncodedBytes(value DFloat) []byte {
+	if value.IsSpecial() {
+		if encoded, ok := cachedSpecials[value]; ok {
+			return encoded
+		}
+	} else if value.Exponent >= cachedMinExponent && value.Exponent <= cachedMaxExponent &&
+		value.Coefficient >= -cachedMaxCoefficient && value.Coefficient <= cachedMaxCoefficient {
+		return cachedEncodings[value.Exponent-cachedMinExponent][value.Coefficient+cachedMaxCoefficient]
+	}
+	return AppendEncode(nil, value)
+}
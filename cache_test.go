@@ -0,0 +1,61 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kstenerud/go-describe"
+)
+
+func assertEncodedBytes(t *testing.T, value DFloat) {
+	expected := AppendEncode(nil, value)
+	if actual := EncodedBytes(value); !bytes.Equal(actual, expected) {
+		t.Errorf("Value %v: Expected %v but got %v", value, describe.D(expected), describe.D(actual))
+	}
+}
+
+func TestEncodedBytes(t *testing.T) {
+	for _, value := range []DFloat{Zero(), NegativeZero(), Infinity(), NegativeInfinity(), QuietNaN(),
+		SignalingNaN(), NegativeQuietNaN(), NegativeSignalingNaN(), DFloatValue(-5, 1), DFloatValue(5, 128),
+		DFloatValue(0, -9223372036854775808), {Exponent: 0, Coefficient: 10}} {
+		assertEncodedBytes(t, value)
+	}
+	for exponent := int32(-6); exponent <= 6; exponent++ {
+		for coefficient := int64(-130); coefficient <= 130; coefficient++ {
+			assertEncodedBytes(t, DFloat{Exponent: exponent, Coefficient: coefficient})
+		}
+	}
+
+	cached := EncodedBytes(DFloatValue(0, 1))
+	if grown := append(cached, 0xff); &grown[0] == &cached[0] {
+		t.Errorf("Expected appending to a cached encoding to reallocate")
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		EncodedBytes(DFloatValue(-2, 25))
+		EncodedBytes(Infinity())
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations but got %v", allocs)
+	}
+}
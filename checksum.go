@@ -0,0 +1,103 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Checksummed streams (see Encoder.SetChecksumInterval()) group values into
+// blocks:
+//
+//     count         ULEB128: number of values in the block (at least 1)
+//     values        count compact float values
+//     checksum      4 bytes: little endian CRC-32C of the count and values
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+var ErrorChecksumMismatch = errors.New("Compact float checksum mismatch")
+
+// Describes data that failed checksum verification. It matches
+// ErrorChecksumMismatch via errors.Is().
+type ChecksumErrorDetails struct {
+	// The checksum computed over the data.
+	Expected uint32
+	// The checksum stored with the data.
+	Actual uint32
+	// The offset of the stored checksum from the start of the stream.
+	Offset int
+}
+
+func (this *ChecksumErrorDetails) Error() string {
+	return fmt.Sprintf("%v: expected %08x but got %08x at offset %v", ErrorChecksumMismatch, this.Expected, this.Actual, this.Offset)
+}
+
+func (this *ChecksumErrorDetails) Is(target error) bool {
+	return target == ErrorChecksumMismatch
+}
+
+func appendCRC32C(dst []byte, checksum uint32) []byte {
+	var buffer [4]byte
+	binary.LittleEndian.PutUint32(buffer[:], checksum)
+	return append(dst, buffer[:]...)
+}
+
+// Reads a little endian CRC-32C from reader (whose offset is the number of
+// bytes already read from it), and compares it to expected.
+func verifyCRC32C(reader io.Reader, offset int, expected uint32) error {
+	var buffer [4]byte
+	if _, err := io.ReadFull(reader, buffer[:]); err != nil {
+		return incompleteIfTruncated(err, 1)
+	}
+	if actual := binary.LittleEndian.Uint32(buffer[:]); actual != expected {
+		return &ChecksumErrorDetails{
+			Expected: expected,
+			Actual:   actual,
+			Offset:   offset,
+		}
+	}
+	return nil
+}
+
+// Computes the CRC-32C of everything read through it.
+type checksumReader struct {
+	reader   *countingReader
+	checksum uint32
+	buffer   [1]byte
+}
+
+func (this *checksumReader) Read(p []byte) (n int, err error) {
+	n, err = this.reader.Read(p)
+	this.checksum = crc32.Update(this.checksum, crc32cTable, p[:n])
+	return
+}
+
+func (this *checksumReader) ReadByte() (b byte, err error) {
+	if b, err = this.reader.ReadByte(); err == nil {
+		this.buffer[0] = b
+		this.checksum = crc32.Update(this.checksum, crc32cTable, this.buffer[:])
+	}
+	return
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func encodeChecksummed(t *testing.T, interval int, values []DFloat) []byte {
+	buffer := &bytes.Buffer{}
+	encoder := NewEncoder(buffer)
+	encoder.SetChecksumInterval(interval)
+	for _, value := range values {
+		if _, err := encoder.Encode(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func decodeChecksummed(data []byte) (values []DFloat, err error) {
+	decoder := NewDecoder(bytes.NewReader(data))
+	decoder.SetVerifyChecksums(true)
+	for decoder.Next() {
+		value, _ := decoder.Value()
+		values = append(values, value)
+	}
+	return values, decoder.Err()
+}
+
+func TestChecksumRoundTrip(t *testing.T) {
+	values := []DFloat{DFloatValue(-2, 1050), QuietNaN(), NegativeZero(), DFloatValue(100, -1), DFloatValue(0, 7)}
+	for _, interval := range []int{1, 2, 5, 100} {
+		encoded := encodeChecksummed(t, interval, values)
+		decoded, err := decodeChecksummed(encoded)
+		if err != nil {
+			t.Errorf("Interval %v: %v", interval, err)
+		}
+		if !reflect.DeepEqual(decoded, values) {
+			t.Errorf("Interval %v: Expected %v but got %v", interval, values, decoded)
+		}
+	}
+}
+
+func TestChecksumEncoding(t *testing.T) {
+	encoded := encodeChecksummed(t, 2, []DFloat{DFloatValue(0, 1), DFloatValue(0, 2), DFloatValue(0, 3)})
+	// Block of 2 values, then a block of 1 written by Flush()
+	if len(encoded) != 1+4+4+1+2+4 || encoded[0] != 2 || encoded[9] != 1 {
+		t.Errorf("Unexpected encoding %x", encoded)
+	}
+
+	// Turning checksums off mid-stream finishes the current block
+	buffer := &bytes.Buffer{}
+	encoder := NewEncoder(buffer)
+	encoder.SetChecksumInterval(10)
+	encoder.Encode(DFloatValue(0, 1))
+	encoder.SetChecksumInterval(0)
+	encoder.Encode(DFloatValue(0, 2))
+	encoder.Flush()
+	if expected := []byte{0x01, 0x00, 0x01}; !bytes.HasPrefix(buffer.Bytes(), expected) || !bytes.HasSuffix(buffer.Bytes(), []byte{0x00, 0x02}) || buffer.Len() != 9 {
+		t.Errorf("Unexpected encoding %x", buffer.Bytes())
+	}
+}
+
+func TestChecksumCorruption(t *testing.T) {
+	values := []DFloat{DFloatValue(-2, 1050), DFloatValue(3, 7), DFloatValue(0, 9)}
+	encoded := encodeChecksummed(t, 2, values)
+
+	for i := range encoded {
+		corrupt := append([]byte(nil), encoded...)
+		corrupt[i] ^= 0x01
+		decoded, err := decodeChecksummed(corrupt)
+		if err == nil {
+			t.Errorf("Byte %v: Expected an error", i)
+			continue
+		}
+		var details *ChecksumErrorDetails
+		if errors.As(err, &details) {
+			if !errors.Is(err, ErrorChecksumMismatch) {
+				t.Errorf("Byte %v: Expected the error to match ErrorChecksumMismatch", i)
+			}
+			// Nothing from the corrupt block is returned
+			if i < 9 && len(decoded) != 0 || i >= 9 && len(decoded) != 2 {
+				t.Errorf("Byte %v: Unexpected values %v", i, decoded)
+			}
+		}
+	}
+
+	corrupt := append([]byte(nil), encoded...)
+	corrupt[2] ^= 0x01
+	_, err := decodeChecksummed(corrupt)
+	var details *ChecksumErrorDetails
+	if !errors.As(err, &details) || details.Offset != 5 {
+		t.Errorf("Expected a checksum error at offset 5 but got %v", err)
+	}
+
+	// The first block ends at 9 bytes
+	for length := 1; length < len(encoded); length++ {
+		if length == 9 {
+			continue
+		}
+		if _, err := decodeChecksummed(encoded[:length]); !errors.Is(err, ErrorIncomplete) {
+			t.Errorf("Length %v: Expected ErrorIncomplete but got %v", length, err)
+		}
+	}
+
+	if _, err := decodeChecksummed([]byte{0x00}); !errors.Is(err, ErrorMalformed) {
+		t.Errorf("Expected ErrorMalformed for an empty block but got %v", err)
+	}
+}
+
+func TestChecksumEmptyStream(t *testing.T) {
+	decoder := NewDecoder(bytes.NewReader(nil))
+	decoder.SetVerifyChecksums(true)
+	if _, _, err := decoder.Decode(); err != io.EOF {
+		t.Errorf("Expected io.EOF but got %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"io"
+)
+
+// Chunked encoding and decoding handle values whose coefficients are too big to
+// comfortably hold in memory (for example, million-digit decimals), by passing
+// the coefficient magnitude through a fixed-size window of 64-bit words, least
+// significant word first. The encoded form is identical to that produced by
+// EncodeBig().
+
+const chunkedWriteBufferSize = 4096
+
+// Encodes a value whose coefficient magnitude is supplied in chunks by next,
+// as 64-bit words, least significant word first. next returns io.EOF (with no
+// words) when there are no more chunks, and may reuse the same slice for every
+// chunk. Memory use is bounded regardless of the coefficient's size. If the
+// coefficient turns out to be zero, zero or negative zero is encoded.
+func EncodeChunked(exponent int32, negative bool, next func() ([]uint64, error), writer io.Writer) (bytesEncoded int, err error) {
+	if exponent == ExpSpecial {
+		panic("EncodeChunked: exponent cannot be ExpSpecial")
+	}
+	coefficientSign := int64(1)
+	if negative {
+		coefficientSign = -1
+	}
+	exponentField, _ := splitDFloat(DFloat{Exponent: exponent, Coefficient: coefficientSign})
+
+	encoder := chunkedEncoder{writer: writer}
+	encoder.buffer = make([]byte, 0, chunkedWriteBufferSize)
+	encoder.buffer = appendULEB128(encoder.buffer, exponentField)
+
+	for {
+		words, nextErr := next()
+		if nextErr == io.EOF {
+			break
+		}
+		if nextErr != nil {
+			return encoder.bytesWritten, nextErr
+		}
+		for _, word := range words {
+			if err = encoder.addWord(word); err != nil {
+				return encoder.bytesWritten, err
+			}
+		}
+	}
+	return encoder.finish(negative)
+}
+
+// Decodes a value, delivering its coefficient magnitude to emit in chunks of
+// 64-bit words (least significant word first) through window, which is reused
+// for every chunk. Memory use is bounded regardless of the coefficient's size,
+// and MaxCoefficientLength does not apply.
+//
+// For special values (zero, infinity, NaN), value is the special value and emit
+// is never called. Otherwise value holds the exponent, and a coefficient of 1
+// or -1 indicating the sign.
+func DecodeChunked(reader io.Reader, window []uint64, emit func(words []uint64) error) (value DFloat, bytesDecoded int, err error) {
+	if len(window) == 0 {
+		panic("DecodeChunked: window must have room for at least one word")
+	}
+	counter := newCountingReader(reader)
+	defer func() { bytesDecoded = counter.bytesRead }()
+
+	exponentField, asBig, _, err := decodeULEB128FromByteReader(&counter, maxExponentFieldLength)
+	if err != nil {
+		if err == ErrorTooLong {
+			err = ErrorExponentTooLarge
+		}
+		err = incompleteIfTruncated(err, counter.bytesRead)
+		return
+	}
+	if asBig != nil {
+		err = ErrorExponentTooLarge
+		return
+	}
+	var isSpecial bool
+	if value, isSpecial = decodeSpecialValue(exponentField, counter.bytesRead); isSpecial {
+		return
+	}
+	exponent, isNegative, err := decodeExponentField(exponentField)
+	if err != nil {
+		return
+	}
+	value = DFloat{Exponent: exponent, Coefficient: 1}
+	if isNegative {
+		value.Coefficient = -1
+	}
+
+	wordCount := 0
+	word := uint64(0)
+	bitIndex := uint(0)
+	for {
+		b, readErr := counter.ReadByte()
+		if readErr != nil {
+			err = incompleteIfTruncated(readErr, counter.bytesRead)
+			return
+		}
+		payload := uint64(b & 0x7f)
+		word |= payload << bitIndex
+		bitIndex += 7
+		if bitIndex >= 64 {
+			window[wordCount] = word
+			wordCount++
+			if wordCount == len(window) {
+				if err = emit(window); err != nil {
+					return
+				}
+				wordCount = 0
+			}
+			bitIndex -= 64
+			word = payload >> (7 - bitIndex)
+		}
+		if b < 0x80 {
+			break
+		}
+	}
+	if bitIndex > 0 {
+		window[wordCount] = word
+		wordCount++
+	}
+	if wordCount > 0 {
+		err = emit(window[:wordCount])
+	}
+	return
+}
+
+// Converts a stream of 64-bit words into ULEB128 groups, holding back zero
+// groups until it's known whether they are trailing (and thus dropped).
+type chunkedEncoder struct {
+	writer       io.Writer
+	buffer       []byte
+	bytesWritten int
+	carry        uint64
+	carryBits    uint
+	pendingZeros int
+	hasGroup     bool
+	lastGroup    byte
+}
+
+func (this *chunkedEncoder) addWord(word uint64) error {
+	// The bits to split into groups are carry (low) followed by word (high).
+	low := this.carry | word<<this.carryBits
+	high := uint64(0)
+	if this.carryBits > 0 {
+		high = word >> (64 - this.carryBits)
+	}
+	available := this.carryBits + 64
+	position := uint(0)
+	for ; available-position >= 7; position += 7 {
+		var group uint64
+		switch {
+		case position+7 <= 64:
+			group = low >> position
+		case position >= 64:
+			group = high >> (position - 64)
+		default:
+			group = low>>position | high<<(64-position)
+		}
+		if err := this.addGroup(byte(group & 0x7f)); err != nil {
+			return err
+		}
+	}
+	this.carryBits = available - position
+	if position >= 64 {
+		this.carry = high >> (position - 64)
+	} else {
+		this.carry = low>>position | high<<(64-position)
+	}
+	this.carry &= uint64(1)<<this.carryBits - 1
+	return nil
+}
+
+func (this *chunkedEncoder) addGroup(group byte) error {
+	if group == 0 {
+		this.pendingZeros++
+		return nil
+	}
+	if this.hasGroup {
+		if err := this.writeByte(this.lastGroup | 0x80); err != nil {
+			return err
+		}
+	}
+	for ; this.pendingZeros > 0; this.pendingZeros-- {
+		if err := this.writeByte(0x80); err != nil {
+			return err
+		}
+	}
+	this.lastGroup = group
+	this.hasGroup = true
+	return nil
+}
+
+func (this *chunkedEncoder) writeByte(b byte) error {
+	this.buffer = append(this.buffer, b)
+	if len(this.buffer) < chunkedWriteBufferSize {
+		return nil
+	}
+	return this.flush()
+}
+
+func (this *chunkedEncoder) flush() error {
+	written, err := this.writer.Write(this.buffer)
+	this.bytesWritten += written
+	this.buffer = this.buffer[:0]
+	return err
+}
+
+func (this *chunkedEncoder) finish(negative bool) (bytesEncoded int, err error) {
+	if this.carryBits > 0 {
+		if err = this.addGroup(byte(this.carry)); err != nil {
+			return this.bytesWritten, err
+		}
+	}
+	if !this.hasGroup {
+		// The coefficient is zero, so discard the exponent field.
+		this.buffer = this.buffer[:0]
+		var encoded [1]byte
+		if negative {
+			EncodeNegativeZero(encoded[:])
+		} else {
+			EncodeZero(encoded[:])
+		}
+		this.buffer = append(this.buffer, encoded[0])
+	} else {
+		this.buffer = append(this.buffer, this.lastGroup)
+	}
+	err = this.flush()
+	return this.bytesWritten, err
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+package compact_float
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/cockroachdb/apd/v2"
+	"github.com/kstenerud/go-describe"
+)
+
+func chunkSource(words []uint64, chunkSize int) func() ([]uint64, error) {
+	chunk := make([]uint64, chunkSize)
+	return func() ([]uint64, error) {
+		if len(words) == 0 {
+			return nil, io.EOF
+		}
+		n := copy(chunk, words)
+		words = words[n:]
+		return chunk[:n], nil
+	}
+}
+
+func assertChunked(t *testing.T, exponent int32, negative bool, words []uint64, chunkSize int) {
+	coefficient := new(big.Int)
+	for i := len(words) - 1; i >= 0; i-- {
+		coefficient.Lsh(coefficient, 64)
+		coefficient.Or(coefficient, new(big.Int).SetUint64(words[i]))
+	}
+	bigValue := apd.NewWithBigInt(coefficient, exponent)
+	bigValue.Negative = negative
+	expected := &bytes.Buffer{}
+	if _, err := EncodeBig(bigValue, expected); err != nil {
+		t.Fatal(err)
+	}
+
+	actual := &bytes.Buffer{}
+	bytesEncoded, err := EncodeChunked(exponent, negative, chunkSource(words, chunkSize), actual)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+		return
+	}
+	if bytesEncoded != expected.Len() || !bytes.Equal(actual.Bytes(), expected.Bytes()) {
+		t.Errorf("Value %v: Expected %v but got %v", bigValue, describe.D(expected.Bytes()), describe.D(actual.Bytes()))
+		return
+	}
+
+	decodedCoefficient := new(big.Int)
+	shift := uint(0)
+	value, bytesDecoded, err := DecodeChunked(actual, make([]uint64, chunkSize), func(words []uint64) error {
+		for _, word := range words {
+			decodedCoefficient.Or(decodedCoefficient, new(big.Int).Lsh(new(big.Int).SetUint64(word), shift))
+			shift += 64
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Value %v: Unexpected error %v", bigValue, err)
+		return
+	}
+	if bytesDecoded != bytesEncoded {
+		t.Errorf("Value %v: Expected %v bytes decoded but got %v", bigValue, bytesEncoded, bytesDecoded)
+	}
+	if coefficient.Sign() == 0 {
+		if !value.IsZero() || value.IsNegativeZero() != negative {
+			t.Errorf("Expected zero but got %v", value)
+		}
+		return
+	}
+	if value.Exponent != exponent || (value.Coefficient < 0) != negative || decodedCoefficient.Cmp(coefficient) != 0 {
+		t.Errorf("Value %v: Decoded exponent %v, sign %v, coefficient %v", bigValue, value.Exponent, value.Coefficient, decodedCoefficient)
+	}
+}
+
+func TestChunked(t *testing.T) {
+	assertChunked(t, 0, false, []uint64{1}, 1)
+	assertChunked(t, -5, true, []uint64{0xffffffffffffffff}, 1)
+	assertChunked(t, 100, false, []uint64{0, 0, 1}, 2)
+	assertChunked(t, 3, false, []uint64{5, 0, 0, 0}, 3)
+	assertChunked(t, 3, true, []uint64{0, 0}, 3)
+	assertChunked(t, 3, false, nil, 3)
+
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		words := make([]uint64, random.Intn(50)+1)
+		for j := range words {
+			words[j] = random.Uint64() >> uint(random.Intn(64))
+		}
+		assertChunked(t, int32(random.Intn(2000)-1000), random.Intn(2) == 0, words, random.Intn(7)+1)
+	}
+
+	// Bigger than the internal write buffer
+	words := make([]uint64, 2000)
+	for i := range words {
+		words[i] = random.Uint64()
+	}
+	assertChunked(t, -1, false, words, 64)
+}
+
+func TestDecodeChunkedSpecial(t *testing.T) {
+	value, bytesDecoded, err := DecodeChunked(bytes.NewReader([]byte{0x82, 0x00}), make([]uint64, 1), func([]uint64) error {
+		t.Errorf("Expected no coefficient for a special value")
+		return nil
+	})
+	if err != nil || value != Infinity() || bytesDecoded != 2 {
+		t.Errorf("Expected infinity but got %v, %v, %v", value, bytesDecoded, err)
+	}
+
+	_, _, err = DecodeChunked(bytes.NewReader([]byte{0x00, 0x80, 0x80}), make([]uint64, 1), func([]uint64) error { return nil })
+	if err != ErrorIncomplete {
+		t.Errorf("Expected ErrorIncomplete but got %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+// Command compact-float converts between decimal strings, hex-encoded compact
+// float bytes, and annotated breakdowns of the encoded fields. It's mainly
+// useful for debugging protocol captures that contain compact float values.
+//
+// Usage:
+//
+//	compact-float encode  <decimal>...  Encode decimal values to hex
+//	compact-float decode  <hex>...      Decode hex bytes to decimal values
+//	compact-float inspect <hex>...      Show the fields of encoded values
+//
+// If no values are given, they are read from stdin as whitespace separated
+// tokens. Hex input may contain a 0x prefix and ':' or '-' separators, and may
+// hold several concatenated compact float values.
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	compact_float "github.com/kstenerud/go-compact-float"
+)
+
+const usage = `Usage: compact-float <command> [values...]
+
+Commands:
+  encode  <decimal>...  Encode decimal values to hex
+  decode  <hex>...      Decode hex bytes to decimal values
+  inspect <hex>...      Show the fields of encoded values
+
+If no values are given, they are read from stdin.
+`
+
+var errorUsage = errors.New("Invalid usage")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if errors.Is(err, errorUsage) {
+			fmt.Fprint(os.Stderr, usage)
+		}
+		fmt.Fprintf(os.Stderr, "compact-float: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: Missing command", errorUsage)
+	}
+
+	var command func(string, io.Writer) error
+	switch args[0] {
+	case "encode":
+		command = encodeCommand
+	case "decode":
+		command = decodeCommand
+	case "inspect":
+		command = inspectCommand
+	case "help", "-h", "-help", "--help":
+		_, err := fmt.Fprint(stdout, usage)
+		return err
+	default:
+		return fmt.Errorf("%w: Unknown command %q", errorUsage, args[0])
+	}
+
+	values := args[1:]
+	if len(values) == 0 {
+		scanner := bufio.NewScanner(stdin)
+		scanner.Split(bufio.ScanWords)
+		for scanner.Scan() {
+			values = append(values, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	for _, value := range values {
+		if err := command(value, stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeCommand(value string, stdout io.Writer) error {
+	dfloat, err := compact_float.DFloatFromString(value)
+	if err != nil {
+		return fmt.Errorf("%v: %w", value, err)
+	}
+	_, err = fmt.Fprintf(stdout, "%v\n", hex.EncodeToString(compact_float.AppendEncode(nil, dfloat)))
+	return err
+}
+
+func decodeCommand(value string, stdout io.Writer) error {
+	data, err := parseHex(value)
+	if err != nil {
+		return err
+	}
+	for len(data) > 0 {
+		text, bytesDecoded, err := decodeText(data)
+		if err != nil {
+			return fmt.Errorf("%v: %w", value, err)
+		}
+		if _, err = fmt.Fprintf(stdout, "%v\n", text); err != nil {
+			return err
+		}
+		data = data[bytesDecoded:]
+	}
+	return nil
+}
+
+func inspectCommand(value string, stdout io.Writer) error {
+	data, err := parseHex(value)
+	if err != nil {
+		return err
+	}
+	for len(data) > 0 {
+		explanation, err := compact_float.Explain(data)
+		if err != nil {
+			return fmt.Errorf("%v: %w", value, err)
+		}
+		if _, err = fmt.Fprintln(stdout, explanation); err != nil {
+			return err
+		}
+		_, _, bytesDecoded, _ := compact_float.DecodeFromBytes(data)
+		data = data[bytesDecoded:]
+	}
+	return nil
+}
+
+// Decodes the value at the start of data, returning its text representation.
+func decodeText(data []byte) (text string, bytesDecoded int, err error) {
+	value, bigValue, bytesDecoded, err := compact_float.DecodeFromBytes(data)
+	if err != nil {
+		return
+	}
+	if bigValue != nil {
+		return fmt.Sprint(bigValue), bytesDecoded, nil
+	}
+	return value.String(), bytesDecoded, nil
+}
+
+func parseHex(value string) ([]byte, error) {
+	cleaned := strings.NewReplacer("0x", "", "0X", "", ":", "", "-", "").Replace(value)
+	data, err := hex.DecodeString(cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("%v: Invalid hex: %w", value, err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%v: No bytes to decode", value)
+	}
+	return data, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func assertRun(t *testing.T, args []string, stdin string, expected string) {
+	var stdout bytes.Buffer
+	if err := run(args, strings.NewReader(stdin), &stdout); err != nil {
+		t.Errorf("%v: %v", args, err)
+		return
+	}
+	if stdout.String() != expected {
+		t.Errorf("%v: Expected\n%v\nbut got\n%v", args, expected, stdout.String())
+	}
+}
+
+func TestEncode(t *testing.T) {
+	assertRun(t, []string{"encode", "1.41e-5", "-0", "inf"}, "", "1e8d01\n03\n8200\n")
+	assertRun(t, []string{"encode"}, "  1.41e-5\n-0 ", "1e8d01\n03\n")
+}
+
+func TestDecode(t *testing.T) {
+	assertRun(t, []string{"decode", "0x1e8d01", "02:82:00"}, "", "0.0000141\n0\nInfinity\n")
+}
+
+func TestInspect(t *testing.T) {
+	assertRun(t, []string{"inspect", "0d2a8200"}, "", `Encoded:          0d 2a (2 bytes, canonical)
+Exponent field:   0d = 13
+  Exponent sign:    + (bit 1 = 0)
+  Coefficient sign: - (bit 0 = 1)
+  Exponent:         3
+Coefficient:      2a = 42
+Value:            -4.2e+4
+
+Encoded:          82 00 (2 bytes, canonical)
+Special value:    82 00 = Infinity
+
+`)
+}
+
+func TestErrors(t *testing.T) {
+	var stdout bytes.Buffer
+	if err := run(nil, nil, &stdout); !errors.Is(err, errorUsage) {
+		t.Errorf("Expected usage error but got %v", err)
+	}
+	if err := run([]string{"unknown"}, nil, &stdout); !errors.Is(err, errorUsage) {
+		t.Errorf("Expected usage error but got %v", err)
+	}
+	for _, args := range [][]string{
+		{"encode", "x"},
+		{"decode", "1e"},
+		{"decode", "zz"},
+		{"inspect", "0x"},
+	} {
+		if err := run(args, nil, &stdout); err == nil {
+			t.Errorf("Expected %v to fail", args)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"io"
+)
+
+// Codec bundles a decoding and encoding configuration, so that an application
+// can configure its behavior once rather than choosing between function
+// variants at every call site:
+//
+//	codec := NewCodec(WithRequireCanonical(true), WithMaxValueSize(32))
+//	value, bigValue, bytesDecoded, err := codec.Decode(reader)
+//	decoder := codec.NewDecoder(reader)
+//
+// A Codec is immutable once created, and is safe for concurrent use.
+type Codec struct {
+	requireCanonical  bool
+	maxValueSize      int
+	readBufferSize    int
+	significantDigits int
+	strictConformance bool
+}
+
+// An option that configures a Codec (see NewCodec()).
+type CodecOption func(*Codec)
+
+// Require every decoded value to be canonically encoded (see
+// DecodeCanonical()). Decoding a non-canonical value fails with
+// ErrorNotCanonical.
+func WithRequireCanonical(requireCanonical bool) CodecOption {
+	return func(codec *Codec) {
+		codec.requireCanonical = requireCanonical
+	}
+}
+
+// Limit the number of bytes a single encoded value may occupy when decoding.
+// Decoding a value that exceeds the limit fails with ErrorTooLong. A limit of 0
+// (the default) means no limit.
+func WithMaxValueSize(maxBytes int) CodecOption {
+	return func(codec *Codec) {
+		codec.maxValueSize = maxBytes
+	}
+}
+
+// Read ahead in chunks of bufferSize bytes in decoders created by
+// Codec.NewDecoder() (see NewBufferedDecoder()). A size of 0 (the default)
+// reads a byte at a time.
+func WithReadBufferSize(bufferSize int) CodecOption {
+	return func(codec *Codec) {
+		codec.readBufferSize = bufferSize
+	}
+}
+
+// Round float64 values to this many significant digits when encoding them
+// (see EncodeFloat64()). A value of 0 (the default) uses the shortest
+// representation that converts back to the same float64.
+func WithSignificantDigits(significantDigits int) CodecOption {
+	return func(codec *Codec) {
+		codec.significantDigits = significantDigits
+	}
+}
+
+// Only accept values that are part of the compact float specification (see
+// CheckConformance()). Encoding or decoding a value that relies on one of this
+// library's extensions fails with ErrorNotConforming.
+func WithStrictConformance(strictConformance bool) CodecOption {
+	return func(codec *Codec) {
+		codec.strictConformance = strictConformance
+	}
+}
+
+// Create a new codec configured by the specified options. With no options, it
+// behaves the same as the package-level functions.
+func NewCodec(options ...CodecOption) *Codec {
+	codec := &Codec{}
+	for _, option := range options {
+		option(codec)
+	}
+	return codec
+}
+
+// Encodes a DFloat to a writer.
+func (this *Codec) Encode(value DFloat, writer io.Writer) (bytesEncoded int, err error) {
+	if this.strictConformance {
+		if err = CheckConformance(value); err != nil {
+			return
+		}
+	}
+	return Encode(value, writer)
+}
+
+// Encodes a float64 to a writer, rounding it to the configured number of
+// significant digits.
+func (this *Codec) EncodeFloat64(value float64, writer io.Writer) (bytesEncoded int, err error) {
+	return EncodeFloat64(value, this.significantDigits, writer)
+}
+
+// Appends the encoded form of a DFloat to dst, growing it as needed, and
+// returns the extended slice.
+func (this *Codec) AppendEncode(dst []byte, value DFloat) []byte {
+	return AppendEncode(dst, value)
+}
+
+// Create a new encoder that writes to the specified writer.
+func (this *Codec) NewEncoder(writer io.Writer) *Encoder {
+	return NewEncoder(writer)
+}
+
+// Decode a float from a reader according to this codec's configuration.
+// bigValue will be nil unless the decoded value is too big to fit into a DFloat.
+func (this *Codec) Decode(reader io.Reader) (value DFloat, bigValue *BigDecimal, bytesDecoded int, err error) {
+	buffer := []byte{0}
+	if this.maxValueSize > 0 {
+		reader = &limitedReader{reader: reader, remaining: this.maxValueSize}
+	}
+	value, bigValue, bytesDecoded, err = decodeWithByteBuffer(reader, buffer, this.requireCanonical)
+	if this.strictConformance {
+		err = checkDecodedConformance(value, err)
+	}
+	return
+}
+
+// Decode a float from a byte slice according to this codec's configuration.
+// bigValue will be nil unless the decoded value is too big to fit into a DFloat.
+func (this *Codec) DecodeFromBytes(data []byte) (value DFloat, bigValue *BigDecimal, bytesDecoded int, err error) {
+	if this.maxValueSize > 0 && len(data) > this.maxValueSize {
+		value, bigValue, bytesDecoded, err = decodeFromBytes(data[:this.maxValueSize], this.requireCanonical)
+		if errors.Is(err, ErrorIncomplete) {
+			err = ErrorTooLong
+		}
+	} else {
+		value, bigValue, bytesDecoded, err = decodeFromBytes(data, this.requireCanonical)
+	}
+	if this.strictConformance {
+		err = checkDecodedConformance(value, err)
+	}
+	return
+}
+
+// Create a new decoder that reads from the specified reader according to this
+// codec's configuration.
+func (this *Codec) NewDecoder(reader io.Reader) *Decoder {
+	var decoder *Decoder
+	if this.readBufferSize > 0 {
+		decoder = NewBufferedDecoder(reader, this.readBufferSize)
+	} else {
+		decoder = NewDecoder(reader)
+	}
+	decoder.SetMaxValueSize(this.maxValueSize)
+	decoder.SetRequireCanonical(this.requireCanonical)
+	decoder.SetStrictConformance(this.strictConformance)
+	return decoder
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCodecDefaults(t *testing.T) {
+	codec := NewCodec()
+	encoded := codec.AppendEncode(nil, DFloatValue(4994, 9445283))
+	if expected := []byte{0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04}; !bytes.Equal(encoded, expected) {
+		t.Errorf("Expected %v but got %v", expected, encoded)
+	}
+	value, _, bytesDecoded, err := codec.Decode(bytes.NewBuffer(encoded))
+	if err != nil || value != DFloatValue(4994, 9445283) || bytesDecoded != len(encoded) {
+		t.Errorf("Expected %v but got %v (%v bytes), %v", DFloatValue(4994, 9445283), value, bytesDecoded, err)
+	}
+
+	buffer := &bytes.Buffer{}
+	if _, err := codec.EncodeFloat64(0.1473445219134543, buffer); err != nil {
+		t.Error(err)
+	}
+	if value, _, _, err = codec.DecodeFromBytes(buffer.Bytes()); err != nil || value != DFloatValue(-16, 1473445219134543) {
+		t.Errorf("Expected %v but got %v, %v", DFloatValue(-16, 1473445219134543), value, err)
+	}
+}
+
+func TestCodecOptions(t *testing.T) {
+	codec := NewCodec(WithRequireCanonical(true), WithMaxValueSize(6), WithSignificantDigits(6))
+
+	nonCanonical := []byte{0x86, 0x00, 0x0f}
+	if _, _, _, err := codec.Decode(bytes.NewBuffer(nonCanonical)); !errors.Is(err, ErrorNotCanonical) {
+		t.Errorf("Expected ErrorNotCanonical but got %v", err)
+	}
+	if _, _, _, err := codec.DecodeFromBytes(nonCanonical); !errors.Is(err, ErrorNotCanonical) {
+		t.Errorf("Expected ErrorNotCanonical but got %v", err)
+	}
+
+	tooLong := []byte{0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04}
+	if _, _, _, err := codec.Decode(bytes.NewBuffer(tooLong)); err != ErrorTooLong {
+		t.Errorf("Expected ErrorTooLong but got %v", err)
+	}
+	if _, _, _, err := codec.DecodeFromBytes(tooLong); err != ErrorTooLong {
+		t.Errorf("Expected ErrorTooLong but got %v", err)
+	}
+	if _, _, _, err := codec.DecodeFromBytes(append([]byte{0x06, 0x0f}, tooLong...)); err != nil {
+		t.Errorf("Expected a short value followed by more data to decode but got %v", err)
+	}
+
+	buffer := &bytes.Buffer{}
+	if _, err := codec.EncodeFloat64(0.1473445219134543, buffer); !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected RoundingError but got %v", err)
+	}
+	if expected := []byte{0x1a, 0x91, 0xff, 0x08}; !bytes.Equal(buffer.Bytes(), expected) {
+		t.Errorf("Expected %v but got %v", expected, buffer.Bytes())
+	}
+}
+
+func TestCodecNewDecoder(t *testing.T) {
+	encoded := []byte{0x06, 0x0f, 0x86, 0x00, 0x0f}
+	for _, codec := range []*Codec{NewCodec(WithRequireCanonical(true)),
+		NewCodec(WithRequireCanonical(true), WithReadBufferSize(64))} {
+		decoder := codec.NewDecoder(bytes.NewBuffer(encoded))
+		if !decoder.Next() {
+			t.Errorf("Expected first value to decode but got %v", decoder.Err())
+			continue
+		}
+		if decoder.Next() {
+			t.Errorf("Expected non-canonical value to fail")
+		}
+		if err := decoder.Err(); !errors.Is(err, ErrorNotCanonical) {
+			t.Errorf("Expected ErrorNotCanonical but got %v", err)
+		}
+	}
+}
@@ -21,34 +21,99 @@
 package compact_float
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"math/big"
+)
 
-	"github.com/cockroachdb/apd/v2"
-	"github.com/kstenerud/go-uleb128"
+// Errors returned while decoding. Errors carrying extra detail wrap one of
+// these, so use errors.Is() to check for them.
+var (
+	ErrorIncomplete         = fmt.Errorf("Compact float value is incomplete: %w", io.ErrUnexpectedEOF)
+	ErrorTooLong            = errors.New("Compact float value exceeds the maximum allowed length")
+	ErrorNotCanonical       = errors.New("Compact float value is not canonically encoded")
+	ErrorValueTooLarge      = errors.New("Compact float value is too large to fit into a DFloat")
+	ErrorTrailingData       = errors.New("Unexpected data after compact float value")
+	ErrorExponentTooLarge   = errors.New("Compact float exponent is too big")
+	ErrorMalformed          = errors.New("Compact float data is malformed")
+	ErrorCoefficientTooLong = errors.New("Compact float coefficient exceeds the maximum allowed length")
 )
 
-var ErrorIncomplete = fmt.Errorf("Compact float value is incomplete")
+// The maximum number of bytes an encoded coefficient may occupy when decoding.
+// This guards against corrupt or malicious data presenting an enormous
+// coefficient and forcing an unbounded allocation. Decoding a longer
+// coefficient fails with ErrorCoefficientTooLong. Set to 0 for no limit.
+var MaxCoefficientLength = 4096
+
+// The exponent field can't legitimately exceed the length of a uint64.
+const maxExponentFieldLength = 10
+
+// The largest exponent field that fits into a DFloat (31 bits of exponent,
+// plus the exponent and coefficient sign bits).
+const maxEncodedExponentField = uint64(0x1ffffffff)
 
 // Maximum number of bytes required to encode a DFloat.
 func MaxEncodeLength() int {
-	// (64 bits / 7) + (33 bits / 7)
-	return 10 + 5
+	return MaxEncodedLength
 }
 
-// Maximum number of bytes required to encode a particular apd.Decimal.
-// This is an estimate; it may be smaller, but never bigger.
-func MaxEncodeLengthBig(value *apd.Decimal) int {
-	if is32Bit() {
-		return len(value.Coeff.Bits())*32/7 + 1 + 5
+// Encodes a DFloat to a writer.
+func Encode(value DFloat, writer io.Writer) (bytesEncoded int, err error) {
+	var buffer [MaxEncodedLength]byte
+	return EncodeWithBuffer(value, writer, buffer[:])
+}
+
+// Converts a float64 to DFloat using the specified number of significant
+// digits (see DFloatFromFloat64()), and encodes it to a writer. If rounding
+// occurs, the rounded value is encoded and the returned error will be
+// RoundingError.
+func EncodeFloat64(value float64, significantDigits int, writer io.Writer) (bytesEncoded int, err error) {
+	asDFloat, conversionErr := DFloatFromFloat64(value, significantDigits)
+	if conversionErr != nil && !errors.Is(conversionErr, roundingError) {
+		err = conversionErr
+		return
+	}
+	if bytesEncoded, err = Encode(asDFloat, writer); err == nil {
+		err = conversionErr
 	}
-	return len(value.Coeff.Bits())*64/7 + 1 + 5
+	return
 }
 
-// Encodes a DFloat to a writer.
-func Encode(value DFloat, writer io.Writer) (bytesEncoded int, err error) {
-	buffer := make([]byte, MaxEncodeLength())
+// Encodes an int64 to a writer, without going through DFloat.
+func EncodeInt64(value int64, writer io.Writer) (bytesEncoded int, err error) {
+	var buffer [MaxEncodedLength]byte
+	bytesEncoded = EncodeInt64ToBytes(value, buffer[:])
+	return writer.Write(buffer[:bytesEncoded])
+}
+
+// Encodes a uint64 to a writer, without going through DFloat.
+func EncodeUint64(value uint64, writer io.Writer) (bytesEncoded int, err error) {
+	var buffer [MaxEncodedLength]byte
+	bytesEncoded = EncodeUint64ToBytes(value, buffer[:])
+	return writer.Write(buffer[:bytesEncoded])
+}
+
+// Encodes an int64 to a byte buffer, without going through DFloat.
+// Assumes the buffer is big enough (see MaxEncodeLength()).
+func EncodeInt64ToBytes(value int64, buffer []byte) (bytesEncoded int) {
+	if value < 0 {
+		return encodeIntegerToBytes(uint64(-value), true, buffer)
+	}
+	return encodeIntegerToBytes(uint64(value), false, buffer)
+}
+
+// Encodes a uint64 to a byte buffer, without going through DFloat.
+// Values above 0x7fffffffffffffff will decode as a big value.
+// Assumes the buffer is big enough (see MaxEncodeLength()).
+func EncodeUint64ToBytes(value uint64, buffer []byte) (bytesEncoded int) {
+	return encodeIntegerToBytes(value, false, buffer)
+}
+
+// Encodes a DFloat to a writer using the supplied scratch buffer (to avoid
+// extra allocations). The buffer must be at least MaxEncodeLength() bytes.
+func EncodeWithBuffer(value DFloat, writer io.Writer, buffer []byte) (bytesEncoded int, err error) {
 	bytesEncoded = EncodeToBytes(value, buffer)
 	return writer.Write(buffer[:bytesEncoded])
 }
@@ -72,70 +137,92 @@ func EncodeToBytes(value DFloat, buffer []byte) (bytesEncoded int) {
 			return EncodeQuietNan(buffer)
 		case CoeffSignalingNan:
 			return EncodeSignalingNan(buffer)
+		case CoeffNegativeNan:
+			return EncodeNegativeQuietNan(buffer)
+		case CoeffNegativeSignalingNan:
+			return EncodeNegativeSignalingNan(buffer)
 		default:
 			panic(fmt.Errorf("%v: Illegal special coefficient", value.Coefficient))
 		}
 	}
 
-	exponent := value.Exponent
-	exponentSign := 0
-	if exponent < 0 {
-		exponent = -exponent
-		exponentSign = 2
-	}
-	coefficient := value.Coefficient
-	coefficientSign := 0
-	if coefficient < 0 {
-		coefficient = -coefficient
-		coefficientSign = 1
+	exponentField, coefficient := splitDFloat(value)
+	if exponentField < smallExponentFieldLimit && coefficient < smallCoefficientLimit {
+		return encodeSmallToBytes(exponentField, coefficient, buffer)
 	}
-	exponentField := uint64(exponent)<<2 | uint64(exponentSign) | uint64(coefficientSign)
-	bytesEncoded = uleb128.EncodeUint64ToBytes(exponentField, buffer)
-	bytesEncoded += uleb128.EncodeUint64ToBytes(uint64(coefficient), buffer[bytesEncoded:])
+	bytesEncoded = encodeULEB128Uint64(exponentField, buffer)
+	bytesEncoded += encodeULEB128Uint64(coefficient, buffer[bytesEncoded:])
 	return
 }
 
-// Encodes an apd.Decimal to a writer.
-func EncodeBig(value *apd.Decimal, writer io.Writer) (bytesEncoded int, err error) {
-	buffer := make([]byte, MaxEncodeLengthBig(value))
-	bytesEncoded = EncodeBigToBytes(value, buffer)
-	return writer.Write(buffer[:bytesEncoded])
+// Returns the exact number of bytes that a DFloat will encode to.
+func EncodedSize(value DFloat) int {
+	if value.IsZero() {
+		return 1
+	}
+	if value.IsSpecial() {
+		return 2
+	}
+
+	exponentField, coefficient := splitDFloat(value)
+	return encodedSizeULEB128Uint64(exponentField) + encodedSizeULEB128Uint64(coefficient)
 }
 
-// Encodes an apt.Decimal to a buffer.
-// Assumes the buffer is big enough (see MaxEncodeLengthBig()).
-func EncodeBigToBytes(value *apd.Decimal, buffer []byte) (bytesEncoded int) {
-	if value.IsZero() {
-		if value.Negative {
-			return EncodeNegativeZero(buffer)
-		}
-		return EncodeZero(buffer)
+// Appends the encoded form of a DFloat to dst, growing it as needed, and
+// returns the extended slice.
+func AppendEncode(dst []byte, value DFloat) []byte {
+	dst, buffer := growForAppend(dst, MaxEncodeLength())
+	bytesEncoded := EncodeToBytes(value, buffer)
+	return dst[:len(dst)+bytesEncoded]
+}
+
+// Encodes a DFloat into exactly width bytes of buffer. Regular values are
+// padded by using extra (overlong) ULEB128 groups in the coefficient, so they
+// remain decodable by Decode(). Special values (zero, infinity, NaN) have no
+// coefficient, and are instead followed by zero bytes.
+// Returns an error if the value's encoding is larger than width.
+func EncodeFixed(value DFloat, width int, buffer []byte) error {
+	size := EncodedSize(value)
+	if size > width {
+		return fmt.Errorf("%v requires %v bytes, which exceeds the fixed width of %v", value, size, width)
 	}
-	switch value.Form {
-	case apd.Infinite:
-		if value.Negative {
-			return EncodeNegativeInfinity(buffer)
+	buffer = buffer[:width]
+	if value.IsZero() || value.IsSpecial() {
+		bytesEncoded := EncodeToBytes(value, buffer)
+		for i := bytesEncoded; i < width; i++ {
+			buffer[i] = 0
 		}
-		return EncodeInfinity(buffer)
-	case apd.NaN:
-		return EncodeQuietNan(buffer)
-	case apd.NaNSignaling:
-		return EncodeSignalingNan(buffer)
+		return nil
 	}
 
-	exponent := value.Exponent
-	exponentSign := 0
-	if exponent < 0 {
-		exponent = -exponent
-		exponentSign = 1
+	exponentField, coefficient := splitDFloat(value)
+	bytesEncoded := encodeULEB128Uint64(exponentField, buffer)
+	encodePaddedULEB128(coefficient, buffer[bytesEncoded:])
+	return nil
+}
+
+// Decodes a DFloat that was encoded using EncodeFixed() with the same width.
+// If the value is too big to fit into a DFloat, the returned error will be
+// ErrorValueTooLarge.
+func DecodeFixed(buffer []byte, width int) (value DFloat, err error) {
+	if len(buffer) < width {
+		return value, ErrorIncomplete
+	}
+	buffer = buffer[:width]
+	value, bigValue, bytesDecoded, err := DecodeFromBytes(buffer)
+	if err != nil {
+		return
+	}
+	if bigValue != nil {
+		err = ErrorValueTooLarge
+		return
 	}
-	significandSign := 0
-	if value.Negative {
-		significandSign = 1
+	for _, b := range buffer[bytesDecoded:] {
+		if b != 0 {
+			err = fmt.Errorf("%w: Unexpected data after fixed-width value %v", ErrorMalformed, value)
+			return
+		}
 	}
-	exponentField := uint64(exponent)<<2 | uint64(exponentSign)<<1 | uint64(significandSign)
-	bytesEncoded = uleb128.EncodeUint64ToBytes(exponentField, buffer)
-	bytesEncoded += uleb128.EncodeToBytes(&value.Coeff, buffer[bytesEncoded:])
 	return
 }
 
@@ -149,6 +236,16 @@ func EncodeSignalingNan(buffer []byte) (bytesEncoded int) {
 	return encodeExtendedSpecialValue(1, buffer)
 }
 
+// Encodes a quiet NaN with its sign bit set, using 2 bytes.
+func EncodeNegativeQuietNan(buffer []byte) (bytesEncoded int) {
+	return encodeExtendedSpecialValue(4, buffer)
+}
+
+// Encodes a signaling NaN with its sign bit set, using 2 bytes.
+func EncodeNegativeSignalingNan(buffer []byte) (bytesEncoded int) {
+	return encodeExtendedSpecialValue(5, buffer)
+}
+
 // Encodes positive infinity, using 2 bytes.
 func EncodeInfinity(buffer []byte) (bytesEncoded int) {
 	return encodeExtendedSpecialValue(2, buffer)
@@ -169,94 +266,354 @@ func EncodeNegativeZero(buffer []byte) (bytesEncoded int) {
 	return encodeSpecialValue(3, buffer)
 }
 
+// Encodes just the exponent field of a regular (non-zero, non-special) value:
+// the exponent and its sign, packed together with the coefficient's sign. The
+// coefficient magnitude (ULEB128) is expected to follow it. This is for
+// protocols that embed compact float inside their own structures or store the
+// coefficient themselves.
+// Assumes the buffer is big enough (see ExponentFieldSize()).
+// Panics if exponent is math.MinInt32 (which cannot be encoded).
+func EncodeExponentField(exponent int32, isNegativeCoefficient bool, buffer []byte) (bytesEncoded int) {
+	if exponent == math.MinInt32 {
+		panic("EncodeExponentField: exponent out of range")
+	}
+	exponentField, _ := splitDFloat(DFloat{Exponent: exponent})
+	if isNegativeCoefficient {
+		exponentField |= 1
+	}
+	return encodeULEB128Uint64(exponentField, buffer)
+}
+
+// Returns the number of bytes that EncodeExponentField() will encode exponent to.
+func ExponentFieldSize(exponent int32) int {
+	exponentField, _ := splitDFloat(DFloat{Exponent: exponent})
+	return encodedSizeULEB128Uint64(exponentField)
+}
+
+// Decodes the exponent field at the start of data (see EncodeExponentField()).
+// If the field is the start of a zero or special value (infinity, NaN) rather
+// than a regular value, the error will be ErrorMalformed, and the whole value
+// should be decoded with DecodeFromBytes() instead.
+func DecodeExponentField(data []byte) (exponent int32, isNegativeCoefficient bool, bytesDecoded int, err error) {
+	exponentField, bytesDecoded, err := decodeExponentFieldBytes(data)
+	if err != nil {
+		return
+	}
+	if _, isSpecial := decodeSpecialValue(exponentField, bytesDecoded); isSpecial {
+		err = fmt.Errorf("%w: %x is a special value rather than an exponent field", ErrorMalformed, data[:bytesDecoded])
+		return
+	}
+	exponent, isNegativeCoefficient, err = decodeExponentField(exponentField)
+	return
+}
+
 // Decode a float.
 // bigValue will be nil unless the decoded value is too big to fit into a DFloat.
-func Decode(reader io.Reader) (value DFloat, bigValue *apd.Decimal, bytesDecoded int, err error) {
+// Returns io.EOF if the reader ends before the value begins, or
+// ErrorIncomplete if it ends partway through the value.
+func Decode(reader io.Reader) (value DFloat, bigValue *BigDecimal, bytesDecoded int, err error) {
+	if _, ok := reader.(io.ByteReader); ok {
+		// Bytes are read via ReadByte(), so no buffer is needed.
+		return DecodeWithByteBuffer(reader, nil)
+	}
 	buffer := []byte{0}
 	return DecodeWithByteBuffer(reader, buffer)
 }
 
 // Decode a float using the supplied single-byte buffer.
 // bigValue will be nil unless the decoded value is too big to fit into a DFloat.
-func DecodeWithByteBuffer(reader io.Reader, buffer []byte) (value DFloat, bigValue *apd.Decimal, bytesDecoded int, err error) {
-	asUint, asBig, bytesDecoded, err := uleb128.DecodeWithByteBuffer(reader, buffer)
+func DecodeWithByteBuffer(reader io.Reader, buffer []byte) (value DFloat, bigValue *BigDecimal, bytesDecoded int, err error) {
+	return decodeWithByteBuffer(reader, buffer, false)
+}
+
+// Decode a float, rejecting any encoding that isn't the unique minimal
+// encoding of its value (with ErrorNotCanonical). This means no overlong
+// ULEB128 groups, no trailing zeros in the coefficient, and zero only in its
+// special form.
+// bigValue will be nil unless the decoded value is too big to fit into a DFloat.
+func DecodeCanonical(reader io.Reader) (value DFloat, bigValue *BigDecimal, bytesDecoded int, err error) {
+	buffer := []byte{0}
+	return decodeWithByteBuffer(reader, buffer, true)
+}
+
+func decodeWithByteBuffer(reader io.Reader, buffer []byte, requireCanonical bool) (value DFloat, bigValue *BigDecimal, bytesDecoded int, err error) {
+	asUint, asBig, bytesDecoded, err := decodeULEB128Limited(reader, buffer, maxExponentFieldLength, ErrorExponentTooLarge)
 	if err != nil {
+		err = incompleteIfTruncated(err, bytesDecoded)
 		return
 	}
 	if asBig != nil {
-		err = fmt.Errorf("Exponent %v is too big", asBig)
+		err = fmt.Errorf("%w: %v", ErrorExponentTooLarge, asBig)
 		return
 	}
 
-	switch bytesDecoded {
-	case 1:
-		switch asUint {
-		case 2:
-			value = dfloatZero
-			return
-		case 3:
-			value = dfloatNegativeZero
+	var isSpecial bool
+	if value, isSpecial = decodeSpecialValue(asUint, bytesDecoded); isSpecial {
+		return
+	}
+
+	exponent, isNegative, err := decodeExponentField(asUint)
+	if err != nil {
+		return
+	}
+
+	exponentField := asUint
+	offset := bytesDecoded
+	asUint, asBig, bytesDecoded, err = decodeULEB128Limited(reader, buffer, MaxCoefficientLength, ErrorCoefficientTooLong)
+	if err != nil {
+		err = incompleteIfTruncated(err, offset)
+		bytesDecoded += offset
+		return
+	}
+	if requireCanonical {
+		if err = checkCanonical(exponentField, offset, asUint, asBig, bytesDecoded); err != nil {
 			return
 		}
-	case 2:
-		switch asUint {
-		case 0:
-			value = dfloatNaN
-			return
-		case 1:
-			value = dfloatSignalingNaN
-			return
-		case 2:
-			value = dfloatInfinity
-			return
-		case 3:
-			value = dfloatNegativeInfinity
+	}
+	bytesDecoded += offset
+
+	value, bigValue, err = decodedValue(exponent, isNegative, asUint, asBig)
+	return
+}
+
+// Decode a float from a byte slice, without copying.
+// bigValue will be nil unless the decoded value is too big to fit into a DFloat.
+// Returns ErrorIncomplete if data ends before the value is complete.
+func DecodeFromBytes(data []byte) (value DFloat, bigValue *BigDecimal, bytesDecoded int, err error) {
+	return decodeFromBytes(data, false)
+}
+
+// Decode a float from a byte slice that must contain exactly one encoded
+// value. If any bytes remain after the value, the returned error will be
+// ErrorTrailingData.
+// bigValue will be nil unless the decoded value is too big to fit into a DFloat.
+func DecodeExact(data []byte) (value DFloat, bigValue *BigDecimal, err error) {
+	value, bigValue, bytesDecoded, err := DecodeFromBytes(data)
+	if err == nil && bytesDecoded != len(data) {
+		err = ErrorTrailingData
+	}
+	return
+}
+
+func decodeFromBytes(data []byte, requireCanonical bool) (value DFloat, bigValue *BigDecimal, bytesDecoded int, err error) {
+	if !requireCanonical {
+		var ok bool
+		if value, bytesDecoded, ok = decodeSmallFromBytes(data); ok {
 			return
 		}
 	}
-
-	maxEncodedExponent := uint64(0x1ffffffff)
-	if asUint > maxEncodedExponent {
-		err = fmt.Errorf("Exponent %v is too big", asUint)
+	if err = checkULEB128Length(data, maxExponentFieldLength, ErrorExponentTooLarge); err != nil {
+		return
+	}
+	asUint, asBig, bytesDecoded, err := decodeULEB128FromBytes(data)
+	if err != nil {
+		return
+	}
+	if asBig != nil {
+		err = fmt.Errorf("%w: %v", ErrorExponentTooLarge, asBig)
 		return
 	}
 
-	negMult := []int{1, -1}
-	coeffMult := int64(negMult[asUint&1])
-	expMult := int32(negMult[(asUint>>1)&1])
+	var isSpecial bool
+	if value, isSpecial = decodeSpecialValue(asUint, bytesDecoded); isSpecial {
+		return
+	}
 
-	exponent := int32(asUint>>2) * expMult
+	exponent, isNegative, err := decodeExponentField(asUint)
+	if err != nil {
+		return
+	}
 
+	exponentField := asUint
 	offset := bytesDecoded
-	if asUint, asBig, bytesDecoded, err = uleb128.DecodeWithByteBuffer(reader, buffer); err != nil {
+	if err = checkULEB128Length(data[offset:], MaxCoefficientLength, ErrorCoefficientTooLong); err != nil {
+		return
+	}
+	if asUint, asBig, bytesDecoded, err = decodeULEB128FromBytes(data[offset:]); err != nil {
 		return
 	}
+	if requireCanonical {
+		if err = checkCanonical(exponentField, offset, asUint, asBig, bytesDecoded); err != nil {
+			return
+		}
+	}
 	bytesDecoded += offset
 
+	value, bigValue, err = decodedValue(exponent, isNegative, asUint, asBig)
+	return
+}
+
+// Encodes this value to a writer (implements io.WriterTo).
+func (this DFloat) WriteTo(writer io.Writer) (bytesWritten int64, err error) {
+	bytesEncoded, err := Encode(this, writer)
+	return int64(bytesEncoded), err
+}
+
+// Decodes a value from a reader into this DFloat (implements io.ReaderFrom).
+// If the decoded value is too big to fit into a DFloat, the returned error will
+// be ErrorValueTooLarge and this DFloat will not be modified.
+func (this *DFloat) ReadFrom(reader io.Reader) (bytesRead int64, err error) {
+	value, bytesDecoded, err := DecodeSmall(reader)
+	if err == nil {
+		*this = value
+	}
+	return int64(bytesDecoded), err
+}
+
+// Decode a float that must fit into a DFloat. If the encoded value is too big,
+// the returned error will be ErrorValueTooLarge (bytesDecoded will still
+// report how many bytes the value occupied).
+func DecodeSmall(reader io.Reader) (value DFloat, bytesDecoded int, err error) {
+	value, bigValue, bytesDecoded, err := Decode(reader)
+	if err == nil && bigValue != nil {
+		err = ErrorValueTooLarge
+	}
+	return
+}
+
+// Decode a float, failing with ErrorTooLong if the encoded value would occupy
+// more than maxBytes bytes. This guards against corrupt or malicious data
+// presenting an enormous coefficient.
+// bigValue will be nil unless the decoded value is too big to fit into a DFloat.
+func DecodeLimited(reader io.Reader, maxBytes int) (value DFloat, bigValue *BigDecimal, bytesDecoded int, err error) {
+	limited := limitedReader{reader: reader, remaining: maxBytes}
+	buffer := []byte{0}
+	return DecodeWithByteBuffer(&limited, buffer)
+}
+
+// Determines the total size of the encoded value at the start of prefix,
+// without decoding its coefficient. If prefix doesn't contain enough bytes to
+// determine the size, complete will be false.
+func PeekEncodedSize(prefix []byte) (size int, complete bool) {
+	exponentLength, err := uleb128FromBytesLength(prefix)
+	if err != nil {
+		return 0, false
+	}
+	if exponentLength <= 2 {
+		exponentField, _, _, _ := decodeULEB128FromBytes(prefix[:exponentLength])
+		if _, isSpecial := decodeSpecialValue(exponentField, exponentLength); isSpecial {
+			return exponentLength, true
+		}
+	}
+	coefficientLength, err := uleb128FromBytesLength(prefix[exponentLength:])
+	if err != nil {
+		return 0, false
+	}
+	return exponentLength + coefficientLength, true
+}
+
+// Checks whether data begins with the unique minimal (canonical) encoding of a
+// value, without materializing the value itself. Returns the length of the
+// encoded value, and an error if the data is incomplete or malformed.
+func IsCanonicalEncoding(data []byte) (isCanonical bool, bytesDecoded int, err error) {
+	exponentField, asBig, exponentLength, err := decodeULEB128FromBytes(data)
+	if err != nil {
+		return
+	}
 	if asBig != nil {
-		bigValue = apd.NewWithBigInt(asBig, exponent)
-		bigValue.Negative = coeffMult < 0
+		err = fmt.Errorf("%w: %v", ErrorExponentTooLarge, asBig)
 		return
 	}
+	if _, isSpecial := decodeSpecialValue(exponentField, exponentLength); isSpecial {
+		return true, exponentLength, nil
+	}
+	if _, _, err = decodeExponentField(exponentField); err != nil {
+		return
+	}
+
+	coefficientLength, err := uleb128FromBytesLength(data[exponentLength:])
+	if err != nil {
+		return
+	}
+	bytesDecoded = exponentLength + coefficientLength
+	coefficient := data[exponentLength:bytesDecoded]
+
+	isCanonical = isMinimalULEB128(data[:exponentLength]) &&
+		isMinimalULEB128(coefficient) &&
+		uleb128Mod10(coefficient) != 0
+	return
+}
 
-	if asUint&0x8000000000000000 != 0 {
-		if is32Bit() {
-			bigValue = &apd.Decimal{
-				Negative: coeffMult < 0,
-				Exponent: exponent,
-			}
-			bigValue.Coeff.SetBits([]big.Word{big.Word(asUint), big.Word(asUint >> 32)})
-		} else {
-			bigValue = &apd.Decimal{
-				Negative: coeffMult < 0,
-				Exponent: exponent,
-			}
-			bigValue.Coeff.SetBits([]big.Word{big.Word(asUint)})
+// Decodes the special values (zero, infinity, NaN), which are identified by
+// the exponent field value and its encoded length.
+func decodeSpecialValue(exponentField uint64, encodedLength int) (value DFloat, isSpecial bool) {
+	switch encodedLength {
+	case 1:
+		switch exponentField {
+		case 2:
+			return dfloatZero, true
+		case 3:
+			return dfloatNegativeZero, true
 		}
+	case 2:
+		switch exponentField {
+		case 0:
+			return dfloatNaN, true
+		case 1:
+			return dfloatSignalingNaN, true
+		case 2:
+			return dfloatInfinity, true
+		case 3:
+			return dfloatNegativeInfinity, true
+		case 4:
+			return dfloatNegativeNaN, true
+		case 5:
+			return dfloatNegativeSignalingNaN, true
+		}
+	}
+	return
+}
+
+// Checks that a decoded (non-special) value was encoded in its unique minimal
+// form, given the decoded fields and their encoded lengths.
+func checkCanonical(exponentField uint64, exponentLength int, asUint uint64, asBig *big.Int, coefficientLength int) error {
+	if exponentLength != encodedSizeULEB128Uint64(exponentField) {
+		return ErrorNotCanonical
+	}
+
+	if asBig != nil {
+		if coefficientLength != encodedSizeULEB128(asBig) {
+			return ErrorNotCanonical
+		}
+		if new(big.Int).Rem(asBig, big.NewInt(10)).Sign() == 0 {
+			return ErrorNotCanonical
+		}
+		return nil
+	}
+
+	if coefficientLength != encodedSizeULEB128Uint64(asUint) {
+		return ErrorNotCanonical
+	}
+	// Zero has its own special encoding, and trailing zeros must be moved into
+	// the exponent.
+	if asUint%10 == 0 {
+		return ErrorNotCanonical
+	}
+	return nil
+}
+
+func decodeExponentField(exponentField uint64) (exponent int32, isNegative bool, err error) {
+	if exponentField > maxEncodedExponentField {
+		err = fmt.Errorf("%w: %v", ErrorExponentTooLarge, exponentField)
 		return
 	}
 
-	coefficient := int64(asUint) * coeffMult
+	negMult := []int32{1, -1}
+	exponent = int32(exponentField>>2) * negMult[(exponentField>>1)&1]
+	isNegative = exponentField&1 == 1
+	return
+}
+
+func decodedValue(exponent int32, isNegative bool, asUint uint64, asBig *big.Int) (value DFloat, bigValue *BigDecimal, err error) {
+	if asBig != nil || asUint&0x8000000000000000 != 0 {
+		bigValue, err = newBigValue(exponent, isNegative, asUint, asBig)
+		return
+	}
+
+	coefficient := int64(asUint)
+	if isNegative {
+		coefficient = -coefficient
+	}
 	value = DFloat{
 		Exponent:    exponent,
 		Coefficient: coefficient,
@@ -264,6 +621,183 @@ func DecodeWithByteBuffer(reader io.Reader, buffer []byte) (value DFloat, bigVal
 	return
 }
 
+// Makes sure dst has room for byteCount more bytes, returning the (possibly
+// reallocated) dst and the free space after its current contents.
+func growForAppend(dst []byte, byteCount int) (grown []byte, buffer []byte) {
+	if cap(dst)-len(dst) < byteCount {
+		grown = make([]byte, len(dst), 2*cap(dst)+byteCount)
+		copy(grown, dst)
+	} else {
+		grown = dst
+	}
+	buffer = grown[len(grown) : len(grown)+byteCount]
+	return
+}
+
+// Most values (including nearly all float64-derived ones) have an exponent
+// field that fits in 2 ULEB128 bytes and a coefficient that fits in 4, so these
+// get unrolled fast paths ahead of the general varint code.
+const (
+	smallExponentFieldLimit = 1 << 14
+	smallCoefficientLimit   = 1 << 28
+)
+
+// Encodes an exponent field below smallExponentFieldLimit and a coefficient
+// below smallCoefficientLimit.
+func encodeSmallToBytes(exponentField uint64, coefficient uint64, buffer []byte) (bytesEncoded int) {
+	if exponentField < 1<<7 {
+		buffer[0] = byte(exponentField)
+		bytesEncoded = 1
+	} else {
+		buffer[0] = byte(exponentField) | 0x80
+		buffer[1] = byte(exponentField >> 7)
+		bytesEncoded = 2
+	}
+
+	buffer = buffer[bytesEncoded:]
+	switch {
+	case coefficient < 1<<7:
+		buffer[0] = byte(coefficient)
+		return bytesEncoded + 1
+	case coefficient < 1<<14:
+		buffer[0] = byte(coefficient) | 0x80
+		buffer[1] = byte(coefficient >> 7)
+		return bytesEncoded + 2
+	case coefficient < 1<<21:
+		buffer[0] = byte(coefficient) | 0x80
+		buffer[1] = byte(coefficient>>7) | 0x80
+		buffer[2] = byte(coefficient >> 14)
+		return bytesEncoded + 3
+	default:
+		buffer[0] = byte(coefficient) | 0x80
+		buffer[1] = byte(coefficient>>7) | 0x80
+		buffer[2] = byte(coefficient>>14) | 0x80
+		buffer[3] = byte(coefficient >> 21)
+		return bytesEncoded + 4
+	}
+}
+
+// Decodes a value with a 1-2 byte exponent field and a 1-4 byte coefficient.
+// Returns false if data doesn't have that shape, or holds a special value.
+func decodeSmallFromBytes(data []byte) (value DFloat, bytesDecoded int, ok bool) {
+	if len(data) < 2 {
+		return
+	}
+	exponentField := uint32(data[0])
+	if exponentField < 0x80 {
+		if exponentField == 2 || exponentField == 3 {
+			// Zero and negative zero
+			return
+		}
+		bytesDecoded = 1
+	} else {
+		// A zero high byte is either a 2-byte special value or overlong.
+		if data[1] == 0 || data[1] >= 0x80 {
+			return
+		}
+		exponentField = exponentField&0x7f | uint32(data[1])<<7
+		bytesDecoded = 2
+	}
+
+	c := data[bytesDecoded:]
+	var coefficient uint32
+	switch {
+	case len(c) >= 1 && c[0] < 0x80:
+		coefficient = uint32(c[0])
+		bytesDecoded++
+	case len(c) >= 2 && c[1] < 0x80:
+		coefficient = uint32(c[0]&0x7f) | uint32(c[1])<<7
+		bytesDecoded += 2
+	case len(c) >= 3 && c[2] < 0x80:
+		coefficient = uint32(c[0]&0x7f) | uint32(c[1]&0x7f)<<7 | uint32(c[2])<<14
+		bytesDecoded += 3
+	case len(c) >= 4 && c[3] < 0x80:
+		coefficient = uint32(c[0]&0x7f) | uint32(c[1]&0x7f)<<7 | uint32(c[2]&0x7f)<<14 | uint32(c[3])<<21
+		bytesDecoded += 4
+	default:
+		return
+	}
+
+	value.Exponent = int32(exponentField >> 2)
+	if exponentField&2 != 0 {
+		value.Exponent = -value.Exponent
+	}
+	value.Coefficient = int64(coefficient)
+	if exponentField&1 != 0 {
+		value.Coefficient = -value.Coefficient
+	}
+	return value, bytesDecoded, true
+}
+
+// Splits a (non-special) DFloat into its encoded exponent field and
+// coefficient magnitude.
+func splitDFloat(value DFloat) (exponentField uint64, coefficient uint64) {
+	exponent := value.Exponent
+	exponentSign := 0
+	if exponent < 0 {
+		exponent = -exponent
+		exponentSign = 2
+	}
+	signedCoefficient := value.Coefficient
+	coefficientSign := 0
+	if signedCoefficient < 0 {
+		signedCoefficient = -signedCoefficient
+		coefficientSign = 1
+	}
+	exponentField = uint64(exponent)<<2 | uint64(exponentSign) | uint64(coefficientSign)
+	coefficient = uint64(signedCoefficient)
+	return
+}
+
+// Reader that fails with ErrorTooLong once more than a certain number of
+// bytes have been read.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int
+}
+
+func (this *limitedReader) Read(p []byte) (n int, err error) {
+	if this.remaining <= 0 {
+		return 0, ErrorTooLong
+	}
+	if len(p) > this.remaining {
+		p = p[:this.remaining]
+	}
+	n, err = this.reader.Read(p)
+	this.remaining -= n
+	return
+}
+
+// Converts an end-of-data error into ErrorIncomplete if any bytes of the value
+// had already been read.
+func incompleteIfTruncated(err error, bytesRead int) error {
+	if err == io.ErrUnexpectedEOF || (err == io.EOF && bytesRead > 0) {
+		return ErrorIncomplete
+	}
+	return err
+}
+
+// Encodes an integer magnitude and sign, moving trailing zeros into the
+// exponent.
+func encodeIntegerToBytes(magnitude uint64, isNegative bool, buffer []byte) (bytesEncoded int) {
+	if magnitude == 0 {
+		return EncodeZero(buffer)
+	}
+
+	exponent := uint64(0)
+	for magnitude%10 == 0 {
+		magnitude /= 10
+		exponent++
+	}
+	exponentField := exponent << 2
+	if isNegative {
+		exponentField |= 1
+	}
+	bytesEncoded = encodeULEB128Uint64(exponentField, buffer)
+	bytesEncoded += encodeULEB128Uint64(magnitude, buffer[bytesEncoded:])
+	return
+}
+
 func encodeSpecialValue(value byte, buffer []byte) (bytesEncoded int) {
 	buffer[0] = value
 	return 1
@@ -274,7 +808,3 @@ func encodeExtendedSpecialValue(value byte, buffer []byte) (bytesEncoded int) {
 	buffer[1] = 0
 	return 2
 }
-
-func is32Bit() bool {
-	return ^uint(0) == 0xffffffff
-}
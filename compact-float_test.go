@@ -18,12 +18,20 @@
 // FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
 // IN THE SOFTWARE.
 
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
 package compact_float
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math"
+	"math/big"
+	"math/rand"
 	"testing"
 
 	"github.com/cockroachdb/apd/v2"
@@ -45,6 +53,15 @@ func assertCodecAPD(t *testing.T, sourceValue *apd.Decimal, expectedEncoded []by
 		t.Errorf("Value %v: Expected encoded %v but got %v", sourceValue, describe.D(expectedEncoded), describe.D(actualEncoded.Bytes()))
 		return
 	}
+	if size := EncodedSizeBig(sourceValue); size != len(expectedEncoded) {
+		t.Errorf("Value %v: Expected encoded size %v but got %v", sourceValue, len(expectedEncoded), size)
+		return
+	}
+	prefix := []byte{0xff}
+	if appended := AppendEncodeBig(prefix, sourceValue); !bytes.Equal(appended, append(prefix, expectedEncoded...)) {
+		t.Errorf("Value %v: Expected appended %v but got %v", sourceValue, describe.D(append(prefix, expectedEncoded...)), describe.D(appended))
+		return
+	}
 	var value DFloat
 	var bigValue *apd.Decimal
 	var bytesDecoded int
@@ -66,10 +83,15 @@ func assertCodecAPD(t *testing.T, sourceValue *apd.Decimal, expectedEncoded []by
 			if bigValue.Cmp(sourceValue) != 0 {
 				t.Errorf("Expected decoded big %v but got %v", sourceValue, bigValue)
 			}
+			assertDecodeFromBytes(t, expectedEncoded, value, bigValue)
+			assertDecodeInto(t, expectedEncoded, sourceValue)
 			return
 		}
 	}
 
+	assertDecodeFromBytes(t, expectedEncoded, value, bigValue)
+	assertDecodeInto(t, expectedEncoded, sourceValue)
+
 	expectedValue, err := DFloatFromAPD(sourceValue)
 	if err != nil {
 		t.Errorf("Unexpected error converting from apd.Decimal %v to dfloat: %v", sourceValue, err)
@@ -81,6 +103,69 @@ func assertCodecAPD(t *testing.T, sourceValue *apd.Decimal, expectedEncoded []by
 	}
 }
 
+func assertDecodeFromBytes(t *testing.T, encoded []byte, expectedValue DFloat, expectedBigValue *apd.Decimal) {
+	oversizeEncoded := append(append([]byte{}, encoded...), 0)
+	value, bigValue, bytesDecoded, err := DecodeFromBytes(oversizeEncoded)
+	if err != nil {
+		t.Errorf("Encoded %v: %v", describe.D(encoded), err)
+		return
+	}
+	if bytesDecoded != len(encoded) {
+		t.Errorf("Encoded %v: Expected to decode %v bytes but decoded %v", describe.D(encoded), len(encoded), bytesDecoded)
+		return
+	}
+	if _, _, err := DecodeExact(oversizeEncoded); err != ErrorTrailingData {
+		t.Errorf("Encoded %v: Expected ErrorTrailingData but got %v", describe.D(encoded), err)
+		return
+	}
+	if _, _, err := DecodeExact(encoded); err != nil {
+		t.Errorf("Encoded %v: %v", describe.D(encoded), err)
+		return
+	}
+	if expectedBigValue != nil {
+		if bigValue == nil || bigValue.Cmp(expectedBigValue) != 0 {
+			t.Errorf("Encoded %v: Expected decoded big %v but got %v", describe.D(encoded), expectedBigValue, bigValue)
+			return
+		}
+	} else if bigValue != nil || value != expectedValue {
+		t.Errorf("Encoded %v: Expected decoded dfloat %v but got %v (big %v)", describe.D(encoded), expectedValue, value, bigValue)
+		return
+	}
+
+	if size, complete := PeekEncodedSize(oversizeEncoded); !complete || size != len(encoded) {
+		t.Errorf("Encoded %v: Expected peeked size %v but got %v (complete %v)", describe.D(encoded), len(encoded), size, complete)
+		return
+	}
+	for i := 0; i < len(encoded); i++ {
+		if _, complete := PeekEncodedSize(encoded[:i]); complete {
+			t.Errorf("Encoded %v: Expected peek of %v bytes to be incomplete", describe.D(encoded), i)
+			return
+		}
+		if _, _, _, err := DecodeFromBytes(encoded[:i]); err != ErrorIncomplete {
+			t.Errorf("Encoded %v: Expected truncation at %v bytes to produce ErrorIncomplete but got %v", describe.D(encoded), i, err)
+			return
+		}
+	}
+}
+
+func assertDecodeInto(t *testing.T, encoded []byte, expectedValue *apd.Decimal) {
+	// Start with a value that has existing coefficient storage to be reused.
+	dst, _, _ := apd.NewFromString("-1.23456789012345678901234567890e100")
+	bytesDecoded, err := DecodeInto(bytes.NewBuffer(encoded), dst)
+	if err != nil {
+		t.Errorf("Encoded %v: %v", describe.D(encoded), err)
+		return
+	}
+	if bytesDecoded != len(encoded) {
+		t.Errorf("Encoded %v: Expected to decode %v bytes but decoded %v", describe.D(encoded), len(encoded), bytesDecoded)
+		return
+	}
+	if dst.Form != expectedValue.Form || dst.Negative != expectedValue.Negative ||
+		(dst.Form == apd.Finite && dst.Cmp(expectedValue) != 0) {
+		t.Errorf("Encoded %v: Expected decoded %v but got %v", describe.D(encoded), expectedValue, dst)
+	}
+}
+
 func assertCodecDecimal(t *testing.T, expectedValue DFloat, expectedEncoded []byte) {
 	actualEncoded := &bytes.Buffer{}
 	bytesEncoded, err := Encode(expectedValue, actualEncoded)
@@ -96,6 +181,15 @@ func assertCodecDecimal(t *testing.T, expectedValue DFloat, expectedEncoded []by
 		t.Errorf("Value %v: Expected encoded %v but got %v", expectedValue, describe.D(expectedEncoded), describe.D(actualEncoded.Bytes()))
 		return
 	}
+	if size := EncodedSize(expectedValue); size != len(expectedEncoded) {
+		t.Errorf("Value %v: Expected encoded size %v but got %v", expectedValue, len(expectedEncoded), size)
+		return
+	}
+	prefix := []byte{0xff}
+	if appended := AppendEncode(prefix, expectedValue); !bytes.Equal(appended, append(prefix, expectedEncoded...)) {
+		t.Errorf("Value %v: Expected appended %v but got %v", expectedValue, describe.D(append(prefix, expectedEncoded...)), describe.D(appended))
+		return
+	}
 	actualValue, _, bytesDecoded, err := Decode(bytes.NewBuffer(expectedEncoded))
 	if err != nil {
 		t.Errorf("Value %v: %v", expectedValue, err)
@@ -170,6 +264,8 @@ func TestAPD(t *testing.T) {
 	assertAPD(t, "-inf", []byte{0x83, 0x00})
 	assertAPD(t, "nan", []byte{0x80, 0x00})
 	assertAPD(t, "snan", []byte{0x81, 0x00})
+	assertAPD(t, "-nan", []byte{0x84, 0x00})
+	assertAPD(t, "-snan", []byte{0x85, 0x00})
 
 	assertAPD(t, "1", []byte{0x00, 0x01})
 	assertAPD(t, "1.5", []byte{0x06, 0x0f})
@@ -197,6 +293,8 @@ func TestDecimal(t *testing.T) {
 	assertDecimal(t, "-inf", []byte{0x83, 0x00}, nil)
 	assertDecimal(t, "nan", []byte{0x80, 0x00}, nil)
 	assertDecimal(t, "snan", []byte{0x81, 0x00}, nil)
+	assertDecimal(t, "-nan", []byte{0x84, 0x00}, nil)
+	assertDecimal(t, "-snan", []byte{0x85, 0x00}, nil)
 
 	assertDecimal(t, "1", []byte{0x00, 0x01}, nil)
 	assertDecimal(t, "1.5", []byte{0x06, 0x0f}, nil)
@@ -235,6 +333,21 @@ func TestSpecialF64(t *testing.T) {
 	assertFloat64(t, snan, 0, snan, []byte{0x81, 0x00}, nil)
 	assertFloat64(t, inf, 0, inf, []byte{0x82, 0x00}, nil)
 	assertFloat64(t, ninf, 0, ninf, []byte{0x83, 0x00}, nil)
+
+	nqnan := math.Float64frombits(math.Float64bits(qnan) | signBit)
+	nsnan := math.Float64frombits(math.Float64bits(snan) | signBit)
+	assertFloat64(t, nqnan, 0, nqnan, []byte{0x84, 0x00}, nil)
+	assertFloat64(t, nsnan, 0, nsnan, []byte{0x85, 0x00}, nil)
+	for _, value := range []float64{nqnan, nsnan} {
+		converted, err := DFloatFromFloat64(value, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if bits := math.Float64bits(converted.Float()); bits&signBit == 0 {
+			t.Errorf("Expected %v to keep its sign bit but got %x", converted, bits)
+		}
+	}
 }
 
 func Test1_0(t *testing.T) {
@@ -280,3 +393,462 @@ func Test0_5935555Round4(t *testing.T) {
 func Test0_1473445219134543Round6(t *testing.T) {
 	assertFloat64(t, 14.73445219134543, 6, 14.7345, []byte{0x12, 0x91, 0xff, 0x08}, RoundingError())
 }
+
+func TestEncodeWithBufferAllocations(t *testing.T) {
+	value := DFloatValue(-5, 1234567)
+	buffer := make([]byte, MaxEncodeLength())
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := EncodeWithBuffer(value, ioutil.Discard, buffer); err != nil {
+			t.Error(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations but got %v", allocs)
+	}
+}
+
+func TestDecodeLimited(t *testing.T) {
+	encoded := []byte{0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04}
+	value, _, bytesDecoded, err := DecodeLimited(bytes.NewBuffer(encoded), len(encoded))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if bytesDecoded != len(encoded) || value != DFloatValue(4994, 9445283) {
+		t.Errorf("Expected %v (%v bytes) but got %v (%v bytes)", DFloatValue(4994, 9445283), len(encoded), value, bytesDecoded)
+		return
+	}
+	for _, limit := range []int{0, 1, 3, len(encoded) - 1} {
+		if _, _, _, err := DecodeLimited(bytes.NewBuffer(encoded), limit); err != ErrorTooLong {
+			t.Errorf("Limit %v: Expected ErrorTooLong but got %v", limit, err)
+		}
+	}
+}
+
+func assertCanonical(t *testing.T, encoded []byte) {
+	if isCanonical, bytesDecoded, err := IsCanonicalEncoding(encoded); !isCanonical || bytesDecoded != len(encoded) || err != nil {
+		t.Errorf("Encoded %v: Expected IsCanonicalEncoding to report canonical with length %v but got %v, %v (%v)", describe.D(encoded), len(encoded), isCanonical, bytesDecoded, err)
+	}
+	if _, _, bytesDecoded, err := DecodeCanonical(bytes.NewBuffer(encoded)); err != nil || bytesDecoded != len(encoded) {
+		t.Errorf("Encoded %v: Expected canonical decode of %v bytes but got %v bytes (%v)", describe.D(encoded), len(encoded), bytesDecoded, err)
+	}
+}
+
+func assertNotCanonical(t *testing.T, encoded []byte) {
+	if isCanonical, bytesDecoded, err := IsCanonicalEncoding(encoded); isCanonical || bytesDecoded != len(encoded) || err != nil {
+		t.Errorf("Encoded %v: Expected IsCanonicalEncoding to report non-canonical with length %v but got %v, %v (%v)", describe.D(encoded), len(encoded), isCanonical, bytesDecoded, err)
+	}
+	if _, _, _, err := DecodeCanonical(bytes.NewBuffer(encoded)); err != ErrorNotCanonical {
+		t.Errorf("Encoded %v: Expected ErrorNotCanonical but got %v", describe.D(encoded), err)
+	}
+	if _, _, _, err := Decode(bytes.NewBuffer(encoded)); err != nil {
+		t.Errorf("Encoded %v: Expected non-strict decode to succeed but got %v", describe.D(encoded), err)
+	}
+}
+
+func TestDecodeCanonical(t *testing.T) {
+	assertCanonical(t, []byte{0x02})
+	assertCanonical(t, []byte{0x03})
+	assertCanonical(t, []byte{0x80, 0x00})
+	assertCanonical(t, []byte{0x83, 0x00})
+	assertCanonical(t, []byte{0x06, 0x0f})
+	assertCanonical(t, []byte{0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04})
+	assertCanonical(t, []byte{0xcf, 0x9d, 0x01, 0xd1, 0x8e, 0xa2, 0xe6, 0x83, 0x8a, 0xbf, 0xc1, 0xbb,
+		0xe1, 0xf3, 0xdf, 0xfc, 0xee, 0xac, 0xe5, 0xfe, 0xe1, 0x8f, 0xe2, 0x43})
+
+	// Overlong exponent field
+	assertNotCanonical(t, []byte{0x86, 0x00, 0x0f})
+	// Overlong coefficient
+	assertNotCanonical(t, []byte{0x06, 0x8f, 0x00})
+	// Trailing zero in coefficient (1.50)
+	assertNotCanonical(t, []byte{0x0a, 0x96, 0x01})
+	// Non-special zero
+	assertNotCanonical(t, []byte{0x00, 0x00})
+	// Trailing zero in big coefficient
+	assertNotCanonical(t, []byte{0x00, 0x80, 0x80, 0xc0, 0x98, 0xd6, 0xc5, 0xd7, 0xe3, 0xeb, 0x0a})
+}
+
+func TestIsCanonicalEncodingIncomplete(t *testing.T) {
+	for _, encoded := range [][]byte{{}, {0x80}, {0x06}, {0x06, 0x8f}} {
+		if _, _, err := IsCanonicalEncoding(encoded); err != ErrorIncomplete {
+			t.Errorf("Encoded %v: Expected ErrorIncomplete but got %v", describe.D(encoded), err)
+		}
+	}
+}
+
+func TestDecodeSmall(t *testing.T) {
+	value, bytesDecoded, err := DecodeSmall(bytes.NewBuffer([]byte{0x06, 0x0f}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if value != DFloatValue(-1, 15) || bytesDecoded != 2 {
+		t.Errorf("Expected 1.5 (2 bytes) but got %v (%v bytes)", value, bytesDecoded)
+		return
+	}
+
+	encoded := []byte{0x00, 0x80, 0x80, 0xc0, 0x98, 0xd6, 0xc5, 0xd7, 0xe3, 0xeb, 0x0a}
+	_, bytesDecoded, err = DecodeSmall(bytes.NewBuffer(encoded))
+	if err != ErrorValueTooLarge {
+		t.Errorf("Expected ErrorValueTooLarge but got %v", err)
+	}
+	if bytesDecoded != len(encoded) {
+		t.Errorf("Expected %v bytes decoded but got %v", len(encoded), bytesDecoded)
+	}
+}
+
+func TestEncodeFloat64(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	bytesEncoded, err := EncodeFloat64(0.5935555, 4, buffer)
+	if !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected RoundingError but got %v", err)
+		return
+	}
+	expected := []byte{0x12, 0xb0, 0x2e}
+	if bytesEncoded != len(expected) || !bytes.Equal(buffer.Bytes(), expected) {
+		t.Errorf("Expected encoded %v but got %v", describe.D(expected), describe.D(buffer.Bytes()))
+		return
+	}
+
+	buffer.Reset()
+	if _, err = EncodeFloat64(1.5, 0, buffer); err != nil {
+		t.Error(err)
+		return
+	}
+	expected = []byte{0x06, 0x0f}
+	if !bytes.Equal(buffer.Bytes(), expected) {
+		t.Errorf("Expected encoded %v but got %v", describe.D(expected), describe.D(buffer.Bytes()))
+	}
+}
+
+func assertEncodeInt64(t *testing.T, value int64) {
+	expected := &bytes.Buffer{}
+	if _, err := Encode(DFloatValue(0, value), expected); err != nil {
+		t.Error(err)
+		return
+	}
+	actual := &bytes.Buffer{}
+	if _, err := EncodeInt64(value, actual); err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(actual.Bytes(), expected.Bytes()) {
+		t.Errorf("Value %v: Expected encoded %v but got %v", value, describe.D(expected.Bytes()), describe.D(actual.Bytes()))
+	}
+}
+
+func assertEncodeUint64(t *testing.T, value uint64) {
+	expected := &bytes.Buffer{}
+	bigValue := apd.NewWithBigInt(new(big.Int).SetUint64(value), 0)
+	if _, _, err := apd.BaseContext.Reduce(bigValue, bigValue); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := EncodeBig(bigValue, expected); err != nil {
+		t.Error(err)
+		return
+	}
+	actual := &bytes.Buffer{}
+	if _, err := EncodeUint64(value, actual); err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(actual.Bytes(), expected.Bytes()) {
+		t.Errorf("Value %v: Expected encoded %v but got %v", value, describe.D(expected.Bytes()), describe.D(actual.Bytes()))
+	}
+}
+
+func TestEncodeInteger(t *testing.T) {
+	assertEncodeInt64(t, 0)
+	assertEncodeInt64(t, 1)
+	assertEncodeInt64(t, -1)
+	assertEncodeInt64(t, 1000)
+	assertEncodeInt64(t, -1234500)
+	assertEncodeInt64(t, math.MaxInt64)
+	assertEncodeInt64(t, math.MinInt64+1)
+
+	assertEncodeUint64(t, 0)
+	assertEncodeUint64(t, 100)
+	assertEncodeUint64(t, 10000000000000000000)
+	assertEncodeUint64(t, math.MaxUint64)
+}
+
+func assertFixed(t *testing.T, value DFloat, width int, expected []byte) {
+	buffer := make([]byte, width)
+	if err := EncodeFixed(value, width, buffer); err != nil {
+		t.Errorf("Value %v: %v", value, err)
+		return
+	}
+	if !bytes.Equal(buffer, expected) {
+		t.Errorf("Value %v: Expected encoded %v but got %v", value, describe.D(expected), describe.D(buffer))
+		return
+	}
+	actual, err := DecodeFixed(buffer, width)
+	if err != nil {
+		t.Errorf("Value %v: %v", value, err)
+		return
+	}
+	if actual != value {
+		t.Errorf("Expected %v but got %v", value, actual)
+	}
+}
+
+func TestFixed(t *testing.T) {
+	assertFixed(t, DFloatValue(-1, 15), 2, []byte{0x06, 0x0f})
+	assertFixed(t, DFloatValue(-1, 15), 5, []byte{0x06, 0x8f, 0x80, 0x80, 0x00})
+	assertFixed(t, NegativeZero(), 4, []byte{0x03, 0x00, 0x00, 0x00})
+	assertFixed(t, Infinity(), 3, []byte{0x82, 0x00, 0x00})
+
+	// Padded regular values are still decodable normally
+	value, _, _, err := Decode(bytes.NewBuffer([]byte{0x06, 0x8f, 0x80, 0x80, 0x00}))
+	if err != nil || value != DFloatValue(-1, 15) {
+		t.Errorf("Expected 1.5 but got %v (%v)", value, err)
+	}
+
+	if err := EncodeFixed(DFloatValue(4994, 9445283), 4, make([]byte, 4)); err == nil {
+		t.Errorf("Expected value to not fit into fixed width 4")
+	}
+	if _, err := DecodeFixed([]byte{0x02, 0x01}, 2); err == nil {
+		t.Errorf("Expected non-zero padding to fail")
+	}
+}
+
+func TestEncodeBigWithBufferAllocations(t *testing.T) {
+	value, _, err := apd.NewFromString("-9.4452837206285466345998345667683453466347345e-5000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	buffer := make([]byte, EncodedSizeBig(value))
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := EncodeBigWithBuffer(value, ioutil.Discard, buffer); err != nil {
+			t.Error(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations but got %v", allocs)
+	}
+}
+
+func TestWriterToReaderFrom(t *testing.T) {
+	var writerTo io.WriterTo = DFloatValue(-1, 15)
+	buffer := &bytes.Buffer{}
+	bytesWritten, err := writerTo.WriteTo(buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if bytesWritten != 2 || !bytes.Equal(buffer.Bytes(), []byte{0x06, 0x0f}) {
+		t.Errorf("Expected [6 15] but got %v", describe.D(buffer.Bytes()))
+		return
+	}
+
+	var value DFloat
+	var readerFrom io.ReaderFrom = &value
+	bytesRead, err := readerFrom.ReadFrom(buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if bytesRead != 2 || value != DFloatValue(-1, 15) {
+		t.Errorf("Expected 1.5 (2 bytes) but got %v (%v bytes)", value, bytesRead)
+	}
+}
+
+func assertDecodeErrorIs(t *testing.T, encoded []byte, expected error) {
+	_, _, _, err := DecodeFromBytes(encoded)
+	if !errors.Is(err, expected) {
+		t.Errorf("Encoded %v: Expected error %v but got %v", describe.D(encoded), expected, err)
+	}
+	_, _, _, err = Decode(bytes.NewBuffer(encoded))
+	if !errors.Is(err, expected) {
+		t.Errorf("Encoded %v: Expected error %v but got %v", describe.D(encoded), expected, err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	assertDecodeErrorIs(t, []byte{0xff, 0xff, 0xff, 0xff, 0x7f, 0x01}, ErrorExponentTooLarge)
+	assertDecodeErrorIs(t, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 0x01}, ErrorExponentTooLarge)
+	if _, err := DecodeFixed([]byte{0x02, 0x01}, 2); !errors.Is(err, ErrorMalformed) {
+		t.Errorf("Expected ErrorMalformed but got %v", err)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	if _, _, _, err := Decode(bytes.NewBuffer([]byte{})); err != io.EOF {
+		t.Errorf("Expected io.EOF but got %v", err)
+	}
+	for _, encoded := range [][]byte{{0x80}, {0x06}, {0x06, 0x8f}, {0x88, 0x9c, 0x01, 0xa3}} {
+		_, _, bytesDecoded, err := Decode(bytes.NewBuffer(encoded))
+		if err != ErrorIncomplete {
+			t.Errorf("Encoded %v: Expected ErrorIncomplete but got %v", describe.D(encoded), err)
+		}
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("Encoded %v: Expected error to wrap io.ErrUnexpectedEOF", describe.D(encoded))
+		}
+		if bytesDecoded != len(encoded) {
+			t.Errorf("Encoded %v: Expected %v bytes decoded but got %v", describe.D(encoded), len(encoded), bytesDecoded)
+		}
+		if _, err := DecodeInto(bytes.NewBuffer(encoded), &apd.Decimal{}); err != ErrorIncomplete {
+			t.Errorf("Encoded %v: Expected DecodeInto to return ErrorIncomplete but got %v", describe.D(encoded), err)
+		}
+	}
+}
+
+func TestMaxCoefficientLength(t *testing.T) {
+	oldMax := MaxCoefficientLength
+	defer func() { MaxCoefficientLength = oldMax }()
+
+	encoded := []byte{0x00, 0x80, 0x80, 0xc0, 0x98, 0xd6, 0xc5, 0xd7, 0xe3, 0xeb, 0x0a}
+	MaxCoefficientLength = 10
+	if _, _, _, err := Decode(bytes.NewBuffer(encoded)); err != nil {
+		t.Errorf("Expected coefficient of exactly the maximum length to decode but got %v", err)
+	}
+
+	MaxCoefficientLength = 9
+	assertDecodeErrorIs(t, encoded, ErrorCoefficientTooLong)
+	if _, err := DecodeInto(bytes.NewBuffer(encoded), &apd.Decimal{}); !errors.Is(err, ErrorCoefficientTooLong) {
+		t.Errorf("Expected ErrorCoefficientTooLong but got %v", err)
+	}
+
+	MaxCoefficientLength = 0
+	if _, _, _, err := DecodeFromBytes(encoded); err != nil {
+		t.Errorf("Expected no limit but got %v", err)
+	}
+}
+
+func TestSmallFastPath(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		value := DFloat{
+			Exponent:    int32(random.Intn(1<<13)) - 1<<12,
+			Coefficient: random.Int63n(1<<29) - 1<<28,
+		}
+		if i%2 == 0 {
+			value.Exponent >>= uint(random.Intn(13))
+			value.Coefficient >>= uint(random.Intn(29))
+		}
+		if value.IsZero() {
+			continue
+		}
+
+		exponentField, coefficient := splitDFloat(value)
+		expected := make([]byte, 0, MaxEncodeLength())
+		expected = appendULEB128(expected, exponentField)
+		expected = appendULEB128(expected, coefficient)
+
+		buffer := make([]byte, MaxEncodeLength())
+		bytesEncoded := EncodeToBytes(value, buffer)
+		if !bytes.Equal(buffer[:bytesEncoded], expected) {
+			t.Fatalf("Value %v: Expected encoding %v but got %v", value, describe.D(expected), describe.D(buffer[:bytesEncoded]))
+		}
+
+		decoded, bigValue, bytesDecoded, err := DecodeFromBytes(expected)
+		if err != nil || bigValue != nil || decoded != value || bytesDecoded != len(expected) {
+			t.Fatalf("Value %v: Decoded %v, %v, %v, %v", value, decoded, bigValue, bytesDecoded, err)
+		}
+	}
+
+	// Shapes the fast path must hand off to the general path
+	assertDecodeFromBytes(t, []byte{0x80, 0x00}, QuietNaN(), nil)
+	assertDecodeFromBytes(t, []byte{0x82, 0x00}, Infinity(), nil)
+	assertDecodeFromBytes(t, []byte{0x02}, Zero(), nil)
+	assertDecodeFromBytes(t, []byte{0x03}, NegativeZero(), nil)
+}
+
+func TestDecodeByteReader(t *testing.T) {
+	oldMax := MaxCoefficientLength
+	defer func() { MaxCoefficientLength = oldMax }()
+	MaxCoefficientLength = 20
+
+	encodings := [][]byte{
+		{0x06, 0x0f},
+		{0x02},
+		{0x83, 0x00},
+		{0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04},
+		{0x00, 0x80, 0x80, 0xc0, 0x98, 0xd6, 0xc5, 0xd7, 0xe3, 0xeb, 0x0a},
+		{0x00, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01},
+		{0x00, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80},
+		{0x88, 0x9c},
+		{},
+	}
+	for _, encoded := range encodings {
+		// bytes.Reader implements io.ByteReader; the wrapper hides it.
+		value, bigValue, bytesDecoded, err := Decode(bytes.NewReader(encoded))
+		eValue, eBigValue, eBytesDecoded, eErr := Decode(struct{ io.Reader }{bytes.NewReader(encoded)})
+		if value != eValue || fmt.Sprint(bigValue) != fmt.Sprint(eBigValue) || bytesDecoded != eBytesDecoded || fmt.Sprint(err) != fmt.Sprint(eErr) {
+			t.Errorf("Encoding %v: ByteReader decode gave %v, %v, %v, %v but Reader decode gave %v, %v, %v, %v",
+				describe.D(encoded), value, bigValue, bytesDecoded, err, eValue, eBigValue, eBytesDecoded, eErr)
+		}
+	}
+
+	reader := bytes.NewReader([]byte{0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04})
+	allocs := testing.AllocsPerRun(100, func() {
+		reader.Seek(0, io.SeekStart)
+		Decode(reader)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations but got %v", allocs)
+	}
+}
+
+func TestDecodeAllocations(t *testing.T) {
+	for _, value := range []DFloat{DFloatValue(-3, 1234), DFloatValue(1000, 0x7fffffffffffffff), DFloatValue(-1000, -0x7fffffffffffffff)} {
+		encoded := AppendEncode(nil, value)
+		reader := bytes.NewReader(encoded)
+		allocs := testing.AllocsPerRun(100, func() {
+			DecodeFromBytes(encoded)
+			reader.Seek(0, io.SeekStart)
+			Decode(reader)
+		})
+		if allocs != 0 {
+			t.Errorf("Value %v: Expected no allocations but got %v", value, allocs)
+		}
+	}
+}
+
+func TestExponentField(t *testing.T) {
+	assertField := func(exponent int32, isNegative bool, expected []byte) {
+		buffer := make([]byte, 10)
+		bytesEncoded := EncodeExponentField(exponent, isNegative, buffer)
+		if !bytes.Equal(buffer[:bytesEncoded], expected) {
+			t.Errorf("%v, %v: expected %x but got %x", exponent, isNegative, expected, buffer[:bytesEncoded])
+		}
+		if size := ExponentFieldSize(exponent); size != len(expected) {
+			t.Errorf("%v: expected size %v but got %v", exponent, len(expected), size)
+		}
+		actualExponent, actualIsNegative, bytesDecoded, err := DecodeExponentField(buffer[:bytesEncoded])
+		if err != nil {
+			t.Errorf("%x: %v", expected, err)
+			return
+		}
+		if actualExponent != exponent || actualIsNegative != isNegative || bytesDecoded != len(expected) {
+			t.Errorf("%x: expected %v, %v but got %v, %v (%v bytes)", expected, exponent, isNegative, actualExponent, actualIsNegative, bytesDecoded)
+		}
+	}
+	assertField(0, false, []byte{0x00})
+	assertField(0, true, []byte{0x01})
+	assertField(-1, false, []byte{0x06})
+	assertField(-1, true, []byte{0x07})
+	assertField(31, false, []byte{0x7c})
+	assertField(32, true, []byte{0x81, 0x01})
+	assertField(math.MaxInt32, false, []byte{0xfc, 0xff, 0xff, 0xff, 0x1f})
+	assertField(-math.MaxInt32, true, []byte{0xff, 0xff, 0xff, 0xff, 0x1f})
+
+	// The field must match the start of a full encoding.
+	encoded := AppendEncode(nil, DFloatValue(-2, -1995))
+	buffer := make([]byte, 10)
+	bytesEncoded := EncodeExponentField(-2, true, buffer)
+	if !bytes.Equal(encoded[:bytesEncoded], buffer[:bytesEncoded]) {
+		t.Errorf("Expected %x to start with %x", encoded, buffer[:bytesEncoded])
+	}
+
+	for _, special := range [][]byte{{0x02}, {0x03}, {0x80, 0x00}, {0x82, 0x00}} {
+		if _, _, _, err := DecodeExponentField(special); !errors.Is(err, ErrorMalformed) {
+			t.Errorf("%x: expected ErrorMalformed but got %v", special, err)
+		}
+	}
+	if _, _, _, err := DecodeExponentField([]byte{0x86}); !errors.Is(err, ErrorIncomplete) {
+		t.Errorf("Expected ErrorIncomplete but got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"fmt"
+)
+
+// Concise Encoding (https://concise-encoding.org) stores decimal floating
+// point values as compact floats in its binary format (CBE), and as decimal
+// text in its text format (CTE). These functions handle both, so that CE
+// implementations don't need to wrap the lower level API themselves.
+
+// The CBE type code that introduces a decimal float (followed by a compact
+// float payload).
+const CBETypeDecimalFloat = 0x65
+
+// Appends a complete CBE decimal float (type code and compact float payload)
+// to dst, returning the extended slice.
+func AppendCBE(dst []byte, value DFloat) []byte {
+	dst = append(dst, CBETypeDecimalFloat)
+	return AppendEncode(dst, value)
+}
+
+// Decodes the compact float payload of a CBE decimal float from data (which
+// starts just after the type code). Any valid encoding is accepted, including
+// non-canonical ones. If data ends before the payload does, the error is
+// ErrorIncomplete, and CBEPayloadLength() can be used to find out how many
+// bytes are needed.
+// bigValue will be nil unless the decoded value is too big to fit into a DFloat.
+func DecodeCBEPayload(data []byte) (value DFloat, bigValue *BigDecimal, bytesDecoded int, err error) {
+	return DecodeFromBytes(data)
+}
+
+// Returns the length of the compact float payload at the start of prefix
+// (which starts just after the type code), without decoding it. complete is
+// false if prefix doesn't contain enough of the payload to tell, in which case
+// length is the minimum number of bytes needed to find out more.
+func CBEPayloadLength(prefix []byte) (length int, complete bool) {
+	if length, complete = PeekEncodedSize(prefix); complete {
+		return
+	}
+	return len(prefix) + 1, false
+}
+
+// Appends the CTE text form of value to dst, returning the extended slice.
+// Finite values use the same format as String(), infinities are "@inf" and
+// "-@inf", and NaNs are "@nan" and "@snan" (CTE NaNs have no sign).
+func AppendCTE(dst []byte, value DFloat) []byte {
+	switch {
+	case value.IsNegativeInfinity():
+		return append(dst, "-@inf"...)
+	case value.IsInfinity():
+		return append(dst, "@inf"...)
+	case value.IsSignalingNan():
+		return append(dst, "@snan"...)
+	case value.IsNan():
+		return append(dst, "@nan"...)
+	}
+	return value.AppendText(dst, 'g')
+}
+
+// Returns the CTE text form of value (see AppendCTE()).
+func FormatCTE(value DFloat) string {
+	var buffer [32]byte
+	return string(AppendCTE(buffer[:0], value))
+}
+
+// Parses a CTE decimal float: an optional '-', digits, an optional fractional
+// part, and an optional exponent ('e' or 'E', optional sign, digits). Digits
+// may be separated by '_' (e.g. "1_000.5"). "@inf", "-@inf", "@nan" and "@snan"
+// are also accepted. As with DFloatFromString(), a value that had to be
+// rounded is returned rounded, along with RoundingError.
+func ParseCTE(text string) (DFloat, error) {
+	switch text {
+	case "@inf":
+		return dfloatInfinity, nil
+	case "-@inf":
+		return dfloatNegativeInfinity, nil
+	case "@nan":
+		return dfloatNaN, nil
+	case "@snan":
+		return dfloatSignalingNaN, nil
+	}
+
+	digits := make([]byte, 0, len(text))
+	index := 0
+	if index < len(text) && text[index] == '-' {
+		digits = append(digits, '-')
+		index++
+	}
+	var ok bool
+	if digits, index, ok = appendCTEDigits(digits, text, index); !ok {
+		return dfloatZero, ctePrefixError(text)
+	}
+	if index < len(text) && text[index] == '.' {
+		digits = append(digits, '.')
+		if digits, index, ok = appendCTEDigits(digits, text, index+1); !ok {
+			return dfloatZero, ctePrefixError(text)
+		}
+	}
+	if index < len(text) && (text[index] == 'e' || text[index] == 'E') {
+		digits = append(digits, 'e')
+		index++
+		if index < len(text) && (text[index] == '+' || text[index] == '-') {
+			digits = append(digits, text[index])
+			index++
+		}
+		if digits, index, ok = appendCTEDigits(digits, text, index); !ok {
+			return dfloatZero, ctePrefixError(text)
+		}
+	}
+	if index != len(text) {
+		return dfloatZero, ctePrefixError(text)
+	}
+	return DFloatFromString(string(digits))
+}
+
+// Appends the run of digits (optionally separated by single '_' characters)
+// at text[index:], returning the index after it. ok is false if there are no
+// digits, or if a separator isn't between two digits.
+func appendCTEDigits(dst []byte, text string, index int) (result []byte, nextIndex int, ok bool) {
+	start := index
+	for index < len(text) {
+		c := text[index]
+		switch {
+		case c >= '0' && c <= '9':
+			dst = append(dst, c)
+		case c == '_' && index > start && index+1 < len(text) && text[index+1] >= '0' && text[index+1] <= '9':
+		default:
+			return dst, index, index > start
+		}
+		index++
+	}
+	return dst, index, index > start
+}
+
+func ctePrefixError(text string) error {
+	return fmt.Errorf("%w: %q is not a CTE decimal float", ErrorMalformed, text)
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCBERoundTrip(t *testing.T) {
+	for _, str := range []string{"0", "-0", "1.5", "-1.5e100", "inf", "-inf", "nan", "snan"} {
+		value, err := DFloatFromString(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded := AppendCBE(nil, value)
+		if encoded[0] != CBETypeDecimalFloat {
+			t.Errorf("%v: expected type code %02x but got %02x", str, CBETypeDecimalFloat, encoded[0])
+		}
+		length, complete := CBEPayloadLength(encoded[1:])
+		if !complete || length != len(encoded)-1 {
+			t.Errorf("%v: expected payload length %v but got %v (%v)", str, len(encoded)-1, length, complete)
+		}
+		decoded, _, count, err := DecodeCBEPayload(encoded[1:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != len(encoded)-1 || decoded.String() != value.String() {
+			t.Errorf("%v: decoded %v (%v bytes)", str, decoded, count)
+		}
+	}
+}
+
+func TestCBEPayloadIncomplete(t *testing.T) {
+	encoded := AppendCBE(nil, DFloatValue(-3, 1000000))
+	for i := 1; i < len(encoded)-1; i++ {
+		if _, complete := CBEPayloadLength(encoded[1 : i+1]); complete {
+			t.Errorf("%v bytes: expected incomplete", i)
+		}
+		if _, _, _, err := DecodeCBEPayload(encoded[1 : i+1]); !errors.Is(err, ErrorIncomplete) {
+			t.Errorf("%v bytes: expected ErrorIncomplete but got %v", i, err)
+		}
+	}
+	if _, complete := CBEPayloadLength(nil); complete {
+		t.Errorf("Expected empty payload to be incomplete")
+	}
+}
+
+func TestFormatCTE(t *testing.T) {
+	assertFormat := func(str string, expected string) {
+		value, err := DFloatFromString(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := FormatCTE(value); actual != expected {
+			t.Errorf("%v: expected %v but got %v", str, expected, actual)
+		}
+	}
+	assertFormat("1.5", "1.5")
+	assertFormat("-0", "-0")
+	assertFormat("inf", "@inf")
+	assertFormat("-inf", "-@inf")
+	assertFormat("nan", "@nan")
+	assertFormat("snan", "@snan")
+}
+
+func TestParseCTE(t *testing.T) {
+	assertParse := func(text string, expected string) {
+		value, err := ParseCTE(text)
+		if err != nil {
+			t.Errorf("%v: %v", text, err)
+			return
+		}
+		if actual := value.String(); actual != expected {
+			t.Errorf("%v: expected %v but got %v", text, expected, actual)
+		}
+	}
+	assertParse("1.5", "1.5")
+	assertParse("-1_000.000_5", "-1000.0005")
+	assertParse("1.5e+10", "1.5e+10")
+	assertParse("1.5E-10", "1.5e-10")
+	assertParse("-0", "-0")
+	assertParse("@inf", "Infinity")
+	assertParse("-@inf", "-Infinity")
+	assertParse("@nan", "NaN")
+	assertParse("@snan", "sNaN")
+
+	for _, text := range []string{"", "-", "+1", ".5", "5.", "1e", "1e+", "inf", "nan",
+		"0x10", "1__0", "_1", "1_", "1._5", "1.5_", "1e_5", "1.5 ", "@Inf"} {
+		if _, err := ParseCTE(text); !errors.Is(err, ErrorMalformed) {
+			t.Errorf("%q: expected ErrorMalformed but got %v", text, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The version of the compact float specification that this library implements.
+// https://github.com/kstenerud/compact-float/blob/master/compact-float-specification.md
+const SpecificationVersion = "1.0.0"
+
+// Returned in strict conformance mode (see WithStrictConformance()) when a value
+// or encoding is only valid as an extension to the specification.
+var ErrorNotConforming = errors.New("Compact float value is not part of the specification")
+
+// Checks that a value can be represented by the compact float specification
+// alone, without any of this library's extensions (negative NaNs), returning
+// ErrorNotConforming if it can't.
+func CheckConformance(value DFloat) error {
+	if value.IsNegativeNan() {
+		return fmt.Errorf("%w: %v has no encoding in compact float %v", ErrorNotConforming, value, SpecificationVersion)
+	}
+	return nil
+}
+
+// Returns the error (if any) to report for a decoded value in strict
+// conformance mode.
+func checkDecodedConformance(value DFloat, err error) error {
+	if err != nil {
+		return err
+	}
+	return CheckConformance(value)
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCheckConformance(t *testing.T) {
+	for _, value := range []DFloat{Zero(), NegativeZero(), Infinity(), NegativeInfinity(),
+		QuietNaN(), SignalingNaN(), DFloatValue(-3, 1500)} {
+		if err := CheckConformance(value); err != nil {
+			t.Errorf("%v: %v", value, err)
+		}
+	}
+	for _, value := range []DFloat{dfloatNegativeNaN, dfloatNegativeSignalingNaN} {
+		if err := CheckConformance(value); !errors.Is(err, ErrorNotConforming) {
+			t.Errorf("%v: expected ErrorNotConforming but got %v", value, err)
+		}
+	}
+}
+
+func TestCodecStrictConformance(t *testing.T) {
+	lenient := NewCodec()
+	strict := NewCodec(WithStrictConformance(true))
+	for _, encoded := range [][]byte{{0x84, 0x00}, {0x85, 0x00}} {
+		if _, _, _, err := lenient.DecodeFromBytes(encoded); err != nil {
+			t.Errorf("%x: %v", encoded, err)
+		}
+		if _, _, _, err := strict.DecodeFromBytes(encoded); !errors.Is(err, ErrorNotConforming) {
+			t.Errorf("%x: expected ErrorNotConforming but got %v", encoded, err)
+		}
+		if _, _, _, err := strict.Decode(bytes.NewBuffer(encoded)); !errors.Is(err, ErrorNotConforming) {
+			t.Errorf("%x: expected ErrorNotConforming but got %v", encoded, err)
+		}
+	}
+	if _, _, _, err := strict.DecodeFromBytes([]byte{0x80, 0x00}); err != nil {
+		t.Errorf("Expected NaN to conform but got %v", err)
+	}
+
+	buffer := &bytes.Buffer{}
+	if _, err := strict.Encode(dfloatNegativeNaN, buffer); !errors.Is(err, ErrorNotConforming) {
+		t.Errorf("Expected ErrorNotConforming but got %v", err)
+	}
+	if buffer.Len() != 0 {
+		t.Errorf("Expected nothing to be written but got %x", buffer.Bytes())
+	}
+}
+
+func TestDecoderStrictConformance(t *testing.T) {
+	encoded := []byte{0x02, 0x80, 0x00, 0x84, 0x00}
+	decoder := NewCodec(WithStrictConformance(true)).NewDecoder(bytes.NewBuffer(encoded))
+	count := 0
+	for decoder.Next() {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 values before failing but got %v", count)
+	}
+	if err := decoder.Err(); !errors.Is(err, ErrorNotConforming) {
+		t.Errorf("Expected ErrorNotConforming but got %v", err)
+	}
+}
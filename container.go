@@ -0,0 +1,194 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+// A container is a self-describing file of compact float values:
+//
+//     magic         4 bytes: "CFLT"
+//     version       ULEB128: format version (currently 1)
+//     count         ULEB128: number of values
+//     metadata      ULEB128 entry count, then each key and value as a
+//                   ULEB128 byte length followed by UTF-8 text, sorted by key
+//     values        count compact float values
+//     checksum      4 bytes: little endian CRC-32C of everything before it
+
+const ContainerVersion = 1
+
+var containerMagic = []byte{'C', 'F', 'L', 'T'}
+
+var (
+	ErrorNotContainer       = errors.New("Data is not a compact float container")
+	ErrorUnsupportedVersion = errors.New("Unsupported compact float container version")
+)
+
+// A collection of values with optional metadata (such as units or a source
+// description), stored by WriteContainer() and loaded by ReadContainer().
+type Container struct {
+	Metadata map[string]string
+	Values   []DFloat
+}
+
+// Writes a container holding the given values and metadata.
+func WriteContainer(container Container, writer io.Writer) (bytesEncoded int, err error) {
+	buffer := append([]byte(nil), containerMagic...)
+	buffer = appendULEB128(buffer, ContainerVersion)
+	buffer = appendULEB128(buffer, uint64(len(container.Values)))
+
+	keys := make([]string, 0, len(container.Metadata))
+	for key := range container.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	buffer = appendULEB128(buffer, uint64(len(keys)))
+	for _, key := range keys {
+		buffer = appendContainerString(buffer, key)
+		buffer = appendContainerString(buffer, container.Metadata[key])
+	}
+
+	for _, value := range container.Values {
+		buffer = AppendEncode(buffer, value)
+	}
+	buffer = appendCRC32C(buffer, crc32.Checksum(buffer, crc32cTable))
+	return writer.Write(buffer)
+}
+
+// Reads a container written by WriteContainer(). Reading stops at the end of
+// the checksum, so containers can be followed by other data.
+//
+// Fails with ErrorNotContainer if the data doesn't start with the container
+// magic bytes, ErrorUnsupportedVersion if it was written by a newer version
+// of the format, ErrorChecksumMismatch if the data is corrupt, and
+// ErrorValueTooLarge if a value doesn't fit into a DFloat.
+func ReadContainer(reader io.Reader) (container Container, bytesDecoded int, err error) {
+	checksum := crc32.New(crc32cTable)
+	containerReader := containerReader{reader: newCountingReader(io.TeeReader(reader, checksum))}
+	defer func() {
+		bytesDecoded = containerReader.reader.bytesRead
+	}()
+
+	magic := make([]byte, len(containerMagic))
+	if _, err = io.ReadFull(&containerReader.reader, magic); err != nil {
+		err = incompleteIfTruncated(err, 1)
+		return
+	}
+	if !bytes.Equal(magic, containerMagic) {
+		err = fmt.Errorf("%w: magic bytes are %x", ErrorNotContainer, magic)
+		return
+	}
+
+	version, err := containerReader.readLength()
+	if err != nil {
+		return
+	}
+	if version != ContainerVersion {
+		err = fmt.Errorf("%w: %v", ErrorUnsupportedVersion, version)
+		return
+	}
+
+	count, err := containerReader.readLength()
+	if err != nil {
+		return
+	}
+
+	metadataCount, err := containerReader.readLength()
+	if err != nil {
+		return
+	}
+	if metadataCount > 0 {
+		container.Metadata = make(map[string]string)
+	}
+	for i := 0; i < metadataCount; i++ {
+		var key, value string
+		if key, err = containerReader.readString(); err != nil {
+			return
+		}
+		if value, err = containerReader.readString(); err != nil {
+			return
+		}
+		container.Metadata[key] = value
+	}
+
+	// Don't trust count for the initial allocation, since it may be corrupt.
+	const maxInitialValues = 1024
+	initialCapacity := count
+	if initialCapacity > maxInitialValues {
+		initialCapacity = maxInitialValues
+	}
+	container.Values = make([]DFloat, 0, initialCapacity)
+	for i := 0; i < count; i++ {
+		var value DFloat
+		if value, _, err = DecodeSmall(&containerReader.reader); err != nil {
+			err = incompleteIfTruncated(err, 1)
+			return
+		}
+		container.Values = append(container.Values, value)
+	}
+
+	err = verifyCRC32C(&containerReader.reader, containerReader.reader.bytesRead, checksum.Sum32())
+	return
+}
+
+type containerReader struct {
+	reader countingReader
+	buffer [1]byte
+}
+
+func (this *containerReader) readLength() (length int, err error) {
+	asUint, asBig, _, err := decodeULEB128(&this.reader, this.buffer[:])
+	if err != nil {
+		err = incompleteIfTruncated(err, 1)
+		return
+	}
+	if asBig != nil || asUint > uint64(maxInt) {
+		err = fmt.Errorf("%w: Container length field is too big", ErrorMalformed)
+		return
+	}
+	return int(asUint), nil
+}
+
+func (this *containerReader) readString() (str string, err error) {
+	length, err := this.readLength()
+	if err != nil {
+		return
+	}
+	// Copy rather than allocating length up front, so that a corrupt length
+	// can't trigger a huge allocation.
+	var builder bytes.Buffer
+	if _, err = io.CopyN(&builder, &this.reader, int64(length)); err != nil {
+		err = incompleteIfTruncated(err, 1)
+		return
+	}
+	return builder.String(), nil
+}
+
+func appendContainerString(dst []byte, str string) []byte {
+	dst = appendULEB128(dst, uint64(len(str)))
+	return append(dst, str...)
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func assertContainerRoundTrip(t *testing.T, container Container) {
+	buffer := &bytes.Buffer{}
+	bytesEncoded, err := WriteContainer(container, buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffer.WriteString("trailing")
+
+	actual, bytesDecoded, err := ReadContainer(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytesDecoded != bytesEncoded {
+		t.Errorf("Expected to decode %v bytes but got %v", bytesEncoded, bytesDecoded)
+	}
+	if len(container.Values) == 0 {
+		container.Values = []DFloat{}
+	}
+	if !reflect.DeepEqual(actual, container) {
+		t.Errorf("Expected %+v but got %+v", container, actual)
+	}
+	if buffer.String() != "trailing" {
+		t.Errorf("Expected trailing data to be left unread but got %q", buffer.String())
+	}
+}
+
+func TestContainerRoundTrip(t *testing.T) {
+	assertContainerRoundTrip(t, Container{})
+	assertContainerRoundTrip(t, Container{
+		Values: []DFloat{DFloatValue(-2, 1050), QuietNaN(), NegativeZero(), DFloatValue(100, -1)},
+	})
+	assertContainerRoundTrip(t, Container{
+		Metadata: map[string]string{"unit": "°C", "source": "sensor 7", "": ""},
+		Values:   []DFloat{DFloatValue(-1, 215)},
+	})
+}
+
+func TestContainerEncoding(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	WriteContainer(Container{Metadata: map[string]string{"b": "2", "a": "1"}, Values: []DFloat{DFloatValue(0, 1)}}, buffer)
+	expected := []byte{'C', 'F', 'L', 'T', 0x01, 0x01, 0x02, 0x01, 'a', 0x01, '1', 0x01, 'b', 0x01, '2', 0x00, 0x01}
+	if encoded := buffer.Bytes(); !bytes.Equal(encoded[:len(encoded)-4], expected) {
+		t.Errorf("Expected %x but got %x", expected, encoded)
+	}
+}
+
+func TestContainerErrors(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	WriteContainer(Container{Metadata: map[string]string{"a": "b"}, Values: []DFloat{DFloatValue(-2, 1050), DFloatValue(3, 7)}}, buffer)
+	encoded := buffer.Bytes()
+
+	assertFails := func(data []byte, expected error) {
+		if _, _, err := ReadContainer(bytes.NewReader(data)); !errors.Is(err, expected) {
+			t.Errorf("%x: Expected %v but got %v", data, expected, err)
+		}
+	}
+
+	for length := 0; length < len(encoded); length++ {
+		assertFails(encoded[:length], ErrorIncomplete)
+	}
+
+	corrupt := append([]byte(nil), encoded...)
+	corrupt[len(corrupt)-6] ^= 1
+	assertFails(corrupt, ErrorChecksumMismatch)
+
+	corrupt = append([]byte(nil), encoded...)
+	corrupt[0] = 'X'
+	assertFails(corrupt, ErrorNotContainer)
+
+	corrupt = append([]byte(nil), encoded...)
+	corrupt[4] = 2
+	assertFails(corrupt, ErrorUnsupportedVersion)
+
+	assertFails([]byte{'C', 'F', 'L', 'T', 0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, ErrorMalformed)
+}
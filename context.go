@@ -0,0 +1,272 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Returned when a result is too small for a Context's MinExponent and its
+// Underflow policy is UnderflowError.
+var ErrorUnderflow = errors.New("Result is too small to be represented exactly at the minimum exponent")
+
+// Context holds settings that change how conversions to DFloat behave, in the
+// same spirit as apd.Context. The zero value behaves the same as the
+// package-level conversion functions. A Context can also be created on the fly
+// to change the behavior of a single conversion:
+//
+//	value, err := Context{ExponentOverflow: ExponentOverflowClamp}.FromString(str)
+type Context struct {
+	// What to do when a result's exponent is outside of the DFloat range.
+	ExponentOverflow ExponentOverflowPolicy
+	// What to do when a result's exponent is below MinExponent.
+	Underflow UnderflowPolicy
+	// The smallest exponent a result may have when Underflow isn't
+	// UnderflowIgnore. This corresponds to IEEE 754's Etiny (the exponent of
+	// the smallest subnormal), so for example decimal64's exponent range is
+	// emulated with a MinExponent of -398.
+	MinExponent int32
+	// Also accept the Unicode spellings used by spreadsheets and scientific
+	// tools when parsing: "∞" for infinity, and the minus sign U+2212 ("−")
+	// for negative values and exponents.
+	AllowUnicode bool
+	// The rounding mode used when a conversion or UnderflowRound has to drop
+	// digits. "" selects RoundHalfEven. Note that RoundHalfUp rounds ties away
+	// from zero, as in apd. Conversions fail with ErrorUnknownRoundingMode if
+	// the mode is unknown.
+	Rounding RoundingMode
+}
+
+// What a conversion does when the result's exponent is below the context's
+// MinExponent.
+type UnderflowPolicy int
+
+const (
+	// Don't enforce MinExponent (the default).
+	UnderflowIgnore UnderflowPolicy = iota
+	// Round (using the context's Rounding mode) to MinExponent as IEEE 754
+	// does for subnormals. Results that are too small become zero (keeping
+	// their sign) unless the rounding mode rounds them away from zero. If any
+	// non-zero digits are dropped, RoundingError is returned along with the
+	// rounded value.
+	UnderflowRound
+	// Fail with ErrorUnderflow if any non-zero digits would be dropped.
+	UnderflowError
+)
+
+// What a conversion does when the result's exponent doesn't fit into a DFloat.
+type ExponentOverflowPolicy int
+
+const (
+	// Fail the conversion (the default).
+	ExponentOverflowError ExponentOverflowPolicy = iota
+	// Clamp the result to infinity (when too large) or zero (when too small),
+	// keeping its sign as IEEE 754 does, and return RoundingError along with
+	// the clamped value.
+	ExponentOverflowClamp
+)
+
+// Convert a string float representation to DFloat (see DFloatFromString()).
+func (this Context) FromString(str string) (DFloat, error) {
+	return this.fromString(str, this.parseConfig())
+}
+
+// Convert a string float representation to DFloat, with the specified number
+// of significant digits (see DFloatFromStringWithDigits()).
+func (this Context) FromStringWithDigits(str string, significantDigits int) (DFloat, error) {
+	config := this.parseConfig()
+	config.significantDigits = significantDigits
+	return this.fromString(str, config)
+}
+
+// Convert an iee754 binary floating point value to DFloat, with the specified
+// number of significant digits (see DFloatFromFloat64()).
+func (this Context) FromFloat64(value float64, significantDigits int) (DFloat, error) {
+	if this.Underflow != UnderflowIgnore && !math.IsNaN(value) && !math.IsInf(value, 0) {
+		// Go through the shortest decimal text so that the significant
+		// digits and MinExponent are applied in a single rounding.
+		config := this.parseConfig()
+		config.significantDigits = significantDigits
+		return this.fromString(strconv.FormatFloat(value, 'g', -1, 64), config)
+	}
+	return this.apply(DFloatFromFloat64WithMode(value, significantDigits, this.rounding()))
+}
+
+// Parse a string float representation to DFloat, rejecting degenerate input
+// (see Parse()).
+func (this Context) Parse(str string) (DFloat, error) {
+	config := this.parseConfig()
+	config.strict = true
+	return this.fromString(str, config)
+}
+
+// Applies this context's limits to an existing value, such as the result of
+// arithmetic. Values within the limits are returned unchanged.
+func (this Context) Round(value DFloat) (DFloat, error) {
+	if err := this.checkRounding(); err != nil {
+		return value, err
+	}
+	if this.Underflow == UnderflowIgnore || value.IsZero() || value.IsSpecial() || value.Exponent >= this.MinExponent {
+		return value, nil
+	}
+
+	isNegative := value.Coefficient < 0
+	magnitude := uint64(value.Coefficient)
+	if isNegative {
+		magnitude = -magnitude
+	}
+	dropped := int64(this.MinExponent) - int64(value.Exponent)
+	// When every digit is dropped, the remainder is less than half of the
+	// divisor, which is all that matters when deciding whether to round away.
+	quotient, remainder, divisor := uint64(0), magnitude, uint64(10)
+	if dropped < int64(len(exponentMultipliers)) {
+		divisor = exponentMultipliers[dropped]
+		quotient, remainder = magnitude/divisor, magnitude%divisor
+	} else {
+		remainder = 1
+	}
+	roundedAway := remainder != 0 && shouldRoundAway(quotient, remainder, divisor, isNegative, this.rounding())
+	if remainder == 0 {
+		return DFloat{Exponent: this.MinExponent, Coefficient: int64(quotient)}.withSign(isNegative), nil
+	}
+	if this.Underflow == UnderflowError {
+		return dfloatZero, fmt.Errorf("%w: %v with a minimum exponent of %v", ErrorUnderflow, value, this.MinExponent)
+	}
+	if roundedAway {
+		quotient++
+	}
+	rounded := DFloat{Exponent: this.MinExponent, Coefficient: int64(quotient)}.withSign(isNegative)
+	return rounded, newRoundingError(value.String(), int(dropped), roundedAway)
+}
+
+// Parses str and applies this context's limits to it. When MinExponent drops
+// more digits than config's significant digits would, str is rounded once at
+// MinExponent rather than being rounded to significant digits and then again
+// at MinExponent.
+func (this Context) fromString(str string, config parseConfig) (DFloat, error) {
+	if err := this.checkRounding(); err != nil {
+		return dfloatZero, err
+	}
+	value, err := decodeFromString(str, config)
+	if this.Underflow == UnderflowIgnore || (err != nil && !errors.Is(err, roundingError)) ||
+		value.IsZero() || value.IsSpecial() || value.Exponent >= this.MinExponent {
+		return this.apply(value, err)
+	}
+
+	// Truncating to one digit gives the exponent of str's leading digit
+	// (truncation can't carry into the next digit).
+	leadingConfig := config
+	leadingConfig.significantDigits = 1
+	leadingConfig.rounding = RoundDown
+	leading, leadingErr := decodeFromString(str, leadingConfig)
+	keptDigits := int64(leading.Exponent) - int64(this.MinExponent) + 1
+	if keptDigits > 0 {
+		config.significantDigits = int(keptDigits)
+		value, err = decodeFromString(str, config)
+		if err != nil && this.Underflow == UnderflowError {
+			return dfloatZero, fmt.Errorf("%w: %v with a minimum exponent of %v", ErrorUnderflow, str, this.MinExponent)
+		}
+		return value, err
+	}
+
+	if this.Underflow == UnderflowError {
+		return dfloatZero, fmt.Errorf("%w: %v with a minimum exponent of %v", ErrorUnderflow, str, this.MinExponent)
+	}
+	// Every digit is below MinExponent, so the result is either zero or one
+	// unit at MinExponent. Only the leading digit (when it's right below
+	// MinExponent) and whether anything follows it decide which.
+	isNegative := leading.Coefficient < 0
+	remainder := uint64(1)
+	if keptDigits == 0 {
+		remainder = uint64(leading.Coefficient)
+		if isNegative {
+			remainder = -remainder
+		}
+		if remainder == 5 && errors.Is(leadingErr, roundingError) {
+			remainder = 6
+		}
+	}
+	roundedAway := shouldRoundAway(0, remainder, 10, isNegative, this.rounding())
+	quotient := int64(0)
+	if roundedAway {
+		quotient = 1
+	}
+	digitsDropped := int(int64(this.MinExponent) - int64(value.Exponent))
+	var details *RoundingErrorDetails
+	if errors.As(err, &details) {
+		digitsDropped += details.DigitsDropped
+	}
+	rounded := DFloat{Exponent: this.MinExponent, Coefficient: quotient}.withSign(isNegative)
+	return rounded, newRoundingError(str, digitsDropped, roundedAway)
+}
+
+// Applies this context's limits to the result of a conversion.
+func (this Context) apply(value DFloat, err error) (DFloat, error) {
+	if err != nil && !errors.Is(err, roundingError) {
+		return value, err
+	}
+	rounded, roundErr := this.Round(value)
+	if roundErr != nil {
+		return rounded, roundErr
+	}
+	return rounded, err
+}
+
+func (this Context) parseConfig() parseConfig {
+	return parseConfig{
+		clampExponent: this.ExponentOverflow == ExponentOverflowClamp,
+		allowUnicode:  this.AllowUnicode,
+		rounding:      this.rounding(),
+	}
+}
+
+// Returns this context's rounding mode (which checkRounding() has validated).
+func (this Context) rounding() RoundingMode {
+	if this.Rounding == "" {
+		return RoundHalfEven
+	}
+	return this.Rounding
+}
+
+// Fails with ErrorUnknownRoundingMode if this context's rounding mode is
+// unknown.
+func (this Context) checkRounding() error {
+	if this.Rounding != "" && !isKnownRoundingMode(this.Rounding) {
+		return fmt.Errorf("%w: %q", ErrorUnknownRoundingMode, this.Rounding)
+	}
+	return nil
+}
+
+// Returns the value that an out of range result clamps to.
+func clampedExponent(isOverflow bool, isNegative bool) DFloat {
+	switch {
+	case isOverflow && isNegative:
+		return dfloatNegativeInfinity
+	case isOverflow:
+		return dfloatInfinity
+	case isNegative:
+		return dfloatNegativeZero
+	}
+	return dfloatZero
+}
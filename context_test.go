@@ -0,0 +1,209 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestContextExponentOverflowClamp(t *testing.T) {
+	clamp := Context{ExponentOverflow: ExponentOverflowClamp}
+	assertClamped := func(str string, expected DFloat, expectedDirection RoundingDirection) {
+		value, err := clamp.FromString(str)
+		if value != expected {
+			t.Errorf("%v: expected %v but got %v", str, expected, value)
+		}
+		var details *RoundingErrorDetails
+		if !errors.As(err, &details) || details.Direction != expectedDirection {
+			t.Errorf("%v: expected RoundingError %v but got %v", str, expectedDirection, err)
+		}
+		if _, err = DFloatFromString(str); err == nil || errors.Is(err, RoundingError()) {
+			t.Errorf("%v: expected the default context to fail but got %v", str, err)
+		}
+	}
+	assertClamped("1e2147483667", Infinity(), RoundedAwayFromZero)
+	assertClamped("-1.5e3000000000", NegativeInfinity(), RoundedAwayFromZero)
+	assertClamped("1e99999999999999999999", Infinity(), RoundedAwayFromZero)
+	assertClamped("1.5e-2147483647", Zero(), RoundedTowardZero)
+	assertClamped("-1e-99999999999999999999", NegativeZero(), RoundedTowardZero)
+
+	for _, str := range []string{"1e2147483647", "1000e-2147483650", "inf"} {
+		expected, expectedErr := DFloatFromString(str)
+		value, err := clamp.FromString(str)
+		if value != expected || err != expectedErr {
+			t.Errorf("%v: expected %v (%v) but got %v (%v)", str, expected, expectedErr, value, err)
+		}
+	}
+
+	value, err := clamp.FromStringWithDigits("1.25e-2147483648", 2)
+	if value != Zero() || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected clamping to zero but got %v (%v)", value, err)
+	}
+}
+
+func TestContextUnderflow(t *testing.T) {
+	decimal64 := Context{Underflow: UnderflowRound, MinExponent: -398}
+	assertRound := func(value DFloat, expected DFloat, expectRounding bool) {
+		actual, err := decimal64.Round(value)
+		if actual != expected {
+			t.Errorf("%v: expected %v but got %v", value, expected, actual)
+		}
+		if expectRounding != errors.Is(err, RoundingError()) || (err != nil && !expectRounding) {
+			t.Errorf("%v: unexpected error %v", value, err)
+		}
+	}
+	assertRound(DFloatValue(-398, 15), DFloatValue(-398, 15), false)
+	assertRound(DFloatValue(-10, 15), DFloatValue(-10, 15), false)
+	assertRound(DFloatValue(-400, 1500), DFloatValue(-398, 15), false)
+	assertRound(DFloatValue(-400, 1550), DFloatValue(-398, 16), true)
+	assertRound(DFloatValue(-400, 1250), DFloatValue(-398, 12), true)
+	assertRound(DFloatValue(-400, -1251), DFloatValue(-398, -13), true)
+	assertRound(DFloatValue(-399, 5), Zero(), true)
+	assertRound(DFloatValue(-399, 6), DFloatValue(-398, 1), true)
+	assertRound(DFloatValue(-500, -7), NegativeZero(), true)
+	assertRound(DFloatValue(-402, 9223372036854775807), DFloatValue(-398, 922337203685478), true)
+	assertRound(DFloatValue(-420, 9223372036854775807), Zero(), true)
+	assertRound(NegativeZero(), NegativeZero(), false)
+	assertRound(Infinity(), Infinity(), false)
+
+	if value, err := (Context{MinExponent: -398}).Round(DFloatValue(-500, 7)); value != DFloatValue(-500, 7) || err != nil {
+		t.Errorf("Expected UnderflowIgnore to leave the value alone but got %v (%v)", value, err)
+	}
+
+	strict := Context{Underflow: UnderflowError, MinExponent: -2}
+	if value, err := strict.Round(DFloatValue(-4, 1200)); value != DFloatValue(-2, 12) || err != nil {
+		t.Errorf("Expected 0.12 but got %v (%v)", value, err)
+	}
+	if _, err := strict.Round(DFloatValue(-3, 125)); !errors.Is(err, ErrorUnderflow) {
+		t.Errorf("Expected ErrorUnderflow but got %v", err)
+	}
+
+	if value, err := decimal64.FromString("1.5e-398"); value != DFloatValue(-398, 2) || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected 2e-398 but got %v (%v)", value, err)
+	}
+	if _, err := strict.FromString("1.255"); !errors.Is(err, ErrorUnderflow) {
+		t.Errorf("Expected ErrorUnderflow but got %v", err)
+	}
+	if value, err := strict.FromStringWithDigits("1.255", 3); value != DFloatValue(-2, 126) || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected 1.26 but got %v (%v)", value, err)
+	}
+	if _, err := strict.FromString("1.25e-500"); !errors.Is(err, ErrorUnderflow) {
+		t.Errorf("Expected ErrorUnderflow but got %v", err)
+	}
+
+	// Rounded once at the minimum exponent, rather than to 2 digits (0.015)
+	// and then again (0.02).
+	round := Context{Underflow: UnderflowRound, MinExponent: -2}
+	var details *RoundingErrorDetails
+	value, err := round.FromStringWithDigits("0.0149", 2)
+	if value != DFloatValue(-2, 1) || !errors.As(err, &details) || details.Original != "0.0149" {
+		t.Errorf("Expected 0.01 rounded from 0.0149 but got %v (%v)", value, err)
+	}
+	if value, err := round.FromString("0.00500000000000000000001"); value != DFloatValue(-2, 1) || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected 0.01 but got %v (%v)", value, err)
+	}
+	if value, err := round.FromString("-0.005"); value != NegativeZero() || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected -0 but got %v (%v)", value, err)
+	}
+	if value, err := round.FromString("0.0004999999999999999999999"); value != Zero() || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected 0 but got %v (%v)", value, err)
+	}
+	if value, err := (Context{Underflow: UnderflowRound, MinExponent: -2, Rounding: "half_up"}).FromString("0.004999999999999999999999"); value != Zero() || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected 0 but got %v (%v)", value, err)
+	}
+	if value, err := round.FromFloat64(0.0149, 2); value != DFloatValue(-2, 1) || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected 0.01 but got %v (%v)", value, err)
+	}
+
+	if _, err := strict.FromString("x"); err == nil || errors.Is(err, ErrorUnderflow) {
+		t.Errorf("Expected a parse error but got %v", err)
+	}
+}
+
+func TestContextAllowUnicode(t *testing.T) {
+	unicode := Context{AllowUnicode: true}
+	assertParse := func(str string, expected string) {
+		value, err := unicode.Parse(str)
+		if err != nil {
+			t.Errorf("%q: %v", str, err)
+			return
+		}
+		if value.String() != expected {
+			t.Errorf("%q: expected %v but got %v", str, expected, value)
+		}
+		if _, err = Parse(str); !errors.Is(err, ErrorSyntax) {
+			t.Errorf("%q: expected the default context to fail with ErrorSyntax but got %v", str, err)
+		}
+	}
+	assertParse("∞", "Infinity")
+	assertParse("+∞", "Infinity")
+	assertParse("−∞", "-Infinity")
+	assertParse("−1.5", "-1.5")
+	assertParse("1.5e−3", "0.0015")
+	assertParse("−1.5E−3", "-0.0015")
+
+	for _, str := range []string{"INFINITY", "-Infinity", "-iNf"} {
+		if _, err := Parse(str); err != nil {
+			t.Errorf("%q: %v", str, err)
+		}
+	}
+	for _, str := range []string{"∞∞", "1∞", "−", "1−5"} {
+		if value, err := unicode.Parse(str); !errors.Is(err, ErrorSyntax) {
+			t.Errorf("Expected %q to fail with ErrorSyntax but got %v (%v)", str, value, err)
+		}
+	}
+}
+
+func TestContextRounding(t *testing.T) {
+	halfUp := Context{Rounding: "half_up"}
+	if value, err := halfUp.FromStringWithDigits("2.5", 1); value != DFloatValue(0, 3) || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected 3 but got %v (%v)", value, err)
+	}
+	if value, err := halfUp.FromFloat64(-0.125, 2); value != DFloatValue(-2, -13) || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected -0.13 but got %v (%v)", value, err)
+	}
+	if value, err := (Context{}).FromFloat64(-0.125, 2); value != DFloatValue(-2, -12) || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected -0.12 but got %v (%v)", value, err)
+	}
+
+	ceiling := Context{Rounding: "ceiling", Underflow: UnderflowRound, MinExponent: -2}
+	if value, err := ceiling.Round(DFloatValue(-3, 121)); value != DFloatValue(-2, 13) || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected 0.13 but got %v (%v)", value, err)
+	}
+	if value, err := ceiling.Round(DFloatValue(-100, 1)); value != DFloatValue(-2, 1) || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected 0.01 but got %v (%v)", value, err)
+	}
+	if value, err := ceiling.Round(DFloatValue(-100, -1)); value != NegativeZero() || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected -0 but got %v (%v)", value, err)
+	}
+
+	sideways := Context{Rounding: "sideways"}
+	if _, err := sideways.FromString("1"); !errors.Is(err, ErrorUnknownRoundingMode) {
+		t.Errorf("FromString: Expected ErrorUnknownRoundingMode but got %v", err)
+	}
+	if _, err := sideways.FromFloat64(1, 0); !errors.Is(err, ErrorUnknownRoundingMode) {
+		t.Errorf("FromFloat64: Expected ErrorUnknownRoundingMode but got %v", err)
+	}
+	if _, err := sideways.Round(DFloatValue(-3, 1)); !errors.Is(err, ErrorUnknownRoundingMode) {
+		t.Errorf("Round: Expected ErrorUnknownRoundingMode but got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// Parses a column of decimal strings. errors is nil if every value parsed
+// successfully, and otherwise has an entry for each value (nil for those that
+// parsed). As with DFloatFromString(), a value that had to be rounded is
+// stored rounded and its error is RoundingError.
+func ParseColumn(column []string) (values []DFloat, errors []error) {
+	values = make([]DFloat, len(column))
+	for i, str := range column {
+		value, err := DFloatFromString(str)
+		values[i] = value
+		if err != nil {
+			if errors == nil {
+				errors = make([]error, len(column))
+			}
+			errors[i] = err
+		}
+	}
+	return
+}
+
+// Options for FormatColumn() and DecodeCSVColumn().
+type ColumnFormatOpts struct {
+	// The format to use, as in DFloat.Text(). 0 selects 'g'.
+	Format byte
+
+	// How to render zeros, as in DFloat.TextWithZeroFormat(). nil renders
+	// them as in DFloat.Text().
+	Zero *ZeroFormat
+}
+
+// Appends the text of value to dst as specified by opts and format.
+func (this ColumnFormatOpts) appendText(dst []byte, value DFloat, format byte) []byte {
+	if this.Zero != nil {
+		return value.AppendTextWithZeroFormat(dst, format, *this.Zero)
+	}
+	return value.AppendText(dst, format)
+}
+
+// Formats a column of values as strings. All of the strings share a single
+// backing allocation.
+func FormatColumn(values []DFloat, opts ColumnFormatOpts) []string {
+	format := opts.Format
+	if format == 0 {
+		format = 'g'
+	}
+
+	ends := make([]int, len(values))
+	var text []byte
+	for i, value := range values {
+		text = opts.appendText(text, value, format)
+		ends[i] = len(text)
+	}
+	joined := string(text)
+
+	column := make([]string, len(values))
+	start := 0
+	for i, end := range ends {
+		column[i] = joined[start:end]
+		start = end
+	}
+	return column
+}
+
+// Reads the remaining records from reader, encoding the field at index column
+// of each one to writer as a compact float. Any header row must be read
+// beforehand.
+//
+// Fails on the first field that can't be represented exactly (including
+// values that would be rounded), with an error that includes the row number
+// (counting from 1 at the first record read). Use ParseColumn() to handle
+// rounding differently.
+func EncodeCSVColumn(reader *csv.Reader, column int, writer io.Writer) (rowCount int, err error) {
+	var buffer []byte
+	for {
+		record, readErr := reader.Read()
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return rowCount, readErr
+		}
+		if column < 0 || column >= len(record) {
+			return rowCount, fmt.Errorf("row %v: column %v not found (record has %v fields)", rowCount+1, column, len(record))
+		}
+		value, parseErr := DFloatFromString(record[column])
+		if parseErr != nil {
+			return rowCount, fmt.Errorf("row %v: %w", rowCount+1, parseErr)
+		}
+		buffer = AppendEncode(buffer[:0], value)
+		if _, err = writer.Write(buffer); err != nil {
+			return
+		}
+		rowCount++
+	}
+	return
+}
+
+// Decodes compact float values from reader until it is exhausted, writing each
+// as a single-field record to writer. writer is flushed before returning.
+// Values too big to fit into a DFloat fail with ErrorValueTooLarge.
+func DecodeCSVColumn(reader io.Reader, writer *csv.Writer, opts ColumnFormatOpts) (rowCount int, err error) {
+	format := opts.Format
+	if format == 0 {
+		format = 'g'
+	}
+
+	decoder := NewDecoder(reader)
+	record := make([]string, 1)
+	var text []byte
+	for decoder.Next() {
+		value, bigValue := decoder.Value()
+		if bigValue != nil {
+			return rowCount, fmt.Errorf("row %v: %w", rowCount+1, ErrorValueTooLarge)
+		}
+		text = opts.appendText(text[:0], value, format)
+		record[0] = string(text)
+		if err = writer.Write(record); err != nil {
+			return
+		}
+		rowCount++
+	}
+	if err = decoder.Err(); err != nil {
+		return
+	}
+	writer.Flush()
+	err = writer.Error()
+	return
+}
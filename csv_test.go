@@ -0,0 +1,110 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseColumn(t *testing.T) {
+	values, errs := ParseColumn([]string{"1.5", "-0", "1e100", "NaN"})
+	if errs != nil {
+		t.Errorf("Expected no errors but got %v", errs)
+	}
+	expected := []DFloat{DFloatValue(-1, 15), NegativeZero(), DFloatValue(100, 1), QuietNaN()}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v but got %v", expected, values)
+	}
+
+	values, errs = ParseColumn([]string{"1", "x", "12345678901234567891"})
+	if len(errs) != 3 || errs[0] != nil || errs[1] == nil || !errors.Is(errs[2], RoundingError()) {
+		t.Errorf("Unexpected errors %v", errs)
+	}
+	if values[2] != DFloatValue(1, 1234567890123456789) {
+		t.Errorf("Expected the rounded value but got %v", values[2])
+	}
+
+	if values, errs = ParseColumn(nil); len(values) != 0 || errs != nil {
+		t.Errorf("Expected an empty result but got %v, %v", values, errs)
+	}
+}
+
+func TestFormatColumn(t *testing.T) {
+	values := []DFloat{DFloatValue(-1, 15), NegativeZero(), DFloatValue(10, 1), QuietNaN()}
+	if actual, expected := FormatColumn(values, ColumnFormatOpts{}), []string{"1.5", "-0", "1e+10", "NaN"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v but got %v", expected, actual)
+	}
+	if actual, expected := FormatColumn(values, ColumnFormatOpts{Format: 'f'}), []string{"1.5", "-0", "10000000000", "NaN"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v but got %v", expected, actual)
+	}
+	values = []DFloat{DFloatValue(-1, 15), NegativeZero(), Zero(), QuietNaN()}
+	if actual, expected := FormatColumn(values, ColumnFormatOpts{Format: 'e', Zero: &ZeroFormat{FractionDigits: 1}}), []string{"1.5e+0", "-0.0e+0", "0.0e+0", "NaN"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v but got %v", expected, actual)
+	}
+	if actual := FormatColumn(nil, ColumnFormatOpts{}); len(actual) != 0 {
+		t.Errorf("Expected an empty column but got %v", actual)
+	}
+}
+
+func TestCSVColumnRoundTrip(t *testing.T) {
+	reader := csv.NewReader(strings.NewReader("name,price\nwidget,1.50\ngadget,-0.07\nthing,1e3\n"))
+	if _, err := reader.Read(); err != nil {
+		t.Fatal(err)
+	}
+	encoded := &bytes.Buffer{}
+	rowCount, err := EncodeCSVColumn(reader, 1, encoded)
+	if err != nil || rowCount != 3 {
+		t.Fatalf("Expected 3 rows but got %v (%v)", rowCount, err)
+	}
+
+	output := &strings.Builder{}
+	rowCount, err = DecodeCSVColumn(encoded, csv.NewWriter(output), ColumnFormatOpts{Format: 'f'})
+	if err != nil || rowCount != 3 {
+		t.Fatalf("Expected 3 rows but got %v (%v)", rowCount, err)
+	}
+	if expected := "1.5\n-0.07\n1000\n"; output.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, output.String())
+	}
+}
+
+func TestEncodeCSVColumnErrors(t *testing.T) {
+	assertFails := func(data string, column int, expected string) {
+		_, err := EncodeCSVColumn(csv.NewReader(strings.NewReader(data)), column, &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q: Expected an error containing %q but got %v", data, expected, err)
+		}
+	}
+	assertFails("1\n2\nx\n", 0, "row 3")
+	assertFails("1\n12345678901234567891\n", 0, "row 2")
+	assertFails("1,2\n3\n", 1, "wrong number of fields")
+	assertFails("1\n", 1, "column 1 not found")
+}
+
+func TestDecodeCSVColumnErrors(t *testing.T) {
+	if _, err := DecodeCSVColumn(bytes.NewReader([]byte{0x02, 0x80}), csv.NewWriter(&bytes.Buffer{}), ColumnFormatOpts{}); !errors.Is(err, ErrorIncomplete) {
+		t.Errorf("Expected ErrorIncomplete but got %v", err)
+	}
+}
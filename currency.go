@@ -0,0 +1,218 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrorUnknownCurrency   = errors.New("Unknown ISO 4217 currency code")
+	ErrorNotFiniteCurrency = errors.New("Infinity and NaN cannot be formatted as a currency amount")
+	ErrorCurrencyTooLarge  = errors.New("Amount has too many digits to be formatted as a currency amount")
+)
+
+// The most integer digits that FormatCurrency() will produce, which keeps a
+// value such as 1e2000000000 from producing a huge string.
+const maxCurrencyIntegerDigits = 100
+
+type currencyInfo struct {
+	minorUnits int
+	symbol     string
+}
+
+// ISO 4217 minor units (the number of digits after the decimal point) for every
+// active code that has them, and the commonly used symbol where one is
+// unambiguous. Codes without minor units (such as the precious metals XAU and
+// XAG, or the fund code XDR) are not included.
+var currencies = map[string]currencyInfo{
+	"AED": {2, ""}, "AFN": {2, ""}, "ALL": {2, ""}, "AMD": {2, ""},
+	"ANG": {2, ""}, "AOA": {2, ""}, "ARS": {2, ""}, "AUD": {2, "A$"},
+	"AWG": {2, ""}, "AZN": {2, ""}, "BAM": {2, ""}, "BBD": {2, ""},
+	"BDT": {2, ""}, "BGN": {2, ""}, "BHD": {3, ""}, "BIF": {0, ""},
+	"BMD": {2, ""}, "BND": {2, ""}, "BOB": {2, ""}, "BOV": {2, ""},
+	"BRL": {2, "R$"}, "BSD": {2, ""}, "BTN": {2, ""}, "BWP": {2, ""},
+	"BYN": {2, ""}, "BZD": {2, ""}, "CAD": {2, "CA$"}, "CDF": {2, ""},
+	"CHE": {2, ""}, "CHF": {2, ""}, "CHW": {2, ""}, "CLF": {4, ""},
+	"CLP": {0, ""}, "CNY": {2, "CN¥"}, "COP": {2, ""}, "COU": {2, ""},
+	"CRC": {2, ""}, "CUP": {2, ""}, "CVE": {2, ""}, "CZK": {2, ""},
+	"DJF": {0, ""}, "DKK": {2, ""}, "DOP": {2, ""}, "DZD": {2, ""},
+	"EGP": {2, ""}, "ERN": {2, ""}, "ETB": {2, ""}, "EUR": {2, "€"},
+	"FJD": {2, ""}, "FKP": {2, ""}, "GBP": {2, "£"}, "GEL": {2, ""},
+	"GHS": {2, ""}, "GIP": {2, ""}, "GMD": {2, ""}, "GNF": {0, ""},
+	"GTQ": {2, ""}, "GYD": {2, ""}, "HKD": {2, "HK$"}, "HNL": {2, ""},
+	"HTG": {2, ""}, "HUF": {2, ""}, "IDR": {2, ""}, "ILS": {2, "₪"},
+	"INR": {2, "₹"}, "IQD": {3, ""}, "IRR": {2, ""}, "ISK": {0, ""},
+	"JMD": {2, ""}, "JOD": {3, ""}, "JPY": {0, "¥"}, "KES": {2, ""},
+	"KGS": {2, ""}, "KHR": {2, ""}, "KMF": {0, ""}, "KPW": {2, ""},
+	"KRW": {0, "₩"}, "KWD": {3, ""}, "KYD": {2, ""}, "KZT": {2, ""},
+	"LAK": {2, ""}, "LBP": {2, ""}, "LKR": {2, ""}, "LRD": {2, ""},
+	"LSL": {2, ""}, "LYD": {3, ""}, "MAD": {2, ""}, "MDL": {2, ""},
+	"MGA": {2, ""}, "MKD": {2, ""}, "MMK": {2, ""}, "MNT": {2, ""},
+	"MOP": {2, ""}, "MRU": {2, ""}, "MUR": {2, ""}, "MVR": {2, ""},
+	"MWK": {2, ""}, "MXN": {2, "MX$"}, "MXV": {2, ""}, "MYR": {2, ""},
+	"MZN": {2, ""}, "NAD": {2, ""}, "NGN": {2, "₦"}, "NIO": {2, ""},
+	"NOK": {2, ""}, "NPR": {2, ""}, "NZD": {2, "NZ$"}, "OMR": {3, ""},
+	"PAB": {2, ""}, "PEN": {2, ""}, "PGK": {2, ""}, "PHP": {2, "₱"},
+	"PKR": {2, ""}, "PLN": {2, ""}, "PYG": {0, ""}, "QAR": {2, ""},
+	"RON": {2, ""}, "RSD": {2, ""}, "RUB": {2, "₽"}, "RWF": {0, ""},
+	"SAR": {2, ""}, "SBD": {2, ""}, "SCR": {2, ""}, "SDG": {2, ""},
+	"SEK": {2, ""}, "SGD": {2, ""}, "SHP": {2, ""}, "SLE": {2, ""},
+	"SOS": {2, ""}, "SRD": {2, ""}, "SSP": {2, ""}, "STN": {2, ""},
+	"SVC": {2, ""}, "SYP": {2, ""}, "SZL": {2, ""}, "THB": {2, "฿"},
+	"TJS": {2, ""}, "TMT": {2, ""}, "TND": {3, ""}, "TOP": {2, ""},
+	"TRY": {2, "₺"}, "TTD": {2, ""}, "TWD": {2, "NT$"}, "TZS": {2, ""},
+	"UAH": {2, "₴"}, "UGX": {0, ""}, "USD": {2, "$"}, "USN": {2, ""},
+	"UYI": {0, ""}, "UYU": {2, ""}, "UYW": {4, ""}, "UZS": {2, ""},
+	"VED": {2, ""}, "VES": {2, ""}, "VND": {0, "₫"}, "VUV": {0, ""},
+	"WST": {2, ""}, "XAF": {0, ""}, "XCD": {2, ""}, "XCG": {2, ""},
+	"XOF": {0, ""}, "XPF": {0, ""}, "YER": {2, ""}, "ZAR": {2, ""},
+	"ZMW": {2, ""}, "ZWG": {2, ""},
+}
+
+// Returns the number of ISO 4217 minor unit digits for a currency code, and
+// whether the code is known. Codes are case insensitive.
+func CurrencyMinorUnits(code string) (minorUnits int, ok bool) {
+	info, ok := currencies[strings.ToUpper(code)]
+	return info.minorUnits, ok
+}
+
+// Options for FormatCurrency().
+type CurrencyOpts struct {
+	// Place the currency symbol before the amount (e.g. "€1.234,50") instead
+	// of the code after it (e.g. "1,234.50 EUR"). Currencies without a known
+	// symbol always use the code.
+	UseSymbol bool
+
+	// Digit group separator. 0 selects ','. Use -1 for no grouping.
+	GroupSeparator rune
+
+	// Decimal separator. 0 selects '.'.
+	DecimalSeparator rune
+}
+
+// Formats this value as an amount in the currency identified by an ISO 4217
+// code, rounded (half-to-even) to the currency's minor units. For example,
+// 1234.5 in USD formats as "1,234.50 USD", or as "€1.234,50" in EUR with
+// UseSymbol and swapped separators.
+//
+// A value that rounds to zero is formatted without a sign. Fails with
+// ErrorUnknownCurrency if the code is not known, with ErrorNotFiniteCurrency if
+// the value is infinity or NaN, and with ErrorCurrencyTooLarge if the amount
+// would have more than 100 integer digits.
+func (this DFloat) FormatCurrency(code string, opts CurrencyOpts) (string, error) {
+	code = strings.ToUpper(code)
+	info, ok := currencies[code]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrorUnknownCurrency, code)
+	}
+	if this.IsInfinity() || this.IsNan() {
+		return "", fmt.Errorf("%w: %v", ErrorNotFiniteCurrency, this)
+	}
+	if !this.IsZero() && int64(this.Exponent)+int64(decimalDigitCount(magnitudeInt64(this.Coefficient))) > maxCurrencyIntegerDigits {
+		return "", fmt.Errorf("%w: %v", ErrorCurrencyTooLarge, this)
+	}
+
+	groupSeparator, decimalSeparator := opts.GroupSeparator, opts.DecimalSeparator
+	if groupSeparator == 0 {
+		groupSeparator = ','
+	}
+	if decimalSeparator == 0 {
+		decimalSeparator = '.'
+	}
+
+	var digitsBuffer [40]byte
+	digits := appendMinorUnitDigits(digitsBuffer[:0], this, info.minorUnits)
+	for len(digits) <= info.minorUnits {
+		digits = append([]byte{'0'}, digits...)
+	}
+	integerDigits := digits[:len(digits)-info.minorUnits]
+
+	var builder strings.Builder
+	if this.Coefficient < 0 && !isAllZeros(digits) {
+		builder.WriteByte('-')
+	}
+	useSymbol := opts.UseSymbol && info.symbol != ""
+	if useSymbol {
+		builder.WriteString(info.symbol)
+	}
+	for i, digit := range integerDigits {
+		if i > 0 && (len(integerDigits)-i)%3 == 0 && groupSeparator >= 0 {
+			builder.WriteRune(groupSeparator)
+		}
+		builder.WriteByte(digit)
+	}
+	if info.minorUnits > 0 {
+		builder.WriteRune(decimalSeparator)
+		builder.Write(digits[len(integerDigits):])
+	}
+	if !useSymbol {
+		builder.WriteByte(' ')
+		builder.WriteString(code)
+	}
+	return builder.String(), nil
+}
+
+// Appends the digits of this value's magnitude scaled by 10^minorUnits and
+// rounded half-to-even to an integer. The caller must keep the value within
+// maxCurrencyIntegerDigits, since every trailing zero is appended.
+func appendMinorUnitDigits(dst []byte, value DFloat, minorUnits int) []byte {
+	if value.IsZero() {
+		return append(dst, '0')
+	}
+	magnitude := uint64(value.Coefficient)
+	if value.Coefficient < 0 {
+		magnitude = -magnitude
+	}
+	shift := int64(value.Exponent) + int64(minorUnits)
+	if shift >= 0 {
+		dst = strconv.AppendUint(dst, magnitude, 10)
+		for i := int64(0); i < shift; i++ {
+			dst = append(dst, '0')
+		}
+		return dst
+	}
+	if shift < -19 {
+		// The magnitude is below 10^19, so it's less than half a minor unit.
+		return append(dst, '0')
+	}
+	divisor := uint64(1)
+	for i := int64(0); i < -shift; i++ {
+		divisor *= 10
+	}
+	quotient, remainder := magnitude/divisor, magnitude%divisor
+	if remainder > divisor-remainder || (remainder == divisor-remainder && quotient&1 == 1) {
+		quotient++
+	}
+	return strconv.AppendUint(dst, quotient, 10)
+}
+
+func isAllZeros(digits []byte) bool {
+	for _, digit := range digits {
+		if digit != '0' {
+			return false
+		}
+	}
+	return true
+}
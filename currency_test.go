@@ -0,0 +1,107 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"testing"
+)
+
+func assertFormatCurrency(t *testing.T, str string, code string, opts CurrencyOpts, expected string) {
+	value, err := DFloatFromString(str)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	actual, err := value.FormatCurrency(code, opts)
+	if err != nil {
+		t.Errorf("%v %v: %v", str, code, err)
+		return
+	}
+	if actual != expected {
+		t.Errorf("%v %v: Expected [%v] but got [%v]", str, code, expected, actual)
+	}
+}
+
+func assertFormatCurrencyFails(t *testing.T, value DFloat, code string, expected error) {
+	if _, err := value.FormatCurrency(code, CurrencyOpts{}); !errors.Is(err, expected) {
+		t.Errorf("%v %v: Expected %v but got %v", value, code, expected, err)
+	}
+}
+
+func TestFormatCurrency(t *testing.T) {
+	european := CurrencyOpts{UseSymbol: true, GroupSeparator: '.', DecimalSeparator: ','}
+
+	assertFormatCurrency(t, "1234.5", "USD", CurrencyOpts{}, "1,234.50 USD")
+	assertFormatCurrency(t, "1234.5", "EUR", european, "€1.234,50")
+	assertFormatCurrency(t, "1234.5", "usd", CurrencyOpts{UseSymbol: true}, "$1,234.50")
+	assertFormatCurrency(t, "-1234567.891", "USD", CurrencyOpts{}, "-1,234,567.89 USD")
+	assertFormatCurrency(t, "-1234567.891", "USD", CurrencyOpts{UseSymbol: true}, "-$1,234,567.89")
+	assertFormatCurrency(t, "1234567", "USD", CurrencyOpts{GroupSeparator: -1}, "1234567.00 USD")
+	assertFormatCurrency(t, "0", "USD", CurrencyOpts{}, "0.00 USD")
+	assertFormatCurrency(t, "-0", "USD", CurrencyOpts{}, "0.00 USD")
+	assertFormatCurrency(t, "0.07", "USD", CurrencyOpts{}, "0.07 USD")
+	assertFormatCurrency(t, "1e5", "USD", CurrencyOpts{}, "100,000.00 USD")
+
+	// Minor units
+	assertFormatCurrency(t, "1234.5", "JPY", CurrencyOpts{}, "1,234 JPY")
+	assertFormatCurrency(t, "1235.5", "JPY", CurrencyOpts{UseSymbol: true}, "¥1,236")
+	assertFormatCurrency(t, "1.2345", "KWD", CurrencyOpts{}, "1.234 KWD")
+	assertFormatCurrency(t, "1.23456", "CLF", CurrencyOpts{}, "1.2346 CLF")
+
+	// Currencies without a symbol fall back to the code
+	assertFormatCurrency(t, "1234.5", "CHF", CurrencyOpts{UseSymbol: true, GroupSeparator: '\''}, "1'234.50 CHF")
+
+	// Half-to-even rounding
+	assertFormatCurrency(t, "0.125", "USD", CurrencyOpts{}, "0.12 USD")
+	assertFormatCurrency(t, "0.135", "USD", CurrencyOpts{}, "0.14 USD")
+	assertFormatCurrency(t, "0.1250001", "USD", CurrencyOpts{}, "0.13 USD")
+	assertFormatCurrency(t, "-0.004", "USD", CurrencyOpts{}, "0.00 USD")
+	assertFormatCurrency(t, "999999999999999999e-18", "USD", CurrencyOpts{}, "1.00 USD")
+	assertFormatCurrency(t, "9223372036854775807e-21", "USD", CurrencyOpts{}, "0.01 USD")
+	assertFormatCurrency(t, "9223372036854775807e-22", "USD", CurrencyOpts{}, "0.00 USD")
+	assertFormatCurrency(t, "1e-100", "USD", CurrencyOpts{}, "0.00 USD")
+}
+
+func TestFormatCurrencyFails(t *testing.T) {
+	assertFormatCurrencyFails(t, DFloatValue(0, 1), "XYZ", ErrorUnknownCurrency)
+	assertFormatCurrencyFails(t, Infinity(), "USD", ErrorNotFiniteCurrency)
+	assertFormatCurrencyFails(t, QuietNaN(), "USD", ErrorNotFiniteCurrency)
+	assertFormatCurrencyFails(t, DFloatValue(2000000000, 1), "USD", ErrorCurrencyTooLarge)
+	assertFormatCurrencyFails(t, DFloatValue(100, -1), "USD", ErrorCurrencyTooLarge)
+	if _, err := DFloatValue(99, 1).FormatCurrency("USD", CurrencyOpts{GroupSeparator: -1}); err != nil {
+		t.Errorf("Expected a 100 digit amount to format but got %v", err)
+	}
+}
+
+func TestCurrencyMinorUnits(t *testing.T) {
+	if units, ok := CurrencyMinorUnits("bhd"); !ok || units != 3 {
+		t.Errorf("Expected 3 minor units for BHD but got %v (%v)", units, ok)
+	}
+	for _, code := range []string{"UGX", "PYG", "RWF", "BIF", "KMF", "XPF", "VUV", "DJF"} {
+		if units, ok := CurrencyMinorUnits(code); !ok || units != 0 {
+			t.Errorf("Expected 0 minor units for %v but got %v (%v)", code, units, ok)
+		}
+	}
+	if _, ok := CurrencyMinorUnits("XYZ"); ok {
+		t.Errorf("Expected XYZ to be unknown")
+	}
+}
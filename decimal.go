@@ -0,0 +1,219 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Decimal is implemented by the decimal types that can be compact float
+// encoded (DFloat, DFloat128, and APDDecimal for apd.Decimal), so that code
+// handling all of them can go through a single code path rather than three.
+type Decimal interface {
+	// Returns this value's exponent and coefficient, storing the coefficient in
+	// dst (which is returned). Special values have an exponent of ExpSpecial
+	// and a coefficient of one of the CoeffXYZ codes, as in DFloat.
+	Unpack(dst *big.Int) (exponent int32, coefficient *big.Int)
+
+	// Returns the exact number of bytes that this value will encode to.
+	EncodedSize() int
+
+	// Encodes this value to buffer, which must be at least EncodedSize() bytes
+	// long.
+	EncodeToBytes(buffer []byte) (bytesEncoded int)
+}
+
+// A Decimal that decoded values can be stored into (usually a pointer to one
+// of the Decimal types).
+type MutableDecimal interface {
+	Decimal
+
+	// Sets this value from an exponent and coefficient in the form returned by
+	// Unpack(). If the coefficient is too big to fit, its lower significant
+	// digits will be rounded (half-to-even) and RoundingError will be returned
+	// along with the rounded value.
+	Pack(exponent int32, coefficient *big.Int) error
+}
+
+// Encodes any Decimal to a writer.
+func EncodeDecimal(value Decimal, writer io.Writer) (bytesEncoded int, err error) {
+	var buffer [MaxEncodedLength128]byte
+	encoded := buffer[:]
+	if size := value.EncodedSize(); size > len(encoded) {
+		encoded = make([]byte, size)
+	}
+	bytesEncoded = value.EncodeToBytes(encoded)
+	return writer.Write(encoded[:bytesEncoded])
+}
+
+// Appends the encoded form of any Decimal to dst, growing it as needed, and
+// returns the extended slice.
+func AppendEncodeDecimal(dst []byte, value Decimal) []byte {
+	dst, buffer := growForAppend(dst, value.EncodedSize())
+	bytesEncoded := value.EncodeToBytes(buffer)
+	return dst[:len(dst)+bytesEncoded]
+}
+
+// Decode a float from a reader into any MutableDecimal.
+// Returns io.EOF if the reader ends before the value begins, or
+// ErrorIncomplete if it ends partway through the value.
+func DecodeDecimal(reader io.Reader, dst MutableDecimal) (bytesDecoded int, err error) {
+	buffer := []byte{0}
+	exponentField, asBig, bytesDecoded, err := decodeULEB128Limited(reader, buffer, maxExponentFieldLength, ErrorExponentTooLarge)
+	if err != nil {
+		err = incompleteIfTruncated(err, bytesDecoded)
+		return
+	}
+	if asBig != nil {
+		err = fmt.Errorf("%w: %v", ErrorExponentTooLarge, asBig)
+		return
+	}
+	if special, isSpecial := decodeSpecialValue(exponentField, bytesDecoded); isSpecial {
+		err = dst.Pack(special.Exponent, big.NewInt(special.Coefficient))
+		return
+	}
+	exponent, isNegative, err := decodeExponentField(exponentField)
+	if err != nil {
+		return
+	}
+
+	offset := bytesDecoded
+	asUint, asBig, bytesDecoded, err := decodeULEB128Limited(reader, buffer, MaxCoefficientLength, ErrorCoefficientTooLong)
+	bytesDecoded += offset
+	if err != nil {
+		err = incompleteIfTruncated(err, offset)
+		return
+	}
+	err = packDecoded(dst, exponent, isNegative, asUint, asBig)
+	return
+}
+
+// Decode a float from a byte slice into any MutableDecimal.
+// Returns ErrorIncomplete if data ends before the value is complete.
+func DecodeDecimalFromBytes(data []byte, dst MutableDecimal) (bytesDecoded int, err error) {
+	if err = checkULEB128Length(data, maxExponentFieldLength, ErrorExponentTooLarge); err != nil {
+		return
+	}
+	exponentField, asBig, bytesDecoded, err := decodeULEB128FromBytes(data)
+	if err != nil {
+		return
+	}
+	if asBig != nil {
+		err = fmt.Errorf("%w: %v", ErrorExponentTooLarge, asBig)
+		return
+	}
+	if special, isSpecial := decodeSpecialValue(exponentField, bytesDecoded); isSpecial {
+		err = dst.Pack(special.Exponent, big.NewInt(special.Coefficient))
+		return
+	}
+	exponent, isNegative, err := decodeExponentField(exponentField)
+	if err != nil {
+		return
+	}
+
+	offset := bytesDecoded
+	if err = checkULEB128Length(data[offset:], MaxCoefficientLength, ErrorCoefficientTooLong); err != nil {
+		return
+	}
+	asUint, asBig, bytesDecoded, err := decodeULEB128FromBytes(data[offset:])
+	if err != nil {
+		return
+	}
+	bytesDecoded += offset
+	err = packDecoded(dst, exponent, isNegative, asUint, asBig)
+	return
+}
+
+func packDecoded(dst MutableDecimal, exponent int32, isNegative bool, asUint uint64, asBig *big.Int) error {
+	coefficient := asBig
+	if coefficient == nil {
+		coefficient = new(big.Int).SetUint64(asUint)
+	}
+	if isNegative {
+		coefficient.Neg(coefficient)
+	}
+	return dst.Pack(exponent, coefficient)
+}
+
+func (this DFloat) Unpack(dst *big.Int) (exponent int32, coefficient *big.Int) {
+	return this.Exponent, dst.SetInt64(this.Coefficient)
+}
+
+func (this DFloat) EncodedSize() int {
+	return EncodedSize(this)
+}
+
+func (this DFloat) EncodeToBytes(buffer []byte) (bytesEncoded int) {
+	return EncodeToBytes(this, buffer)
+}
+
+func (this *DFloat) Pack(exponent int32, coefficient *big.Int) (err error) {
+	if !coefficient.IsInt64() && exponent != ExpSpecial {
+		coefficient, exponent = stripTrailingZeros(coefficient, exponent)
+	}
+	if coefficient.IsInt64() {
+		*this = DFloat{Exponent: exponent, Coefficient: coefficient.Int64()}
+		return
+	}
+	*this, err = DFloatFromString(fmt.Sprintf("%ve%v", coefficient, exponent))
+	return
+}
+
+func (this DFloat128) Unpack(dst *big.Int) (exponent int32, coefficient *big.Int) {
+	high, low := this.Coefficient.magnitude()
+	dst.SetUint64(high)
+	dst.Lsh(dst, 64)
+	dst.Or(dst, new(big.Int).SetUint64(low))
+	if this.Coefficient.IsNegative() {
+		dst.Neg(dst)
+	}
+	return this.Exponent, dst
+}
+
+func (this DFloat128) EncodedSize() int {
+	return EncodedSizeDFloat128(this)
+}
+
+func (this DFloat128) EncodeToBytes(buffer []byte) (bytesEncoded int) {
+	return EncodeDFloat128ToBytes(this, buffer)
+}
+
+func (this *DFloat128) Pack(exponent int32, coefficient *big.Int) error {
+	magnitude := new(big.Int).Abs(coefficient)
+	rounded, roundedExponent, digitsDropped, roundedAway, err := roundInt128Magnitude(magnitude, exponent)
+	if err != nil {
+		return fmt.Errorf("%w: %ve%v", err, coefficient, exponent)
+	}
+	asInt128, err := Int128FromBigInt(rounded)
+	if err != nil {
+		return err
+	}
+	if coefficient.Sign() < 0 {
+		asInt128 = asInt128.Neg()
+	}
+	*this = DFloat128{Exponent: roundedExponent, Coefficient: asInt128}
+	if digitsDropped > 0 {
+		return newRoundingError(fmt.Sprintf("%ve%v", coefficient, exponent), digitsDropped, roundedAway)
+	}
+	return nil
+}
@@ -0,0 +1,354 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+package compact_float
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/cockroachdb/apd/v2"
+)
+
+// IEEE 754-2008 decimal128 values (binary integer decimal encoding) are
+// represented here as a high and low uint64 pair. This is the same layout used
+// by MongoDB's primitive.Decimal128, so conversion is a matter of:
+//
+//     high, low, err := value.Decimal128()
+//     d128 := primitive.NewDecimal128(high, low)
+//
+//     value, err := DFloatFromDecimal128(d128.GetBytes())
+
+const (
+	decimal128ExponentBias = 6176
+	decimal128MinExponent  = -6176
+	decimal128MaxExponent  = 6111
+	decimal128MaxDigits    = 34
+
+	decimal128SignBit      = uint64(1) << 63
+	decimal128SpecialMask  = uint64(0x1f) << 58
+	decimal128Infinity     = uint64(0x1e) << 58
+	decimal128NaN          = uint64(0x1f) << 58
+	decimal128SignalingBit = uint64(1) << 57
+	decimal128LargeForm    = uint64(3) << 61
+)
+
+var decimal128MaxCoefficient = new(big.Int).Sub(new(big.Int).Exp(big.NewInt(10), big.NewInt(decimal128MaxDigits), nil), big.NewInt(1))
+
+// Convert an IEEE 754-2008 decimal128 value (in BID format, split into high
+// and low 64-bit halves) to DFloat. If the value is too big to fit, its lower
+// significant digits will be rounded (half-to-even) and RoundingError will be
+// returned along with the rounded value.
+func DFloatFromDecimal128(high, low uint64) (DFloat, error) {
+	return DFloatFromAPD(APDFromDecimal128(high, low))
+}
+
+// Convert an IEEE 754-2008 decimal128 value (in BID format, split into high
+// and low 64-bit halves) to apd.Decimal. All decimal128 values can be
+// represented as apd.Decimal.
+func APDFromDecimal128(high, low uint64) *apd.Decimal {
+	value := apd.New(0, 0)
+	value.Negative = high&decimal128SignBit != 0
+
+	switch high & decimal128SpecialMask {
+	case decimal128Infinity:
+		value.Form = apd.Infinite
+		return value
+	case decimal128NaN:
+		if high&decimal128SignalingBit != 0 {
+			value.Form = apd.NaNSignaling
+		} else {
+			value.Form = apd.NaN
+		}
+		return value
+	}
+
+	var biasedExponent uint64
+	if high&decimal128LargeForm == decimal128LargeForm {
+		// The coefficient would be at least 2^113, which exceeds the maximum of
+		// 10^34-1. IEEE 754 specifies that such non-canonical values are zero.
+		biasedExponent = (high >> 47) & 0x3fff
+	} else {
+		biasedExponent = (high >> 49) & 0x3fff
+		coefficientHigh := high & 0x1ffffffffffff
+		value.Coeff.SetUint64(coefficientHigh)
+		value.Coeff.Lsh(&value.Coeff, 64)
+		value.Coeff.Or(&value.Coeff, new(big.Int).SetUint64(low))
+		if value.Coeff.Cmp(decimal128MaxCoefficient) > 0 {
+			value.Coeff.SetInt64(0)
+		}
+	}
+	value.Exponent = int32(biasedExponent) - decimal128ExponentBias
+	return value
+}
+
+// Convert an IEEE 754-2008 decimal128 value (in BID format, split into high
+// and low 64-bit halves) to DFloat128 without allocating. All decimal128 values
+// can be represented as DFloat128.
+func DFloat128FromDecimal128(high, low uint64) DFloat128 {
+	isNegative := high&decimal128SignBit != 0
+	switch high & decimal128SpecialMask {
+	case decimal128Infinity:
+		if isNegative {
+			return DFloat128FromDFloat(dfloatNegativeInfinity)
+		}
+		return DFloat128FromDFloat(dfloatInfinity)
+	case decimal128NaN:
+		switch {
+		case high&decimal128SignalingBit != 0 && isNegative:
+			return DFloat128FromDFloat(dfloatNegativeSignalingNaN)
+		case high&decimal128SignalingBit != 0:
+			return DFloat128FromDFloat(dfloatSignalingNaN)
+		case isNegative:
+			return DFloat128FromDFloat(dfloatNegativeNaN)
+		}
+		return DFloat128FromDFloat(dfloatNaN)
+	}
+
+	var biasedExponent uint64
+	var coefficientHigh uint64
+	if high&decimal128LargeForm == decimal128LargeForm {
+		// Non-canonical coefficients (>= 2^113) are zero.
+		biasedExponent = (high >> 47) & 0x3fff
+		low = 0
+	} else {
+		biasedExponent = (high >> 49) & 0x3fff
+		coefficientHigh = high & 0x1ffffffffffff
+		// 10^34-1, the maximum coefficient, is 0x1ed09bead87c0 378d8e63ffffffff.
+		const maxHigh, maxLow = 0x1ed09bead87c0, 0x378d8e63ffffffff
+		if coefficientHigh > maxHigh || (coefficientHigh == maxHigh && low > maxLow) {
+			coefficientHigh, low = 0, 0
+		}
+	}
+	if coefficientHigh == 0 && low == 0 && isNegative {
+		return DFloat128FromDFloat(dfloatNegativeZero)
+	}
+	return DFloat128{
+		Exponent:    int32(biasedExponent) - decimal128ExponentBias,
+		Coefficient: int128FromMagnitude(coefficientHigh, low, isNegative),
+	}
+}
+
+// Convert this value to an IEEE 754-2008 decimal128 value (in BID format,
+// split into high and low 64-bit halves). Returns an error if the value cannot
+// be represented exactly.
+func (this DFloat128) Decimal128() (high, low uint64, err error) {
+	return APDToDecimal128(this.APD())
+}
+
+// Convert this value to an IEEE 754-2008 decimal128 value (in BID format,
+// split into high and low 64-bit halves). Returns an error if the value cannot
+// be represented exactly.
+func (this DFloat) Decimal128() (high, low uint64, err error) {
+	return APDToDecimal128(this.APD())
+}
+
+// Convert an apd.Decimal to an IEEE 754-2008 decimal128 value (in BID format,
+// split into high and low 64-bit halves). Returns an error if the value cannot
+// be represented exactly.
+func APDToDecimal128(value *apd.Decimal) (high, low uint64, err error) {
+	if value.Negative {
+		high = decimal128SignBit
+	}
+
+	switch value.Form {
+	case apd.Infinite:
+		high |= decimal128Infinity
+		return
+	case apd.NaN:
+		high |= decimal128NaN
+		return
+	case apd.NaNSignaling:
+		high |= decimal128NaN | decimal128SignalingBit
+		return
+	}
+
+	coefficient := new(big.Int).Set(&value.Coeff)
+	exponent := int64(value.Exponent)
+	ten := big.NewInt(10)
+	remainder := new(big.Int)
+	quotient := new(big.Int)
+
+	if coefficient.Sign() == 0 {
+		if exponent < decimal128MinExponent {
+			exponent = decimal128MinExponent
+		} else if exponent > decimal128MaxExponent {
+			exponent = decimal128MaxExponent
+		}
+	}
+
+	// Remove trailing zeros while the value is out of range.
+	for exponent < decimal128MaxExponent &&
+		(exponent < decimal128MinExponent || coefficient.Cmp(decimal128MaxCoefficient) > 0) {
+		quotient.QuoRem(coefficient, ten, remainder)
+		if remainder.Sign() != 0 {
+			break
+		}
+		coefficient.Set(quotient)
+		exponent++
+	}
+
+	// Add trailing zeros while the exponent is too big.
+	for exponent > decimal128MaxExponent {
+		quotient.Mul(coefficient, ten)
+		if quotient.Cmp(decimal128MaxCoefficient) > 0 {
+			break
+		}
+		coefficient.Set(quotient)
+		exponent--
+	}
+
+	if coefficient.Cmp(decimal128MaxCoefficient) > 0 {
+		err = fmt.Errorf("%v cannot fit into decimal128: Too many significant digits", value)
+		return
+	}
+	if exponent < decimal128MinExponent || exponent > decimal128MaxExponent {
+		err = fmt.Errorf("%v cannot fit into decimal128: Exponent out of range", value)
+		return
+	}
+
+	low = new(big.Int).And(coefficient, new(big.Int).SetUint64(^uint64(0))).Uint64()
+	high |= new(big.Int).Rsh(coefficient, 64).Uint64()
+	high |= uint64(exponent+decimal128ExponentBias) << 49
+	return
+}
+
+// Decodes compact float values from reader until it is exhausted, writing each
+// to writer as a 16-byte IEEE 754-2008 decimal128 value (BID format, little
+// endian: the low half first, as in Intel's BID library and BSON).
+//
+// Values with more than 34 significant digits are rounded (half-to-even), and
+// if onRounding is not nil, it's called with the index of the value and a
+// RoundingError describing what was dropped. Rounding doesn't stop the
+// transcoding, but the first RoundingError is returned once the stream ends.
+// Values whose exponent is out of decimal128 range fail.
+func TranscodeToDecimal128(reader io.Reader, writer io.Writer, onRounding func(index int, err error)) (count int, err error) {
+	decodeReader := newCountingReader(reader)
+	var value unpackedDecimal
+	var roundingErr error
+	var buffer [16]byte
+	for {
+		if _, err = DecodeDecimal(&decodeReader, &value); err != nil {
+			if err == io.EOF {
+				err = roundingErr
+			}
+			return
+		}
+
+		high, low, conversionErr := unpackedToDecimal128(&value)
+		if conversionErr != nil {
+			if !errors.Is(conversionErr, roundingError) {
+				err = fmt.Errorf("value %v: %w", count, conversionErr)
+				return
+			}
+			if roundingErr == nil {
+				roundingErr = conversionErr
+			}
+			if onRounding != nil {
+				onRounding(count, conversionErr)
+			}
+		}
+
+		binary.LittleEndian.PutUint64(buffer[:8], low)
+		binary.LittleEndian.PutUint64(buffer[8:], high)
+		if _, err = writer.Write(buffer[:]); err != nil {
+			return
+		}
+		count++
+	}
+}
+
+// Reads 16-byte IEEE 754-2008 decimal128 values (BID format, little endian)
+// from reader until it is exhausted, writing each to writer as a compact
+// float. Every decimal128 value converts exactly. Fails with ErrorIncomplete
+// if the stream ends partway through a value.
+func TranscodeFromDecimal128(reader io.Reader, writer io.Writer) (count int, err error) {
+	var buffer [16]byte
+	encoded := make([]byte, 0, MaxEncodedLength128)
+	for {
+		if _, err = io.ReadFull(reader, buffer[:]); err != nil {
+			if err == io.EOF {
+				err = nil
+			} else {
+				err = incompleteIfTruncated(err, 1)
+			}
+			return
+		}
+		low := binary.LittleEndian.Uint64(buffer[:8])
+		high := binary.LittleEndian.Uint64(buffer[8:])
+		encoded = AppendEncodeDFloat128(encoded[:0], DFloat128FromDecimal128(high, low))
+		if _, err = writer.Write(encoded); err != nil {
+			return
+		}
+		count++
+	}
+}
+
+// Converts a decoded value to decimal128, rounding to 34 significant digits
+// if necessary (in which case the returned error is RoundingError).
+func unpackedToDecimal128(value *unpackedDecimal) (high, low uint64, err error) {
+	if value.exponent == ExpSpecial {
+		special := DFloat{Exponent: value.exponent, Coefficient: value.coefficient.Int64()}
+		return special.Decimal128()
+	}
+
+	asAPD := apd.Decimal{Exponent: value.exponent}
+	asAPD.Coeff.Abs(&value.coefficient)
+	asAPD.Negative = value.coefficient.Sign() < 0
+	var roundingErr error
+	if asAPD.Coeff.Cmp(decimal128MaxCoefficient) > 0 {
+		digits := asAPD.Coeff.Append(nil, 10)
+		original := string(appendDecimalText(nil, asAPD.Negative, int64(value.exponent), digits, 'g'))
+		digitsDropped := len(digits) - decimal128MaxDigits
+		divisor := pow10BigInt(int64(digitsDropped))
+		remainder := new(big.Int)
+		asAPD.Coeff.QuoRem(&asAPD.Coeff, divisor, remainder)
+		roundedAway := false
+		if comparison := remainder.Lsh(remainder, 1).Cmp(divisor); comparison > 0 || (comparison == 0 && asAPD.Coeff.Bit(0) == 1) {
+			asAPD.Coeff.Add(&asAPD.Coeff, big.NewInt(1))
+			roundedAway = true
+		}
+		exponent := int64(value.exponent) + int64(digitsDropped)
+		if asAPD.Coeff.Cmp(decimal128MaxCoefficient) > 0 {
+			// Rounded up to 10^34
+			asAPD.Coeff.Quo(&asAPD.Coeff, big.NewInt(10))
+			exponent++
+		}
+		if exponent > decimal128MaxExponent {
+			err = fmt.Errorf("%v cannot fit into decimal128: Exponent out of range", original)
+			return
+		}
+		asAPD.Exponent = int32(exponent)
+		if remainder.Sign() != 0 {
+			roundingErr = newRoundingError(original, digitsDropped, roundedAway)
+		}
+	}
+
+	if high, low, err = APDToDecimal128(&asAPD); err == nil {
+		err = roundingErr
+	}
+	return
+}
@@ -0,0 +1,277 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/apd/v2"
+)
+
+func assertDecimal128(t *testing.T, strValue string, expectedHigh, expectedLow uint64) {
+	value, err := DFloatFromString(strValue)
+	if err != nil {
+		t.Errorf("Unexpected error converting string %v to DFloat: %v", strValue, err)
+		return
+	}
+	high, low, err := value.Decimal128()
+	if err != nil {
+		t.Errorf("Value %v: Error converting to decimal128: %v", value, err)
+		return
+	}
+	if high != expectedHigh || low != expectedLow {
+		t.Errorf("Value %v: Expected decimal128 %016x %016x but got %016x %016x", value, expectedHigh, expectedLow, high, low)
+		return
+	}
+	actual, err := DFloatFromDecimal128(high, low)
+	if err != nil {
+		t.Errorf("Value %v: Error converting from decimal128: %v", value, err)
+		return
+	}
+	if actual != value {
+		t.Errorf("Expected %v but got %v", value, actual)
+	}
+}
+
+func assertAPDDecimal128(t *testing.T, strValue string) {
+	value, _, err := apd.NewFromString(strValue)
+	if err != nil {
+		t.Errorf("Unexpected error converting string %v to apd.Decimal: %v", strValue, err)
+		return
+	}
+	high, low, err := APDToDecimal128(value)
+	if err != nil {
+		t.Errorf("Value %v: Error converting to decimal128: %v", value, err)
+		return
+	}
+	actual := APDFromDecimal128(high, low)
+	if actual.Cmp(value) != 0 {
+		t.Errorf("Expected %v but got %v", value, actual)
+	}
+}
+
+func assertAPDDecimal128Fails(t *testing.T, strValue string) {
+	value, _, err := apd.NewFromString(strValue)
+	if err != nil {
+		t.Errorf("Unexpected error converting string %v to apd.Decimal: %v", strValue, err)
+		return
+	}
+	if _, _, err := APDToDecimal128(value); err == nil {
+		t.Errorf("Expected conversion of %v to decimal128 to fail", value)
+	}
+}
+
+func TestDecimal128(t *testing.T) {
+	assertDecimal128(t, "0", 0x3040000000000000, 0)
+	assertDecimal128(t, "-0", 0xb040000000000000, 0)
+	assertDecimal128(t, "inf", 0x7800000000000000, 0)
+	assertDecimal128(t, "-inf", 0xf800000000000000, 0)
+	assertDecimal128(t, "nan", 0x7c00000000000000, 0)
+	assertDecimal128(t, "snan", 0x7e00000000000000, 0)
+	assertDecimal128(t, "-nan", 0xfc00000000000000, 0)
+	assertDecimal128(t, "-snan", 0xfe00000000000000, 0)
+	assertDecimal128(t, "1", 0x3040000000000000, 1)
+	assertDecimal128(t, "-1.5", 0xb03e000000000000, 15)
+	assertDecimal128(t, "9223372036854775807", 0x3040000000000000, 0x7fffffffffffffff)
+	assertDecimal128(t, "1e6111", 0x5ffe000000000000, 1)
+	assertDecimal128(t, "1e-6176", 0x0000000000000000, 1)
+}
+
+func TestAPDDecimal128(t *testing.T) {
+	assertAPDDecimal128(t, "1234567890123456789012345678901234")
+	assertAPDDecimal128(t, "-1.234567890123456789012345678901234e-6000")
+	assertAPDDecimal128(t, "1e6144")
+	assertAPDDecimal128(t, "100e-6178")
+
+	assertAPDDecimal128Fails(t, "12345678901234567890123456789012345")
+	assertAPDDecimal128Fails(t, "1e6145")
+	assertAPDDecimal128Fails(t, "1e-6177")
+}
+
+func assertDFloat128Decimal128(t *testing.T, strValue string) {
+	value, _, err := apd.NewFromString(strValue)
+	if err != nil {
+		t.Errorf("Unexpected error converting string %v to apd.Decimal: %v", strValue, err)
+		return
+	}
+	high, low, err := APDToDecimal128(value)
+	if err != nil {
+		t.Errorf("Value %v: Error converting to decimal128: %v", value, err)
+		return
+	}
+	actual := DFloat128FromDecimal128(high, low)
+	if expected := APDFromDecimal128(high, low); actual.APD().CmpTotal(expected) != 0 {
+		t.Errorf("Expected %v but got %v", expected, actual)
+	}
+	actualHigh, actualLow, err := actual.Decimal128()
+	if err != nil || actualHigh != high || actualLow != low {
+		t.Errorf("Value %v: Expected decimal128 %016x %016x but got %016x %016x (%v)", value, high, low, actualHigh, actualLow, err)
+	}
+}
+
+func TestDFloat128Decimal128(t *testing.T) {
+	assertDFloat128Decimal128(t, "0")
+	assertDFloat128Decimal128(t, "-0")
+	assertDFloat128Decimal128(t, "Infinity")
+	assertDFloat128Decimal128(t, "-Infinity")
+	assertDFloat128Decimal128(t, "NaN")
+	assertDFloat128Decimal128(t, "-sNaN")
+	assertDFloat128Decimal128(t, "-1.5")
+	assertDFloat128Decimal128(t, "9999999999999999999999999999999999")
+	assertDFloat128Decimal128(t, "-1.234567890123456789012345678901234e-6000")
+	assertDFloat128Decimal128(t, "1e6144")
+
+	// Non-canonical coefficients are zero.
+	if value := DFloat128FromDecimal128(0x3041ed09bead87c0, 0x378d8e6400000000); !value.IsZero() {
+		t.Errorf("Expected zero but got %v", value)
+	}
+	if value := DFloat128FromDecimal128(0x6000000000000000, 1); !value.IsZero() {
+		t.Errorf("Expected zero but got %v", value)
+	}
+}
+
+func TestDFloat128APD(t *testing.T) {
+	for _, str := range []string{
+		"0",
+		"-0",
+		"-Infinity",
+		"sNaN",
+		"-1.50",
+		"-1.234567890123456789012345678901234e-6000",
+		"170141183460469231731687303715884105727e100",
+	} {
+		value, _, err := apd.NewFromString(str)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		actual, err := DFloat128FromAPD(value)
+		if err != nil {
+			t.Errorf("%v: %v", str, err)
+			continue
+		}
+		if actual.APD().Cmp(value) != 0 || actual.APD().Negative != value.Negative {
+			t.Errorf("Expected %v but got %v", value, actual)
+		}
+	}
+
+	value, _, _ := apd.NewFromString("-1234567890123456789012345678901234567851e10")
+	actual, err := DFloat128FromAPD(value)
+	expected := DFloat128{Exponent: 12, Coefficient: newTestInt128(t, "-12345678901234567890123456789012345679")}
+	if !errors.Is(err, RoundingError()) || actual != expected {
+		t.Errorf("Expected %v but got %v (%v)", expected, actual, err)
+	}
+}
+
+func encodeAPDStream(t *testing.T, strValues ...string) []byte {
+	var encoded []byte
+	for _, strValue := range strValues {
+		value, _, err := apd.NewFromString(strValue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded = AppendEncodeBig(encoded, value)
+	}
+	return encoded
+}
+
+func TestDecimal128Transcode(t *testing.T) {
+	encoded := encodeAPDStream(t, "0", "-0", "-1.5", "inf", "-snan", "1234567890123456789012345678901234", "1e6111", "-1e-6176")
+	asDecimal128 := &bytes.Buffer{}
+	count, err := TranscodeToDecimal128(bytes.NewReader(encoded), asDecimal128, func(index int, err error) {
+		t.Errorf("Unexpected rounding of value %v: %v", index, err)
+	})
+	if err != nil || count != 8 || asDecimal128.Len() != 8*16 {
+		t.Fatalf("Expected 8 values (128 bytes) but got %v (%v bytes): %v", count, asDecimal128.Len(), err)
+	}
+	if expected := []byte{15, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x3e, 0xb0}; !bytes.Equal(asDecimal128.Bytes()[32:48], expected) {
+		t.Errorf("Expected -1.5 to be %x but got %x", expected, asDecimal128.Bytes()[32:48])
+	}
+
+	roundTripped := &bytes.Buffer{}
+	if count, err = TranscodeFromDecimal128(asDecimal128, roundTripped); err != nil || count != 8 {
+		t.Fatalf("Expected 8 values but got %v: %v", count, err)
+	}
+	if !bytes.Equal(roundTripped.Bytes(), encoded) {
+		t.Errorf("Expected %x but got %x", encoded, roundTripped.Bytes())
+	}
+}
+
+func TestDecimal128TranscodeRounding(t *testing.T) {
+	encoded := encodeAPDStream(t, "1", "12345678901234567890123456789012345", "9999999999999999999999999999999999.5", "12345678901234567890123456789012340")
+	var roundedIndexes []int
+	asDecimal128 := &bytes.Buffer{}
+	count, err := TranscodeToDecimal128(bytes.NewReader(encoded), asDecimal128, func(index int, err error) {
+		roundedIndexes = append(roundedIndexes, index)
+	})
+	var details *RoundingErrorDetails
+	if count != 4 || !errors.As(err, &details) {
+		t.Fatalf("Expected 4 values and a RoundingError but got %v: %v", count, err)
+	}
+	if details.DigitsDropped != 1 || details.Direction != RoundedTowardZero || details.Original != "12345678901234567890123456789012345" {
+		t.Errorf("Unexpected rounding details %+v", details)
+	}
+	if len(roundedIndexes) != 2 || roundedIndexes[0] != 1 || roundedIndexes[1] != 2 {
+		t.Errorf("Expected values 1 and 2 to be rounded but got %v", roundedIndexes)
+	}
+
+	decoded := &bytes.Buffer{}
+	if _, err = TranscodeFromDecimal128(asDecimal128, decoded); err != nil {
+		t.Fatal(err)
+	}
+	decoder := NewDecoder(decoded)
+	var values []string
+	for decoder.Next() {
+		value, bigValue := decoder.Value()
+		if bigValue != nil {
+			values = append(values, bigValue.Text('g'))
+		} else {
+			values = append(values, value.Text('g'))
+		}
+	}
+	expected := []string{"1", "1.234567890123456789012345678901234E+34", "1.000000000000000000000000000000000E+34", "1.234567890123456789012345678901234E+34"}
+	if len(values) != len(expected) {
+		t.Fatalf("Expected %v but got %v", expected, values)
+	}
+	for i := range values {
+		if !strings.EqualFold(values[i], expected[i]) {
+			t.Errorf("Value %v: Expected %v but got %v", i, expected[i], values[i])
+		}
+	}
+}
+
+func TestDecimal128TranscodeErrors(t *testing.T) {
+	if _, err := TranscodeToDecimal128(bytes.NewReader(encodeAPDStream(t, "1", "1e7000")), &bytes.Buffer{}, nil); err == nil || !strings.HasPrefix(err.Error(), "value 1:") {
+		t.Errorf("Expected an exponent range error for value 1 but got %v", err)
+	}
+	if _, err := TranscodeToDecimal128(bytes.NewReader([]byte{0x02, 0x80}), &bytes.Buffer{}, nil); !errors.Is(err, ErrorIncomplete) {
+		t.Errorf("Expected ErrorIncomplete but got %v", err)
+	}
+	if _, err := TranscodeFromDecimal128(bytes.NewReader(make([]byte, 20)), &bytes.Buffer{}); !errors.Is(err, ErrorIncomplete) {
+		t.Errorf("Expected ErrorIncomplete but got %v", err)
+	}
+}
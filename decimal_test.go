@@ -0,0 +1,125 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// Encodes source through the Decimal interface, then decodes it into dst
+// (both from bytes and from a reader), checking that the value is unchanged.
+func assertDecimalTranscode(t *testing.T, source Decimal, dst MutableDecimal) {
+	expected := decimalText(source)
+	encoded := AppendEncodeDecimal(nil, source)
+	var buffer bytes.Buffer
+	if _, err := EncodeDecimal(source, &buffer); err != nil || !bytes.Equal(buffer.Bytes(), encoded) {
+		t.Errorf("%v: Expected EncodeDecimal to produce %x but got %x (%v)", source, encoded, buffer.Bytes(), err)
+	}
+
+	bytesDecoded, err := DecodeDecimalFromBytes(encoded, dst)
+	if err != nil || bytesDecoded != len(encoded) {
+		t.Errorf("%x: Failed to decode (%v bytes): %v", encoded, bytesDecoded, err)
+		return
+	}
+	if actual := decimalText(dst); actual != expected {
+		t.Errorf("%x: Expected %v but got %v", encoded, expected, actual)
+	}
+
+	bytesDecoded, err = DecodeDecimal(bytes.NewReader(encoded), dst)
+	if err != nil || bytesDecoded != len(encoded) {
+		t.Errorf("%x: Failed to decode from reader (%v bytes): %v", encoded, bytesDecoded, err)
+		return
+	}
+	if actual := decimalText(dst); actual != expected {
+		t.Errorf("%x: Expected %v from reader but got %v", encoded, expected, actual)
+	}
+}
+
+// Formats a Decimal as coefficient and exponent (or the special value name).
+func decimalText(value Decimal) string {
+	exponent, coefficient := value.Unpack(new(big.Int))
+	if exponent == ExpSpecial {
+		return DFloat{Exponent: exponent, Coefficient: coefficient.Int64()}.String()
+	}
+	return fmt.Sprintf("%ve%v", coefficient, exponent)
+}
+
+func mustInt128(value *big.Int) Int128 {
+	result, err := Int128FromBigInt(value)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func TestDecimalInterface(t *testing.T) {
+	for _, value := range append(generatedSpecials, generatedExtremes...) {
+		var asDFloat DFloat
+		assertDecimalTranscode(t, value, &asDFloat)
+		var asDFloat128 DFloat128
+		assertDecimalTranscode(t, value, &asDFloat128)
+		assertDecimalTranscode(t, DFloat128FromDFloat(value), &asDFloat)
+	}
+
+	var asDFloat128 DFloat128
+	big34 := DFloat128{Exponent: -4, Coefficient: mustInt128(big.NewInt(0).Exp(big.NewInt(10), big.NewInt(33), nil))}
+	assertDecimalTranscode(t, big34, &asDFloat128)
+}
+
+func TestDecimalPackRounding(t *testing.T) {
+	coefficient, _ := new(big.Int).SetString("-123456789012345678951", 10)
+	var asDFloat DFloat
+	if err := asDFloat.Pack(-2, coefficient); !errors.Is(err, RoundingError()) || asDFloat != (DFloat{Exponent: 1, Coefficient: -123456789012345679}) {
+		t.Errorf("Expected rounded value but got %v (%v)", asDFloat, err)
+	}
+
+	coefficient, _ = new(big.Int).SetString("1234567890123456789012345678901234567851", 10)
+	var asDFloat128 DFloat128
+	expected, _ := new(big.Int).SetString("12345678901234567890123456789012345679", 10)
+	if err := asDFloat128.Pack(-2, coefficient); !errors.Is(err, RoundingError()) || asDFloat128 != (DFloat128{Coefficient: mustInt128(expected)}) {
+		t.Errorf("Expected rounded value but got %v (%v)", asDFloat128, err)
+	}
+	if err := asDFloat128.Pack(math.MaxInt32, coefficient); !errors.Is(err, ErrorExponentTooLarge) {
+		t.Errorf("Expected ErrorExponentTooLarge but got %v", err)
+	}
+}
+
+func TestDecodeDecimalErrors(t *testing.T) {
+	var value DFloat
+	if _, err := DecodeDecimalFromBytes([]byte{0x0d}, &value); !errors.Is(err, ErrorIncomplete) {
+		t.Errorf("Expected ErrorIncomplete but got %v", err)
+	}
+	if _, err := DecodeDecimal(bytes.NewReader([]byte{0x0d, 0x80}), &value); !errors.Is(err, ErrorIncomplete) {
+		t.Errorf("Expected ErrorIncomplete but got %v", err)
+	}
+	tooBig := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0x01}
+	if _, err := DecodeDecimalFromBytes(tooBig, &value); !errors.Is(err, ErrorExponentTooLarge) {
+		t.Errorf("Expected ErrorExponentTooLarge but got %v", err)
+	}
+	if _, err := DecodeDecimal(bytes.NewReader(tooBig), &value); !errors.Is(err, ErrorExponentTooLarge) {
+		t.Errorf("Expected ErrorExponentTooLarge but got %v", err)
+	}
+}
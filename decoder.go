@@ -0,0 +1,302 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+package compact_float
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Decoder reads a sequence of compact float values from a reader.
+//
+// Decode() can be called directly, or Next() can be used to iterate:
+//
+//	decoder := NewDecoder(reader)
+//	for decoder.Next() {
+//	    value, bigValue := decoder.Value()
+//	    ...
+//	}
+//	if err := decoder.Err(); err != nil {
+//	    ...
+//	}
+type Decoder struct {
+	reader       countingReader
+	buffer       [1]byte
+	value        DFloat
+	bigValue     *BigDecimal
+	err          error
+	maxValueSize int
+	canonical    bool
+	strict       bool
+
+	verifyChecksums bool
+	blockValues     []DFloat
+	blockBigValues  []*BigDecimal
+	blockIndex      int
+}
+
+// Create a new decoder that reads from the specified reader.
+func NewDecoder(reader io.Reader) *Decoder {
+	return &Decoder{
+		reader: newCountingReader(reader),
+	}
+}
+
+// Create a new decoder that reads from the specified reader in chunks of
+// bufferSize bytes rather than a byte at a time, greatly reducing the number of
+// calls to the reader (and thus syscalls when reading from a network
+// connection or file). BytesDecoded() still reports exactly the bytes consumed
+// by decoded values, but because data is read ahead, the underlying reader may
+// have been read past that point.
+func NewBufferedDecoder(reader io.Reader, bufferSize int) *Decoder {
+	return NewDecoder(bufio.NewReaderSize(reader, bufferSize))
+}
+
+// Limit the number of bytes a single encoded value may occupy. Decoding a value
+// that exceeds the limit fails with ErrorTooLong. A limit of 0 means no limit.
+func (this *Decoder) SetMaxValueSize(maxBytes int) {
+	this.maxValueSize = maxBytes
+}
+
+// Require every value to be canonically encoded (see DecodeCanonical()).
+// Decoding a non-canonical value fails with ErrorNotCanonical.
+func (this *Decoder) SetRequireCanonical(requireCanonical bool) {
+	this.canonical = requireCanonical
+}
+
+// Only accept values that are part of the compact float specification (see
+// CheckConformance()). Decoding a value that relies on one of this library's
+// extensions fails with ErrorNotConforming.
+func (this *Decoder) SetStrictConformance(strictConformance bool) {
+	this.strict = strictConformance
+}
+
+// Expect the stream to be grouped into checksummed blocks (see
+// Encoder.SetChecksumInterval()), and verify each block's checksum. The values
+// in a block are only returned once the whole block has been verified. A
+// corrupt block fails with a *ChecksumErrorDetails, which matches
+// ErrorChecksumMismatch.
+func (this *Decoder) SetVerifyChecksums(verifyChecksums bool) {
+	this.verifyChecksums = verifyChecksums
+}
+
+// Decode the next value from the stream.
+// bigValue will be nil unless the decoded value is too big to fit into a DFloat.
+// Returns io.EOF if the stream ended cleanly before the value began, or
+// ErrorIncomplete if the stream ended partway through the value.
+func (this *Decoder) Decode() (value DFloat, bigValue *BigDecimal, err error) {
+	if this.verifyChecksums {
+		return this.decodeChecksummed()
+	}
+	return this.decodeFrom(&this.reader)
+}
+
+func (this *Decoder) decodeFrom(reader io.Reader) (value DFloat, bigValue *BigDecimal, err error) {
+	if this.maxValueSize > 0 {
+		limited := limitedReader{reader: reader, remaining: this.maxValueSize}
+		value, bigValue, _, err = decodeWithByteBuffer(&limited, this.buffer[:], this.canonical)
+	} else {
+		value, bigValue, _, err = decodeWithByteBuffer(reader, this.buffer[:], this.canonical)
+	}
+	if this.strict {
+		err = checkDecodedConformance(value, err)
+	}
+	return
+}
+
+// Returns the next value of the current checksummed block, reading and
+// verifying the next block if the current one is used up.
+func (this *Decoder) decodeChecksummed() (value DFloat, bigValue *BigDecimal, err error) {
+	if this.blockIndex < len(this.blockValues) {
+		value, bigValue = this.blockValues[this.blockIndex], this.blockBigValues[this.blockIndex]
+		this.blockIndex++
+		return
+	}
+	this.blockValues = this.blockValues[:0]
+	this.blockBigValues = this.blockBigValues[:0]
+	this.blockIndex = 0
+
+	checksummed := checksumReader{reader: &this.reader}
+	count, asBig, byteCount, err := decodeULEB128(&checksummed, this.buffer[:])
+	if err != nil {
+		err = incompleteIfTruncated(err, byteCount)
+		return
+	}
+	if asBig != nil || count == 0 || count > uint64(maxInt) {
+		err = fmt.Errorf("%w: Invalid checksum block value count", ErrorMalformed)
+		return
+	}
+	for i := uint64(0); i < count; i++ {
+		if value, bigValue, err = this.decodeFrom(&checksummed); err != nil {
+			err = incompleteIfTruncated(err, 1)
+			return
+		}
+		this.blockValues = append(this.blockValues, value)
+		this.blockBigValues = append(this.blockBigValues, bigValue)
+	}
+	if err = verifyCRC32C(&this.reader, this.reader.bytesRead, checksummed.checksum); err != nil {
+		this.blockValues = this.blockValues[:0]
+		this.blockBigValues = this.blockBigValues[:0]
+		return
+	}
+	return this.decodeChecksummed()
+}
+
+// Advance to the next value in the stream, returning false at the end of the
+// stream or on error (check Err() to tell the difference).
+func (this *Decoder) Next() bool {
+	if this.err != nil {
+		return false
+	}
+	this.value, this.bigValue, this.err = this.Decode()
+	return this.err == nil
+}
+
+// Returns the value decoded by the last successful call to Next().
+// bigValue will be nil unless the decoded value is too big to fit into a DFloat.
+func (this *Decoder) Value() (value DFloat, bigValue *BigDecimal) {
+	return this.value, this.bigValue
+}
+
+// Returns the error that stopped iteration via Next(), or nil if the stream
+// ended cleanly.
+func (this *Decoder) Err() error {
+	if this.err == io.EOF {
+		return nil
+	}
+	return this.err
+}
+
+// Returns the total number of bytes consumed from the reader so far.
+func (this *Decoder) BytesDecoded() int {
+	return this.reader.bytesRead
+}
+
+// Counts the bytes consumed from a reader. It always implements io.ByteReader,
+// passing through to the underlying reader's ReadByte() if it has one.
+type countingReader struct {
+	reader     io.Reader
+	byteReader io.ByteReader
+	buffer     [1]byte
+	bytesRead  int
+}
+
+func newCountingReader(reader io.Reader) countingReader {
+	byteReader, _ := reader.(io.ByteReader)
+	return countingReader{
+		reader:     reader,
+		byteReader: byteReader,
+	}
+}
+
+func (this *countingReader) Read(p []byte) (n int, err error) {
+	n, err = this.reader.Read(p)
+	this.bytesRead += n
+	return
+}
+
+func (this *countingReader) ReadByte() (b byte, err error) {
+	if this.byteReader != nil {
+		if b, err = this.byteReader.ReadByte(); err == nil {
+			this.bytesRead++
+		}
+		return
+	}
+	if _, err = io.ReadFull(this, this.buffer[:]); err != nil {
+		return
+	}
+	return this.buffer[0], nil
+}
+
+// StreamDecoder decodes compact float values from data that arrives in
+// arbitrary chunks, without ever blocking on a reader. Feed it data as it
+// arrives, then call TryNext() until it reports that no complete value is
+// available.
+type StreamDecoder struct {
+	pending []byte
+	// The offset in pending of the first byte that hasn't been decoded yet.
+	start int
+}
+
+// Create a new stream decoder.
+func NewStreamDecoder() *StreamDecoder {
+	return &StreamDecoder{}
+}
+
+// Add more data to be decoded. The data is copied, so the caller may reuse it.
+func (this *StreamDecoder) Feed(data []byte) {
+	if this.start > 0 && len(this.pending)+len(data) > cap(this.pending) {
+		// Only move the undecoded bytes down when the buffer would otherwise
+		// have to grow, so that decoding stays linear.
+		remaining := copy(this.pending, this.pending[this.start:])
+		this.pending = this.pending[:remaining]
+		this.start = 0
+	}
+	this.pending = append(this.pending, data...)
+}
+
+// Try to decode the next value from the data fed so far.
+// ok will be false (with a nil error) if there isn't enough data for a
+// complete value yet; feed more data and try again.
+// bigValue will be nil unless the decoded value is too big to fit into a DFloat.
+//
+// If the next value is malformed, err is returned and its bytes stay buffered
+// (a malformed value's length can't be known), so every later call will fail
+// the same way. To continue past it, drop the bytes with Discard() (for example
+// Discard(Buffered()) to drop everything fed so far).
+func (this *StreamDecoder) TryNext() (value DFloat, bigValue *BigDecimal, ok bool, err error) {
+	value, bigValue, bytesDecoded, err := DecodeFromBytes(this.pending[this.start:])
+	if err != nil {
+		if err == ErrorIncomplete {
+			err = nil
+		}
+		return
+	}
+
+	ok = true
+	this.start += bytesDecoded
+	if this.start == len(this.pending) {
+		this.pending = this.pending[:0]
+		this.start = 0
+	}
+	return
+}
+
+// Drops up to byteCount bytes that haven't been decoded yet (such as a
+// malformed value), returning the number of bytes dropped.
+func (this *StreamDecoder) Discard(byteCount int) int {
+	if byteCount > this.Buffered() {
+		byteCount = this.Buffered()
+	} else if byteCount < 0 {
+		byteCount = 0
+	}
+	this.start += byteCount
+	if this.start == len(this.pending) {
+		this.pending = this.pending[:0]
+		this.start = 0
+	}
+	return byteCount
+}
+
+// Returns the number of bytes fed that haven't been decoded yet.
+func (this *StreamDecoder) Buffered() int {
+	return len(this.pending) - this.start
+}
@@ -0,0 +1,205 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+package compact_float
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	encoded := []byte{0x06, 0x0f, 0x02, 0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04, 0x82, 0x00}
+	decoder := NewDecoder(bytes.NewBuffer(encoded))
+	var values []DFloat
+	for decoder.Next() {
+		value, bigValue := decoder.Value()
+		if bigValue != nil {
+			t.Errorf("Expected no big value but got %v", bigValue)
+		}
+		values = append(values, value)
+	}
+	if err := decoder.Err(); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := []DFloat{DFloatValue(-1, 15), Zero(), DFloatValue(4994, 9445283), Infinity()}
+	if len(values) != len(expected) {
+		t.Errorf("Expected %v but got %v", expected, values)
+		return
+	}
+	for i, value := range values {
+		if value != expected[i] {
+			t.Errorf("Expected %v but got %v", expected, values)
+			return
+		}
+	}
+	if decoder.BytesDecoded() != len(encoded) {
+		t.Errorf("Expected to decode %v bytes but decoded %v", len(encoded), decoder.BytesDecoded())
+	}
+	if _, _, err := decoder.Decode(); err != io.EOF {
+		t.Errorf("Expected io.EOF but got %v", err)
+	}
+}
+
+func TestDecoderTruncated(t *testing.T) {
+	for _, encoded := range [][]byte{{0x06}, {0x06, 0x8f}, {0x88, 0x9c}, {0x82}} {
+		decoder := NewDecoder(bytes.NewBuffer(encoded))
+		if decoder.Next() {
+			t.Errorf("Expected truncated data %v to fail", encoded)
+			continue
+		}
+		if err := decoder.Err(); err != ErrorIncomplete {
+			t.Errorf("Expected ErrorIncomplete for %v but got %v", encoded, err)
+		}
+	}
+}
+
+func TestStreamDecoder(t *testing.T) {
+	encoded := []byte{0x06, 0x0f, 0x02, 0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04, 0x82, 0x00}
+	expected := []DFloat{DFloatValue(-1, 15), Zero(), DFloatValue(4994, 9445283), Infinity()}
+	decoder := NewStreamDecoder()
+	var values []DFloat
+	for _, b := range encoded {
+		decoder.Feed([]byte{b})
+		for {
+			value, _, ok, err := decoder.TryNext()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !ok {
+				break
+			}
+			values = append(values, value)
+		}
+	}
+	if len(values) != len(expected) {
+		t.Errorf("Expected %v but got %v", expected, values)
+		return
+	}
+	for i, value := range values {
+		if value != expected[i] {
+			t.Errorf("Expected %v but got %v", expected, values)
+			return
+		}
+	}
+	if decoder.Buffered() != 0 {
+		t.Errorf("Expected no buffered data but got %v bytes", decoder.Buffered())
+	}
+}
+
+func TestStreamDecoderMalformed(t *testing.T) {
+	decoder := NewStreamDecoder()
+	var data []byte
+	for i := 0; i < 1000; i++ {
+		data = AppendEncode(data, DFloatValue(-1, int64(i)))
+	}
+	decoder.Feed(data)
+	for i := 0; i < 1000; i++ {
+		if value, _, ok, err := decoder.TryNext(); !ok || err != nil || value != DFloatValue(-1, int64(i)) {
+			t.Errorf("Expected %v but got %v (%v, %v)", DFloatValue(-1, int64(i)), value, ok, err)
+			return
+		}
+	}
+
+	malformed := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 0x01}
+	decoder.Feed(malformed)
+	decoder.Feed(AppendEncode(nil, DFloatValue(0, 7)))
+	for i := 0; i < 2; i++ {
+		if _, _, ok, err := decoder.TryNext(); ok || err == nil {
+			t.Errorf("Expected an error for the malformed value but got %v", err)
+		}
+	}
+	if discarded := decoder.Discard(len(malformed)); discarded != len(malformed) {
+		t.Errorf("Expected to discard %v bytes but discarded %v", len(malformed), discarded)
+	}
+	if value, _, ok, err := decoder.TryNext(); !ok || err != nil || value != DFloatValue(0, 7) {
+		t.Errorf("Expected 7 after discarding the malformed value but got %v (%v, %v)", value, ok, err)
+	}
+	if discarded := decoder.Discard(10); discarded != 0 || decoder.Buffered() != 0 {
+		t.Errorf("Expected nothing left to discard but discarded %v", discarded)
+	}
+}
+
+func TestDecoderMaxValueSize(t *testing.T) {
+	encoded := []byte{0x06, 0x0f, 0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04}
+	decoder := NewDecoder(bytes.NewBuffer(encoded))
+	decoder.SetMaxValueSize(6)
+	if _, _, err := decoder.Decode(); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, _, err := decoder.Decode(); err != ErrorTooLong {
+		t.Errorf("Expected ErrorTooLong but got %v", err)
+	}
+}
+
+type readCounter struct {
+	reader io.Reader
+	calls  int
+}
+
+func (this *readCounter) Read(p []byte) (int, error) {
+	this.calls++
+	return this.reader.Read(p)
+}
+
+func TestBufferedDecoder(t *testing.T) {
+	var encoded []byte
+	var sizes []int
+	for i := 0; i < 1000; i++ {
+		before := len(encoded)
+		encoded = AppendEncode(encoded, DFloatValue(int32(i%20-10), int64(i*i*i)))
+		sizes = append(sizes, len(encoded)-before)
+	}
+
+	for _, decoder := range []*Decoder{NewDecoder(struct{ io.Reader }{bytes.NewReader(encoded)}),
+		NewBufferedDecoder(bytes.NewReader(encoded), 64)} {
+		for i := 0; decoder.Next(); i++ {
+			value, _ := decoder.Value()
+			if expected := DFloatValue(int32(i%20-10), int64(i*i*i)); value != expected {
+				t.Errorf("Expected %v but got %v", expected, value)
+				return
+			}
+		}
+		if err := decoder.Err(); err != nil {
+			t.Error(err)
+			return
+		}
+		if decoder.BytesDecoded() != len(encoded) {
+			t.Errorf("Expected to decode %v bytes but decoded %v", len(encoded), decoder.BytesDecoded())
+		}
+	}
+
+	counter := &readCounter{reader: bytes.NewReader(encoded)}
+	decoder := NewBufferedDecoder(counter, 4096)
+	bytesDecoded := 0
+	for i := 0; decoder.Next(); i++ {
+		bytesDecoded += sizes[i]
+		if decoder.BytesDecoded() != bytesDecoded {
+			t.Errorf("Value %v: Expected %v bytes decoded but got %v", i, bytesDecoded, decoder.BytesDecoded())
+			return
+		}
+	}
+	if maxCalls := len(encoded)/4096 + 2; counter.calls > maxCalls {
+		t.Errorf("Expected at most %v reads but got %v", maxCalls, counter.calls)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+package compact_float
+
+import (
+	"fmt"
+	"io"
+)
+
+// Delta encoding stores each DFloat as the difference between its exponent
+// and coefficient and those of the previous value (starting from zero). Each
+// difference is zigzag encoded (so that small negative differences stay
+// small), then written as ULEB128: first the exponent difference, then the
+// coefficient difference.
+//
+// Sequences of slowly changing values with the same exponent (such as prices
+// or counters) encode to around 2 bytes per value.
+
+// DeltaEncoder writes a sequence of delta-encoded DFloat values.
+type DeltaEncoder struct {
+	writer   io.Writer
+	previous DFloat
+	buffer   [2 * maxULEB128Uint64Length]byte
+}
+
+// Create a new delta encoder that writes to the specified writer.
+func NewDeltaEncoder(writer io.Writer) *DeltaEncoder {
+	return &DeltaEncoder{writer: writer}
+}
+
+// Encode the next value in the sequence.
+func (this *DeltaEncoder) Encode(value DFloat) (bytesEncoded int, err error) {
+	exponentDelta := int64(value.Exponent) - int64(this.previous.Exponent)
+	coefficientDelta := int64(uint64(value.Coefficient) - uint64(this.previous.Coefficient))
+	bytesEncoded = encodeULEB128Uint64(zigzagEncode(exponentDelta), this.buffer[:])
+	bytesEncoded += encodeULEB128Uint64(zigzagEncode(coefficientDelta), this.buffer[bytesEncoded:])
+	this.previous = value
+	return this.writer.Write(this.buffer[:bytesEncoded])
+}
+
+// Restart the sequence, so that the next value is encoded relative to zero.
+func (this *DeltaEncoder) Reset() {
+	this.previous = dfloatZero
+}
+
+// DeltaDecoder reads a sequence of delta-encoded DFloat values.
+type DeltaDecoder struct {
+	reader   io.Reader
+	previous DFloat
+	buffer   [1]byte
+}
+
+// Create a new delta decoder that reads from the specified reader.
+func NewDeltaDecoder(reader io.Reader) *DeltaDecoder {
+	return &DeltaDecoder{reader: reader}
+}
+
+// Decode the next value in the sequence. Returns io.EOF if there are no more
+// values, or ErrorIncomplete if the data ends partway through a value.
+func (this *DeltaDecoder) Decode() (value DFloat, bytesDecoded int, err error) {
+	exponentDelta, asBig, bytesDecoded, err := decodeULEB128(this.reader, this.buffer[:])
+	if err != nil {
+		err = incompleteIfTruncated(err, bytesDecoded)
+		return
+	}
+	if asBig != nil {
+		err = fmt.Errorf("%w: Exponent delta %v is too big", ErrorMalformed, asBig)
+		return
+	}
+	offset := bytesDecoded
+	coefficientDelta, asBig, bytesDecoded, err := decodeULEB128(this.reader, this.buffer[:])
+	if err != nil {
+		err = incompleteIfTruncated(err, offset+bytesDecoded)
+		return
+	}
+	if asBig != nil {
+		err = fmt.Errorf("%w: Coefficient delta %v is too big", ErrorMalformed, asBig)
+		return
+	}
+	bytesDecoded += offset
+
+	exponent := int64(this.previous.Exponent) + zigzagDecode(exponentDelta)
+	if exponent < int64(ExpSpecial) || exponent > 0x7fffffff {
+		err = fmt.Errorf("%w: %v", ErrorExponentTooLarge, exponent)
+		return
+	}
+	value = DFloat{
+		Exponent:    int32(exponent),
+		Coefficient: int64(uint64(this.previous.Coefficient) + uint64(zigzagDecode(coefficientDelta))),
+	}
+	if value.IsSpecial() && !isSpecialCoefficient(value.Coefficient) {
+		err = fmt.Errorf("%w: %v is not a special value code", ErrorMalformed, value.Coefficient)
+		return dfloatZero, bytesDecoded, err
+	}
+	this.previous = value
+	return
+}
+
+// Restart the sequence, so that the next value is decoded relative to zero.
+func (this *DeltaDecoder) Reset() {
+	this.previous = dfloatZero
+}
+
+// Returns true if coefficient is one of the codes that a special value (one
+// with the exponent ExpSpecial) can have.
+func isSpecialCoefficient(coefficient int64) bool {
+	switch coefficient {
+	case CoeffNegativeZero, CoeffInfinity, CoeffNegativeInfinity, CoeffNan,
+		CoeffSignalingNan, CoeffNegativeNan, CoeffNegativeSignalingNan:
+		return true
+	}
+	return false
+}
+
+func zigzagEncode(value int64) uint64 {
+	return uint64(value<<1) ^ uint64(value>>63)
+}
+
+func zigzagDecode(value uint64) int64 {
+	return int64(value>>1) ^ -int64(value&1)
+}
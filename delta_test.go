@@ -0,0 +1,106 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/kstenerud/go-describe"
+)
+
+func TestDelta(t *testing.T) {
+	values := []DFloat{
+		DFloatValue(-2, 10123),
+		DFloatValue(-2, 10125),
+		DFloatValue(-2, 10119),
+		DFloatValue(-2, 10119),
+		NegativeZero(),
+		Infinity(),
+		DFloatValue(0x7fffffff, math.MaxInt64),
+		DFloatValue(-0x7fffffff, math.MinInt64),
+		QuietNaN(),
+	}
+	buffer := &bytes.Buffer{}
+	encoder := NewDeltaEncoder(buffer)
+	for _, value := range values {
+		if _, err := encoder.Encode(value); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	expectedStart := []byte{0x03, 0x96, 0x9e, 0x01, 0x00, 0x04, 0x00, 0x0b, 0x00, 0x00}
+	if !bytes.Equal(buffer.Bytes()[:len(expectedStart)], expectedStart) {
+		t.Errorf("Expected encoding to start with %v but got %v", describe.D(expectedStart), describe.D(buffer.Bytes()))
+		return
+	}
+
+	decoder := NewDeltaDecoder(buffer)
+	for _, expected := range values {
+		actual, _, err := decoder.Decode()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if actual != expected {
+			t.Errorf("Expected %v but got %v", expected, actual)
+			return
+		}
+	}
+	if buffer.Len() != 0 {
+		t.Errorf("Expected all data to be decoded but %v bytes remain", buffer.Len())
+	}
+}
+
+func TestDeltaTruncated(t *testing.T) {
+	for _, encoded := range [][]byte{{0x03}, {0x96}, {0x03, 0x96}, {0x03, 0x96, 0x9e}} {
+		decoder := NewDeltaDecoder(bytes.NewBuffer(encoded))
+		if _, _, err := decoder.Decode(); err != ErrorIncomplete {
+			t.Errorf("Expected ErrorIncomplete for %v but got %v", describe.D(encoded), err)
+		}
+	}
+	decoder := NewDeltaDecoder(bytes.NewBuffer(nil))
+	if _, _, err := decoder.Decode(); err != io.EOF {
+		t.Errorf("Expected io.EOF for empty data but got %v", err)
+	}
+}
+
+func TestDeltaIllegalSpecial(t *testing.T) {
+	encoded := []byte{0xff, 0xff, 0xff, 0xff, 0x0f, 0x54}
+	decoder := NewDeltaDecoder(bytes.NewBuffer(encoded))
+	if value, _, err := decoder.Decode(); !errors.Is(err, ErrorMalformed) {
+		t.Errorf("Expected ErrorMalformed for %v but got %v (%v)", describe.D(encoded), value, err)
+	}
+}
+
+func TestZigzag(t *testing.T) {
+	for _, value := range []int64{0, 1, -1, 2, -2, math.MaxInt64, math.MinInt64} {
+		if actual := zigzagDecode(zigzagEncode(value)); actual != value {
+			t.Errorf("Expected %v but got %v", value, actual)
+		}
+	}
+	if zigzagEncode(-1) != 1 || zigzagEncode(1) != 2 {
+		t.Errorf("Expected zigzag -1 = 1 and 1 = 2 but got %v and %v", zigzagEncode(-1), zigzagEncode(1))
+	}
+}
@@ -21,14 +21,13 @@
 package compact_float
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
 	"math/big"
 	"strconv"
 	"strings"
-
-	"github.com/cockroachdb/apd/v2"
 )
 
 // An exponent value of ExpSpecial indicates that this is a special value.
@@ -36,11 +35,13 @@ import (
 // is represented (CoeffInfinity, CoeffNan, etc).
 const ExpSpecial = int32(-0x80000000)
 const (
-	CoeffNegativeZero     = 0
-	CoeffInfinity         = 1
-	CoeffNegativeInfinity = 5
-	CoeffNan              = 2
-	CoeffSignalingNan     = 6
+	CoeffNegativeZero         = 0
+	CoeffInfinity             = 1
+	CoeffNegativeInfinity     = 5
+	CoeffNan                  = 2
+	CoeffSignalingNan         = 6
+	CoeffNegativeNan          = 10
+	CoeffNegativeSignalingNan = 14
 )
 
 // DFloat represents a decimal floating point value in 96 bits.
@@ -60,12 +61,34 @@ func DFloatValue(exponent int32, coefficient int64) DFloat {
 	}.minimized()
 }
 
+// Create a DFloat without minimizing it, so that any trailing zeros in the
+// coefficient (and thus the declared precision) are kept when it's encoded and
+// formatted. Note that such values are not canonical (see DecodeCanonical()).
+func DFloatValuePreserving(exponent int32, coefficient int64) DFloat {
+	return DFloat{
+		Exponent:    exponent,
+		Coefficient: coefficient,
+	}
+}
+
 // Convert an iee754 binary floating point value to DFloat, with the specified
 // number of significant digits. Rounding is half-to-even, meaning it rounds
 // towards an even number when exactly halfway. If rounding occurs, the returned
 // error will be RoundingError.
 // If significantDigits is less than 1, no rounding takes place.
 func DFloatFromFloat64(value float64, significantDigits int) (DFloat, error) {
+	return DFloatFromFloat64WithMode(value, significantDigits, RoundHalfEven)
+}
+
+// Convert an iee754 binary floating point value to DFloat, with the specified
+// number of significant digits, using the specified rounding mode. If rounding
+// occurs, the returned error will be RoundingError.
+// If significantDigits is less than 1, no rounding takes place.
+// Fails with ErrorUnknownRoundingMode if the rounding mode is unknown.
+func DFloatFromFloat64WithMode(value float64, significantDigits int, rounding RoundingMode) (DFloat, error) {
+	if !isKnownRoundingMode(rounding) {
+		return dfloatZero, fmt.Errorf("%w: %q", ErrorUnknownRoundingMode, rounding)
+	}
 	if math.Float64bits(value) == math.Float64bits(0) {
 		return dfloatZero, nil
 	} else if value == math.Copysign(0, -1) {
@@ -76,14 +99,42 @@ func DFloatFromFloat64(value float64, significantDigits int) (DFloat, error) {
 		return dfloatNegativeInfinity, nil
 	} else if math.IsNaN(value) {
 		bits := math.Float64bits(value)
+		if bits&signBit != 0 {
+			if bits&quietBit != 0 {
+				return dfloatNegativeNaN, nil
+			}
+			return dfloatNegativeSignalingNaN, nil
+		}
 		if bits&quietBit != 0 {
 			return dfloatNaN, nil
 		}
 		return dfloatSignalingNaN, nil
 	}
 
-	asString := strconv.FormatFloat(value, 'g', -1, 64)
-	return decodeFromString(asString, significantDigits)
+	coefficient, exponent := float64ToShortestDecimal(math.Abs(value))
+	var err error
+	if significantDigits > 0 && significantDigits < len(digitsMax) && coefficient > digitsMax[significantDigits] {
+		digitCount := len(digitsMax) - 1
+		for coefficient <= digitsMax[digitCount-1] {
+			digitCount--
+		}
+		dropped := digitCount - significantDigits
+		divisor := digitsMax[dropped] + 1
+		remainder := coefficient % divisor
+		coefficient /= divisor
+		exponent += int32(dropped)
+		roundedAway := remainder != 0 && shouldRoundAway(coefficient, remainder, divisor, value < 0, rounding)
+		if roundedAway {
+			coefficient++
+		}
+		err = newRoundingError(strconv.FormatFloat(value, 'g', -1, 64), dropped, roundedAway)
+	}
+
+	result := DFloatValue(exponent, int64(coefficient))
+	if value < 0 {
+		result.Coefficient = -result.Coefficient
+	}
+	return result, err
 }
 
 // Convert an unsigned int to DFloat. If the value is too big to fit, its lowest
@@ -94,18 +145,14 @@ func DFloatFromUInt(value uint64) (DFloat, error) {
 		return DFloatValue(0, int64(value)), nil
 	}
 
+	original := value
 	remainder := value % 10
 	value /= 10
-	if remainder >= 5 {
-		if remainder == 5 {
-			if value&1 == 1 {
-				value++
-			}
-		} else {
-			value++
-		}
+	roundedAway := remainder > 5 || (remainder == 5 && value&1 == 1)
+	if roundedAway {
+		value++
 	}
-	return DFloatValue(1, int64(value)), roundingError
+	return DFloatValue(1, int64(value)), newRoundingError(strconv.FormatUint(original, 10), 1, roundedAway)
 }
 
 // Convert a big.Int to DFloat. If the value is too big to fit, its lower
@@ -120,7 +167,7 @@ func DFloatFromBigInt(value *big.Int) (DFloat, error) {
 		return DFloatFromUInt(value.Uint64())
 	}
 
-	return DFloatFromAPD(apd.NewWithBigInt(value, 0))
+	return DFloatFromString(value.String())
 }
 
 var bitsToDigits = []int{0, 1, 1, 1, 1, 2, 2, 2, 3, 3}
@@ -137,54 +184,141 @@ func DFloatFromBigFloat(value *big.Float) (DFloat, error) {
 		return dfloatInfinity, nil
 	}
 
+	return DFloatFromString(bigFloatText(value))
+}
+
+// Returns the text of a big.Float to as many digits as its precision allows.
+func bigFloatText(value *big.Float) string {
 	precisionBits := int(value.Prec())
 	digits := (precisionBits/10)*3 + bitsToDigits[precisionBits%10]
-	str := value.Text('g', digits)
-	return DFloatFromString(str)
+	return value.Text('g', digits)
 }
 
-// Convert an apd.Decimal to DFloat. If the value is too big to fit, its lower
-// significant digits will be rounded (half-to-even) and
+// Convert a string float representation to DFloat. If the value is too big to
+// fit, its lower significant digits will be rounded (half-to-even) and
 // RoundingError will be returned along with the rounded value.
-func DFloatFromAPD(value *apd.Decimal) (DFloat, error) {
-	if value.IsZero() {
-		if value.Negative {
-			return dfloatNegativeZero, nil
-		}
-		return dfloatZero, nil
+func DFloatFromString(str string) (DFloat, error) {
+	return decodeFromString(str, parseConfig{})
+}
+
+// Convert a string float representation to DFloat, with the specified number
+// of significant digits. Rounding is half-to-even. If rounding occurs, the
+// returned error will be RoundingError.
+// If significantDigits is less than 1, it behaves like DFloatFromString().
+func DFloatFromStringWithDigits(str string, significantDigits int) (DFloat, error) {
+	return decodeFromString(str, parseConfig{significantDigits: significantDigits})
+}
+
+// Convert a string float representation to DFloat, with the specified number
+// of significant digits, using the specified rounding mode. If rounding occurs,
+// the returned error will be RoundingError.
+// If significantDigits is less than 1, only values too big to fit are rounded.
+// Fails with ErrorUnknownRoundingMode if the rounding mode is unknown.
+func DFloatFromStringWithMode(str string, significantDigits int, rounding RoundingMode) (DFloat, error) {
+	if !isKnownRoundingMode(rounding) {
+		return dfloatZero, fmt.Errorf("%w: %q", ErrorUnknownRoundingMode, rounding)
 	}
-	switch value.Form {
-	case apd.Infinite:
-		if value.Negative {
-			return dfloatNegativeInfinity, nil
-		}
-		return dfloatInfinity, nil
-	case apd.NaN:
-		return dfloatNaN, nil
-	case apd.NaNSignaling:
-		return dfloatSignalingNaN, nil
+	return decodeFromString(str, parseConfig{significantDigits: significantDigits, rounding: rounding})
+}
+
+// Convert a string float representation to DFloat, keeping any trailing zeros
+// in the coefficient so that the declared precision survives encoding and
+// formatting ("1.500" becomes 1500e-3 rather than 15e-1). Zero is still
+// stored as plain zero. Otherwise it behaves like DFloatFromString().
+func DFloatFromStringPreserving(str string) (DFloat, error) {
+	return decodeFromString(str, parseConfig{preserveTrailingZeros: true})
+}
+
+// Parse a string float representation to DFloat. Unlike DFloatFromString(),
+// degenerate input such as an empty string, a bare sign, a lone decimal point,
+// or an exponent marker without digits is rejected with ErrorSyntax. Other
+// behavior (including rounding) is the same as DFloatFromString().
+func Parse(str string) (DFloat, error) {
+	return decodeFromString(str, parseConfig{strict: true})
+}
+
+// Parse a string float representation to DFloat, with the specified number of
+// significant digits (see Parse() and DFloatFromStringWithDigits()).
+func ParseWithDigits(str string, significantDigits int) (DFloat, error) {
+	return decodeFromString(str, parseConfig{strict: true, significantDigits: significantDigits})
+}
+
+// Parse a string float representation to DFloat like Parse(), also returning
+// the payload of a NaN. Payloads can be written as in C ("nan(42)",
+// "snan(0x2a)") or as in the General Decimal Arithmetic specification
+// ("NaN42"). DFloat itself has no room for a payload, so callers that need to
+// preserve it must keep it alongside the value. payload is 0 for NaNs without
+// a payload, and for all other values.
+func ParseWithNaNPayload(str string) (value DFloat, payload uint64, err error) {
+	if value, err = Parse(str); err == nil && value.IsNan() {
+		_, payload, _ = splitNaNPayload(strings.ToLower(strings.TrimLeft(str, "+-")))
 	}
+	return
+}
 
-	if value.Coeff.IsInt64() {
-		d := DFloat{
-			Exponent:    value.Exponent,
-			Coefficient: value.Coeff.Int64(),
-		}.minimized()
-		if value.Negative {
-			d.Coefficient = -d.Coefficient
-		}
-		return d, nil
+// Parse a string float representation like Parse(), except that a value with
+// too many significant digits to fit into a DFloat is returned in full
+// precision as bigValue rather than being rounded, mirroring Decode(). bigValue
+// will be nil unless the value is too big to fit into a DFloat. Exponents
+// outside of the DFloat range still fail with ErrorExponentTooLarge.
+//
+// When building with the compactfloat_nobig tag, a value that would have to be
+// rounded fails with ErrorValueTooLarge instead.
+func ParseAny(str string) (value DFloat, bigValue *BigDecimal, err error) {
+	if value, err = Parse(str); !errors.Is(err, roundingError) {
+		return
 	}
+	return parseBigValue(str, err)
+}
 
-	str := value.Text('g')
-	return DFloatFromString(str)
+// Convert a json.Number (as produced by json.Decoder.UseNumber()) to DFloat. If
+// the value is too big to fit, its lower significant digits will be rounded
+// (half-to-even) and RoundingError will be returned along with the rounded
+// value. Fails with ErrorSyntax if the value isn't a valid JSON number (such as
+// an empty string, "nan", "inf", "+1" or "1e").
+func DFloatFromJSONNumber(value json.Number) (DFloat, error) {
+	if !isJSONNumberSyntax(string(value)) {
+		return dfloatZero, fmt.Errorf("%w: %q is not a JSON number", ErrorSyntax, string(value))
+	}
+	return Parse(string(value))
 }
 
-// Convert a string float representation to DFloat. If the value is too big to
-// fit, its lower significant digits will be rounded (half-to-even) and
-// RoundingError will be returned along with the rounded value.
-func DFloatFromString(str string) (DFloat, error) {
-	return decodeFromString(str, 0)
+// Returns true if str follows the JSON number grammar (RFC 8259 section 6):
+// an optional minus sign, an integer part without leading zeros, then an
+// optional fraction and exponent, each with at least one digit.
+func isJSONNumberSyntax(str string) bool {
+	skipDigits := func(str string) (rest string, digitCount int) {
+		for digitCount < len(str) && str[digitCount] >= '0' && str[digitCount] <= '9' {
+			digitCount++
+		}
+		return str[digitCount:], digitCount
+	}
+
+	if len(str) > 0 && str[0] == '-' {
+		str = str[1:]
+	}
+	if len(str) > 1 && str[0] == '0' && str[1] >= '0' && str[1] <= '9' {
+		return false
+	}
+	str, digitCount := skipDigits(str)
+	if digitCount == 0 {
+		return false
+	}
+	if len(str) > 0 && str[0] == '.' {
+		if str, digitCount = skipDigits(str[1:]); digitCount == 0 {
+			return false
+		}
+	}
+	if len(str) > 0 && (str[0] == 'e' || str[0] == 'E') {
+		str = str[1:]
+		if len(str) > 0 && (str[0] == '+' || str[0] == '-') {
+			str = str[1:]
+		}
+		if str, digitCount = skipDigits(str); digitCount == 0 {
+			return false
+		}
+	}
+	return len(str) == 0
 }
 
 func Zero() DFloat {
@@ -211,6 +345,14 @@ func SignalingNaN() DFloat {
 	return dfloatSignalingNaN
 }
 
+func NegativeQuietNaN() DFloat {
+	return dfloatNegativeNaN
+}
+
+func NegativeSignalingNaN() DFloat {
+	return dfloatNegativeSignalingNaN
+}
+
 func (this DFloat) IsSpecial() bool {
 	return this.Exponent == ExpSpecial
 }
@@ -238,8 +380,14 @@ func (this DFloat) IsNegativeInfinity() bool {
 	return this == dfloatNegativeInfinity
 }
 
+// Returns true if the value is a positive or negative signaling NaN
 func (this DFloat) IsSignalingNan() bool {
-	return this == dfloatSignalingNaN
+	return this == dfloatSignalingNaN || this == dfloatNegativeSignalingNaN
+}
+
+// Returns true if the value is a quiet or signaling NaN with its sign bit set
+func (this DFloat) IsNegativeNan() bool {
+	return this == dfloatNegativeNaN || this == dfloatNegativeSignalingNaN
 }
 
 func (this DFloat) String() string {
@@ -260,17 +408,169 @@ func (this DFloat) String() string {
 // displaying precision that is not present in the Decimal when it appends
 // zeros. All other formats always show the exact precision of the Decimal.
 //
-// This method call is forwarded to *apd.Decimal.Text()
+// The output is identical to that of *apd.Decimal.Text()
 func (this DFloat) Text(format byte) string {
-	if this == NegativeZero() {
-		return "-0"
+	var buffer [32]byte
+	return string(this.AppendText(buffer[:0], format))
+}
+
+// Appends the String() representation of this value to dst, returning the
+// extended slice. No allocations occur if dst has enough capacity.
+func (this DFloat) AppendString(dst []byte) []byte {
+	return this.AppendText(dst, 'g')
+}
+
+// Appends the Text() representation of this value in the given format to dst,
+// returning the extended slice. No allocations occur if dst has enough
+// capacity.
+func (this DFloat) AppendText(dst []byte, format byte) []byte {
+	if this.IsSpecial() {
+		switch this {
+		case dfloatNegativeZero:
+			return append(dst, "-0"...)
+		case dfloatInfinity:
+			return append(dst, "Infinity"...)
+		case dfloatNegativeInfinity:
+			return append(dst, "-Infinity"...)
+		case dfloatNaN:
+			return append(dst, "NaN"...)
+		case dfloatSignalingNaN:
+			return append(dst, "sNaN"...)
+		case dfloatNegativeNaN:
+			return append(dst, "-NaN"...)
+		case dfloatNegativeSignalingNaN:
+			return append(dst, "-sNaN"...)
+		}
+		return DFloat{}.AppendText(dst, format)
+	}
+
+	var digitsBuffer [20]byte
+	magnitude := uint64(this.Coefficient)
+	if this.Coefficient < 0 {
+		magnitude = -magnitude
 	}
-	return this.APD().Text(format)
+	digits := strconv.AppendUint(digitsBuffer[:0], magnitude, 10)
+	return appendDecimalText(dst, this.Coefficient < 0, int64(this.Exponent), digits, format)
+}
+
+// Controls how TextWithZeroFormat() and AppendTextWithZeroFormat() render
+// zeros. The zero value renders every zero (including -0 and zeros with
+// preserved trailing digits) as "0" in the 'f' format and as "0e+0" in the 'e'
+// format, so that zeros line up with the other values in scientific output.
+type ZeroFormat struct {
+	// The number of zero digits to render after the decimal point ("0.000e+0"
+	// in the 'e' format and "0.000" in the 'f' format for 3).
+	FractionDigits int
+}
+
+// Returns the Text() representation of this value, rendering zeros as
+// specified by zero rather than as a plain "0" or "-0".
+func (this DFloat) TextWithZeroFormat(format byte, zero ZeroFormat) string {
+	var buffer [32]byte
+	return string(this.AppendTextWithZeroFormat(buffer[:0], format, zero))
+}
+
+// Appends the TextWithZeroFormat() representation of this value to dst,
+// returning the extended slice.
+//
+// Panics if zero.FractionDigits is negative.
+func (this DFloat) AppendTextWithZeroFormat(dst []byte, format byte, zero ZeroFormat) []byte {
+	if zero.FractionDigits < 0 {
+		panic(fmt.Errorf("%v: negative zero fraction digits", zero.FractionDigits))
+	}
+	if !this.IsZero() {
+		return this.AppendText(dst, format)
+	}
+
+	var digitsBuffer [20]byte
+	digits := digitsBuffer[:0]
+	for i := 0; i <= zero.FractionDigits; i++ {
+		digits = append(digits, '0')
+	}
+	return appendDecimalText(dst, this.IsNegativeZero(), -int64(zero.FractionDigits), digits, format)
+}
+
+// Appends the text representation of a finite value with the given sign,
+// exponent and coefficient digits, in the same formats as DFloat.Text().
+func appendDecimalText(dst []byte, isNegative bool, exponent int64, digits []byte, format byte) []byte {
+	sign := len(dst)
+	if isNegative {
+		dst = append(dst, '-')
+	}
+	switch format {
+	case 'e', 'E':
+		return appendFormatE(dst, format, exponent, digits)
+	case 'f':
+		return appendFormatF(dst, exponent, digits)
+	case 'g', 'G':
+		// See: http://speleotrove.com/decimal/daconvs.html#reftostr
+		const adjustedExponentLimit = -6
+		adjustedExponent := exponent + int64(len(digits)-1)
+		if exponent <= 0 && adjustedExponent >= adjustedExponentLimit {
+			return appendFormatF(dst, exponent, digits)
+		}
+		return appendFormatE(dst, format+'e'-'g', exponent, digits)
+	}
+	return append(dst[:sign], '%', format)
+}
+
+// d.ddddde±d
+func appendFormatE(dst []byte, format byte, exponent int64, digits []byte) []byte {
+	adjustedExponent := exponent + int64(len(digits)) - 1
+	dst = append(dst, digits[0])
+	if len(digits) > 1 {
+		dst = append(dst, '.')
+		dst = append(dst, digits[1:]...)
+	}
+	dst = append(dst, format)
+	if adjustedExponent < 0 {
+		dst = append(dst, '-')
+		adjustedExponent = -adjustedExponent
+	} else {
+		dst = append(dst, '+')
+	}
+	return strconv.AppendInt(dst, adjustedExponent, 10)
+}
+
+// ddddddd.ddddd
+func appendFormatF(dst []byte, exponent int64, digits []byte) []byte {
+	if exponent >= 0 {
+		dst = append(dst, digits...)
+		for i := int64(0); i < exponent; i++ {
+			dst = append(dst, '0')
+		}
+		return dst
+	}
+
+	if left := -exponent - int64(len(digits)); left >= 0 {
+		dst = append(dst, "0."...)
+		for i := int64(0); i < left; i++ {
+			dst = append(dst, '0')
+		}
+		return append(dst, digits...)
+	}
+	point := int64(len(digits)) + exponent
+	dst = append(dst, digits[:point]...)
+	dst = append(dst, '.')
+	return append(dst, digits[point:]...)
+}
+
+// Returns the json.Number representation of this value.
+// Returns an error if the value is infinity or NaN, which JSON cannot
+// represent.
+func (this DFloat) JSONNumber() (json.Number, error) {
+	if this.IsInfinity() || this.IsNan() {
+		return "", fmt.Errorf("%v cannot be represented as a JSON number", this)
+	}
+	return json.Number(this.Text('g')), nil
 }
 
 // Returns the int64 representation of this value.
 // Returns an error if the value cannot fit.
 func (this DFloat) Int() (int64, error) {
+	if this.IsNegativeZero() {
+		return 0, nil
+	}
 	if this.Exponent < 0 {
 		return 0, fmt.Errorf("%v cannot fit into int: Not a whole number", this)
 	}
@@ -279,8 +579,8 @@ func (this DFloat) Int() (int64, error) {
 	}
 	expMult := int64(exponentMultipliers[this.Exponent])
 	result := this.Coefficient * expMult
-	if result < 0 || result/expMult != this.Coefficient {
-		return 0, fmt.Errorf("%v cannot fit into uint: Value too big", this)
+	if result/expMult != this.Coefficient {
+		return 0, fmt.Errorf("%v cannot fit into int: Value too big", this)
 	}
 	return result, nil
 }
@@ -288,6 +588,12 @@ func (this DFloat) Int() (int64, error) {
 // Returns the uint64 representation of this value.
 // Returns an error if the value cannot fit.
 func (this DFloat) Uint() (uint64, error) {
+	if this.IsNegativeZero() {
+		return 0, nil
+	}
+	if this.Coefficient < 0 {
+		return 0, fmt.Errorf("%v cannot fit into uint: Negative", this)
+	}
 	if this.Exponent < 0 {
 		return 0, fmt.Errorf("%v cannot fit into uint: Not a whole number", this)
 	}
@@ -305,6 +611,9 @@ func (this DFloat) Uint() (uint64, error) {
 // Returns the big.Int representation of this value.
 // Returns an error if the value is not a whole number.
 func (this DFloat) BigInt() (*big.Int, error) {
+	if this.IsNegativeZero() {
+		return new(big.Int), nil
+	}
 	if this.Exponent < 0 {
 		return nil, fmt.Errorf("%v cannot fit into big.Int: Not a whole number", this)
 	}
@@ -314,8 +623,9 @@ func (this DFloat) BigInt() (*big.Int, error) {
 	return exp.Mul(exp, big.NewInt(this.Coefficient)), nil
 }
 
-// Returns the float64 representation of this value. The result will be rounded
-// according to strconv.ParseFloat() if it doesn't fit.
+// Returns the float64 representation of this value, correctly rounded
+// (half-to-even) if it doesn't fit. Values with up to 15 digit coefficients
+// and small exponents are converted without allocating.
 func (this DFloat) Float() float64 {
 	switch this {
 	case dfloatZero:
@@ -333,6 +643,14 @@ func (this DFloat) Float() float64 {
 		return math.Float64frombits(math.Float64bits(math.NaN()) | uint64(quietBit))
 	case dfloatSignalingNaN:
 		return math.Float64frombits(math.Float64bits(math.NaN()) & ^uint64(quietBit))
+	case dfloatNegativeNaN:
+		return math.Float64frombits(math.Float64bits(math.NaN()) | uint64(quietBit) | signBit)
+	case dfloatNegativeSignalingNaN:
+		return math.Float64frombits(math.Float64bits(math.NaN())&^uint64(quietBit) | signBit)
+	}
+
+	if result, ok := this.exactFloat(); ok {
+		return result
 	}
 
 	result, err := strconv.ParseFloat(this.String(), 64)
@@ -346,60 +664,115 @@ func (this DFloat) Float() float64 {
 	return result
 }
 
+// Powers of ten that are exactly representable in a float64.
+var float64Pow10 = [...]float64{
+	1e0, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10, 1e11,
+	1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18, 1e19, 1e20, 1e21, 1e22,
+}
+
+// The largest integer magnitude below which all integers are exactly
+// representable in a float64.
+const float64MaxExactInt = 1 << 53
+
+// Converts to float64 without allocating when both the coefficient and the
+// power of ten are exact in float64, in which case a single multiply or divide
+// produces the correctly rounded result (Clinger's fast path). Returns false if
+// the fast path doesn't apply.
+func (this DFloat) exactFloat() (result float64, ok bool) {
+	coefficient := this.Coefficient
+	negative := coefficient < 0
+	if negative {
+		coefficient = -coefficient
+	}
+	if coefficient > float64MaxExactInt || coefficient < 0 {
+		return
+	}
+
+	exponent := this.Exponent
+	maxExponent := int32(len(float64Pow10) - 1)
+	if exponent > maxExponent {
+		// Move the excess into the coefficient if it stays exact.
+		for exponent > maxExponent && coefficient <= float64MaxExactInt/10 {
+			coefficient *= 10
+			exponent--
+		}
+		if exponent > maxExponent {
+			return
+		}
+	}
+	if exponent < -maxExponent {
+		return
+	}
+
+	result = float64(coefficient)
+	if exponent >= 0 {
+		result *= float64Pow10[exponent]
+	} else {
+		result /= float64Pow10[-exponent]
+	}
+	if negative {
+		result = -result
+	}
+	return result, true
+}
+
+// Convert to a big.Float with 63 bits of precision (enough to hold any
+// coefficient exactly), rounding half-to-even. Returns nil for NaN (see
+// BigFloatWithPrecision()).
 func (this DFloat) BigFloat() *big.Float {
+	return this.BigFloatWithPrecision(63, big.ToNearestEven)
+}
+
+// Convert to a big.Float with the specified precision (in bits) and rounding
+// mode. The value is computed as coefficient × 10^exponent in big.Float
+// arithmetic. The result is correctly rounded whenever 10^|exponent| is exactly
+// representable in precision+64 bits (roughly |exponent| <= (precision+64)/2.3),
+// and is otherwise within an ulp.
+//
+// big.Float has no NaN, so every NaN (quiet or signaling, positive or negative)
+// returns nil.
+func (this DFloat) BigFloatWithPrecision(precision uint, mode big.RoundingMode) *big.Float {
+	if this.IsNan() {
+		return nil
+	}
+	result := new(big.Float).SetPrec(precision).SetMode(mode)
 	switch this {
 	case dfloatZero:
-		return big.NewFloat(0.0)
+		return result.SetFloat64(0)
 	case dfloatNegativeZero:
-		// Go doesn't handle literal -0.0 by design
-		v := 0.0
-		v = -v
-		return big.NewFloat(v)
+		return result.Neg(result.SetFloat64(0))
 	case dfloatInfinity:
-		return big.NewFloat(math.Inf(1))
+		return result.SetInf(false)
 	case dfloatNegativeInfinity:
-		return big.NewFloat(math.Inf(-1))
-	case dfloatNaN:
-		return big.NewFloat(math.Float64frombits(math.Float64bits(math.NaN()) | uint64(quietBit)))
-	case dfloatSignalingNaN:
-		return big.NewFloat(math.Float64frombits(math.Float64bits(math.NaN()) & ^uint64(quietBit)))
+		return result.SetInf(true)
 	}
 
-	str := this.String()
-	f, _, err := big.ParseFloat(str, 10, 63, big.ToNearestEven)
-	if err != nil {
-		panic(fmt.Errorf("BUG: error decoding stringified DFloat %v: %v", this, err))
+	coefficient := new(big.Float).SetInt64(this.Coefficient)
+	if this.Exponent == 0 || this.Coefficient == 0 {
+		return result.Set(coefficient)
 	}
-	return f
+
+	exponent := int64(this.Exponent)
+	if exponent > 0 {
+		return result.Mul(coefficient, bigFloatPow10(uint64(exponent), precision+64))
+	}
+	return result.Quo(coefficient, bigFloatPow10(uint64(-exponent), precision+64))
 }
 
-// Returns the apd.Decimal representation of this value. All DFloat values can
-// be represented as apd.Decimal.
-func (this DFloat) APD() *apd.Decimal {
-	switch this {
-	case dfloatNegativeZero:
-		v := apd.New(0, 0)
-		v.Negative = true
-		return v
-	case dfloatInfinity:
-		v := apd.New(0, 0)
-		v.Form = apd.Infinite
-		return v
-	case dfloatNegativeInfinity:
-		v := apd.New(0, 0)
-		v.Form = apd.Infinite
-		v.Negative = true
-		return v
-	case dfloatNaN:
-		v := apd.New(0, 0)
-		v.Form = apd.NaN
-		return v
-	case dfloatSignalingNaN:
-		v := apd.New(0, 0)
-		v.Form = apd.NaNSignaling
-		return v
+// Returns 10^exponent at the specified precision, computed by squaring.
+func bigFloatPow10(exponent uint64, precision uint) *big.Float {
+	result := new(big.Float).SetPrec(precision).SetInt64(1)
+	base := new(big.Float).SetPrec(precision).SetInt64(10)
+	for {
+		if exponent&1 == 1 {
+			result.Mul(result, base)
+		}
+		exponent >>= 1
+		if exponent == 0 {
+			return result
+		}
+		base.Mul(base, base)
 	}
-	return apd.New(this.Coefficient, this.Exponent)
 }
 
 func (this DFloat) minimized() (d DFloat) {
@@ -414,7 +787,7 @@ func (this DFloat) minimized() (d DFloat) {
 		return
 	}
 
-	for {
+	for d.Exponent < math.MaxInt32 {
 		coeff := d.Coefficient / 10
 		if coeff*10 != d.Coefficient {
 			break
@@ -441,38 +814,110 @@ var digitsMax = []uint64{
 	9999999999999999999,
 }
 
-func decodeFromString(value string, significantDigits int) (result DFloat, err error) {
+// Settings that modify how decodeFromString() parses a value.
+type parseConfig struct {
+	// Round to this many significant digits (if greater than 0).
+	significantDigits int
+	// Keep trailing zeros in the coefficient rather than minimizing it.
+	preserveTrailingZeros bool
+	// Clamp out of range exponents to infinity or zero rather than failing.
+	clampExponent bool
+	// Reject degenerate input (see Parse()).
+	strict bool
+	// Accept "∞" and the Unicode minus sign (see Context.AllowUnicode).
+	allowUnicode bool
+	// The rounding mode to use when dropping digits ("" selects half_even).
+	rounding RoundingMode
+}
+
+// Splits a lowercased NaN with an optional payload ("nan42", "nan(42)" or
+// "snan(0x2a)") into its name and payload. Other text is returned unchanged.
+// ok is false if the payload is malformed or doesn't fit into a uint64.
+func splitNaNPayload(text string) (name string, payload uint64, ok bool) {
+	switch {
+	case strings.HasPrefix(text, "snan"):
+		name = "snan"
+	case strings.HasPrefix(text, "nan"):
+		name = "nan"
+	default:
+		return text, 0, true
+	}
+	payloadText := text[len(name):]
+	if payloadText == "" {
+		return name, 0, true
+	}
+	base := 10
+	if len(payloadText) >= 2 && payloadText[0] == '(' && payloadText[len(payloadText)-1] == ')' {
+		payloadText = payloadText[1 : len(payloadText)-1]
+		if strings.HasPrefix(payloadText, "0x") {
+			payloadText = payloadText[2:]
+			base = 16
+		}
+	}
+	payload, err := strconv.ParseUint(payloadText, base, 64)
+	return name, payload, err == nil
+}
+
+var unicodeSpellings = strings.NewReplacer("\u2212", "-", "\u221e", "inf")
+
+func decodeFromString(value string, config parseConfig) (result DFloat, err error) {
 	if len(value) < 1 {
+		if config.strict {
+			return dfloatZero, fmt.Errorf("%w: Empty string", ErrorSyntax)
+		}
 		return dfloatZero, nil
 	}
+	original := value
+	rounding := config.rounding
+	if rounding == "" {
+		rounding = RoundHalfEven
+	} else if !isKnownRoundingMode(rounding) {
+		panic(fmt.Errorf("%q: unknown rounding mode", rounding))
+	}
 
 	const significandCap = uint64(0x7fffffffffffffff)
 
 	significandMax := uint64(0)
 	significandMaxDigits := len(digitsMax) - 1
-	if significantDigits <= 0 || significantDigits > significandMaxDigits {
+	if config.significantDigits <= 0 || config.significantDigits > significandMaxDigits {
 		significandMax = uint64(0x7fffffffffffffff)
 	} else {
-		significandMax = digitsMax[significantDigits]
+		significandMax = digitsMax[config.significantDigits]
 	}
 
 	cutoffDigitCount := 0
 	fractionalDigitCount := 0
+	droppedDigitCount := 0
 
 	exponent := int64(0)
 	significand := uint64(0)
 	significandSign := int64(1)
 	rounded := 0
 	firstRounded := true
+	droppedNonZero := false
 	didRoundResult := false
+	mantissaDigitCount := 0
 
+	if config.allowUnicode {
+		value = unicodeSpellings.Replace(value)
+	}
 	if value[0] == '-' {
 		significandSign = -1
 		value = value[1:]
+	} else if value[0] == '+' {
+		value = value[1:]
+	}
+	if len(value) == 0 {
+		err = fmt.Errorf("%w: %q is not a floating point value", ErrorSyntax, original)
+		return
 	}
 
 	if value[0] > '9' {
-		value := strings.ToLower(value)
+		value, _, ok := splitNaNPayload(strings.ToLower(value))
+		if !ok {
+			err = fmt.Errorf("%w: %q has a malformed NaN payload", ErrorSyntax, original)
+			return
+		}
 		switch value {
 		case "inf", "infinity":
 			if significandSign < 0 {
@@ -483,40 +928,49 @@ func decodeFromString(value string, significantDigits int) (result DFloat, err e
 			return
 		case "nan":
 			if significandSign < 0 {
-				err = fmt.Errorf("NaN cannot be negative")
-				return
+				result = dfloatNegativeNaN
+			} else {
+				result = dfloatNaN
 			}
-			result = dfloatNaN
 			return
 		case "snan":
 			if significandSign < 0 {
-				err = fmt.Errorf("NaN cannot be negative")
-				return
+				result = dfloatNegativeSignalingNaN
+			} else {
+				result = dfloatSignalingNaN
 			}
-			result = dfloatSignalingNaN
 			return
 		default:
-			err = fmt.Errorf("%v: Not a floating point value", value)
+			err = fmt.Errorf("%w: %q is not a floating point value", ErrorSyntax, original)
+			return
 		}
 	}
 
 	decodeExponent := func(str string) error {
-		const exponentCap = int64(0x7fffffff)
+		// Leave room for the exponent to be adjusted afterwards
+		const exponentCap = int64(1) << 40
 		exponentSign := int64(1)
-		if str[0] == '-' {
+		if len(str) > 0 && str[0] == '-' {
 			exponentSign = -1
 			str = str[1:]
-		} else if str[0] == '+' {
+		} else if len(str) > 0 && str[0] == '+' {
 			str = str[1:]
 		}
+		if config.strict && len(str) == 0 {
+			return fmt.Errorf("%w: %q has no exponent digits", ErrorSyntax, original)
+		}
 
 		for _, ch := range str {
 			if ch < '0' || ch > '9' {
-				return fmt.Errorf("%c: Unexpected character while decoding DFloat exponent", ch)
+				return fmt.Errorf("%w: Unexpected character %q in %q", ErrorSyntax, ch, original)
 			}
 			exponent = exponent*10 + int64(ch-'0')
 			if exponent > exponentCap {
-				return fmt.Errorf("Exponent overflow while decoding DFloat")
+				if !config.clampExponent {
+					return &ExponentOverflowErrorDetails{Original: original, Exponent: exponent * exponentSign}
+				}
+				// Still far enough out of range to clamp the same way.
+				exponent = exponentCap
 			}
 		}
 		exponent *= exponentSign
@@ -531,12 +985,17 @@ func decodeFromString(value string, significantDigits int) (result DFloat, err e
 				return decodeExponent(str[i+1:])
 			}
 			if ch < '0' || ch > '9' {
-				return fmt.Errorf("%c: Unexpected character while decoding DFloat", ch)
+				return fmt.Errorf("%w: Unexpected character %q in %q", ErrorSyntax, ch, original)
 			}
+			mantissaDigitCount++
 			if firstRounded || rounded == 5 {
 				rounded = rounded + int(ch-'0')
 				firstRounded = false
 			}
+			if ch != '0' {
+				droppedNonZero = true
+			}
+			droppedDigitCount++
 		}
 		return nil
 	}
@@ -548,7 +1007,12 @@ func decodeFromString(value string, significantDigits int) (result DFloat, err e
 				return decodeExponent(str[i+1:])
 			}
 			if ch < '0' || ch > '9' {
-				return fmt.Errorf("%c: Unexpected character while decoding DFloat fractional", ch)
+				return fmt.Errorf("%w: Unexpected character %q in %q", ErrorSyntax, ch, original)
+			}
+			mantissaDigitCount++
+			// Check before multiplying so that the significand can't wrap.
+			if significand > significandMax/10 {
+				return decodeRoundedFractional(str[i:])
 			}
 			nextSignificand := significand*10 + uint64(ch-'0')
 			if nextSignificand > significandMax {
@@ -570,12 +1034,17 @@ func decodeFromString(value string, significantDigits int) (result DFloat, err e
 				return decodeExponent(str[i+1:])
 			}
 			if ch < '0' || ch > '9' {
-				return fmt.Errorf("%c: Unexpected character while decoding DFloat fractional", ch)
+				return fmt.Errorf("%w: Unexpected character %q in %q", ErrorSyntax, ch, original)
 			}
+			mantissaDigitCount++
 			if firstRounded || rounded == 5 {
 				rounded = rounded + int(ch-'0')
 				firstRounded = false
 			}
+			if ch != '0' {
+				droppedNonZero = true
+			}
+			droppedDigitCount++
 			cutoffDigitCount++
 		}
 		return nil
@@ -590,7 +1059,11 @@ func decodeFromString(value string, significantDigits int) (result DFloat, err e
 				return decodeExponent(str[i+1:])
 			}
 			if ch < '0' || ch > '9' {
-				return fmt.Errorf("%c: Unexpected character while decoding DFloat significand", ch)
+				return fmt.Errorf("%w: Unexpected character %q in %q", ErrorSyntax, ch, original)
+			}
+			mantissaDigitCount++
+			if significand > significandMax/10 {
+				return decodeRounded(str[i:])
 			}
 			nextSignificand := significand*10 + uint64(ch-'0')
 			if nextSignificand > significandMax {
@@ -604,9 +1077,33 @@ func decodeFromString(value string, significantDigits int) (result DFloat, err e
 	if err := decodeSignificand(value); err != nil {
 		return dfloatZero, err
 	}
+	if config.strict && mantissaDigitCount == 0 {
+		return dfloatZero, fmt.Errorf("%w: %q has no digits", ErrorSyntax, original)
+	}
 
-	if rounded > 5 || (rounded == 5 && significand&1 == 1) {
+	// rounded compares the dropped digits to half (5 means exactly half), so
+	// turn it into an equivalent remainder out of 10.
+	roundedAway := false
+	if droppedNonZero {
+		remainder := uint64(rounded)
+		if remainder > 6 {
+			remainder = 6
+		} else if remainder == 0 {
+			remainder = 1
+		}
+		roundedAway = shouldRoundAway(significand, remainder, 10, significandSign < 0, rounding)
+	}
+	if roundedAway {
 		significand++
+		// Rounding 9223372036854775807 up gives 9223372036854775808, which
+		// doesn't fit, so drop its last digit too (an 8, which rounds up in
+		// any mode that rounded away in the first place).
+		if significand > significandCap {
+			significand /= 10
+			significand++
+			exponent++
+			droppedDigitCount++
+		}
 	}
 
 	exponent += int64(cutoffDigitCount)
@@ -616,24 +1113,122 @@ func decodeFromString(value string, significantDigits int) (result DFloat, err e
 		return dfloatNegativeZero, nil
 	}
 
+	// Minimize (or when preserving trailing zeros, only drop enough of them to
+	// bring a too-small exponent into range), then use trailing zeros to bring
+	// a too-big exponent into range if possible.
+	const maxExponent = int64(0x7fffffff)
+	if significand == 0 {
+		exponent = 0
+	}
+	for significand != 0 && significand%10 == 0 && (!config.preserveTrailingZeros || exponent < -maxExponent) {
+		significand /= 10
+		exponent++
+	}
+	// Reported on overflow rather than the adjusted exponent below, which
+	// depends on how many zeros could be added to the significand.
+	unadjustedExponent := exponent
+	for exponent > maxExponent && significand <= significandCap/10 {
+		significand *= 10
+		exponent--
+	}
+	if exponent > maxExponent || exponent < -maxExponent {
+		if !config.clampExponent {
+			return dfloatZero, &ExponentOverflowErrorDetails{Original: original, Exponent: unadjustedExponent}
+		}
+		return clampedExponent(exponent > 0, significandSign < 0), newRoundingError(original, droppedDigitCount+decimalDigitCount(significand), exponent > 0)
+	}
+
 	result = DFloat{
 		Coefficient: int64(significand) * significandSign,
 		Exponent:    int32(exponent),
-	}.minimized()
+	}
 
 	if didRoundResult {
-		err = roundingError
+		err = newRoundingError(original, droppedDigitCount, roundedAway)
 	}
 	return
 }
 
+// Returned (wrapped) when text is not a valid decimal float.
+var ErrorSyntax = errors.New("Invalid decimal float syntax")
+
 var roundingError = errors.New("RoundingError")
 
+// Returns the sentinel for rounding errors. Every error returned due to
+// rounding matches it via errors.Is(), and can be inspected further by
+// extracting a *RoundingErrorDetails via errors.As().
 func RoundingError() error {
 	return roundingError
 }
 
+// The direction a value moved when it was rounded.
+type RoundingDirection int
+
+const (
+	RoundedTowardZero RoundingDirection = iota
+	RoundedAwayFromZero
+)
+
+func (this RoundingDirection) String() string {
+	if this == RoundedAwayFromZero {
+		return "away from zero"
+	}
+	return "toward zero"
+}
+
+// Describes a rounding that occurred during a conversion.
+type RoundingErrorDetails struct {
+	// The number of significant digits that were dropped from the original.
+	DigitsDropped int
+	// The direction that the rounded value moved relative to the original.
+	Direction RoundingDirection
+	// The text representation of the original value, before rounding.
+	Original string
+}
+
+func (this *RoundingErrorDetails) Error() string {
+	return fmt.Sprintf("%v: %v rounded %v (%v digits dropped)", roundingError, this.Original, this.Direction, this.DigitsDropped)
+}
+
+func (this *RoundingErrorDetails) Is(target error) bool {
+	return target == roundingError
+}
+
+// Describes a parsed value whose exponent is outside of the DFloat range. It
+// matches ErrorExponentTooLarge via errors.Is().
+type ExponentOverflowErrorDetails struct {
+	// The text that was being parsed.
+	Original string
+	// The exponent of the value as parsed, before any zeros are added to its
+	// coefficient to try to bring the exponent into range. For a whole number
+	// coefficient without trailing zeros, this is the exponent written in the
+	// text (so "1e3000000000" reports 3000000000). Absurdly large exponents are
+	// reported as the point at which parsing gave up.
+	Exponent int64
+}
+
+func (this *ExponentOverflowErrorDetails) Error() string {
+	return fmt.Sprintf("%v: %v has exponent %v, which is outside of the DFloat range", ErrorExponentTooLarge, this.Original, this.Exponent)
+}
+
+func (this *ExponentOverflowErrorDetails) Is(target error) bool {
+	return target == ErrorExponentTooLarge
+}
+
+func newRoundingError(original string, digitsDropped int, roundedAway bool) error {
+	direction := RoundedTowardZero
+	if roundedAway {
+		direction = RoundedAwayFromZero
+	}
+	return &RoundingErrorDetails{
+		DigitsDropped: digitsDropped,
+		Direction:     direction,
+		Original:      original,
+	}
+}
+
 const quietBit = 1 << 50
+const signBit = 1 << 63
 
 var (
 	dfloatZero             = DFloat{0, 0}
@@ -642,4 +1237,7 @@ var (
 	dfloatNegativeInfinity = DFloat{ExpSpecial, CoeffNegativeInfinity}
 	dfloatNaN              = DFloat{ExpSpecial, CoeffNan}
 	dfloatSignalingNaN     = DFloat{ExpSpecial, CoeffSignalingNan}
+
+	dfloatNegativeNaN          = DFloat{ExpSpecial, CoeffNegativeNan}
+	dfloatNegativeSignalingNaN = DFloat{ExpSpecial, CoeffNegativeSignalingNan}
 )
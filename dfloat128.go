@@ -0,0 +1,449 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+)
+
+// A signed 128-bit integer in two's complement form, split into high and low
+// 64-bit halves.
+type Int128 struct {
+	High int64
+	Low  uint64
+}
+
+func Int128FromInt64(value int64) Int128 {
+	return Int128{High: value >> 63, Low: uint64(value)}
+}
+
+// Convert a big.Int to Int128. Returns ErrorValueTooLarge if it doesn't fit.
+func Int128FromBigInt(value *big.Int) (Int128, error) {
+	if value.BitLen() > 127 {
+		return Int128{}, fmt.Errorf("%w: %v doesn't fit into 128 bits", ErrorValueTooLarge, value)
+	}
+	magnitude := new(big.Int).Abs(value)
+	low := new(big.Int).And(magnitude, new(big.Int).SetUint64(^uint64(0))).Uint64()
+	high := new(big.Int).Rsh(magnitude, 64).Uint64()
+	return int128FromMagnitude(high, low, value.Sign() < 0), nil
+}
+
+func (this Int128) IsNegative() bool {
+	return this.High < 0
+}
+
+func (this Int128) IsZero() bool {
+	return this.High == 0 && this.Low == 0
+}
+
+// Returns true if the value fits into an int64.
+func (this Int128) IsInt64() bool {
+	return this.High == int64(this.Low)>>63
+}
+
+func (this Int128) Neg() Int128 {
+	low, borrow := bits.Sub64(0, this.Low, 0)
+	high, _ := bits.Sub64(0, uint64(this.High), borrow)
+	return Int128{High: int64(high), Low: low}
+}
+
+func (this Int128) BigInt() *big.Int {
+	high, low := this.magnitude()
+	value := new(big.Int).SetUint64(high)
+	value.Lsh(value, 64)
+	value.Or(value, new(big.Int).SetUint64(low))
+	if this.IsNegative() {
+		value.Neg(value)
+	}
+	return value
+}
+
+func (this Int128) String() string {
+	high, low := this.magnitude()
+	var buffer [40]byte
+	dst := buffer[:0]
+	if this.IsNegative() {
+		dst = append(dst, '-')
+	}
+	return string(appendUint128(dst, high, low))
+}
+
+// Returns the absolute value as an unsigned 128-bit high/low pair.
+func (this Int128) magnitude() (high uint64, low uint64) {
+	if this.IsNegative() {
+		this = this.Neg()
+	}
+	return uint64(this.High), this.Low
+}
+
+func int128FromMagnitude(high uint64, low uint64, isNegative bool) Int128 {
+	value := Int128{High: int64(high), Low: low}
+	if isNegative {
+		return value.Neg()
+	}
+	return value
+}
+
+// DFloat128 represents a decimal floating point value with a 128-bit
+// coefficient, which is enough for decimal128-class values (up to 34 digits)
+// without having to resort to apd.Decimal. Exponents and special values work
+// the same way as in DFloat: an exponent of ExpSpecial indicates a special
+// value, with a coefficient of one of the CoeffXYZ codes.
+type DFloat128 struct {
+	Exponent    int32
+	Coefficient Int128
+}
+
+func DFloat128Value(exponent int32, coefficient Int128) DFloat128 {
+	return DFloat128{
+		Exponent:    exponent,
+		Coefficient: coefficient,
+	}.minimized()
+}
+
+// Convert a DFloat to DFloat128. All DFloat values can be represented exactly.
+func DFloat128FromDFloat(value DFloat) DFloat128 {
+	return DFloat128{
+		Exponent:    value.Exponent,
+		Coefficient: Int128FromInt64(value.Coefficient),
+	}
+}
+
+// Convert this value to DFloat. If the coefficient is too big to fit, its lower
+// significant digits will be rounded (half-to-even) and RoundingError will be
+// returned along with the rounded value.
+func (this DFloat128) DFloat() (DFloat, error) {
+	if this.Coefficient.IsInt64() {
+		return DFloat{Exponent: this.Exponent, Coefficient: int64(this.Coefficient.Low)}, nil
+	}
+	return DFloatFromString(this.Text('e'))
+}
+
+func (this DFloat128) IsSpecial() bool {
+	return this.Exponent == ExpSpecial
+}
+
+// Returns true if the value is positive or negative zero
+func (this DFloat128) IsZero() bool {
+	return this.Coefficient.IsZero()
+}
+
+// Returns true if the value is positive or negative infinity
+func (this DFloat128) IsInfinity() bool {
+	return this.IsSpecial() && this.special().IsInfinity()
+}
+
+// Returns true if the value is a quiet or signaling NaN
+func (this DFloat128) IsNan() bool {
+	return this.IsSpecial() && this.special().IsNan()
+}
+
+func (this DFloat128) String() string {
+	return this.Text('g')
+}
+
+// Returns the text representation of this value in the same formats as
+// DFloat.Text().
+func (this DFloat128) Text(format byte) string {
+	var buffer [48]byte
+	return string(this.AppendText(buffer[:0], format))
+}
+
+// Appends the Text() representation of this value in the given format to dst,
+// returning the extended slice.
+func (this DFloat128) AppendText(dst []byte, format byte) []byte {
+	if this.IsSpecial() || this.Coefficient.IsInt64() {
+		return DFloat{Exponent: this.Exponent, Coefficient: int64(this.Coefficient.Low)}.AppendText(dst, format)
+	}
+
+	var digitsBuffer [40]byte
+	high, low := this.Coefficient.magnitude()
+	digits := appendUint128(digitsBuffer[:0], high, low)
+	exponent := int64(this.Exponent)
+
+	sign := len(dst)
+	if this.Coefficient.IsNegative() {
+		dst = append(dst, '-')
+	}
+	switch format {
+	case 'e', 'E':
+		return appendFormatE(dst, format, exponent, digits)
+	case 'f':
+		return appendFormatF(dst, exponent, digits)
+	case 'g', 'G':
+		const adjustedExponentLimit = -6
+		adjustedExponent := exponent + int64(len(digits)-1)
+		if exponent <= 0 && adjustedExponent >= adjustedExponentLimit {
+			return appendFormatF(dst, exponent, digits)
+		}
+		return appendFormatE(dst, format+'e'-'g', exponent, digits)
+	}
+	return append(dst[:sign], '%', format)
+}
+
+func (this DFloat128) special() DFloat {
+	return DFloat{Exponent: ExpSpecial, Coefficient: int64(this.Coefficient.Low)}
+}
+
+func (this DFloat128) minimized() DFloat128 {
+	if this.IsSpecial() {
+		return this
+	}
+	if this.Coefficient.IsZero() {
+		return DFloat128{}
+	}
+
+	high, low := this.Coefficient.magnitude()
+	for this.Exponent < math.MaxInt32 {
+		quotientHigh, quotientLow, remainder := divUint128By(high, low, 10)
+		if remainder != 0 {
+			break
+		}
+		high, low = quotientHigh, quotientLow
+		this.Exponent++
+	}
+	this.Coefficient = int128FromMagnitude(high, low, this.Coefficient.IsNegative())
+	return this
+}
+
+// Maximum number of bytes that a DFloat128 can encode to.
+// (128 bits / 7) + (33 bits / 7)
+const MaxEncodedLength128 = 19 + 5
+
+// Encodes a DFloat128 to a writer.
+func EncodeDFloat128(value DFloat128, writer io.Writer) (bytesEncoded int, err error) {
+	var buffer [MaxEncodedLength128]byte
+	bytesEncoded = EncodeDFloat128ToBytes(value, buffer[:])
+	return writer.Write(buffer[:bytesEncoded])
+}
+
+// Encodes a DFloat128 to a byte buffer.
+// Assumes the buffer is big enough (see MaxEncodedLength128).
+func EncodeDFloat128ToBytes(value DFloat128, buffer []byte) (bytesEncoded int) {
+	if value.IsSpecial() || value.Coefficient.IsZero() {
+		return EncodeToBytes(DFloat{Exponent: value.Exponent, Coefficient: int64(value.Coefficient.Low)}, buffer)
+	}
+
+	exponentField, _ := splitDFloat(DFloat{Exponent: value.Exponent})
+	if value.Coefficient.IsNegative() {
+		exponentField |= 1
+	}
+	bytesEncoded = encodeULEB128Uint64(exponentField, buffer)
+	high, low := value.Coefficient.magnitude()
+	bytesEncoded += encodeULEB128Uint128(high, low, buffer[bytesEncoded:])
+	return
+}
+
+// Appends the encoded form of a DFloat128 to dst, growing it as needed, and
+// returns the extended slice.
+func AppendEncodeDFloat128(dst []byte, value DFloat128) []byte {
+	dst, buffer := growForAppend(dst, EncodedSizeDFloat128(value))
+	bytesEncoded := EncodeDFloat128ToBytes(value, buffer)
+	return dst[:len(dst)+bytesEncoded]
+}
+
+// Returns the exact number of bytes that a DFloat128 will encode to.
+func EncodedSizeDFloat128(value DFloat128) int {
+	if value.IsSpecial() || value.Coefficient.IsZero() {
+		return EncodedSize(DFloat{Exponent: value.Exponent, Coefficient: int64(value.Coefficient.Low)})
+	}
+	exponentField, _ := splitDFloat(DFloat{Exponent: value.Exponent})
+	high, low := value.Coefficient.magnitude()
+	return encodedSizeULEB128Uint64(exponentField) + encodedSizeULEB128Uint128(high, low)
+}
+
+// Decode a DFloat128 from a reader. Values whose coefficients don't fit into
+// 127 bits fail with ErrorValueTooLarge.
+func DecodeDFloat128(reader io.Reader) (value DFloat128, bytesDecoded int, err error) {
+	var buffer [MaxEncodedLength128]byte
+	if bytesDecoded, err = readULEB128Bytes(reader, buffer[:maxExponentFieldLength], 0, ErrorExponentTooLarge); err != nil {
+		return
+	}
+	exponentField, _, err := decodeULEB128Uint64FromBytes(buffer[:bytesDecoded], ErrorExponentTooLarge)
+	if err != nil {
+		return
+	}
+	if _, isSpecial := decodeSpecialValue(exponentField, bytesDecoded); !isSpecial {
+		if bytesDecoded, err = readULEB128Bytes(reader, buffer[:], bytesDecoded, ErrorValueTooLarge); err != nil {
+			return
+		}
+	}
+	value, bytesDecoded, err = DecodeDFloat128FromBytes(buffer[:bytesDecoded])
+	return
+}
+
+// Reads the bytes of a ULEB128 value into buffer (starting at offset) one at a
+// time, returning the offset after the value. Fails with tooLongErr if the
+// value doesn't fit into buffer.
+func readULEB128Bytes(reader io.Reader, buffer []byte, offset int, tooLongErr error) (int, error) {
+	for {
+		if offset == len(buffer) {
+			return offset, tooLongErr
+		}
+		if _, err := io.ReadFull(reader, buffer[offset:offset+1]); err != nil {
+			return offset, incompleteIfTruncated(err, offset)
+		}
+		offset++
+		if buffer[offset-1]&0x80 == 0 {
+			return offset, nil
+		}
+	}
+}
+
+// Decode a DFloat128 from a byte slice without allocating. Values whose
+// coefficients don't fit into 127 bits fail with ErrorValueTooLarge.
+func DecodeDFloat128FromBytes(data []byte) (value DFloat128, bytesDecoded int, err error) {
+	exponentField, bytesDecoded, err := decodeULEB128Uint64FromBytes(data, ErrorExponentTooLarge)
+	if err != nil {
+		return
+	}
+	if special, isSpecial := decodeSpecialValue(exponentField, bytesDecoded); isSpecial {
+		value = DFloat128FromDFloat(special)
+		return
+	}
+	if exponentField > maxEncodedExponentField {
+		err = fmt.Errorf("%w: %v", ErrorExponentTooLarge, exponentField)
+		return
+	}
+
+	offset := bytesDecoded
+	high, low, bytesDecoded, err := decodeULEB128Uint128FromBytes(data[offset:])
+	if err != nil {
+		return
+	}
+	if MaxCoefficientLength > 0 && bytesDecoded > MaxCoefficientLength {
+		err = ErrorCoefficientTooLong
+		return
+	}
+	bytesDecoded += offset
+
+	exponent, isNegative, _ := decodeExponentField(exponentField)
+	value = DFloat128{
+		Exponent:    exponent,
+		Coefficient: int128FromMagnitude(high, low, isNegative),
+	}
+	return
+}
+
+// Rounds a coefficient magnitude (half-to-even) to 38 significant digits if it
+// doesn't fit into 127 bits, adjusting the exponent to match. digitsDropped is
+// 0 if only zeros were dropped. Fails with ErrorExponentTooLarge if the
+// exponent overflows.
+func roundInt128Magnitude(magnitude *big.Int, exponent int32) (rounded *big.Int, roundedExponent int32,
+	digitsDropped int, roundedAway bool, err error) {
+
+	if magnitude.BitLen() <= 127 {
+		return magnitude, exponent, 0, false, nil
+	}
+
+	const maxDigits = 38
+	digitsDropped = len(magnitude.Text(10)) - maxDigits
+	if int64(exponent)+int64(digitsDropped) > math.MaxInt32 {
+		err = ErrorExponentTooLarge
+		return
+	}
+	divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digitsDropped)), nil)
+	remainder := new(big.Int)
+	rounded, remainder = new(big.Int).QuoRem(magnitude, divisor, remainder)
+	roundedExponent = exponent + int32(digitsDropped)
+	if remainder.Sign() == 0 {
+		// Only trailing zeros were dropped, so nothing was lost.
+		digitsDropped = 0
+		return
+	}
+	if comparison := remainder.Lsh(remainder, 1).Cmp(divisor); comparison > 0 || (comparison == 0 && rounded.Bit(0) == 1) {
+		rounded.Add(rounded, big.NewInt(1))
+		roundedAway = true
+	}
+	return
+}
+
+// Divides an unsigned 128-bit value by a 64-bit divisor.
+func divUint128By(high uint64, low uint64, divisor uint64) (quotientHigh uint64, quotientLow uint64, remainder uint64) {
+	quotientHigh, remainder = high/divisor, high%divisor
+	quotientLow, remainder = bits.Div64(remainder, low, divisor)
+	return
+}
+
+// Appends the decimal digits of an unsigned 128-bit value.
+func appendUint128(dst []byte, high uint64, low uint64) []byte {
+	if high == 0 {
+		return strconv.AppendUint(dst, low, 10)
+	}
+
+	// Split into base 10^19 chunks, least significant first.
+	const chunkDivisor = 10000000000000000000
+	const chunkDigits = 19
+	var chunks [3]uint64
+	chunkCount := 0
+	for high != 0 {
+		high, low, chunks[chunkCount] = divUint128By(high, low, chunkDivisor)
+		chunkCount++
+	}
+	dst = strconv.AppendUint(dst, low, 10)
+	for i := chunkCount - 1; i >= 0; i-- {
+		var chunkBuffer [chunkDigits]byte
+		chunk := strconv.AppendUint(chunkBuffer[:0], chunks[i], 10)
+		for j := len(chunk); j < chunkDigits; j++ {
+			dst = append(dst, '0')
+		}
+		dst = append(dst, chunk...)
+	}
+	return dst
+}
+
+func encodedSizeULEB128Uint128(high uint64, low uint64) int {
+	if high == 0 {
+		return encodedSizeULEB128Uint64(low)
+	}
+	return (128 - bits.LeadingZeros64(high) + 6) / 7
+}
+
+func encodeULEB128Uint128(high uint64, low uint64, buffer []byte) (bytesEncoded int) {
+	for high != 0 || low >= 0x80 {
+		buffer[bytesEncoded] = byte(low) | 0x80
+		low = low>>7 | high<<57
+		high >>= 7
+		bytesEncoded++
+	}
+	buffer[bytesEncoded] = byte(low)
+	return bytesEncoded + 1
+}
+
+// Decode a ULEB128 value that must fit into 127 bits, without allocating.
+func decodeULEB128Uint128FromBytes(data []byte) (high uint64, low uint64, bytesDecoded int, err error) {
+	if bytesDecoded, err = uleb128FromBytesLength(data); err != nil {
+		return
+	}
+	for i := bytesDecoded - 1; i >= 0; i-- {
+		if high>>(63-7) != 0 {
+			return 0, 0, bytesDecoded, ErrorValueTooLarge
+		}
+		high = high<<7 | low>>57
+		low = low<<7 | uint64(data[i]&0x7f)
+	}
+	return
+}
@@ -0,0 +1,181 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func newTestInt128(t *testing.T, str string) Int128 {
+	value, ok := new(big.Int).SetString(str, 10)
+	if !ok {
+		t.Fatalf("Bad test integer %v", str)
+	}
+	result, err := Int128FromBigInt(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestInt128(t *testing.T) {
+	for _, str := range []string{
+		"0",
+		"1",
+		"-1",
+		"9223372036854775807",
+		"-9223372036854775808",
+		"18446744073709551616",
+		"-18446744073709551616",
+		"170141183460469231731687303715884105727",
+		"-170141183460469231731687303715884105727",
+	} {
+		value := newTestInt128(t, str)
+		if value.String() != str {
+			t.Errorf("Expected %v but got %v", str, value)
+		}
+		if value.BigInt().String() != str {
+			t.Errorf("Expected %v but got %v", str, value.BigInt())
+		}
+		if value.Neg().Neg() != value {
+			t.Errorf("Expected %v to survive double negation but got %v", str, value.Neg().Neg())
+		}
+	}
+
+	if !Int128FromInt64(math.MinInt64).IsInt64() || newTestInt128(t, "9223372036854775808").IsInt64() {
+		t.Errorf("IsInt64 is wrong")
+	}
+	tooBig, _ := new(big.Int).SetString("170141183460469231731687303715884105728", 10)
+	if _, err := Int128FromBigInt(tooBig); !errors.Is(err, ErrorValueTooLarge) {
+		t.Errorf("Expected ErrorValueTooLarge but got %v", err)
+	}
+}
+
+func TestDFloat128Text(t *testing.T) {
+	assertText := func(value DFloat128, format byte, expected string) {
+		if actual := value.Text(format); actual != expected {
+			t.Errorf("Expected %v but got %v", expected, actual)
+		}
+	}
+	big34 := newTestInt128(t, "-1234567890123456789012345678901234")
+	assertText(DFloat128{Exponent: -4, Coefficient: big34}, 'g', "-123456789012345678901234567890.1234")
+	assertText(DFloat128{Exponent: 5, Coefficient: big34}, 'g', "-1.234567890123456789012345678901234e+38")
+	assertText(DFloat128{Exponent: -40, Coefficient: big34}, 'f', "-0.0000001234567890123456789012345678901234")
+	assertText(DFloat128{Exponent: -2, Coefficient: Int128FromInt64(150)}, 'g', "1.50")
+	assertText(DFloat128FromDFloat(NegativeInfinity()), 'g', "-Infinity")
+	assertText(DFloat128FromDFloat(SignalingNaN()), 'g', "sNaN")
+}
+
+func TestDFloat128Conversions(t *testing.T) {
+	value := DFloat128Value(0, newTestInt128(t, "12345678901234567890000"))
+	if expected := (DFloat128{Exponent: 4, Coefficient: newTestInt128(t, "1234567890123456789")}); value != expected {
+		t.Errorf("Expected %v but got %v", expected, value)
+	}
+	asDFloat, err := value.DFloat()
+	if err != nil || asDFloat != (DFloat{Exponent: 4, Coefficient: 1234567890123456789}) {
+		t.Errorf("Expected 1234567890123456789e4 but got %v (%v)", asDFloat, err)
+	}
+
+	asDFloat, err = DFloat128{Exponent: -2, Coefficient: newTestInt128(t, "123456789012345678901")}.DFloat()
+	if !errors.Is(err, RoundingError()) || asDFloat != (DFloat{Exponent: 0, Coefficient: 1234567890123456789}) {
+		t.Errorf("Expected rounded 1234567890123456789 but got %v (%v)", asDFloat, err)
+	}
+
+	for _, dfloat := range generatedSpecials {
+		if value := DFloat128FromDFloat(dfloat); value.String() != dfloat.String() {
+			t.Errorf("Expected %v but got %v", dfloat, value)
+		}
+	}
+}
+
+func assertDFloat128RoundTrip(t *testing.T, value DFloat128, expectedEncoded []byte) {
+	encoded := AppendEncodeDFloat128(nil, value)
+	if expectedEncoded != nil && !bytes.Equal(encoded, expectedEncoded) {
+		t.Errorf("%v: Expected encoding %x but got %x", value, expectedEncoded, encoded)
+	}
+	if len(encoded) != EncodedSizeDFloat128(value) {
+		t.Errorf("%v: Encoded to %v bytes but EncodedSizeDFloat128 reports %v", value, len(encoded), EncodedSizeDFloat128(value))
+	}
+
+	decoded, bytesDecoded, err := DecodeDFloat128FromBytes(encoded)
+	if err != nil || bytesDecoded != len(encoded) || decoded.minimized() != value.minimized() {
+		t.Errorf("%x: Expected %v but got %v (%v bytes, %v)", encoded, value, decoded, bytesDecoded, err)
+	}
+	decoded, bytesDecoded, err = DecodeDFloat128(bytes.NewReader(encoded))
+	if err != nil || bytesDecoded != len(encoded) || decoded.minimized() != value.minimized() {
+		t.Errorf("%x: Expected %v from reader but got %v (%v bytes, %v)", encoded, value, decoded, bytesDecoded, err)
+	}
+}
+
+func TestDFloat128Encoding(t *testing.T) {
+	// Values that fit into a DFloat encode identically.
+	for _, value := range append(generatedSpecials, generatedExtremes...) {
+		assertDFloat128RoundTrip(t, DFloat128FromDFloat(value), AppendEncode(nil, value))
+	}
+
+	assertDFloat128RoundTrip(t, DFloat128{Exponent: -3, Coefficient: newTestInt128(t, "-18446744073709551616")},
+		[]byte{0x0f, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x02})
+	assertDFloat128RoundTrip(t, DFloat128{Exponent: math.MaxInt32, Coefficient: newTestInt128(t, "170141183460469231731687303715884105727")}, nil)
+	assertDFloat128RoundTrip(t, DFloat128{Exponent: -math.MaxInt32, Coefficient: newTestInt128(t, "-9999999999999999999999999999999999")}, nil)
+}
+
+func TestDFloat128DecodeErrors(t *testing.T) {
+	tooBig := append([]byte{0x00}, AppendEncodeDFloat128(nil, DFloat128{Coefficient: Int128{High: math.MaxInt64, Low: math.MaxUint64}})[1:]...)
+	tooBig[len(tooBig)-1] |= 0x02
+	for _, data := range [][]byte{
+		{},
+		{0x0d},
+		{0x0d, 0x80},
+		tooBig,
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0x01},
+	} {
+		if _, _, err := DecodeDFloat128FromBytes(data); err == nil {
+			t.Errorf("Expected %x to fail", data)
+		}
+		if _, _, err := DecodeDFloat128(bytes.NewReader(data)); err == nil {
+			t.Errorf("Expected %x to fail from reader", data)
+		}
+	}
+	if _, _, err := DecodeDFloat128FromBytes(tooBig); !errors.Is(err, ErrorValueTooLarge) {
+		t.Errorf("Expected ErrorValueTooLarge but got %v", err)
+	}
+	if _, _, err := DecodeDFloat128(bytes.NewReader([]byte{0x0d, 0x80})); !errors.Is(err, ErrorIncomplete) {
+		t.Errorf("Expected ErrorIncomplete but got %v", err)
+	}
+}
+
+func TestDFloat128NoAlloc(t *testing.T) {
+	value := DFloat128{Exponent: -3, Coefficient: newTestInt128(t, "-1234567890123456789012345678901234")}
+	var buffer [MaxEncodedLength128]byte
+	allocs := testing.AllocsPerRun(100, func() {
+		length := EncodeDFloat128ToBytes(value, buffer[:])
+		if _, _, err := DecodeDFloat128FromBytes(buffer[:length]); err != nil {
+			t.Error(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations but got %v", allocs)
+	}
+}
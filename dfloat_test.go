@@ -18,11 +18,19 @@
 // FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
 // IN THE SOFTWARE.
 
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
 package compact_float
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
+	"math/rand"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/cockroachdb/apd/v2"
@@ -49,7 +57,7 @@ func assertConvertToString(t *testing.T, value DFloat, expected string) {
 
 func assertConvertFromString(t *testing.T, str string, expected string, expectedErr error) {
 	value, err := DFloatFromString(str)
-	if err != expectedErr {
+	if !errors.Is(err, expectedErr) {
 		t.Errorf("Expected conversion of string %v to cause error %v but got %v (produced value %v)", str, expectedErr, err, value)
 		return
 	}
@@ -129,7 +137,7 @@ func assertConvertToBigFloat(t *testing.T, value DFloat, expected *apd.Decimal)
 
 func assertDFloatFromString(t *testing.T, value string, expectedErr error) DFloat {
 	result, err := DFloatFromString(value)
-	if err != expectedErr {
+	if !errors.Is(err, expectedErr) {
 		t.Errorf("Expected conversion of %v to produce error %v but got %v", value, expectedErr, err)
 	}
 	return result
@@ -137,7 +145,7 @@ func assertDFloatFromString(t *testing.T, value string, expectedErr error) DFloa
 
 func assertDFloatFromUint(t *testing.T, value uint64, expectedErr error) DFloat {
 	result, err := DFloatFromUInt(value)
-	if err != expectedErr {
+	if !errors.Is(err, expectedErr) {
 		t.Errorf("Expected conversion of %v to produce error %v but got %v", value, expectedErr, err)
 	}
 	return result
@@ -145,7 +153,7 @@ func assertDFloatFromUint(t *testing.T, value uint64, expectedErr error) DFloat
 
 func assertDFloatFromBigInt(t *testing.T, value *big.Int, expectedErr error) DFloat {
 	result, err := DFloatFromBigInt(value)
-	if err != expectedErr {
+	if !errors.Is(err, expectedErr) {
 		t.Errorf("Expected conversion of %v to produce error %v but got %v", value, expectedErr, err)
 	}
 	return result
@@ -153,7 +161,7 @@ func assertDFloatFromBigInt(t *testing.T, value *big.Int, expectedErr error) DFl
 
 func assertDFloatFromFloat64(t *testing.T, value float64, sigDigits int, expectedErr error) DFloat {
 	result, err := DFloatFromFloat64(value, sigDigits)
-	if err != expectedErr {
+	if !errors.Is(err, expectedErr) {
 		t.Errorf("Expected conversion of %v with %v significant digits to produce error %v but got %v", value, sigDigits, expectedErr, err)
 	}
 	return result
@@ -161,7 +169,7 @@ func assertDFloatFromFloat64(t *testing.T, value float64, sigDigits int, expecte
 
 func assertDFloatFromBigFloat(t *testing.T, value *big.Float, expectedErr error) DFloat {
 	result, err := DFloatFromBigFloat(value)
-	if err != expectedErr {
+	if !errors.Is(err, expectedErr) {
 		t.Errorf("Expected conversion of %v to produce error %v but got %v", value, expectedErr, err)
 	}
 	return result
@@ -169,7 +177,7 @@ func assertDFloatFromBigFloat(t *testing.T, value *big.Float, expectedErr error)
 
 func assertDFloatFromAPD(t *testing.T, value *apd.Decimal, expectedErr error) DFloat {
 	result, err := DFloatFromAPD(value)
-	if err != expectedErr {
+	if !errors.Is(err, expectedErr) {
 		t.Errorf("Expected conversion of %v to produce error %v but got %v", value, expectedErr, err)
 	}
 	return result
@@ -309,6 +317,52 @@ func TestSNan(t *testing.T) {
 	}
 }
 
+func TestNegativeNan(t *testing.T) {
+	for _, v := range []DFloat{NegativeQuietNaN(), NegativeSignalingNaN()} {
+		if v.IsInfinity() {
+			t.Errorf("%v should not be inf", v)
+		}
+		if !v.IsNan() {
+			t.Errorf("%v should be NaN", v)
+		}
+		if !v.IsNegativeNan() {
+			t.Errorf("%v should be negative NaN", v)
+		}
+	}
+	if NegativeQuietNaN().IsSignalingNan() {
+		t.Errorf("%v should not be signaling NaN", NegativeQuietNaN())
+	}
+	if !NegativeSignalingNaN().IsSignalingNan() {
+		t.Errorf("%v should be signaling NaN", NegativeSignalingNaN())
+	}
+	if QuietNaN().IsNegativeNan() {
+		t.Errorf("%v should not be negative NaN", QuietNaN())
+	}
+	assertConvertFromString(t, "-nan", "-NaN", nil)
+	assertConvertFromString(t, "-snan", "-sNaN", nil)
+}
+
+func TestNaNTextRoundTrip(t *testing.T) {
+	for _, v := range []DFloat{QuietNaN(), SignalingNaN(), NegativeQuietNaN(), NegativeSignalingNaN()} {
+		for _, format := range []byte{'e', 'E', 'f', 'g', 'G'} {
+			text := v.Text(format)
+			parsed, err := Parse(text)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			if parsed != v {
+				t.Errorf("Text %v (format %c) parsed back as %v", text, format, parsed)
+			}
+			if DFloat128FromDFloat(v).Text(format) != text {
+				t.Errorf("DFloat128 text of %v should be %v but got %v", v, text, DFloat128FromDFloat(v).Text(format))
+			}
+		}
+	}
+	assertConvertFromString(t, "sNaN", "sNaN", nil)
+	assertConvertFromString(t, "-sNaN", "-sNaN", nil)
+}
+
 func TestText(t *testing.T) {
 	assertTextFormat(t, "1.0", 'e', "1e+0")
 	assertTextFormat(t, "1.0", 'E', "1E+0")
@@ -387,11 +441,248 @@ func TestConvertFromString(t *testing.T) {
 	assertConvertFromString(t, "1.23456789123456789123456789e+100", "1.234567891234567891e+100", RoundingError())
 }
 
+func TestConvertFromStringPreserving(t *testing.T) {
+	assertPreserved := func(str string, expected DFloat, expectedText string) {
+		value, err := DFloatFromStringPreserving(str)
+		if err != nil {
+			t.Errorf("%v: %v", str, err)
+			return
+		}
+		if value != expected {
+			t.Errorf("%v: expected %#v but got %#v", str, expected, value)
+		}
+		if value.String() != expectedText {
+			t.Errorf("%v: expected %v but got %v", str, expectedText, value)
+		}
+		decoded, _, _, err := DecodeFromBytes(AppendEncode(nil, value))
+		if err != nil || decoded != value {
+			t.Errorf("%v: encoding round trip gave %#v (%v)", str, decoded, err)
+		}
+	}
+	assertPreserved("1.500", DFloatValuePreserving(-3, 1500), "1.500")
+	assertPreserved("-1500", DFloatValuePreserving(0, -1500), "-1500")
+	assertPreserved("1.0e5", DFloatValuePreserving(4, 10), "1.0e+5")
+	assertPreserved("1.5", DFloatValue(-1, 15), "1.5")
+	assertPreserved("0.000", Zero(), "0")
+	assertPreserved("-0.0", NegativeZero(), "-0")
+	assertPreserved("1000e-2147483650", DFloatValuePreserving(-2147483647, 1), "1e-2147483647")
+
+	if DFloatValuePreserving(-3, 1500) == DFloatValue(-3, 1500) {
+		t.Errorf("Expected DFloatValuePreserving() to keep trailing zeros")
+	}
+}
+
+func TestConvertFromStringWithMode(t *testing.T) {
+	assertMode := func(str string, rounding RoundingMode, expected string) {
+		t.Helper()
+		value, err := DFloatFromStringWithMode(str, 3, rounding)
+		if value.String() != expected {
+			t.Errorf("%v rounded %v: expected %v but got %v", str, rounding, expected, value)
+		}
+		if isRounded := value.String() != str; isRounded != errors.Is(err, RoundingError()) {
+			t.Errorf("%v rounded %v: unexpected error %v", str, rounding, err)
+		}
+	}
+	for _, mode := range []RoundingMode{RoundHalfEven, RoundHalfUp, RoundHalfDown, RoundDown, RoundUp, RoundFloor, RoundCeiling, Round05Up} {
+		assertMode("1.23", mode, "1.23")
+	}
+	assertMode("1.225", "half_even", "1.22")
+	assertMode("1.225", "half_up", "1.23")
+	assertMode("-1.225", "half_up", "-1.23")
+	assertMode("1.225", "half_down", "1.22")
+	assertMode("1.2250001", "half_down", "1.23")
+	assertMode("1.2249", "half_up", "1.22")
+	assertMode("1.2201", "down", "1.22")
+	assertMode("1.2201", "up", "1.23")
+	assertMode("1.22001", "ceiling", "1.23")
+	assertMode("-1.22001", "ceiling", "-1.22")
+	assertMode("1.22001", "floor", "1.22")
+	assertMode("-1.22001", "floor", "-1.23")
+	assertMode("1.259", "05up", "1.26")
+	assertMode("1.269", "05up", "1.26")
+	assertMode("-1239", "up", "-1.24e+3")
+	assertMode("9223372036854775807", "up", "9.23e+18")
+
+	if value, err := DFloatFromStringWithMode("9223372036854775807.1", 0, "ceiling"); value != DFloatValue(1, 922337203685477581) || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected 9223372036854775810 but got %v (%v)", value, err)
+	}
+	if value, err := DFloatFromStringWithMode("9223372036854775807.1", 0, "down"); value != DFloatValue(0, 9223372036854775807) || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected 9223372036854775807 but got %v (%v)", value, err)
+	}
+
+	if _, err := DFloatFromStringWithMode("1", 0, "sideways"); !errors.Is(err, ErrorUnknownRoundingMode) {
+		t.Errorf("Expected ErrorUnknownRoundingMode but got %v", err)
+	}
+}
+
+func TestConvertFromFloat64WithMode(t *testing.T) {
+	assertMode := func(value float64, rounding RoundingMode, expected DFloat) {
+		t.Helper()
+		actual, err := DFloatFromFloat64WithMode(value, 2, rounding)
+		if actual != expected || !errors.Is(err, RoundingError()) {
+			t.Errorf("%v rounded %v: expected %v but got %v (%v)", value, rounding, expected, actual, err)
+		}
+	}
+	assertMode(0.125, "half_even", DFloatValue(-2, 12))
+	assertMode(0.125, "half_up", DFloatValue(-2, 13))
+	assertMode(-0.125, "half_up", DFloatValue(-2, -13))
+	assertMode(0.129, "down", DFloatValue(-2, 12))
+	assertMode(0.121, "ceiling", DFloatValue(-2, 13))
+	assertMode(-0.121, "ceiling", DFloatValue(-2, -12))
+	assertMode(-0.121, "floor", DFloatValue(-2, -13))
+
+	if _, err := DFloatFromFloat64WithMode(1, 0, "sideways"); !errors.Is(err, ErrorUnknownRoundingMode) {
+		t.Errorf("Expected ErrorUnknownRoundingMode but got %v", err)
+	}
+}
+
+func TestConvertFromStringRoundingCarry(t *testing.T) {
+	assertConvertFromString(t, "92233720368547758075", "9.22337203685477581e+19", RoundingError())
+	assertConvertFromString(t, "-9.2233720368547758079e-5", "-0.0000922337203685477581", RoundingError())
+	assertConvertFromString(t, "92233720368547758065", "9.223372036854775806e+19", RoundingError())
+}
+
+func TestConvertFromStringSignificandWrap(t *testing.T) {
+	assertConvertFromString(t, "18446744073709551616", "1.844674407370955162e+19", RoundingError())
+	assertConvertFromString(t, "-1844674407370955161.7", "-1844674407370955162", RoundingError())
+	assertConvertFromString(t, "36893488147419103232e10", "3.689348814741910323e+29", RoundingError())
+}
+
+func TestConvertFromStringExponentOverflow(t *testing.T) {
+	assertOverflow := func(str string, expectedExponent int64) {
+		value, err := DFloatFromString(str)
+		var details *ExponentOverflowErrorDetails
+		if !errors.As(err, &details) || !errors.Is(err, ErrorExponentTooLarge) {
+			t.Errorf("%v: expected exponent overflow but got %v (%v)", str, value, err)
+			return
+		}
+		if details.Original != str || details.Exponent != expectedExponent {
+			t.Errorf("%v: unexpected details %+v", str, details)
+		}
+	}
+	assertOverflow("1e2147483667", 2147483667)
+	assertOverflow("1e3000000000", 3000000000)
+	assertOverflow("-25e3000000000", 3000000000)
+	assertOverflow("-1.5e-2147483647", -2147483648)
+	assertOverflow("0.0000000001e-2147483640", -2147483650)
+	assertOverflow("12345678901234567890123456789e2147483640", 2147483650)
+	assertOverflow("1e-99999999999999", -9999999999999)
+}
+
+func TestParse(t *testing.T) {
+	for _, str := range []string{"", "-", ".", "-.", "1e", "1e+", "1.5E-", ".e5", "e5", "1..2", "1.2.3", "x1", "1x", "--1"} {
+		if value, err := Parse(str); !errors.Is(err, ErrorSyntax) {
+			t.Errorf("Expected %q to fail with ErrorSyntax but got %v (%v)", str, value, err)
+		}
+	}
+	assertParse := func(str string, expected string) {
+		value, err := Parse(str)
+		if err != nil {
+			t.Errorf("%q: %v", str, err)
+			return
+		}
+		if value.String() != expected {
+			t.Errorf("%q: expected %v but got %v", str, expected, value)
+		}
+	}
+	assertParse("0", "0")
+	assertParse("-0", "-0")
+	assertParse(".5", "0.5")
+	assertParse("5.", "5")
+	assertParse("1.5e+3", "1.5e+3")
+	assertParse("Infinity", "Infinity")
+	assertParse("-nan", "-NaN")
+
+	if value, err := ParseWithDigits("1.25", 2); value.String() != "1.2" || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected 1.2 with RoundingError but got %v (%v)", value, err)
+	}
+	if _, err := (Context{}).Parse("1e"); !errors.Is(err, ErrorSyntax) {
+		t.Errorf("Expected ErrorSyntax but got %v", err)
+	}
+
+	// The lenient parser still accepts these.
+	for _, str := range []string{"", ".", "1e"} {
+		if _, err := DFloatFromString(str); err != nil {
+			t.Errorf("Expected DFloatFromString(%q) to succeed but got %v", str, err)
+		}
+	}
+}
+
+func TestConvertFromStringLeadingPlus(t *testing.T) {
+	assertConvertFromString(t, "+1.5", "1.5", nil)
+	assertConvertFromString(t, "+1.5e+3", "1.5e+3", nil)
+	assertConvertFromString(t, "+0", "0", nil)
+	assertConvertFromString(t, "+inf", "Infinity", nil)
+	for _, str := range []string{"+", "+-1", "-+1", "++1", "1e++5"} {
+		if value, err := Parse(str); !errors.Is(err, ErrorSyntax) {
+			t.Errorf("Expected %q to fail with ErrorSyntax but got %v (%v)", str, value, err)
+		}
+	}
+}
+
+func TestParseNaNPayload(t *testing.T) {
+	assertPayload := func(str string, expected DFloat, expectedPayload uint64) {
+		value, payload, err := ParseWithNaNPayload(str)
+		if err != nil {
+			t.Errorf("%q: %v", str, err)
+			return
+		}
+		if value != expected || payload != expectedPayload {
+			t.Errorf("%q: expected %v (%v) but got %v (%v)", str, expected, expectedPayload, value, payload)
+		}
+		if value, err = DFloatFromString(str); err != nil || value != expected {
+			t.Errorf("%q: DFloatFromString() gave %v (%v)", str, value, err)
+		}
+	}
+	assertPayload("nan", QuietNaN(), 0)
+	assertPayload("nan(123)", QuietNaN(), 123)
+	assertPayload("NaN(0x2A)", QuietNaN(), 42)
+	assertPayload("snan(0x7f)", SignalingNaN(), 127)
+	assertPayload("-nan(1)", dfloatNegativeNaN, 1)
+	assertPayload("NaN123", QuietNaN(), 123)
+	assertPayload("sNaN0", SignalingNaN(), 0)
+	assertPayload("nan(18446744073709551615)", QuietNaN(), 18446744073709551615)
+	assertPayload("1.5", DFloatValue(-1, 15), 0)
+
+	for _, str := range []string{"nan()", "nan(", "nan(12", "nan12)", "nan(-1)", "nan(0x)", "nan(0xg)",
+		"nanx", "nan 1", "nan(18446744073709551616)", "inf(1)"} {
+		if value, _, err := ParseWithNaNPayload(str); !errors.Is(err, ErrorSyntax) {
+			t.Errorf("Expected %q to fail with ErrorSyntax but got %v (%v)", str, value, err)
+		}
+	}
+}
+
+func TestConvertFromMalformedString(t *testing.T) {
+	for _, str := range []string{"-", "x", "-x", "e5"} {
+		if value, err := DFloatFromString(str); err == nil {
+			t.Errorf("Expected %q to fail but got %v", str, value)
+		}
+	}
+	assertConvertFromString(t, "1e", "1", nil)
+}
+
+func TestConvertFromStringExponentLimits(t *testing.T) {
+	assertConvertFromString(t, "1e2147483647", "1e+2147483647", nil)
+	assertConvertFromString(t, "1e2147483648", "1.0e+2147483648", nil)
+	assertConvertFromString(t, "10e2147483647", "1.0e+2147483648", nil)
+	assertConvertFromString(t, "1.1e+2147483648", "1.1e+2147483648", nil)
+	assertConvertFromString(t, "9.223372036854775807e+2147483665", "9.223372036854775807e+2147483665", nil)
+	assertConvertFromString(t, "1e-2147483647", "1e-2147483647", nil)
+	assertConvertFromString(t, "1000e-2147483650", "1e-2147483647", nil)
+	for _, str := range []string{"1e2147483667", "1e-2147483648", "1.5e-2147483647"} {
+		if value, err := DFloatFromString(str); err == nil {
+			t.Errorf("Expected %v to overflow but got %v", str, value)
+		}
+	}
+}
+
 func TestConvertToUint(t *testing.T) {
 	assertConvertToUint(t, DFloatValue(5, 60340534), uint64(6034053400000))
 	assertConvertToUint(t, DFloatValue(1, 1844674407370955161), uint64(18446744073709551610))
 	assertConvertToUintFails(t, DFloatValue(2, 1844674407370955161))
 	assertConvertToUintFails(t, DFloatValue(-1, 1844674407370955161))
+	assertConvertToUintFails(t, DFloatValue(0, -1))
+	assertConvertToUint(t, NegativeZero(), 0)
 }
 
 func TestConvertToInt(t *testing.T) {
@@ -400,6 +691,10 @@ func TestConvertToInt(t *testing.T) {
 	assertConvertToInt(t, DFloatValue(18, 1), int64(1000000000000000000))
 	assertConvertToIntFails(t, DFloatValue(19, 1))
 	assertConvertToIntFails(t, DFloatValue(-1, 1))
+	assertConvertToInt(t, DFloatValue(3, -2), int64(-2000))
+	assertConvertToInt(t, DFloatValue(0, -0x7fffffffffffffff), int64(-0x7fffffffffffffff))
+	assertConvertToIntFails(t, DFloatValue(1, -0x7fffffffffffffff))
+	assertConvertToInt(t, NegativeZero(), 0)
 }
 
 func TestConvertToFloat(t *testing.T) {
@@ -414,6 +709,28 @@ func TestConvertToFloat(t *testing.T) {
 	v.Float()
 }
 
+func TestConvertToFloatFastPath(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		value := DFloatValue(int32(random.Intn(80)-40), random.Int63n(1<<54)-(1<<53))
+		expected, err := strconv.ParseFloat(value.String(), 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := value.Float(); actual != expected {
+			t.Errorf("Expected %v to convert to %v but got %v", value, expected, actual)
+		}
+	}
+
+	value := DFloatValue(-3, 123456789)
+	allocs := testing.AllocsPerRun(100, func() {
+		value.Float()
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations but got %v", allocs)
+	}
+}
+
 func TestConvertToBigFloat(t *testing.T) {
 	str := "123456789012345.6789"
 	expected, _, err := big.ParseFloat(str, 10, 63, big.ToNearestEven)
@@ -430,6 +747,49 @@ func TestConvertToBigFloat(t *testing.T) {
 	}
 }
 
+func assertBigFloatWithPrecision(t *testing.T, str string, precision uint, mode big.RoundingMode) {
+	expected, _, err := big.ParseFloat(str, 10, precision, mode)
+	if err != nil {
+		panic(err)
+	}
+	df, err := DFloatFromString(str)
+	if err != nil {
+		panic(err)
+	}
+	actual := df.BigFloatWithPrecision(precision, mode)
+	if actual.Cmp(expected) != 0 || actual.Prec() != precision || actual.Mode() != mode {
+		t.Errorf("Expected %v (prec %v, mode %v) but got %v (prec %v, mode %v)", expected, precision, mode, actual, actual.Prec(), actual.Mode())
+	}
+}
+
+func TestConvertToBigFloatWithPrecision(t *testing.T) {
+	assertBigFloatWithPrecision(t, "123456789012345.6789", 24, big.ToNearestEven)
+	assertBigFloatWithPrecision(t, "0.1", 200, big.ToNearestEven)
+	assertBigFloatWithPrecision(t, "-0.1", 53, big.ToZero)
+	assertBigFloatWithPrecision(t, "-0.1", 53, big.AwayFromZero)
+	assertBigFloatWithPrecision(t, "9.87654321e+40", 100, big.ToNegativeInf)
+	assertBigFloatWithPrecision(t, "-5.5e-30", 64, big.ToPositiveInf)
+	assertBigFloatWithPrecision(t, "1e-5", 8, big.ToNearestAway)
+
+	if !DFloatValue(1000000000, 1).BigFloatWithPrecision(64, big.ToNearestEven).IsInf() {
+		t.Errorf("Expected overflow to infinity")
+	}
+	if DFloatValue(-1000000000, 1).BigFloatWithPrecision(64, big.ToNearestEven).Sign() != 0 {
+		t.Errorf("Expected underflow to zero")
+	}
+	if !NegativeZero().BigFloatWithPrecision(64, big.ToNearestEven).Signbit() {
+		t.Errorf("Expected negative zero")
+	}
+	for _, nan := range []DFloat{QuietNaN(), SignalingNaN(), dfloatNegativeNaN, dfloatNegativeSignalingNaN} {
+		if actual := nan.BigFloatWithPrecision(64, big.ToNearestEven); actual != nil {
+			t.Errorf("Expected %v to give nil but got %v", nan, actual)
+		}
+		if actual := nan.BigFloat(); actual != nil {
+			t.Errorf("Expected %v to give nil but got %v", nan, actual)
+		}
+	}
+}
+
 func TestConvertToBigInt(t *testing.T) {
 	assertConvertToBigInt(t, DFloatValue(1, 1234), big.NewInt(12340))
 	assertConvertToBigIntFails(t, DFloatValue(-1, 1))
@@ -440,3 +800,162 @@ func TestConvertToBigDecimalFloat(t *testing.T) {
 	assertConvertToBigFloat(t, DFloatValue(1, 1), apd.NewWithBigInt(big.NewInt(10), 0))
 	assertConvertToBigFloat(t, DFloatValue(100, 105833), apd.NewWithBigInt(big.NewInt(105833), 100))
 }
+
+func TestJSONNumber(t *testing.T) {
+	var decoded []json.Number
+	decoder := json.NewDecoder(strings.NewReader("[1.5, -0, 9.3942e100, 12345678901234567890]"))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := []string{"1.5", "-0", "9.3942e+100", "1.234567890123456789e+19"}
+	expectedErr := []error{nil, nil, nil, RoundingError()}
+	for i, number := range decoded {
+		value, err := DFloatFromJSONNumber(number)
+		if !errors.Is(err, expectedErr[i]) {
+			t.Errorf("Number %v: Expected error %v but got %v", number, expectedErr[i], err)
+			continue
+		}
+		actual, err := value.JSONNumber()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(actual) != expected[i] {
+			t.Errorf("Expected %v but got %v", expected[i], actual)
+		}
+	}
+
+	for _, number := range []json.Number{"", "nan", "NaN", "inf", "-Infinity", "1e", "1e+", "+1", ".5", "1.", "01", "-", "0x10", "1 ", "1,5"} {
+		if value, err := DFloatFromJSONNumber(number); !errors.Is(err, ErrorSyntax) {
+			t.Errorf("Expected %q to fail with ErrorSyntax but got %v (%v)", number, value, err)
+		}
+	}
+	for _, number := range []json.Number{"0", "-0.0", "10", "1E5", "1e-5", "-1.25e+3", "0.001"} {
+		if _, err := DFloatFromJSONNumber(number); err != nil {
+			t.Errorf("Expected %q to convert but got %v", number, err)
+		}
+	}
+
+	if _, err := Infinity().JSONNumber(); err == nil {
+		t.Errorf("Expected infinity to fail conversion to json.Number")
+	}
+	if _, err := QuietNaN().JSONNumber(); err == nil {
+		t.Errorf("Expected NaN to fail conversion to json.Number")
+	}
+}
+
+func TestRoundingErrorDetails(t *testing.T) {
+	assertRoundingDetails := func(err error, digitsDropped int, direction RoundingDirection, original string) {
+		var details *RoundingErrorDetails
+		if !errors.As(err, &details) {
+			t.Errorf("Expected RoundingErrorDetails but got %v", err)
+			return
+		}
+		if details.DigitsDropped != digitsDropped || details.Direction != direction || details.Original != original {
+			t.Errorf("Expected %v digits dropped %v from %v but got %v", digitsDropped, direction, original, details)
+		}
+	}
+
+	_, err := DFloatFromUInt(9223372036854775815)
+	assertRoundingDetails(err, 1, RoundedAwayFromZero, "9223372036854775815")
+	_, err = DFloatFromUInt(9223372036854775814)
+	assertRoundingDetails(err, 1, RoundedTowardZero, "9223372036854775814")
+	_, err = DFloatFromString("-1.23456789123456789123456789e+100")
+	assertRoundingDetails(err, 8, RoundedTowardZero, "-1.23456789123456789123456789e+100")
+	_, err = DFloatFromFloat64(1.594365, 6)
+	assertRoundingDetails(err, 1, RoundedTowardZero, "1.594365")
+	_, err = DFloatFromFloat64(1.594375, 6)
+	assertRoundingDetails(err, 1, RoundedAwayFromZero, "1.594375")
+
+	value, _ := DFloatFromString("2.59")
+	_, err = value.ParquetInt64(10, 1, apd.RoundHalfUp)
+	assertRoundingDetails(err, 1, RoundedAwayFromZero, "2.59")
+	_, err = value.ParquetInt64(10, 0, apd.RoundDown)
+	assertRoundingDetails(err, 2, RoundedTowardZero, "2.59")
+}
+
+func TestAPDInto(t *testing.T) {
+	dst := apd.New(-12345, 10)
+	dst.Form = apd.NaNSignaling
+	for _, value := range []DFloat{DFloatValue(-3, -1234), Zero(), NegativeZero(), Infinity(),
+		NegativeInfinity(), QuietNaN(), SignalingNaN(), NegativeQuietNaN(), DFloatValue(5, 9223372036854775807)} {
+		actual := value.APDInto(dst)
+		if actual != dst {
+			t.Errorf("Expected APDInto to return dst")
+		}
+		expected := value.APD()
+		if actual.Form != expected.Form || actual.Negative != expected.Negative ||
+			actual.Exponent != expected.Exponent || actual.Coeff.Cmp(&expected.Coeff) != 0 {
+			t.Errorf("Expected %v to convert to %v but got %v", value, expected, actual)
+		}
+	}
+
+	value := DFloatValue(-3, 123456789)
+	allocs := testing.AllocsPerRun(100, func() {
+		value.APDInto(dst)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations but got %v", allocs)
+	}
+}
+
+func TestTextWithZeroFormat(t *testing.T) {
+	assertZeroFormat := func(value DFloat, format byte, zero ZeroFormat, expected string) {
+		t.Helper()
+		if actual := value.TextWithZeroFormat(format, zero); actual != expected {
+			t.Errorf("Value %v format %c zero %+v: Expected %v but got %v", value, format, zero, expected, actual)
+		}
+	}
+	assertZeroFormat(Zero(), 'e', ZeroFormat{}, "0e+0")
+	assertZeroFormat(NegativeZero(), 'e', ZeroFormat{}, "-0e+0")
+	assertZeroFormat(NegativeZero(), 'E', ZeroFormat{}, "-0E+0")
+	assertZeroFormat(DFloatValuePreserving(-3, 0), 'e', ZeroFormat{}, "0e+0")
+	assertZeroFormat(DFloatValuePreserving(5, 0), 'f', ZeroFormat{}, "0")
+	assertZeroFormat(Zero(), 'e', ZeroFormat{FractionDigits: 3}, "0.000e+0")
+	assertZeroFormat(NegativeZero(), 'e', ZeroFormat{FractionDigits: 3}, "-0.000e+0")
+	assertZeroFormat(Zero(), 'f', ZeroFormat{FractionDigits: 3}, "0.000")
+	assertZeroFormat(NegativeZero(), 'g', ZeroFormat{FractionDigits: 2}, "-0.00")
+	assertZeroFormat(Zero(), 'x', ZeroFormat{}, "%x")
+	assertZeroFormat(DFloatValue(-3, 1500), 'e', ZeroFormat{FractionDigits: 3}, "1.5e+0")
+	assertZeroFormat(QuietNaN(), 'e', ZeroFormat{FractionDigits: 3}, "NaN")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected negative fraction digits to panic")
+		}
+	}()
+	Zero().TextWithZeroFormat('e', ZeroFormat{FractionDigits: -1})
+}
+
+func TestAppendText(t *testing.T) {
+	values := []DFloat{Zero(), NegativeZero(), Infinity(), NegativeInfinity(), QuietNaN(),
+		SignalingNaN(), NegativeQuietNaN(), NegativeSignalingNaN(), DFloatValue(0, -9223372036854775808),
+		DFloatValue(-7, 1), DFloatValue(-6, 1), DFloatValue(3, -15), DFloatValue(-3, 123456)}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		values = append(values, DFloatValue(int32(random.Intn(60)-40), random.Int63()>>uint(random.Intn(63))*int64(random.Intn(3)-1)))
+	}
+
+	for _, value := range values {
+		for _, format := range []byte{'e', 'E', 'f', 'g', 'G', 'x'} {
+			expected := value.APD().Text(format)
+			if value.IsNegativeZero() {
+				expected = "-0"
+			}
+			if actual := string(value.AppendText([]byte("prefix"), format)); actual != "prefix"+expected {
+				t.Errorf("Value %v format %c: Expected %v but got %v", value, format, expected, actual)
+			}
+		}
+	}
+
+	value := DFloatValue(-15, -123456789)
+	buffer := make([]byte, 0, 100)
+	allocs := testing.AllocsPerRun(100, func() {
+		value.AppendString(buffer[:0])
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations but got %v", allocs)
+	}
+}
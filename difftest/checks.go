@@ -0,0 +1,159 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+package difftest
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/cockroachdb/apd/v2"
+	compact_float "github.com/kstenerud/go-compact-float"
+)
+
+// Returns checks of the DFloat conversions against their apd equivalents.
+func ConversionChecks() []UnaryCheck {
+	return []UnaryCheck{
+		{
+			Name: "Text(e)",
+			DFloat: func(value compact_float.DFloat) (string, error) {
+				return value.Text('e'), nil
+			},
+			APD: func(context *apd.Context, value *apd.Decimal) (string, error) {
+				if value.IsZero() && value.Negative {
+					// DFloat always formats negative zero as "-0"
+					return "-0", nil
+				}
+				return value.Text('e'), nil
+			},
+		},
+		{
+			Name: "Text(g)",
+			DFloat: func(value compact_float.DFloat) (string, error) {
+				return value.Text('g'), nil
+			},
+			APD: func(context *apd.Context, value *apd.Decimal) (string, error) {
+				return value.Text('g'), nil
+			},
+		},
+		{
+			Name: "Encode",
+			DFloat: func(value compact_float.DFloat) (string, error) {
+				return hex.EncodeToString(compact_float.AppendEncode(nil, value)), nil
+			},
+			APD: func(context *apd.Context, value *apd.Decimal) (string, error) {
+				return hex.EncodeToString(compact_float.AppendEncodeBig(nil, value)), nil
+			},
+		},
+		{
+			Name: "Int",
+			DFloat: func(value compact_float.DFloat) (string, error) {
+				result, err := value.Int()
+				return strconv.FormatInt(result, 10), err
+			},
+			APD: func(context *apd.Context, value *apd.Decimal) (string, error) {
+				result, err := value.Int64()
+				return strconv.FormatInt(result, 10), err
+			},
+		},
+		{
+			Name: "BigInt",
+			DFloat: func(value compact_float.DFloat) (string, error) {
+				if value.Exponent > maxBigIntExponent {
+					return "skipped", nil
+				}
+				result, err := value.BigInt()
+				if err != nil {
+					return "", err
+				}
+				return result.String(), nil
+			},
+			APD: func(context *apd.Context, value *apd.Decimal) (string, error) {
+				if value.Exponent > maxBigIntExponent {
+					return "skipped", nil
+				}
+				result, err := apdBigInt(context, value)
+				if err != nil {
+					return "", err
+				}
+				return result.String(), nil
+			},
+		},
+		{
+			Name: "Float",
+			DFloat: func(value compact_float.DFloat) (string, error) {
+				return strconv.FormatFloat(value.Float(), 'g', -1, 64), nil
+			},
+			APD: func(context *apd.Context, value *apd.Decimal) (string, error) {
+				// DFloat converts NaNs to NaN, and out of range values to
+				// infinity or zero, rather than failing.
+				if value.Form == apd.NaN || value.Form == apd.NaNSignaling {
+					return "NaN", nil
+				}
+				result, err := value.Float64()
+				if errors.Is(err, strconv.ErrRange) {
+					err = nil
+				}
+				return strconv.FormatFloat(result, 'g', -1, 64), err
+			},
+		},
+		{
+			Name: "FromAPD",
+			DFloat: func(value compact_float.DFloat) (string, error) {
+				return value.String(), nil
+			},
+			APD: func(context *apd.Context, value *apd.Decimal) (string, error) {
+				result, err := compact_float.DFloatFromAPD(value)
+				return result.String(), err
+			},
+		},
+	}
+}
+
+var errNotInteger = errors.New("Not an integer")
+
+// Integers with exponents above this are too expensive to expand.
+const maxBigIntExponent = 10000
+
+// Returns the integer value of a finite, integral apd.Decimal.
+func apdBigInt(context *apd.Context, value *apd.Decimal) (*big.Int, error) {
+	if value.Form != apd.Finite {
+		return nil, errNotInteger
+	}
+	integral := new(apd.Decimal)
+	fractional := new(apd.Decimal)
+	value.Modf(integral, fractional)
+	if !fractional.IsZero() {
+		return nil, errNotInteger
+	}
+	result := new(big.Int).Set(&integral.Coeff)
+	for exponent := integral.Exponent; exponent > 0; exponent-- {
+		result.Mul(result, big.NewInt(10))
+	}
+	if integral.Negative {
+		result.Neg(result)
+	}
+	return result, nil
+}
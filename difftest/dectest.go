@@ -0,0 +1,261 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package difftest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	compact_float "github.com/kstenerud/go-compact-float"
+)
+
+// A test case from a decNumber .decTest file
+// (http://speleotrove.com/decimal/dectest.html), such as:
+//
+//	basx012 toSci '1.0E+2' -> 1.0E+2 Rounded
+//
+// Directives is the environment in effect for the case (precision, rounding,
+// maxexponent, etc), with lowercased keys and values.
+type DecTestCase struct {
+	ID         string
+	Operation  string
+	Operands   []string
+	Result     string
+	Conditions []string
+	Directives map[string]string
+	Line       int
+}
+
+var ErrorDecTestSyntax = errors.New("Malformed decTest line")
+
+// Parse the test cases of a .decTest file. Directives apply to all of the
+// cases that follow them. "dectest:" directives (which include other files)
+// are recorded but not followed.
+func ParseDecTest(reader io.Reader) (cases []DecTestCase, err error) {
+	directives := map[string]string{}
+	scanner := bufio.NewScanner(reader)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		tokens, tokenizeErr := tokenizeDecTestLine(scanner.Text())
+		if tokenizeErr != nil {
+			return nil, fmt.Errorf("%w: Line %v: %v", ErrorDecTestSyntax, lineNumber, tokenizeErr)
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+
+		if strings.HasSuffix(tokens[0], ":") {
+			if len(tokens) != 2 {
+				return nil, fmt.Errorf("%w: Line %v: Directive must have one value", ErrorDecTestSyntax, lineNumber)
+			}
+			// Directives are copied on write, so each case keeps its own view.
+			updated := make(map[string]string, len(directives)+1)
+			for key, value := range directives {
+				updated[key] = value
+			}
+			updated[strings.ToLower(strings.TrimSuffix(tokens[0], ":"))] = strings.ToLower(tokens[1])
+			directives = updated
+			continue
+		}
+
+		arrow := -1
+		for i, token := range tokens {
+			if token == "->" {
+				arrow = i
+				break
+			}
+		}
+		if arrow < 3 || arrow == len(tokens)-1 {
+			return nil, fmt.Errorf("%w: Line %v: Expected id operation operands -> result", ErrorDecTestSyntax, lineNumber)
+		}
+		cases = append(cases, DecTestCase{
+			ID:         tokens[0],
+			Operation:  strings.ToLower(tokens[1]),
+			Operands:   tokens[2:arrow],
+			Result:     tokens[arrow+1],
+			Conditions: tokens[arrow+2:],
+			Directives: directives,
+			Line:       lineNumber,
+		})
+	}
+	err = scanner.Err()
+	return
+}
+
+// Split a line into tokens, removing quotes and the trailing comment. Within a
+// quoted token, a doubled quote character stands for itself.
+func tokenizeDecTestLine(line string) (tokens []string, err error) {
+	for i := 0; i < len(line); {
+		switch ch := line[i]; {
+		case ch == ' ' || ch == '\t':
+			i++
+		case strings.HasPrefix(line[i:], "--"):
+			return
+		case ch == '\'' || ch == '"':
+			var token strings.Builder
+			for i++; ; i++ {
+				if i >= len(line) {
+					return nil, fmt.Errorf("Unterminated quote")
+				}
+				if line[i] == ch {
+					if i+1 < len(line) && line[i+1] == ch {
+						i++
+					} else {
+						i++
+						break
+					}
+				}
+				token.WriteByte(line[i])
+			}
+			tokens = append(tokens, token.String())
+		default:
+			start := i
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				i++
+			}
+			tokens = append(tokens, line[start:i])
+		}
+	}
+	return
+}
+
+type DecTestOutcome int
+
+const (
+	DecTestPassed DecTestOutcome = iota
+	DecTestFailed
+	DecTestSkipped
+)
+
+func (this DecTestOutcome) String() string {
+	switch this {
+	case DecTestPassed:
+		return "passed"
+	case DecTestFailed:
+		return "failed"
+	case DecTestSkipped:
+		return "skipped"
+	}
+	return fmt.Sprintf("DecTestOutcome(%d)", int(this))
+}
+
+// The outcome of running a DecTestCase. Reason explains failures and skips.
+type DecTestResult struct {
+	Case    DecTestCase
+	Outcome DecTestOutcome
+	Reason  string
+}
+
+func (this DecTestResult) String() string {
+	return fmt.Sprintf("%v (line %v) %v: %v", this.Case.ID, this.Case.Line, this.Outcome, this.Reason)
+}
+
+// DFloat has no NaN payloads, no exponent limits below its own, and only rounds
+// half-to-even, so cases depending on these are skipped.
+var decTestSkippedConditions = map[string]bool{
+	"clamped":   true,
+	"overflow":  true,
+	"underflow": true,
+	"subnormal": true,
+}
+
+// The most significant digits a DFloat can always hold.
+const maxDecTestPrecision = 18
+
+// Run the cases that apply to DFloat. Currently supported operations are
+// toSci (conversion from a string, rounded to the context precision).
+// Because DFloat values are always minimized, results are compared by value
+// rather than by their exact text (so 1.0 matches 1).
+func RunDecTest(cases []DecTestCase) (results []DecTestResult) {
+	for _, testCase := range cases {
+		outcome, reason := runDecTestCase(testCase)
+		results = append(results, DecTestResult{
+			Case:    testCase,
+			Outcome: outcome,
+			Reason:  reason,
+		})
+	}
+	return
+}
+
+func runDecTestCase(testCase DecTestCase) (DecTestOutcome, string) {
+	if testCase.Operation != "tosci" {
+		return DecTestSkipped, "Unsupported operation " + testCase.Operation
+	}
+	if len(testCase.Operands) != 1 {
+		return DecTestSkipped, "Unexpected number of operands"
+	}
+	if rounding := testCase.Directives["rounding"]; rounding != "half_even" {
+		return DecTestSkipped, "Unsupported rounding " + rounding
+	}
+	if extended := testCase.Directives["extended"]; extended == "0" {
+		return DecTestSkipped, "Subset arithmetic"
+	}
+	precision, err := strconv.Atoi(testCase.Directives["precision"])
+	if err != nil || precision < 1 || precision > maxDecTestPrecision {
+		return DecTestSkipped, "Unsupported precision " + testCase.Directives["precision"]
+	}
+
+	operand := testCase.Operands[0]
+	expectSyntaxError := false
+	expectRounded := false
+	for _, condition := range testCase.Conditions {
+		condition = strings.ToLower(condition)
+		switch {
+		case decTestSkippedConditions[condition]:
+			return DecTestSkipped, "Unsupported condition " + condition
+		case condition == "conversion_syntax":
+			expectSyntaxError = true
+		case condition == "rounded":
+			expectRounded = true
+		}
+	}
+	if operand == "#" || testCase.Result == "#" {
+		return DecTestSkipped, "Null operand or result"
+	}
+
+	actual, err := compact_float.ParseWithDigits(operand, precision)
+	if expectSyntaxError {
+		if err == nil || errors.Is(err, compact_float.RoundingError()) {
+			return DecTestFailed, fmt.Sprintf("Expected %q to be rejected but got %v", operand, actual)
+		}
+		return DecTestPassed, ""
+	}
+	wasRounded := errors.Is(err, compact_float.RoundingError())
+	if err != nil && !wasRounded {
+		return DecTestFailed, fmt.Sprintf("%q failed to parse: %v", operand, err)
+	}
+	if wasRounded != expectRounded {
+		return DecTestFailed, fmt.Sprintf("%q: Expected rounded=%v but got %v", operand, expectRounded, wasRounded)
+	}
+
+	expected, err := compact_float.DFloatFromString(testCase.Result)
+	if err != nil {
+		return DecTestSkipped, fmt.Sprintf("Result %q can't be represented: %v", testCase.Result, err)
+	}
+	if actual != expected {
+		return DecTestFailed, fmt.Sprintf("%q: Expected %v but got %v", operand, testCase.Result, actual)
+	}
+	return DecTestPassed, ""
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package difftest
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDecTest(t *testing.T) {
+	cases, err := ParseDecTest(strings.NewReader(`
+precision: 9   -- comment
+ROUNDING: Half_Even
+abc001 toSci '1.0' -> 1.0 -- comment
+abc002 ADD "it""s" '-' 3 -> # Conversion_syntax Inexact
+`))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(cases) != 2 {
+		t.Errorf("Expected 2 cases but got %v", len(cases))
+		return
+	}
+	expected := DecTestCase{
+		ID:         "abc002",
+		Operation:  "add",
+		Operands:   []string{`it"s`, "-", "3"},
+		Result:     "#",
+		Conditions: []string{"Conversion_syntax", "Inexact"},
+		Directives: map[string]string{"precision": "9", "rounding": "half_even"},
+		Line:       5,
+	}
+	if !reflect.DeepEqual(cases[1], expected) {
+		t.Errorf("Expected %+v but got %+v", expected, cases[1])
+	}
+
+	for _, malformed := range []string{"abc001 toSci '1.0 -> 1.0", "abc001 toSci -> 1", "precision: 9 9", "abc001 toSci 1 ->"} {
+		if _, err := ParseDecTest(strings.NewReader(malformed)); !errors.Is(err, ErrorDecTestSyntax) {
+			t.Errorf("Expected %q to fail with ErrorDecTestSyntax but got %v", malformed, err)
+		}
+	}
+}
+
+// Cases that the parser doesn't handle yet.
+var knownDecTestFailures = map[string]bool{}
+
+func TestRunDecTest(t *testing.T) {
+	file, err := os.Open("testdata/tosci.decTest")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer file.Close()
+	cases, err := ParseDecTest(file)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	outcomes := map[DecTestOutcome]int{}
+	for _, result := range RunDecTest(cases) {
+		outcomes[result.Outcome]++
+		if result.Outcome == DecTestFailed && !knownDecTestFailures[result.Case.ID] {
+			t.Error(result)
+		}
+	}
+	t.Log(outcomes)
+}
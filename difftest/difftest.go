@@ -0,0 +1,179 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+// Package difftest cross-checks DFloat conversions (and arithmetic, as it is
+// added) against the same computations done with apd, over large random
+// corpora, and reports any divergences.
+//
+//	harness := difftest.New(1)
+//	harness.RunUnary(difftest.ConversionChecks(), 100000)
+//	if divergences := harness.Divergences(); len(divergences) > 0 {
+//	    ...
+//	}
+package difftest
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+
+	"github.com/cockroachdb/apd/v2"
+	compact_float "github.com/kstenerud/go-compact-float"
+)
+
+// A check of a one-argument operation. Both functions render their result as
+// a string, which must match exactly. A non-nil error is rendered as "error",
+// so the two sides must also agree on which inputs fail.
+type UnaryCheck struct {
+	Name   string
+	DFloat func(value compact_float.DFloat) (string, error)
+	APD    func(context *apd.Context, value *apd.Decimal) (string, error)
+}
+
+// A check of a two-argument operation (see UnaryCheck).
+type BinaryCheck struct {
+	Name   string
+	DFloat func(a, b compact_float.DFloat) (string, error)
+	APD    func(context *apd.Context, a, b *apd.Decimal) (string, error)
+}
+
+// A case where the DFloat and apd results differ.
+type Divergence struct {
+	Check    string
+	Inputs   []compact_float.DFloat
+	DFloat   string
+	Expected string
+}
+
+func (this Divergence) String() string {
+	inputs := make([]string, 0, len(this.Inputs))
+	for _, input := range this.Inputs {
+		inputs = append(inputs, fmt.Sprintf("%v (%v, %v)", input, input.Exponent, input.Coefficient))
+	}
+	return fmt.Sprintf("%v(%v): DFloat gave %v but apd gave %v", this.Check, strings.Join(inputs, ", "), this.DFloat, this.Expected)
+}
+
+// Harness runs checks over pseudo-random values and collects divergences.
+type Harness struct {
+	// The apd context passed to the apd side of each check.
+	Context *apd.Context
+	// Stop recording divergences after this many (0 = no limit).
+	MaxDivergences int
+	// Size passed to DFloat.Generate(), which bounds typical exponents.
+	Size int
+
+	random      *rand.Rand
+	divergences []Divergence
+	checksRun   int
+}
+
+// Create a new harness whose random values are generated from seed (the same
+// seed always produces the same values). The apd context has enough precision
+// to represent any DFloat exactly.
+func New(seed int64) *Harness {
+	context := apd.BaseContext.WithPrecision(40)
+	context.MaxExponent = apd.MaxExponent
+	context.MinExponent = apd.MinExponent
+	return &Harness{
+		Context:        context,
+		MaxDivergences: 100,
+		Size:           50,
+		random:         rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Run each check against count random values.
+func (this *Harness) RunUnary(checks []UnaryCheck, count int) {
+	for i := 0; i < count; i++ {
+		value := this.generate()
+		for _, check := range checks {
+			this.compare(check.Name, []compact_float.DFloat{value},
+				render(check.DFloat(value)),
+				render(check.APD(this.Context, value.APD())))
+		}
+	}
+}
+
+// Run each check against count random pairs of values.
+func (this *Harness) RunBinary(checks []BinaryCheck, count int) {
+	for i := 0; i < count; i++ {
+		a := this.generate()
+		b := this.generate()
+		for _, check := range checks {
+			this.compare(check.Name, []compact_float.DFloat{a, b},
+				render(check.DFloat(a, b)),
+				render(check.APD(this.Context, a.APD(), b.APD())))
+		}
+	}
+}
+
+// Returns the divergences found so far.
+func (this *Harness) Divergences() []Divergence {
+	return this.divergences
+}
+
+// Returns the number of comparisons made so far.
+func (this *Harness) ChecksRun() int {
+	return this.checksRun
+}
+
+// Writes a summary of the checks run and any divergences found.
+func (this *Harness) Report(writer io.Writer) (err error) {
+	if _, err = fmt.Fprintf(writer, "%v checks run, %v divergences\n", this.checksRun, len(this.divergences)); err != nil {
+		return
+	}
+	for _, divergence := range this.divergences {
+		if _, err = fmt.Fprintln(writer, divergence); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (this *Harness) generate() compact_float.DFloat {
+	return compact_float.DFloat{}.Generate(this.random, this.Size).Interface().(compact_float.DFloat)
+}
+
+func (this *Harness) compare(check string, inputs []compact_float.DFloat, actual string, expected string) {
+	this.checksRun++
+	if actual == expected {
+		return
+	}
+	if this.MaxDivergences > 0 && len(this.divergences) >= this.MaxDivergences {
+		return
+	}
+	this.divergences = append(this.divergences, Divergence{
+		Check:    check,
+		Inputs:   inputs,
+		DFloat:   actual,
+		Expected: expected,
+	})
+}
+
+func render(result string, err error) string {
+	if err != nil {
+		return "error"
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+package difftest
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/apd/v2"
+	compact_float "github.com/kstenerud/go-compact-float"
+)
+
+func TestConversionChecks(t *testing.T) {
+	harness := New(1)
+	harness.RunUnary(ConversionChecks(), 20000)
+	if harness.ChecksRun() != 20000*len(ConversionChecks()) {
+		t.Errorf("Expected %v checks but got %v", 20000*len(ConversionChecks()), harness.ChecksRun())
+	}
+	for _, divergence := range harness.Divergences() {
+		t.Error(divergence)
+	}
+}
+
+func TestBinaryDivergences(t *testing.T) {
+	// Addition via float64 diverges from apd whenever precision is lost.
+	add := BinaryCheck{
+		Name: "Add",
+		DFloat: func(a, b compact_float.DFloat) (string, error) {
+			if a.IsSpecial() || b.IsSpecial() || a.IsZero() || b.IsZero() {
+				return "", errors.New("Unsupported")
+			}
+			result, err := compact_float.DFloatFromFloat64(a.Float()+b.Float(), 0)
+			return result.String(), err
+		},
+		APD: func(context *apd.Context, a, b *apd.Decimal) (string, error) {
+			if a.Form != apd.Finite || b.Form != apd.Finite || a.IsZero() || b.IsZero() {
+				return "", errors.New("Unsupported")
+			}
+			result := new(apd.Decimal)
+			_, err := context.Add(result, a, b)
+			return result.Text('g'), err
+		},
+	}
+
+	harness := New(2)
+	harness.MaxDivergences = 10
+	harness.RunBinary([]BinaryCheck{add}, 1000)
+	if harness.ChecksRun() != 1000 {
+		t.Errorf("Expected 1000 checks but got %v", harness.ChecksRun())
+	}
+	if len(harness.Divergences()) != 10 {
+		t.Errorf("Expected 10 divergences but got %v", len(harness.Divergences()))
+	}
+
+	report := &bytes.Buffer{}
+	if err := harness.Report(report); err != nil {
+		t.Error(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(report.String()), "\n"); len(lines) != 11 ||
+		!strings.HasPrefix(lines[0], "1000 checks run, 10 divergences") ||
+		!strings.HasPrefix(lines[1], "Add(") {
+		t.Errorf("Unexpected report: %v", report)
+	}
+}
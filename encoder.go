@@ -0,0 +1,203 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+package compact_float
+
+import (
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+)
+
+const encoderFlushThreshold = 4096
+
+// Encoder writes a sequence of compact float values to a writer, buffering the
+// encoded bytes internally. Call Flush() when done to write any buffered data.
+//
+// An encoder created with NewBuffersEncoder() instead accumulates its output
+// as a list of segments, which can be handed to the OS in a single vectored
+// write:
+//
+//	encoder := NewBuffersEncoder()
+//	encoder.WriteSegment(header)
+//	encoder.Encode(value)
+//	...
+//	buffers := encoder.Buffers()
+//	buffers.WriteTo(conn)
+type Encoder struct {
+	writer           io.Writer
+	buffer           []byte
+	segments         net.Buffers
+	checksumInterval int
+	block            []byte
+	blockCount       int
+}
+
+// Create a new encoder that writes to the specified writer.
+func NewEncoder(writer io.Writer) *Encoder {
+	return &Encoder{
+		writer: writer,
+		buffer: make([]byte, 0, encoderFlushThreshold+MaxEncodeLength()),
+	}
+}
+
+// Create a new encoder that accumulates its output in memory as segments
+// (retrieved with Buffers()) rather than writing to a writer.
+func NewBuffersEncoder() *Encoder {
+	return NewEncoder(nil)
+}
+
+// Group the values that follow into checksummed blocks of up to interval
+// values each (see checksum.go), so that a decoder with checksum verification
+// enabled can detect corruption. An interval of 1 checksums every value, and 0
+// (the default) disables checksums. Any values in a partially filled block are
+// written out as a shorter block when the encoder is flushed.
+//
+// Panics if interval is negative.
+func (this *Encoder) SetChecksumInterval(interval int) {
+	if interval < 0 {
+		panic("SetChecksumInterval: interval cannot be negative")
+	}
+	this.finishBlock()
+	this.checksumInterval = interval
+}
+
+// Encode a DFloat, returning the number of bytes it encoded to (not including
+// checksum framing).
+func (this *Encoder) Encode(value DFloat) (bytesEncoded int, err error) {
+	target := this.target()
+	start := len(*target)
+	*target = AppendEncode(*target, value)
+	bytesEncoded = len(*target) - start
+	err = this.valueEncoded()
+	return
+}
+
+// Encode a float64, rounded to the specified number of significant digits (see
+// DFloatFromFloat64()). If rounding occurs, the rounded value is encoded and
+// the returned error will be RoundingError.
+func (this *Encoder) EncodeFloat64(value float64, significantDigits int) (bytesEncoded int, err error) {
+	asDFloat, conversionErr := DFloatFromFloat64(value, significantDigits)
+	if conversionErr != nil && !errors.Is(conversionErr, roundingError) {
+		err = conversionErr
+		return
+	}
+	if bytesEncoded, err = this.Encode(asDFloat); err == nil {
+		err = conversionErr
+	}
+	return
+}
+
+// Add a caller-owned segment of data (framing, a pre-encoded payload, etc) to
+// the output after everything encoded so far. A buffers encoder adds it to its
+// segments without copying it, so it must not be modified until the segments
+// have been written. Otherwise, the encoder is flushed and the segment is
+// written directly.
+func (this *Encoder) WriteSegment(segment []byte) (err error) {
+	if err = this.Flush(); err != nil {
+		return
+	}
+	if this.writer == nil {
+		this.segments = append(this.segments, segment)
+		return
+	}
+	_, err = this.writer.Write(segment)
+	return
+}
+
+// Returns all of the output accumulated so far by a buffers encoder (see
+// NewBuffersEncoder()), and starts a new accumulation. Returns nil for an
+// encoder that has a writer.
+func (this *Encoder) Buffers() (buffers net.Buffers) {
+	this.Flush()
+	buffers = this.segments
+	this.segments = nil
+	return
+}
+
+// Write any buffered data to the underlying writer. A buffers encoder moves
+// the buffered data into its segments instead.
+func (this *Encoder) Flush() error {
+	this.finishBlock()
+	if len(this.buffer) == 0 {
+		return nil
+	}
+	if this.writer == nil {
+		this.segments = append(this.segments, this.buffer)
+		// Keep appending to the rest of the array unless it's nearly used up.
+		this.buffer = this.buffer[len(this.buffer):]
+		if cap(this.buffer) < encoderFlushThreshold/4 {
+			this.buffer = make([]byte, 0, encoderFlushThreshold+MaxEncodeLength())
+		}
+		return nil
+	}
+	_, err := this.writer.Write(this.buffer)
+	this.buffer = this.buffer[:0]
+	return err
+}
+
+// Discard any buffered data and switch to writing to the specified writer (or
+// to accumulating segments if writer is nil).
+func (this *Encoder) Reset(writer io.Writer) {
+	this.writer = writer
+	this.buffer = this.buffer[:0]
+	this.segments = nil
+	this.block = this.block[:0]
+	this.blockCount = 0
+}
+
+// Moves any pending checksum block into the buffer, framed by its value count
+// and checksum.
+func (this *Encoder) finishBlock() {
+	if this.blockCount == 0 {
+		return
+	}
+	start := len(this.buffer)
+	this.buffer = appendULEB128(this.buffer, uint64(this.blockCount))
+	this.buffer = append(this.buffer, this.block...)
+	this.buffer = appendCRC32C(this.buffer, crc32.Checksum(this.buffer[start:], crc32cTable))
+	this.block = this.block[:0]
+	this.blockCount = 0
+}
+
+// Returns the slice that the next encoded value should be appended to.
+func (this *Encoder) target() *[]byte {
+	if this.checksumInterval > 0 {
+		return &this.block
+	}
+	return &this.buffer
+}
+
+// Finishes the checksum block if it's full, and flushes if the buffer is full.
+func (this *Encoder) valueEncoded() error {
+	if this.checksumInterval > 0 {
+		if this.blockCount++; this.blockCount >= this.checksumInterval {
+			this.finishBlock()
+		}
+	}
+	return this.flushIfFull()
+}
+
+func (this *Encoder) flushIfFull() error {
+	if len(this.buffer) < encoderFlushThreshold {
+		return nil
+	}
+	return this.Flush()
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/cockroachdb/apd/v2"
+	"github.com/kstenerud/go-describe"
+)
+
+func TestEncoder(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	encoder := NewEncoder(buffer)
+	if _, err := encoder.Encode(DFloatValue(-1, 15)); err != nil {
+		t.Error(err)
+		return
+	}
+	bigValue, _, err := apd.NewFromString("9.445283e+5000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := encoder.EncodeBig(bigValue); err != nil {
+		t.Error(err)
+		return
+	}
+	bytesEncoded, err := encoder.EncodeFloat64(0.5935555, 4)
+	if !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected RoundingError but got %v", err)
+		return
+	}
+	if bytesEncoded != 3 {
+		t.Errorf("Expected to encode 3 bytes but encoded %v", bytesEncoded)
+		return
+	}
+	if buffer.Len() != 0 {
+		t.Errorf("Expected encoder to buffer its output but %v bytes were written", buffer.Len())
+		return
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := []byte{0x06, 0x0f, 0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04, 0x12, 0xb0, 0x2e}
+	if !bytes.Equal(buffer.Bytes(), expected) {
+		t.Errorf("Expected encoded %v but got %v", describe.D(expected), describe.D(buffer.Bytes()))
+	}
+}
+
+func TestEncoderFlushesWhenFull(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	encoder := NewEncoder(buffer)
+	for i := 0; i < encoderFlushThreshold; i++ {
+		if _, err := encoder.Encode(Zero()); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if buffer.Len() != encoderFlushThreshold {
+		t.Errorf("Expected %v bytes to be flushed but got %v", encoderFlushThreshold, buffer.Len())
+		return
+	}
+
+	other := &bytes.Buffer{}
+	encoder.Encode(Zero())
+	encoder.Reset(other)
+	encoder.Encode(Infinity())
+	encoder.Flush()
+	if buffer.Len() != encoderFlushThreshold || !bytes.Equal(other.Bytes(), []byte{0x82, 0x00}) {
+		t.Errorf("Expected reset to discard buffered data but got %v and %v", buffer.Len(), describe.D(other.Bytes()))
+	}
+}
+
+func TestEncoderEncodeBigAllocations(t *testing.T) {
+	value, _, err := apd.NewFromString("-9.4452837206285466345998345667683453466347345e-5000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	encoder := NewEncoder(ioutil.Discard)
+	allocs := testing.AllocsPerRun(1000, func() {
+		if _, err := encoder.EncodeBig(value); err != nil {
+			t.Error(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations but got %v", allocs)
+	}
+}
+
+func TestBuffersEncoder(t *testing.T) {
+	encoder := NewBuffersEncoder()
+	header := []byte{0xff, 0xfe}
+	expected := &bytes.Buffer{}
+
+	if err := encoder.WriteSegment(header); err != nil {
+		t.Error(err)
+		return
+	}
+	expected.Write(header)
+	for i := 0; i < 2000; i++ {
+		value := DFloatValue(int32(i%10), int64(i))
+		if _, err := encoder.Encode(value); err != nil {
+			t.Error(err)
+			return
+		}
+		Encode(value, expected)
+		if i == 1000 {
+			encoder.WriteSegment(header)
+			expected.Write(header)
+		}
+	}
+
+	buffers := encoder.Buffers()
+	if len(buffers) < 4 {
+		t.Errorf("Expected at least 4 segments but got %v", len(buffers))
+	}
+	if &buffers[0][0] != &header[0] {
+		t.Errorf("Expected the header segment to be added without copying")
+	}
+	previous := append([][]byte{}, buffers...)
+	actual := &bytes.Buffer{}
+	if _, err := buffers.WriteTo(actual); err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(actual.Bytes(), expected.Bytes()) {
+		t.Errorf("Segments don't match the sequentially encoded data")
+	}
+
+	// A new accumulation must not disturb the previous one.
+	encoder.Encode(DFloatValue(1, 1))
+	if buffers = encoder.Buffers(); len(buffers) != 1 || !bytes.Equal(buffers[0], []byte{0x04, 0x01}) {
+		t.Errorf("Expected a single new segment but got %v", buffers)
+	}
+	if !bytes.Equal(bytes.Join(previous, nil), expected.Bytes()) {
+		t.Errorf("Previous segments were modified")
+	}
+
+	writerEncoder := NewEncoder(actual)
+	if writerEncoder.Buffers() != nil {
+		t.Errorf("Expected no buffers from an encoder with a writer")
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"fmt"
+	"io"
+)
+
+// Calls emit with every valid encoding of up to maxLength bytes, and the value
+// it decodes to, so that complete truth tables can be compared between
+// implementations. An encoding is valid if DecodeExact() accepts it, which
+// includes non-canonical encodings (use IsCanonicalEncoding() to tell them
+// apart). bigValue will be nil unless the value is too big to fit into a
+// DFloat.
+//
+// Encodings are emitted in order of length, then of exponent field length,
+// then lexicographically. The encoded slice is reused between calls, so copy it
+// if it must be retained. Enumeration stops at the first error that emit
+// returns, and that error is returned.
+//
+// The number of encodings grows by a factor of about 128 for each byte, so
+// maxLength should be kept small (3 bytes produces around 4 million
+// encodings).
+func EnumerateEncodings(maxLength int, emit func(encoded []byte, value DFloat, bigValue *BigDecimal) error) error {
+	encoded := make([]byte, maxLength)
+	for length := 1; length <= maxLength; length++ {
+		for exponentLength := 1; exponentLength <= length && exponentLength <= maxExponentFieldLength; exponentLength++ {
+			if err := enumerateEncodingsFrom(encoded[:length], 0, exponentLength, emit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Writes a truth table of every valid encoding of up to maxLength bytes (see
+// EnumerateEncodings()), one per line, in the form "<hex encoding> <value>".
+func WriteEncodingTable(maxLength int, writer io.Writer) error {
+	return EnumerateEncodings(maxLength, func(encoded []byte, value DFloat, bigValue *BigDecimal) (err error) {
+		if bigValue != nil {
+			_, err = fmt.Fprintf(writer, "%x %v\n", encoded, bigValue)
+		} else {
+			_, err = fmt.Fprintf(writer, "%x %v\n", encoded, value)
+		}
+		return
+	})
+}
+
+// Fills encoded from index onwards with every combination of bytes that forms
+// a ULEB128 exponent field of exponentLength bytes followed by a ULEB128
+// coefficient occupying the rest, emitting those that decode.
+func enumerateEncodingsFrom(encoded []byte, index int, exponentLength int,
+	emit func(encoded []byte, value DFloat, bigValue *BigDecimal) error) error {
+
+	if index == len(encoded) {
+		value, bigValue, err := DecodeExact(encoded)
+		if err != nil {
+			return nil
+		}
+		return emit(encoded, value, bigValue)
+	}
+
+	first, last := 0x80, 0xff
+	if index == exponentLength-1 || index == len(encoded)-1 {
+		first, last = 0x00, 0x7f
+	}
+	for b := first; b <= last; b++ {
+		encoded[index] = byte(b)
+		if err := enumerateEncodingsFrom(encoded, index+1, exponentLength, emit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEnumerateEncodings(t *testing.T) {
+	count := 0
+	canonicalCount := 0
+	var previous []byte
+	err := EnumerateEncodings(2, func(encoded []byte, value DFloat, bigValue *BigDecimal) error {
+		count++
+		if len(encoded) < len(previous) || bytes.Equal(encoded, previous) {
+			t.Errorf("Encoding %x emitted out of order after %x", encoded, previous)
+		}
+		previous = append(previous[:0], encoded...)
+		if bigValue != nil {
+			t.Errorf("%x: Unexpected big value %v", encoded, bigValue)
+		}
+		if decoded, _, err := DecodeExact(encoded); err != nil || decoded != value {
+			t.Errorf("%x: Expected %v but got %v (%v)", encoded, value, decoded, err)
+		}
+		if isCanonical, _, _ := IsCanonicalEncoding(encoded); isCanonical {
+			canonicalCount++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// 1 byte: 0 and -0
+	// 2 bytes: 128 coefficients for each of the 126 one-byte non-zero exponent
+	// fields, plus the 6 special values.
+	expected := 2 + 126*128 + 6
+	if count != expected {
+		t.Errorf("Expected %v encodings but got %v", expected, count)
+	}
+	// Coefficients that are multiples of 10 are not canonical.
+	expectedCanonical := 2 + 126*(128-13) + 6
+	if canonicalCount != expectedCanonical {
+		t.Errorf("Expected %v canonical encodings but got %v", expectedCanonical, canonicalCount)
+	}
+}
+
+func TestEnumerateEncodingsStop(t *testing.T) {
+	stop := errors.New("stop")
+	count := 0
+	err := EnumerateEncodings(3, func(encoded []byte, value DFloat, bigValue *BigDecimal) error {
+		if count++; count == 10 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count != 10 {
+		t.Errorf("Expected to stop after 10 encodings but got %v after %v", err, count)
+	}
+}
+
+func TestWriteEncodingTable(t *testing.T) {
+	var builder strings.Builder
+	if err := WriteEncodingTable(2, &builder); err != nil {
+		t.Error(err)
+		return
+	}
+	lines := strings.Split(builder.String(), "\n")
+	expectedStart := []string{"02 0", "03 -0", "0000 0", "0001 1"}
+	for i, expected := range expectedStart {
+		if lines[i] != expected {
+			t.Errorf("Expected line %v to be %q but got %q", i, expected, lines[i])
+		}
+	}
+	if last := lines[len(lines)-2]; last != "8500 -sNaN" {
+		t.Errorf("Expected last line to be %q but got %q", "8500 -sNaN", last)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Explain produces a human-readable breakdown of the encoded value at the
+// start of data: which bytes form the exponent field, the sign bits, the
+// exponent, the coefficient bytes and value, and the total length. Any bytes
+// after the first value are ignored. For example, Explain([]byte{0x0d, 0x2a})
+// returns:
+//
+//	Encoded:          0d 2a (2 bytes, canonical)
+//	Exponent field:   0d = 13
+//	  Exponent sign:    + (bit 1 = 0)
+//	  Coefficient sign: - (bit 0 = 1)
+//	  Exponent:         3
+//	Coefficient:      2a = 42
+//	Value:            -4.2e+4
+func Explain(data []byte) (string, error) {
+	value, bigValue, bytesDecoded, err := DecodeFromBytes(data)
+	if err != nil {
+		return "", err
+	}
+	encoded := data[:bytesDecoded]
+	isCanonical, _, _ := IsCanonicalEncoding(encoded)
+	exponentLength, _ := uleb128FromBytesLength(encoded)
+	exponentField, _, _, _ := decodeULEB128FromBytes(encoded)
+
+	var valueText string
+	if bigValue != nil {
+		valueText = fmt.Sprint(bigValue)
+	} else {
+		valueText = value.String()
+	}
+
+	canonicalText := "canonical"
+	if !isCanonical {
+		canonicalText = "not canonical"
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Encoded:          %v (%v bytes, %v)\n", explainBytes(encoded), bytesDecoded, canonicalText)
+	if _, isSpecial := decodeSpecialValue(exponentField, exponentLength); isSpecial {
+		fmt.Fprintf(&builder, "Special value:    %v = %v\n", explainBytes(encoded), valueText)
+		return builder.String(), nil
+	}
+
+	fmt.Fprintf(&builder, "Exponent field:   %v = %v\n", explainBytes(encoded[:exponentLength]), exponentField)
+	fmt.Fprintf(&builder, "  Exponent sign:    %v (bit 1 = %v)\n", explainSign(exponentField>>1), (exponentField>>1)&1)
+	fmt.Fprintf(&builder, "  Coefficient sign: %v (bit 0 = %v)\n", explainSign(exponentField), exponentField&1)
+	exponent, _, _ := decodeExponentField(exponentField)
+	fmt.Fprintf(&builder, "  Exponent:         %v\n", exponent)
+	coefficient, coefficientBig, _, _ := decodeULEB128FromBytes(encoded[exponentLength:])
+	if coefficientBig != nil {
+		fmt.Fprintf(&builder, "Coefficient:      %v = %v\n", explainBytes(encoded[exponentLength:]), coefficientBig)
+	} else {
+		fmt.Fprintf(&builder, "Coefficient:      %v = %v\n", explainBytes(encoded[exponentLength:]), coefficient)
+	}
+	fmt.Fprintf(&builder, "Value:            %v\n", valueText)
+	return builder.String(), nil
+}
+
+func explainSign(bit uint64) string {
+	if bit&1 != 0 {
+		return "-"
+	}
+	return "+"
+}
+
+func explainBytes(data []byte) string {
+	var builder strings.Builder
+	for i, b := range data {
+		if i > 0 {
+			builder.WriteByte(' ')
+		}
+		fmt.Fprintf(&builder, "%02x", b)
+	}
+	return builder.String()
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"testing"
+)
+
+func assertExplain(t *testing.T, data []byte, expected string) {
+	actual, err := Explain(data)
+	if err != nil {
+		t.Errorf("%v: %v", data, err)
+		return
+	}
+	if actual != expected {
+		t.Errorf("%v: Expected\n%v\nbut got\n%v", data, expected, actual)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	assertExplain(t, []byte{0x0d, 0x2a, 0xff}, `Encoded:          0d 2a (2 bytes, canonical)
+Exponent field:   0d = 13
+  Exponent sign:    + (bit 1 = 0)
+  Coefficient sign: - (bit 0 = 1)
+  Exponent:         3
+Coefficient:      2a = 42
+Value:            -4.2e+4
+`)
+	assertExplain(t, []byte{0x9e, 0x00, 0x8d, 0x01}, `Encoded:          9e 00 8d 01 (4 bytes, not canonical)
+Exponent field:   9e 00 = 30
+  Exponent sign:    - (bit 1 = 1)
+  Coefficient sign: + (bit 0 = 0)
+  Exponent:         -7
+Coefficient:      8d 01 = 141
+Value:            0.0000141
+`)
+	assertExplain(t, []byte{0x82, 0x00}, `Encoded:          82 00 (2 bytes, canonical)
+Special value:    82 00 = Infinity
+`)
+	assertExplain(t, []byte{0x03}, `Encoded:          03 (1 bytes, canonical)
+Special value:    03 = -0
+`)
+}
+
+func TestExplainMalformed(t *testing.T) {
+	if _, err := Explain([]byte{0x0d}); !errors.Is(err, ErrorIncomplete) {
+		t.Errorf("Expected ErrorIncomplete but got %v", err)
+	}
+	if _, err := Explain(nil); !errors.Is(err, ErrorIncomplete) {
+		t.Errorf("Expected ErrorIncomplete but got %v", err)
+	}
+}
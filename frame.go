@@ -0,0 +1,101 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+package compact_float
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// A frame is an encoded value prefixed by its length in bytes (as ULEB128), so
+// that it can be skipped or routed without being decoded.
+
+// Encodes a DFloat as a length-prefixed frame.
+func EncodeFramed(value DFloat, writer io.Writer) (bytesEncoded int, err error) {
+	buffer := make([]byte, 0, 1+MaxEncodeLength())
+	buffer = appendULEB128(buffer, uint64(EncodedSize(value)))
+	buffer = AppendEncode(buffer, value)
+	return writer.Write(buffer)
+}
+
+// Decodes a length-prefixed frame. bytesDecoded includes the length prefix.
+// Returns an error if the frame length doesn't match the encoded value, io.EOF
+// if there is no data, or ErrorIncomplete if the data ends partway through the
+// frame.
+// bigValue will be nil unless the decoded value is too big to fit into a DFloat.
+func DecodeFramed(reader io.Reader) (value DFloat, bigValue *BigDecimal, bytesDecoded int, err error) {
+	buffer := []byte{0}
+	frameLength, prefixLength, err := decodeFrameLength(reader, buffer)
+	if err != nil {
+		return
+	}
+
+	limited := limitedReader{reader: reader, remaining: frameLength}
+	value, bigValue, bytesDecoded, err = DecodeWithByteBuffer(&limited, buffer)
+	if err == ErrorTooLong {
+		err = fmt.Errorf("%w: Encoded value is longer than its frame length of %v", ErrorMalformed, frameLength)
+	}
+	if err == io.EOF && frameLength > 0 {
+		// The length prefix promised a value that isn't there.
+		err = ErrorIncomplete
+	}
+	if err != nil {
+		return
+	}
+	if bytesDecoded != frameLength {
+		err = fmt.Errorf("%w: Frame length %v doesn't match encoded value length %v", ErrorMalformed, frameLength, bytesDecoded)
+		return
+	}
+	bytesDecoded += prefixLength
+	return
+}
+
+// Skips over a length-prefixed frame without decoding it, returning the number
+// of bytes skipped (including the length prefix).
+func SkipFramed(reader io.Reader) (bytesSkipped int, err error) {
+	buffer := []byte{0}
+	frameLength, prefixLength, err := decodeFrameLength(reader, buffer)
+	if err != nil {
+		return
+	}
+	skipped, err := io.CopyN(ioutil.Discard, reader, int64(frameLength))
+	bytesSkipped = prefixLength + int(skipped)
+	if err == io.EOF {
+		err = ErrorIncomplete
+	}
+	return
+}
+
+func decodeFrameLength(reader io.Reader, buffer []byte) (frameLength int, prefixLength int, err error) {
+	asUint, asBig, prefixLength, err := decodeULEB128(reader, buffer)
+	if err != nil {
+		err = incompleteIfTruncated(err, prefixLength)
+		return
+	}
+	if asBig != nil || asUint > uint64(maxInt) {
+		err = fmt.Errorf("%w: Frame length is too big", ErrorMalformed)
+		return
+	}
+	frameLength = int(asUint)
+	return
+}
+
+const maxInt = int(^uint(0) >> 1)
@@ -0,0 +1,107 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+package compact_float
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/cockroachdb/apd/v2"
+	"github.com/kstenerud/go-describe"
+)
+
+func TestFramed(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	if _, err := EncodeFramed(DFloatValue(-1, 15), buffer); err != nil {
+		t.Error(err)
+		return
+	}
+	bigValue, _, _ := apd.NewFromString("9.445283e+5000")
+	if _, err := EncodeFramedBig(bigValue, buffer); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := EncodeFramed(Infinity(), buffer); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := []byte{0x02, 0x06, 0x0f, 0x07, 0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04, 0x02, 0x82, 0x00}
+	if !bytes.Equal(buffer.Bytes(), expected) {
+		t.Errorf("Expected encoded %v but got %v", describe.D(expected), describe.D(buffer.Bytes()))
+		return
+	}
+
+	value, _, bytesDecoded, err := DecodeFramed(buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if value != DFloatValue(-1, 15) || bytesDecoded != 3 {
+		t.Errorf("Expected 1.5 (3 bytes) but got %v (%v bytes)", value, bytesDecoded)
+		return
+	}
+	bytesSkipped, err := SkipFramed(buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if bytesSkipped != 8 {
+		t.Errorf("Expected to skip 8 bytes but skipped %v", bytesSkipped)
+		return
+	}
+	value, _, _, err = DecodeFramed(buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if value != Infinity() {
+		t.Errorf("Expected infinity but got %v", value)
+	}
+}
+
+func TestFramedMismatch(t *testing.T) {
+	for _, encoded := range [][]byte{{0x01, 0x06, 0x0f}, {0x03, 0x06, 0x0f, 0x00}} {
+		if _, _, _, err := DecodeFramed(bytes.NewBuffer(encoded)); err == nil {
+			t.Errorf("Expected mismatched frame %v to fail", describe.D(encoded))
+		}
+	}
+	if _, err := SkipFramed(bytes.NewBuffer([]byte{0x05, 0x06, 0x0f})); err != ErrorIncomplete {
+		t.Errorf("Expected ErrorIncomplete but got %v", err)
+	}
+}
+
+func TestFramedTruncated(t *testing.T) {
+	for _, encoded := range [][]byte{{0x80}, {0x02}, {0x02, 0x06}} {
+		if _, _, _, err := DecodeFramed(bytes.NewBuffer(encoded)); err != ErrorIncomplete {
+			t.Errorf("Expected ErrorIncomplete for %v but got %v", describe.D(encoded), err)
+		}
+	}
+	if _, err := SkipFramed(bytes.NewBuffer([]byte{0x80})); err != ErrorIncomplete {
+		t.Errorf("Expected ErrorIncomplete for a truncated length but got %v", err)
+	}
+	if _, _, _, err := DecodeFramed(bytes.NewBuffer(nil)); err != io.EOF {
+		t.Errorf("Expected io.EOF for empty data but got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build go1.18 && !compactfloat_nobig
+// +build go1.18,!compactfloat_nobig
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+// Run with e.g. go test -run=NONE -fuzz=FuzzDecode
+
+var fuzzSeedStrings = []string{
+	"0", "-0", "inf", "-inf", "nan", "snan", "-nan", "-snan", "1", "1.5", "-1.2",
+	"9.445283e+5000", "9223372036854775807", "9223372036854775808", "9223372036854775815",
+	"-9.4452837206285466345998345667683453466347345e-5000", "1.23456789123456789123456789e+100",
+	"0.000001", "1e-7", "-123.456e-789", "Infinity", "-NaN", "1e2147483647", "1e-2147483647",
+}
+
+var fuzzSeedEncodings = [][]byte{
+	{0x02}, {0x03}, {0x80, 0x00}, {0x81, 0x00}, {0x82, 0x00}, {0x83, 0x00}, {0x84, 0x00}, {0x85, 0x00},
+	{0x00, 0x01}, {0x06, 0x0f}, {0x07, 0x0c}, {0x86, 0x00, 0x0f},
+	{0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04},
+	{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+	{0x00, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01},
+	{0xcf, 0x9d, 0x01, 0xd1, 0x8e, 0xa2, 0xe6, 0x83, 0x8a, 0xbf, 0xc1, 0xbb,
+		0xe1, 0xf3, 0xdf, 0xfc, 0xee, 0xac, 0xe5, 0xfe, 0xe1, 0x8f, 0xe2, 0x43},
+	{0xff, 0xff, 0xff, 0xff, 0x1f, 0x01},
+	{0x80, 0x80, 0x80, 0x80, 0x20, 0x01},
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, encoded := range fuzzSeedEncodings {
+		f.Add(encoded)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		value, bigValue, bytesDecoded, err := DecodeFromBytes(data)
+		readerValue, readerBigValue, readerBytesDecoded, readerErr := Decode(bytes.NewReader(data))
+		if (err == nil) != (readerErr == nil) {
+			t.Fatalf("DecodeFromBytes returned %v but Decode returned %v", err, readerErr)
+		}
+		if err != nil {
+			return
+		}
+		if value != readerValue || bytesDecoded != readerBytesDecoded ||
+			(bigValue == nil) != (readerBigValue == nil) ||
+			(bigValue != nil && bigValue.CmpTotal(readerBigValue) != 0) {
+			t.Fatalf("DecodeFromBytes returned %v, %v (%v bytes) but Decode returned %v, %v (%v bytes)",
+				value, bigValue, bytesDecoded, readerValue, readerBigValue, readerBytesDecoded)
+		}
+
+		// Re-encoding must decode to the same value, and re-encode to the same
+		// bytes.
+		var encoded []byte
+		if bigValue != nil {
+			encoded = AppendEncodeBig(nil, bigValue)
+		} else {
+			encoded = AppendEncode(nil, value)
+		}
+		value2, bigValue2, bytesDecoded2, err := DecodeFromBytes(encoded)
+		if err != nil {
+			t.Fatalf("Re-encoded %v failed to decode: %v", encoded, err)
+		}
+		if bytesDecoded2 != len(encoded) || (bigValue == nil) != (bigValue2 == nil) ||
+			(bigValue == nil && value2 != value && !(value2.IsZero() && value.IsZero())) ||
+			(bigValue != nil && bigValue.Cmp(bigValue2) != 0) {
+			t.Fatalf("Expected %v, %v but re-decoded %v, %v", value, bigValue, value2, bigValue2)
+		}
+		var reencoded []byte
+		if bigValue2 != nil {
+			reencoded = AppendEncodeBig(nil, bigValue2)
+		} else {
+			reencoded = AppendEncode(nil, value2)
+		}
+		if !bytes.Equal(encoded, reencoded) {
+			t.Fatalf("Expected re-encoding to be stable, but %v became %v", encoded, reencoded)
+		}
+
+		if bigValue == nil {
+			noAllocValue, noAllocBytesDecoded, err := DecodeFromBytesNoAlloc(data)
+			if err != nil || noAllocValue != value || noAllocBytesDecoded != bytesDecoded {
+				t.Fatalf("Expected DecodeFromBytesNoAlloc to return %v (%v bytes) but got %v (%v bytes), %v",
+					value, bytesDecoded, noAllocValue, noAllocBytesDecoded, err)
+			}
+		}
+	})
+}
+
+func FuzzDFloatFromString(f *testing.F) {
+	for _, str := range fuzzSeedStrings {
+		f.Add(str)
+	}
+	f.Fuzz(func(t *testing.T, str string) {
+		value, err := DFloatFromString(str)
+		if err != nil && !errors.Is(err, RoundingError()) {
+			return
+		}
+		if value.Exponent == ExpSpecial && value != dfloatNegativeZero && !value.IsSpecial() {
+			t.Fatalf("%q produced an invalid special value %v", str, value)
+		}
+
+		// The text form of a value must parse back exactly.
+		text := value.String()
+		reparsed, err := DFloatFromString(text)
+		if err != nil {
+			t.Fatalf("%q parsed to %v, whose text %q failed to parse: %v", str, value, text, err)
+		}
+		if reparsed != value && !(reparsed.IsNan() && value.IsNan()) {
+			t.Fatalf("%q parsed to %v, but its text %q parsed to %v", str, value, text, reparsed)
+		}
+	})
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add(int32(0), int64(0))
+	f.Add(int32(-1), int64(15))
+	f.Add(int32(4994), int64(9445283))
+	f.Add(int32(math.MaxInt32), int64(math.MaxInt64))
+	f.Add(int32(-math.MaxInt32), int64(math.MinInt64))
+	f.Add(ExpSpecial, int64(CoeffNegativeSignalingNan))
+	f.Fuzz(func(t *testing.T, exponent int32, coefficient int64) {
+		value := DFloat{Exponent: exponent, Coefficient: coefficient}
+		if exponent == ExpSpecial {
+			switch coefficient {
+			case CoeffNegativeZero, CoeffInfinity, CoeffNegativeInfinity, CoeffNan,
+				CoeffSignalingNan, CoeffNegativeNan, CoeffNegativeSignalingNan:
+			default:
+				return
+			}
+		}
+
+		encoded := AppendEncode(nil, value)
+		if size := EncodedSize(value); size != len(encoded) {
+			t.Fatalf("%v: EncodedSize() returned %v but encoded to %v bytes", value, size, len(encoded))
+		}
+		decoded, bigValue, err := DecodeExact(encoded)
+		if err != nil {
+			t.Fatalf("%v encoded to %v, which failed to decode: %v", value, encoded, err)
+		}
+		if bigValue != nil {
+			if bigValue.Cmp(value.APD()) != 0 {
+				t.Fatalf("%v encoded to %v, which decoded to %v", value, encoded, bigValue)
+			}
+			return
+		}
+		if decoded.minimized() != value.minimized() && !(decoded.IsZero() && value.IsZero()) {
+			t.Fatalf("%v encoded to %v, which decoded to %v", value, encoded, decoded)
+		}
+	})
+}
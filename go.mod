@@ -5,6 +5,5 @@ go 1.14
 require (
 	github.com/cockroachdb/apd/v2 v2.0.2
 	github.com/kstenerud/go-describe v1.2.13
-	github.com/kstenerud/go-uleb128 v1.1.0
 	github.com/lib/pq v1.7.0 // indirect
 )
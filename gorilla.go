@@ -0,0 +1,293 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"fmt"
+	"io"
+)
+
+// Gorilla encoding compresses a stream of DFloat values into a bit stream
+// (most significant bit first), in the style of Facebook's Gorilla time-series
+// compression. Each value is stored as:
+//
+//     exponent:    delta-of-delta from the previous two exponents, zigzag
+//                  encoded:
+//                      0               delta-of-delta is 0
+//                      10   + 7 bits
+//                      110  + 16 bits
+//                      1110 + 36 bits
+//                      1111            end of stream
+//     coefficient: delta from the previous coefficient, zigzag encoded:
+//                      0               delta is 0
+//                      10   + 8 bits
+//                      110  + 20 bits
+//                      1110 + 40 bits
+//                      1111 + 64 bits
+//
+// The stream starts from an exponent, exponent delta and coefficient of 0, and
+// the end of stream marker is padded with 0 bits to a byte boundary.
+//
+// A series of values with a steady exponent costs 1 bit per exponent, and
+// coefficients that don't change cost 1 bit, so slowly changing prices or
+// sensor readings compress to a byte or two per value. Coefficients are stored
+// as deltas rather than as XORs of their bits, because decimal coefficients
+// that are close in value often differ in many low bits.
+
+const (
+	gorillaWriteBufferSize = 4096
+
+	gorillaExponentBits0 = 7
+	gorillaExponentBits1 = 16
+	gorillaExponentBits2 = 36
+
+	gorillaCoefficientBits0 = 8
+	gorillaCoefficientBits1 = 20
+	gorillaCoefficientBits2 = 40
+	gorillaCoefficientBits3 = 64
+)
+
+// GorillaEncoder compresses a stream of DFloat values. Close() must be called
+// after the last value to write the end of stream marker and any buffered
+// data.
+type GorillaEncoder struct {
+	writer              io.Writer
+	previousExponent    int64
+	previousDelta       int64
+	previousCoefficient int64
+	buffer              []byte
+	current             byte
+	bitCount            uint
+	bytesEncoded        int
+}
+
+// Create a new Gorilla encoder that writes to the specified writer.
+func NewGorillaEncoder(writer io.Writer) *GorillaEncoder {
+	return &GorillaEncoder{
+		writer: writer,
+		buffer: make([]byte, 0, gorillaWriteBufferSize),
+	}
+}
+
+// Encode the next value in the stream. Encoded data is buffered, and written
+// out whenever the buffer fills.
+func (this *GorillaEncoder) Encode(value DFloat) error {
+	delta := int64(value.Exponent) - this.previousExponent
+	deltaOfDelta := zigzagEncode(delta - this.previousDelta)
+	switch {
+	case deltaOfDelta == 0:
+		this.writeBits(0b0, 1)
+	case deltaOfDelta < 1<<gorillaExponentBits0:
+		this.writeBits(0b10, 2)
+		this.writeBits(deltaOfDelta, gorillaExponentBits0)
+	case deltaOfDelta < 1<<gorillaExponentBits1:
+		this.writeBits(0b110, 3)
+		this.writeBits(deltaOfDelta, gorillaExponentBits1)
+	default:
+		this.writeBits(0b1110, 4)
+		this.writeBits(deltaOfDelta, gorillaExponentBits2)
+	}
+	this.previousExponent = int64(value.Exponent)
+	this.previousDelta = delta
+
+	coefficientDelta := zigzagEncode(int64(uint64(value.Coefficient) - uint64(this.previousCoefficient)))
+	switch {
+	case coefficientDelta == 0:
+		this.writeBits(0b0, 1)
+	case coefficientDelta < 1<<gorillaCoefficientBits0:
+		this.writeBits(0b10, 2)
+		this.writeBits(coefficientDelta, gorillaCoefficientBits0)
+	case coefficientDelta < 1<<gorillaCoefficientBits1:
+		this.writeBits(0b110, 3)
+		this.writeBits(coefficientDelta, gorillaCoefficientBits1)
+	case coefficientDelta < 1<<gorillaCoefficientBits2:
+		this.writeBits(0b1110, 4)
+		this.writeBits(coefficientDelta, gorillaCoefficientBits2)
+	default:
+		this.writeBits(0b1111, 4)
+		this.writeBits(coefficientDelta, gorillaCoefficientBits3)
+	}
+	this.previousCoefficient = value.Coefficient
+
+	if len(this.buffer) >= gorillaWriteBufferSize-32 {
+		return this.flush()
+	}
+	return nil
+}
+
+// Write the end of stream marker and any buffered data. The encoder can't be
+// used afterwards.
+func (this *GorillaEncoder) Close() error {
+	this.writeBits(0b1111, 4)
+	if this.bitCount > 0 {
+		this.writeBits(0, 8-this.bitCount)
+	}
+	return this.flush()
+}
+
+// Returns the number of bytes written to the writer so far.
+func (this *GorillaEncoder) BytesEncoded() int {
+	return this.bytesEncoded
+}
+
+func (this *GorillaEncoder) writeBits(value uint64, count uint) {
+	for count > 0 {
+		chunk := 8 - this.bitCount
+		if chunk > count {
+			chunk = count
+		}
+		count -= chunk
+		this.current = this.current<<chunk | byte(value>>count)&(1<<chunk-1)
+		this.bitCount += chunk
+		if this.bitCount == 8 {
+			this.buffer = append(this.buffer, this.current)
+			this.current = 0
+			this.bitCount = 0
+		}
+	}
+}
+
+func (this *GorillaEncoder) flush() error {
+	bytesWritten, err := this.writer.Write(this.buffer)
+	this.bytesEncoded += bytesWritten
+	this.buffer = this.buffer[:0]
+	return err
+}
+
+// GorillaDecoder decompresses a stream of values written by a GorillaEncoder.
+// It reads a byte at a time, so it never reads past the end of the stream.
+type GorillaDecoder struct {
+	reader              countingReader
+	previousExponent    int64
+	previousDelta       int64
+	previousCoefficient int64
+	current             byte
+	bitCount            uint
+	done                bool
+}
+
+// Create a new Gorilla decoder that reads from the specified reader.
+func NewGorillaDecoder(reader io.Reader) *GorillaDecoder {
+	return &GorillaDecoder{
+		reader: newCountingReader(reader),
+	}
+}
+
+// Decode the next value in the stream. Returns io.EOF once the end of stream
+// marker has been read.
+func (this *GorillaDecoder) Decode() (value DFloat, err error) {
+	if this.done {
+		return value, io.EOF
+	}
+
+	prefix, err := this.readPrefix()
+	if err != nil {
+		return
+	}
+	var deltaOfDelta uint64
+	switch prefix {
+	case 1:
+		deltaOfDelta, err = this.readBits(gorillaExponentBits0)
+	case 2:
+		deltaOfDelta, err = this.readBits(gorillaExponentBits1)
+	case 3:
+		deltaOfDelta, err = this.readBits(gorillaExponentBits2)
+	case 4:
+		this.done = true
+		return value, io.EOF
+	}
+	if err != nil {
+		return
+	}
+	delta := this.previousDelta + zigzagDecode(deltaOfDelta)
+	exponent := this.previousExponent + delta
+	if exponent < int64(ExpSpecial) || exponent > 0x7fffffff {
+		err = fmt.Errorf("%w: %v", ErrorExponentTooLarge, exponent)
+		return
+	}
+
+	if prefix, err = this.readPrefix(); err != nil {
+		return
+	}
+	var coefficientDelta uint64
+	switch prefix {
+	case 1:
+		coefficientDelta, err = this.readBits(gorillaCoefficientBits0)
+	case 2:
+		coefficientDelta, err = this.readBits(gorillaCoefficientBits1)
+	case 3:
+		coefficientDelta, err = this.readBits(gorillaCoefficientBits2)
+	case 4:
+		coefficientDelta, err = this.readBits(gorillaCoefficientBits3)
+	}
+	if err != nil {
+		return
+	}
+
+	value = DFloat{
+		Exponent:    int32(exponent),
+		Coefficient: int64(uint64(this.previousCoefficient) + uint64(zigzagDecode(coefficientDelta))),
+	}
+	if value.IsSpecial() && !isSpecialCoefficient(value.Coefficient) {
+		return dfloatZero, fmt.Errorf("%w: %v is not a special value code", ErrorMalformed, value.Coefficient)
+	}
+	this.previousExponent = exponent
+	this.previousDelta = delta
+	this.previousCoefficient = value.Coefficient
+	return
+}
+
+// Returns the number of bytes read from the reader so far.
+func (this *GorillaDecoder) BytesDecoded() int {
+	return this.reader.bytesRead
+}
+
+// Reads a bucket prefix of up to 4 bits, returning the number of 1 bits before
+// the terminating 0 (or 4 if there is none).
+func (this *GorillaDecoder) readPrefix() (prefix int, err error) {
+	for prefix < 4 {
+		bit, err := this.readBits(1)
+		if err != nil || bit == 0 {
+			return prefix, err
+		}
+		prefix++
+	}
+	return
+}
+
+func (this *GorillaDecoder) readBits(count uint) (value uint64, err error) {
+	for count > 0 {
+		if this.bitCount == 0 {
+			if this.current, err = this.reader.ReadByte(); err != nil {
+				return 0, incompleteIfTruncated(err, 1)
+			}
+			this.bitCount = 8
+		}
+		chunk := this.bitCount
+		if chunk > count {
+			chunk = count
+		}
+		this.bitCount -= chunk
+		value = value<<chunk | uint64(this.current>>this.bitCount)&(1<<chunk-1)
+		count -= chunk
+	}
+	return
+}
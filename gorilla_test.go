@@ -0,0 +1,143 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func gorillaEncode(t *testing.T, values []DFloat) []byte {
+	buffer := &bytes.Buffer{}
+	encoder := NewGorillaEncoder(buffer)
+	for _, value := range values {
+		if err := encoder.Encode(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if encoder.BytesEncoded() != buffer.Len() {
+		t.Errorf("Expected BytesEncoded %v but got %v", buffer.Len(), encoder.BytesEncoded())
+	}
+	return buffer.Bytes()
+}
+
+func assertGorillaRoundTrip(t *testing.T, values []DFloat) []byte {
+	encoded := gorillaEncode(t, values)
+	decoder := NewGorillaDecoder(bytes.NewReader(encoded))
+	var decoded []DFloat
+	for {
+		value, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded = append(decoded, value)
+	}
+	if !reflect.DeepEqual(decoded, values) {
+		t.Errorf("Expected %v but got %v", values, decoded)
+	}
+	if decoder.BytesDecoded() != len(encoded) {
+		t.Errorf("Expected to decode %v bytes but got %v", len(encoded), decoder.BytesDecoded())
+	}
+	return encoded
+}
+
+func TestGorillaRoundTrip(t *testing.T) {
+	assertGorillaRoundTrip(t, nil)
+	assertGorillaRoundTrip(t, []DFloat{Zero()})
+	assertGorillaRoundTrip(t, []DFloat{
+		DFloatValue(-2, 10050), DFloatValue(-2, 10051), DFloatValue(-2, 10051), DFloatValue(-3, 100505),
+		QuietNaN(), NegativeSignalingNaN(), Infinity(), NegativeZero(),
+		{Exponent: math.MaxInt32, Coefficient: math.MaxInt64}, {Exponent: -math.MaxInt32, Coefficient: math.MinInt64},
+		{Exponent: math.MaxInt32, Coefficient: 1}, DFloatValue(0, -1), DFloatValue(1000, 7),
+	})
+
+	rng := rand.New(rand.NewSource(1))
+	values := make([]DFloat, 1000)
+	for i := range values {
+		values[i] = RandDFloat(rng, RandOpts{MinExponent: -20, MaxExponent: 20, MaxDigits: 19, SpecialProbability: 0.05})
+	}
+	assertGorillaRoundTrip(t, values)
+}
+
+func TestGorillaCompression(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	values := make([]DFloat, 1000)
+	price := int64(1000000)
+	for i := range values {
+		price += rng.Int63n(21) - 10
+		values[i] = DFloat{Exponent: -4, Coefficient: price}
+	}
+	encoded := assertGorillaRoundTrip(t, values)
+	if len(encoded) > 2*len(values) {
+		t.Errorf("Expected at most 2 bytes per value but got %v bytes for %v values", len(encoded), len(values))
+	}
+}
+
+func TestGorillaTruncated(t *testing.T) {
+	encoded := gorillaEncode(t, []DFloat{DFloatValue(-2, 10050), DFloatValue(5, 1), DFloatValue(5, 2)})
+	for length := 0; length < len(encoded); length++ {
+		decoder := NewGorillaDecoder(bytes.NewReader(encoded[:length]))
+		var err error
+		for err == nil {
+			_, err = decoder.Decode()
+		}
+		if !errors.Is(err, ErrorIncomplete) {
+			t.Errorf("Length %v: Expected ErrorIncomplete but got %v", length, err)
+		}
+	}
+}
+
+func TestGorillaExponentOverflow(t *testing.T) {
+	// A delta-of-delta of 2^35 - 1 pushes the exponent out of range.
+	encoder := NewGorillaEncoder(nil)
+	encoder.writeBits(0b1110, 4)
+	encoder.writeBits(1<<gorillaExponentBits2-2, gorillaExponentBits2)
+	encoder.writeBits(0, 1)
+	encoder.writeBits(0, 7)
+	if _, err := NewGorillaDecoder(bytes.NewReader(encoder.buffer)).Decode(); !errors.Is(err, ErrorExponentTooLarge) {
+		t.Errorf("Expected ErrorExponentTooLarge but got %v", err)
+	}
+}
+
+func TestGorillaIllegalSpecial(t *testing.T) {
+	// An exponent of ExpSpecial with a coefficient of 42, which isn't a
+	// special value code.
+	encoder := NewGorillaEncoder(nil)
+	encoder.writeBits(0b1110, 4)
+	encoder.writeBits(zigzagEncode(int64(ExpSpecial)), gorillaExponentBits2)
+	encoder.writeBits(0b10, 2)
+	encoder.writeBits(zigzagEncode(42), gorillaCoefficientBits0)
+	encoder.writeBits(0, 6)
+	if value, err := NewGorillaDecoder(bytes.NewReader(encoder.buffer)).Decode(); !errors.Is(err, ErrorMalformed) {
+		t.Errorf("Expected ErrorMalformed but got %v (%v)", value, err)
+	}
+}
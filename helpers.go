@@ -0,0 +1,156 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"math"
+	"math/big"
+)
+
+// Compares two non-NaN values, returning -1, 0 or 1. Negative zero equals 0.
+func compareDFloat(a DFloat, b DFloat) int {
+	if rankA, rankB := infinityRank(a), infinityRank(b); rankA != rankB || rankA != 0 {
+		return compareInt64(int64(rankA), int64(rankB))
+	}
+
+	if signA, signB := compareInt64(a.Coefficient, 0), compareInt64(b.Coefficient, 0); signA != signB || signA == 0 {
+		return compareInt64(int64(signA), int64(signB))
+	}
+	sign := 1
+	magnitudeA, magnitudeB := uint64(a.Coefficient), uint64(b.Coefficient)
+	if a.Coefficient < 0 {
+		sign = -1
+		magnitudeA, magnitudeB = -magnitudeA, -magnitudeB
+	}
+
+	// Compare adjusted exponents first, so that values with very different
+	// exponents are never scaled. If they match, the exponents differ by less
+	// than 19 and the scaled magnitude has the same digit count as the other,
+	// so it fits into a uint64.
+	digitsA, digitsB := decimalDigitCount(magnitudeA), decimalDigitCount(magnitudeB)
+	adjustedA := int64(a.Exponent) + int64(digitsA)
+	adjustedB := int64(b.Exponent) + int64(digitsB)
+	if adjustedA != adjustedB {
+		return compareInt64(adjustedA, adjustedB) * sign
+	}
+	for exponent := a.Exponent; exponent > b.Exponent; exponent-- {
+		magnitudeA *= 10
+	}
+	for exponent := b.Exponent; exponent > a.Exponent; exponent-- {
+		magnitudeB *= 10
+	}
+	switch {
+	case magnitudeA < magnitudeB:
+		return -sign
+	case magnitudeA > magnitudeB:
+		return sign
+	}
+	return 0
+}
+
+// Returns -1 for negative infinity, 1 for positive infinity, and 0 otherwise.
+func infinityRank(value DFloat) int {
+	switch {
+	case value.IsNegativeInfinity():
+		return -1
+	case value.IsInfinity():
+		return 1
+	}
+	return 0
+}
+
+func compareInt64(a int64, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func decimalDigitCount(value uint64) int {
+	count := 1
+	for value >= 10 {
+		value /= 10
+		count++
+	}
+	return count
+}
+
+func pow10BigInt(exponent int64) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(exponent), nil)
+}
+
+// Returns a copy of a coefficient with its trailing zeros moved into the
+// exponent.
+func stripTrailingZeros(coefficient *big.Int, exponent int32) (*big.Int, int32) {
+	stripped := new(big.Int).Set(coefficient)
+	if stripped.Sign() == 0 {
+		return stripped, exponent
+	}
+	ten := big.NewInt(10)
+	quotient, remainder := new(big.Int), new(big.Int)
+	for exponent < math.MaxInt32 {
+		if quotient.QuoRem(stripped, ten, remainder); remainder.Sign() != 0 {
+			break
+		}
+		stripped, quotient = quotient, stripped
+		exponent++
+	}
+	return stripped, exponent
+}
+
+// Returns true if the value's sign is negative (including -0 and -Infinity).
+func hasNegativeSign(value DFloat) bool {
+	return value.Coefficient < 0 || value == dfloatNegativeZero || value == dfloatNegativeInfinity
+}
+
+// Returns a minimized value with this (non-negative) magnitude and the
+// specified sign, using negative zero for a negative zero magnitude.
+func (this DFloat) withSign(isNegative bool) DFloat {
+	if this.Coefficient == 0 {
+		if isNegative {
+			return dfloatNegativeZero
+		}
+		return dfloatZero
+	}
+	if isNegative {
+		this.Coefficient = -this.Coefficient
+	}
+	return this.minimized()
+}
+
+// Returns the absolute value of an int64 as a uint64 (which can hold the
+// magnitude of math.MinInt64).
+func magnitudeInt64(value int64) uint64 {
+	if value < 0 {
+		return -uint64(value)
+	}
+	return uint64(value)
+}
+
+func infinityWithSign(isNegative bool) DFloat {
+	if isNegative {
+		return dfloatNegativeInfinity
+	}
+	return dfloatInfinity
+}
@@ -0,0 +1,211 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var ErrorInvalidBuckets = errors.New("Histogram bucket boundaries are invalid")
+
+// Histogram counts observed values into buckets with decimal upper boundaries,
+// so that boundaries such as 0.1 are compared exactly rather than as their
+// nearest float64.
+//
+// A value falls into the first bucket whose upper boundary is greater than or
+// equal to it, or into the overflow bucket if it's greater than every boundary.
+// NaNs are counted separately (see NaNCount()). Negative zero is treated as 0.
+type Histogram struct {
+	bounds   []DFloat
+	counts   []uint64
+	count    uint64
+	nanCount uint64
+}
+
+// Creates a histogram with the given bucket upper boundaries (inclusive), which
+// must be strictly ascending and may not contain NaN. An overflow bucket for
+// values above the last boundary is added automatically.
+func NewHistogram(bounds []DFloat) (*Histogram, error) {
+	for i, bound := range bounds {
+		if bound.IsNan() {
+			return nil, fmt.Errorf("%w: boundary %v is NaN", ErrorInvalidBuckets, i)
+		}
+		if i > 0 && compareDFloat(bounds[i-1], bound) >= 0 {
+			return nil, fmt.Errorf("%w: boundary %v (%v) is not greater than %v", ErrorInvalidBuckets, i, bound, bounds[i-1])
+		}
+	}
+	return &Histogram{
+		bounds: append([]DFloat(nil), bounds...),
+		counts: make([]uint64, len(bounds)+1),
+	}, nil
+}
+
+// Returns the index of the bucket that value falls into. The overflow bucket
+// has index len(Bounds()). NaN returns -1.
+func (this *Histogram) Bucket(value DFloat) int {
+	if value.IsNan() {
+		return -1
+	}
+	return sort.Search(len(this.bounds), func(i int) bool {
+		return compareDFloat(value, this.bounds[i]) <= 0
+	})
+}
+
+// Counts a value into its bucket.
+func (this *Histogram) Observe(value DFloat) {
+	index := this.Bucket(value)
+	if index < 0 {
+		this.nanCount++
+		return
+	}
+	this.counts[index]++
+	this.count++
+}
+
+// Decodes and observes all of the encoded values in data. On error, the values
+// before the failing one will have been observed, and bytesDecoded will be the
+// offset of the failing value. Values too big to fit into a DFloat fail with
+// ErrorValueTooLarge.
+func (this *Histogram) ObserveEncoded(data []byte) (bytesDecoded int, err error) {
+	for bytesDecoded < len(data) {
+		value, byteCount, err := DecodeFromBytesNoAlloc(data[bytesDecoded:])
+		if err != nil {
+			return bytesDecoded, err
+		}
+		this.Observe(value)
+		bytesDecoded += byteCount
+	}
+	return
+}
+
+// Returns the bucket upper boundaries (not including the overflow bucket).
+// The returned slice must not be modified.
+func (this *Histogram) Bounds() []DFloat {
+	return this.bounds
+}
+
+// Returns the number of values in each bucket, with the overflow bucket last.
+// The returned slice must not be modified.
+func (this *Histogram) Counts() []uint64 {
+	return this.counts
+}
+
+// Returns the cumulative number of values in each bucket (each bucket's count
+// plus the counts of all buckets below it), with the overflow bucket last.
+func (this *Histogram) CumulativeCounts() []uint64 {
+	cumulative := make([]uint64, len(this.counts))
+	total := uint64(0)
+	for i, count := range this.counts {
+		total += count
+		cumulative[i] = total
+	}
+	return cumulative
+}
+
+// Returns the number of values observed, not including NaNs.
+func (this *Histogram) Count() uint64 {
+	return this.count
+}
+
+// Returns the number of NaN values that were observed.
+func (this *Histogram) NaNCount() uint64 {
+	return this.nanCount
+}
+
+// Clears all counts, keeping the bucket boundaries.
+func (this *Histogram) Reset() {
+	for i := range this.counts {
+		this.counts[i] = 0
+	}
+	this.count = 0
+	this.nanCount = 0
+}
+
+// Generates count boundaries starting at start, with each one factor times the
+// previous (e.g. 1, 2, 4, 8 for start 1 and factor 2). Every boundary is
+// exact.
+//
+// Panics if start is not a finite value greater than 0, if factor is less than
+// 2, if count is less than 1, or if a boundary doesn't fit into a DFloat.
+func ExponentialBuckets(start DFloat, factor int64, count int) []DFloat {
+	if start.IsSpecial() || start.Coefficient <= 0 {
+		panic("ExponentialBuckets: start must be a finite value greater than 0")
+	}
+	if factor < 2 {
+		panic("ExponentialBuckets: factor must be at least 2")
+	}
+	if count < 1 {
+		panic("ExponentialBuckets: count must be at least 1")
+	}
+
+	start = start.minimized()
+	bounds := make([]DFloat, count)
+	bounds[0] = start
+	for i := 1; i < count; i++ {
+		previous := bounds[i-1]
+		if previous.Coefficient > math.MaxInt64/factor {
+			panic(fmt.Errorf("ExponentialBuckets: boundary %v doesn't fit into a DFloat", i))
+		}
+		bounds[i] = DFloatValue(previous.Exponent, previous.Coefficient*factor)
+	}
+	return bounds
+}
+
+// Generates boundaries at each of the given mantissas within every power of 10
+// from 10^minExponent to 10^maxExponent (inclusive), which is the usual way to
+// get evenly spaced buckets on a log scale. For example, mantissas 1, 2.5, 5
+// from exponent -1 to 0 give 0.1, 0.25, 0.5, 1, 2.5, 5.
+//
+// Panics if there are no mantissas, if the mantissas are not strictly
+// ascending within [1, 10), or if minExponent is greater than maxExponent.
+func DecadeBuckets(minExponent int32, maxExponent int32, mantissas ...DFloat) []DFloat {
+	if len(mantissas) == 0 {
+		panic("DecadeBuckets: at least one mantissa is required")
+	}
+	if minExponent > maxExponent {
+		panic("DecadeBuckets: minExponent must be <= maxExponent")
+	}
+	one, ten := DFloatValue(0, 1), DFloatValue(1, 1)
+	for i, mantissa := range mantissas {
+		if mantissa.IsSpecial() || compareDFloat(mantissa, one) < 0 || compareDFloat(mantissa, ten) >= 0 {
+			panic(fmt.Errorf("DecadeBuckets: mantissa %v is not within [1, 10)", mantissa))
+		}
+		if i > 0 && compareDFloat(mantissas[i-1], mantissa) >= 0 {
+			panic("DecadeBuckets: mantissas must be strictly ascending")
+		}
+	}
+
+	bounds := make([]DFloat, 0, int(int64(maxExponent)-int64(minExponent)+1)*len(mantissas))
+	for exponent := int64(minExponent); exponent <= int64(maxExponent); exponent++ {
+		for _, mantissa := range mantissas {
+			mantissa = mantissa.minimized()
+			boundExponent := int64(mantissa.Exponent) + exponent
+			if boundExponent <= int64(ExpSpecial) || boundExponent > math.MaxInt32 {
+				panic(fmt.Errorf("DecadeBuckets: boundary %ve%v doesn't fit into a DFloat", mantissa, exponent))
+			}
+			bounds = append(bounds, DFloat{Exponent: int32(boundExponent), Coefficient: mantissa.Coefficient})
+		}
+	}
+	return bounds
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func mustDFloats(t *testing.T, strs ...string) []DFloat {
+	values := make([]DFloat, len(strs))
+	for i, str := range strs {
+		value, err := DFloatFromString(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values[i] = value
+	}
+	return values
+}
+
+func assertBuckets(t *testing.T, actual []DFloat, expected ...string) {
+	expectedValues := mustDFloats(t, expected...)
+	if len(actual) != len(expectedValues) {
+		t.Errorf("Expected buckets %v but got %v", expectedValues, actual)
+		return
+	}
+	for i := range actual {
+		if actual[i] != expectedValues[i].minimized() {
+			t.Errorf("Expected buckets %v but got %v", expectedValues, actual)
+			return
+		}
+	}
+}
+
+func assertBucketsPanic(t *testing.T, name string, function func()) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%v: Expected a panic", name)
+		}
+	}()
+	function()
+}
+
+func TestHistogram(t *testing.T) {
+	histogram, err := NewHistogram(mustDFloats(t, "0.1", "0.3", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range mustDFloats(t, "-Infinity", "-5", "0", "-0", "0.1", "0.10000001", "0.3", "0.30", "3e-1", "0.99", "1", "1.0000000001", "1e100", "Infinity", "NaN", "-sNaN") {
+		histogram.Observe(value)
+	}
+	if expected := []uint64{5, 4, 2, 3}; !reflect.DeepEqual(histogram.Counts(), expected) {
+		t.Errorf("Expected counts %v but got %v", expected, histogram.Counts())
+	}
+	if expected := []uint64{5, 9, 11, 14}; !reflect.DeepEqual(histogram.CumulativeCounts(), expected) {
+		t.Errorf("Expected cumulative counts %v but got %v", expected, histogram.CumulativeCounts())
+	}
+	if histogram.Count() != 14 || histogram.NaNCount() != 2 {
+		t.Errorf("Expected 14 values and 2 NaNs but got %v and %v", histogram.Count(), histogram.NaNCount())
+	}
+
+	histogram.Reset()
+	if histogram.Count() != 0 || histogram.NaNCount() != 0 || !reflect.DeepEqual(histogram.Counts(), []uint64{0, 0, 0, 0}) {
+		t.Errorf("Expected an empty histogram after reset")
+	}
+	assertBuckets(t, histogram.Bounds(), "0.1", "0.3", "1")
+}
+
+func TestHistogramEncoded(t *testing.T) {
+	histogram, err := NewHistogram(mustDFloats(t, "0", "10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []byte
+	for _, value := range mustDFloats(t, "-1", "7", "70", "10") {
+		data = AppendEncode(data, value)
+	}
+	if bytesDecoded, err := histogram.ObserveEncoded(data); err != nil || bytesDecoded != len(data) {
+		t.Errorf("Expected to decode %v bytes but got %v (%v)", len(data), bytesDecoded, err)
+	}
+	if expected := []uint64{1, 2, 1}; !reflect.DeepEqual(histogram.Counts(), expected) {
+		t.Errorf("Expected counts %v but got %v", expected, histogram.Counts())
+	}
+
+	if bytesDecoded, err := histogram.ObserveEncoded([]byte{0x02, 0x80}); !errors.Is(err, ErrorIncomplete) || bytesDecoded != 1 {
+		t.Errorf("Expected ErrorIncomplete at offset 1 but got %v at %v", err, bytesDecoded)
+	}
+	if histogram.Count() != 5 {
+		t.Errorf("Expected 5 values but got %v", histogram.Count())
+	}
+}
+
+func TestNewHistogramInvalid(t *testing.T) {
+	for _, bounds := range [][]string{
+		{"1", "1"},
+		{"1", "1.0"},
+		{"2", "1"},
+		{"1", "NaN"},
+	} {
+		if _, err := NewHistogram(mustDFloats(t, bounds...)); !errors.Is(err, ErrorInvalidBuckets) {
+			t.Errorf("%v: Expected ErrorInvalidBuckets but got %v", bounds, err)
+		}
+	}
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	assertBuckets(t, ExponentialBuckets(DFloatValue(0, 1), 2, 5), "1", "2", "4", "8", "16")
+	assertBuckets(t, ExponentialBuckets(DFloatValue(-3, 5), 10, 4), "0.005", "0.05", "0.5", "5")
+	assertBuckets(t, ExponentialBuckets(DFloatValue(-1, 10), 3, 3), "1", "3", "9")
+
+	assertBucketsPanic(t, "zero start", func() { ExponentialBuckets(Zero(), 2, 3) })
+	assertBucketsPanic(t, "negative start", func() { ExponentialBuckets(DFloatValue(0, -1), 2, 3) })
+	assertBucketsPanic(t, "infinite start", func() { ExponentialBuckets(Infinity(), 2, 3) })
+	assertBucketsPanic(t, "factor", func() { ExponentialBuckets(DFloatValue(0, 1), 1, 3) })
+	assertBucketsPanic(t, "count", func() { ExponentialBuckets(DFloatValue(0, 1), 2, 0) })
+	assertBucketsPanic(t, "overflow", func() { ExponentialBuckets(DFloatValue(0, 3), 2, 64) })
+}
+
+func TestDecadeBuckets(t *testing.T) {
+	assertBuckets(t, DecadeBuckets(-1, 0, mustDFloats(t, "1", "2.5", "5")...), "0.1", "0.25", "0.5", "1", "2.5", "5")
+	assertBuckets(t, DecadeBuckets(2, 3, DFloatValue(0, 1)), "100", "1000")
+
+	assertBucketsPanic(t, "no mantissas", func() { DecadeBuckets(0, 1) })
+	assertBucketsPanic(t, "exponents", func() { DecadeBuckets(1, 0, DFloatValue(0, 1)) })
+	assertBucketsPanic(t, "mantissa range", func() { DecadeBuckets(0, 1, DFloatValue(1, 1)) })
+	assertBucketsPanic(t, "mantissa order", func() { DecadeBuckets(0, 1, DFloatValue(0, 2), DFloatValue(0, 1)) })
+	assertBucketsPanic(t, "exponent overflow", func() { DecadeBuckets(math.MinInt32+1, math.MinInt32+1, DFloatValue(-1, 25)) })
+}
+
+func TestCompareDFloat(t *testing.T) {
+	ordered := mustDFloats(t, "-Infinity", "-9223372036854775807e10", "-1e10", "-1.5", "-1", "-0.999999999999999999",
+		"0", "1e-1000", "0.999999999999999999", "1", "1.000000000000000001", "9.1e18", "9223372036854775807",
+		"1e19", "Infinity")
+	for i, a := range ordered {
+		for j, b := range ordered {
+			if actual, expected := compareDFloat(a, b), compareInt64(int64(i), int64(j)); actual != expected {
+				t.Errorf("compare %v, %v: Expected %v but got %v", a, b, expected, actual)
+			}
+		}
+	}
+
+	if compareDFloat(NegativeZero(), Zero()) != 0 {
+		t.Errorf("Expected -0 to equal 0")
+	}
+	if compareDFloat(DFloat{Exponent: -18, Coefficient: 1000000000000000000}, DFloatValue(0, 1)) != 0 {
+		t.Errorf("Expected 1000000000000000000e-18 to equal 1")
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// The JSON lines transcoders convert between a stream of compact float values
+// and newline-delimited JSON, one value per line. Finite values are written as
+// JSON numbers with every digit of the coefficient, and infinity and NaN (which
+// JSON numbers can't represent) as the strings "Infinity", "-Infinity", "NaN",
+// "sNaN", "-NaN" and "-sNaN".
+
+const maxJSONLineLength = 1 << 24
+
+// Decodes compact float values from reader until it is exhausted, writing each
+// to writer as a line of JSON. Values of any size are supported.
+func TranscodeToJSONLines(reader io.Reader, writer io.Writer) (count int, err error) {
+	decoder := newCountingReader(reader)
+	buffered := bufio.NewWriter(writer)
+	var value unpackedDecimal
+	var line []byte
+	for {
+		if _, err = DecodeDecimal(&decoder, &value); err != nil {
+			if err == io.EOF {
+				err = buffered.Flush()
+			}
+			return
+		}
+		line = appendJSONValue(line[:0], &value)
+		line = append(line, '\n')
+		if _, err = buffered.Write(line); err != nil {
+			return
+		}
+		count++
+	}
+}
+
+// Reads lines of JSON from reader, writing each value to writer as a compact
+// float. Each line must hold a JSON number, or a JSON string containing any
+// value that DFloatFromString() accepts (such as "NaN" or "1.5"). Blank lines
+// are skipped. Values are encoded exactly, however many digits they have.
+// Errors include the line number.
+func TranscodeFromJSONLines(reader io.Reader, writer io.Writer) (count int, err error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, maxJSONLineLength)
+	buffered := bufio.NewWriter(writer)
+	var encoded []byte
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if encoded, err = appendEncodedJSONValue(encoded[:0], text); err != nil {
+			err = fmt.Errorf("line %v: %w", lineNumber, err)
+			return
+		}
+		if _, err = buffered.Write(encoded); err != nil {
+			return
+		}
+		count++
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	err = buffered.Flush()
+	return
+}
+
+func appendJSONValue(dst []byte, value *unpackedDecimal) []byte {
+	if value.exponent == ExpSpecial {
+		special := DFloat{Exponent: value.exponent, Coefficient: value.coefficient.Int64()}
+		if special.IsNegativeZero() {
+			return append(dst, "-0"...)
+		}
+		dst = append(dst, '"')
+		dst = special.AppendText(dst, 'g')
+		return append(dst, '"')
+	}
+	var magnitude big.Int
+	digits := magnitude.Abs(&value.coefficient).Append(nil, 10)
+	return appendDecimalText(dst, value.coefficient.Sign() < 0, int64(value.exponent), digits, 'g')
+}
+
+func appendEncodedJSONValue(dst []byte, text string) ([]byte, error) {
+	if text[0] == '"' {
+		if err := json.Unmarshal([]byte(text), &text); err != nil {
+			return dst, err
+		}
+	} else if !json.Valid([]byte(text)) || strings.IndexAny(text[:1], "-0123456789") < 0 {
+		return dst, fmt.Errorf("%w: %q is not a JSON number or string", ErrorMalformed, text)
+	}
+
+	value, err := DFloatFromString(text)
+	if err == nil {
+		return AppendEncode(dst, value), nil
+	}
+	if !errors.Is(err, roundingError) {
+		return dst, err
+	}
+	exact, err := parseExactDecimal(text)
+	if err != nil {
+		return dst, err
+	}
+	return AppendEncodeDecimal(dst, exact), nil
+}
+
+// Parses a finite decimal string ([sign] digits [. digits] [e [sign] digits])
+// without rounding.
+func parseExactDecimal(text string) (*unpackedDecimal, error) {
+	mantissa, exponentText := text, ""
+	if index := strings.IndexAny(text, "eE"); index >= 0 {
+		mantissa, exponentText = text[:index], text[index+1:]
+	}
+	exponent := new(big.Int)
+	if exponentText != "" {
+		if _, ok := exponent.SetString(strings.TrimPrefix(exponentText, "+"), 10); !ok {
+			return nil, fmt.Errorf("%w: invalid exponent in %q", ErrorMalformed, text)
+		}
+	}
+	digits := mantissa
+	if index := strings.IndexByte(mantissa, '.'); index >= 0 {
+		digits = mantissa[:index] + mantissa[index+1:]
+		exponent.Sub(exponent, big.NewInt(int64(len(mantissa)-index-1)))
+	}
+	var value unpackedDecimal
+	if _, ok := value.coefficient.SetString(strings.TrimPrefix(digits, "+"), 10); !ok {
+		return nil, fmt.Errorf("%w: invalid digits in %q", ErrorMalformed, text)
+	}
+	if !exponent.IsInt64() || exponent.Int64() <= math.MinInt32 || exponent.Int64() > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: %v", ErrorExponentTooLarge, exponent)
+	}
+	value.exponent = int32(exponent.Int64())
+	if value.coefficient.Sign() == 0 {
+		value.exponent = 0
+		if strings.HasPrefix(text, "-") {
+			value.exponent = ExpSpecial
+		}
+	}
+	return &value, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func assertJSONLinesRoundTrip(t *testing.T, input string, expected string) {
+	encoded := &bytes.Buffer{}
+	count, err := TranscodeFromJSONLines(strings.NewReader(input), encoded)
+	if err != nil {
+		t.Errorf("%q: %v", input, err)
+		return
+	}
+	output := &strings.Builder{}
+	decodedCount, err := TranscodeToJSONLines(encoded, output)
+	if err != nil {
+		t.Errorf("%q: %v", input, err)
+		return
+	}
+	if output.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, output.String())
+	}
+	if count != decodedCount || count != strings.Count(expected, "\n") {
+		t.Errorf("%q: Expected %v values but got %v and %v", input, strings.Count(expected, "\n"), count, decodedCount)
+	}
+}
+
+func TestJSONLinesRoundTrip(t *testing.T) {
+	assertJSONLinesRoundTrip(t, "", "")
+	assertJSONLinesRoundTrip(t, "1.5\n-0\n0\n1e100\n  -2.5E-3  \n\n0.0000001\n", "1.5\n-0\n0\n1e+100\n-0.0025\n1e-7\n")
+	assertJSONLinesRoundTrip(t, "\"NaN\"\n\"-Infinity\"\n\"Infinity\"\n\"sNaN\"\n\"-NaN\"\n\"-sNaN\"\n\"12.5\"\n",
+		"\"NaN\"\n\"-Infinity\"\n\"Infinity\"\n\"sNaN\"\n\"-NaN\"\n\"-sNaN\"\n12.5\n")
+	assertJSONLinesRoundTrip(t, "123456789012345678901234567890.123456789\n-98765432109876543210987654321e-1000\n",
+		"123456789012345678901234567890.123456789\n-9.8765432109876543210987654321e-972\n")
+	assertJSONLinesRoundTrip(t, "-0.000000000000000000000000000\n", "-0\n")
+}
+
+func TestJSONLinesFromErrors(t *testing.T) {
+	for _, input := range []string{"1\nx\n", "1\n2\ntrue\n", "[1]\n", "\"x\"\n", "\"unterminated\n", "Infinity\n", "1e99999999999999999999\n", "123456789012345678901234567890e99999999999\n"} {
+		if _, err := TranscodeFromJSONLines(strings.NewReader(input), &bytes.Buffer{}); err == nil || !strings.HasPrefix(err.Error(), "line ") {
+			t.Errorf("%q: Expected a line error but got %v", input, err)
+		}
+	}
+}
+
+func TestJSONLinesToErrors(t *testing.T) {
+	if _, err := TranscodeToJSONLines(bytes.NewReader([]byte{0x02, 0x80}), &strings.Builder{}); !errors.Is(err, ErrorIncomplete) {
+		t.Errorf("Expected ErrorIncomplete but got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build compactfloat_nobig
+// +build compactfloat_nobig
+
+package compact_float
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// BigDecimal stands in for apd.Decimal when building with the
+// compactfloat_nobig tag, so that the package needs only the standard library.
+// No value of this type is ever produced: decoding a value that is too big to
+// fit into a DFloat fails with ErrorValueTooLarge instead.
+type BigDecimal struct{}
+
+func newBigValue(exponent int32, isNegative bool, asUint uint64, asBig *big.Int) (*BigDecimal, error) {
+	return nil, ErrorValueTooLarge
+}
+
+func parseBigValue(str string, parseErr error) (DFloat, *BigDecimal, error) {
+	return dfloatZero, nil, fmt.Errorf("%w: %q", ErrorValueTooLarge, str)
+}
+
+func bigValueMatches(bigValue *BigDecimal, value DFloat) bool {
+	return false
+}
+
+func bigDecimalAdapter(value interface{}) Decimal {
+	return nil
+}
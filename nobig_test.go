@@ -0,0 +1,68 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build compactfloat_nobig
+// +build compactfloat_nobig
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNoBigDecodeTooLarge(t *testing.T) {
+	for _, encoded := range [][]byte{
+		{0x00, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01},
+		{0x07, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01},
+	} {
+		if _, bigValue, _, err := Decode(bytes.NewBuffer(encoded)); !errors.Is(err, ErrorValueTooLarge) || bigValue != nil {
+			t.Errorf("Encoded %v: Expected Decode to return ErrorValueTooLarge but got %v, %v", encoded, bigValue, err)
+		}
+		if _, bigValue, _, err := DecodeFromBytes(encoded); !errors.Is(err, ErrorValueTooLarge) || bigValue != nil {
+			t.Errorf("Encoded %v: Expected DecodeFromBytes to return ErrorValueTooLarge but got %v, %v", encoded, bigValue, err)
+		}
+	}
+}
+
+func TestNoBigDecode(t *testing.T) {
+	encoded := []byte{0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04}
+	value, bigValue, bytesDecoded, err := Decode(bytes.NewBuffer(encoded))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if expected := DFloatValue(4994, 9445283); value != expected || bigValue != nil || bytesDecoded != len(encoded) {
+		t.Errorf("Expected %v (%v bytes) but got %v, %v (%v bytes)", expected, len(encoded), value, bigValue, bytesDecoded)
+	}
+}
+
+func TestNoBigParseAny(t *testing.T) {
+	if value, bigValue, err := ParseAny("1.5"); value != DFloatValue(-1, 15) || bigValue != nil || err != nil {
+		t.Errorf("Expected 1.5 but got %v, %v (%v)", value, bigValue, err)
+	}
+	if _, bigValue, err := ParseAny("9223372036854775808"); !errors.Is(err, ErrorValueTooLarge) || bigValue != nil {
+		t.Errorf("Expected ErrorValueTooLarge but got %v (%v)", bigValue, err)
+	}
+	if _, bigValue, err := ParseAny("1e99999999999999"); !errors.Is(err, ErrorExponentTooLarge) || bigValue != nil {
+		t.Errorf("Expected ErrorExponentTooLarge but got %v (%v)", bigValue, err)
+	}
+}
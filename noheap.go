@@ -0,0 +1,195 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"strconv"
+)
+
+// The functions in this file are guaranteed not to allocate on the heap, so
+// that they can be used from allocation-restricted code (such as a firmware
+// loop). Results are returned in fixed-size arrays or written to caller
+// supplied buffers, and errors are always one of the unwrapped ErrorXYZ
+// values. Values that are too big to fit into a DFloat are rejected with
+// ErrorValueTooLarge rather than being decoded as big values.
+
+// Maximum number of bytes that a DFloat can encode to.
+// (64 bits / 7) + (33 bits / 7)
+const MaxEncodedLength = 10 + 5
+
+// Encodes a DFloat into a fixed-size array, returning the array and the number
+// of bytes of it that were used.
+func EncodeToArray(value DFloat) (encoded [MaxEncodedLength]byte, length int) {
+	length = EncodeToBytes(value, encoded[:])
+	return
+}
+
+// Decode a float from a byte slice without allocating. If the value is too big
+// to fit into a DFloat, the returned error will be ErrorValueTooLarge.
+// Returns ErrorIncomplete if data ends before the value is complete.
+func DecodeFromBytesNoAlloc(data []byte) (value DFloat, bytesDecoded int, err error) {
+	var ok bool
+	if value, bytesDecoded, ok = decodeSmallFromBytes(data); ok {
+		return
+	}
+
+	exponentField, bytesDecoded, err := decodeULEB128Uint64FromBytes(data, ErrorExponentTooLarge)
+	if err != nil {
+		return
+	}
+	var isSpecial bool
+	if value, isSpecial = decodeSpecialValue(exponentField, bytesDecoded); isSpecial {
+		return
+	}
+	if exponentField > maxEncodedExponentField {
+		err = ErrorExponentTooLarge
+		return
+	}
+
+	offset := bytesDecoded
+	coefficient, bytesDecoded, err := decodeULEB128Uint64FromBytes(data[offset:], ErrorValueTooLarge)
+	if err != nil {
+		return
+	}
+	if MaxCoefficientLength > 0 && bytesDecoded > MaxCoefficientLength {
+		err = ErrorCoefficientTooLong
+		return
+	}
+	if coefficient&0x8000000000000000 != 0 {
+		err = ErrorValueTooLarge
+		return
+	}
+	bytesDecoded += offset
+
+	value.Exponent = int32(exponentField >> 2)
+	if exponentField&2 != 0 {
+		value.Exponent = -value.Exponent
+	}
+	value.Coefficient = int64(coefficient)
+	if exponentField&1 != 0 {
+		value.Coefficient = -value.Coefficient
+	}
+	return
+}
+
+// Writes the Text() representation of this value in the given format to
+// buffer without allocating, returning the number of bytes written. If the
+// buffer is too small, nothing is written and ok will be false (see
+// TextLength()).
+func (this DFloat) TextToBytes(format byte, buffer []byte) (length int, ok bool) {
+	if length = this.TextLength(format); length > len(buffer) {
+		return 0, false
+	}
+	this.AppendText(buffer[:0], format)
+	return length, true
+}
+
+// Returns the number of bytes that the Text() representation of this value in
+// the given format will occupy.
+func (this DFloat) TextLength(format byte) int {
+	if this.IsSpecial() {
+		switch this {
+		case dfloatNegativeZero:
+			return len("-0")
+		case dfloatInfinity:
+			return len("Infinity")
+		case dfloatNegativeInfinity:
+			return len("-Infinity")
+		case dfloatNaN:
+			return len("NaN")
+		case dfloatSignalingNaN:
+			return len("sNaN")
+		case dfloatNegativeNaN:
+			return len("-NaN")
+		case dfloatNegativeSignalingNaN:
+			return len("-sNaN")
+		}
+		return DFloat{}.TextLength(format)
+	}
+
+	var digitsBuffer [20]byte
+	magnitude := uint64(this.Coefficient)
+	sign := 0
+	if this.Coefficient < 0 {
+		magnitude = -magnitude
+		sign = 1
+	}
+	digitCount := int64(len(strconv.AppendUint(digitsBuffer[:0], magnitude, 10)))
+	exponent := int64(this.Exponent)
+
+	switch format {
+	case 'e', 'E':
+		return sign + formatELength(exponent, digitCount)
+	case 'f':
+		return sign + formatFLength(exponent, digitCount)
+	case 'g', 'G':
+		const adjustedExponentLimit = -6
+		adjustedExponent := exponent + digitCount - 1
+		if exponent <= 0 && adjustedExponent >= adjustedExponentLimit {
+			return sign + formatFLength(exponent, digitCount)
+		}
+		return sign + formatELength(exponent, digitCount)
+	}
+	return len("%f")
+}
+
+// Length of d.ddddde±d
+func formatELength(exponent int64, digitCount int64) int {
+	// Leading digit, 'e', sign, and at least one exponent digit.
+	length := 4
+	if digitCount > 1 {
+		length += int(digitCount)
+	}
+	adjustedExponent := exponent + digitCount - 1
+	if adjustedExponent < 0 {
+		adjustedExponent = -adjustedExponent
+	}
+	for ; adjustedExponent >= 10; adjustedExponent /= 10 {
+		length++
+	}
+	return length
+}
+
+// Length of ddddddd.ddddd
+func formatFLength(exponent int64, digitCount int64) int {
+	if exponent >= 0 {
+		return int(digitCount + exponent)
+	}
+	if left := -exponent - digitCount; left >= 0 {
+		return int(left+digitCount) + len("0.")
+	}
+	return int(digitCount) + len(".")
+}
+
+// Decode a ULEB128 value that must fit into a uint64, without allocating.
+// Values that are wider than 64 bits fail with tooLargeErr.
+func decodeULEB128Uint64FromBytes(data []byte, tooLargeErr error) (value uint64, bytesDecoded int, err error) {
+	if bytesDecoded, err = uleb128FromBytesLength(data); err != nil {
+		return
+	}
+	for i := bytesDecoded - 1; i >= 0; i-- {
+		if value>>(64-7) != 0 {
+			return 0, bytesDecoded, tooLargeErr
+		}
+		value = value<<7 | uint64(data[i]&0x7f)
+	}
+	return
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func noAllocTestValues() []DFloat {
+	values := []DFloat{Zero(), NegativeZero(), Infinity(), NegativeInfinity(), QuietNaN(),
+		SignalingNaN(), NegativeQuietNaN(), NegativeSignalingNaN(), DFloatValue(0, -9223372036854775808),
+		DFloatValue(0, 9223372036854775807), DFloatValue(-7, 1), DFloatValue(-6, 1), DFloatValue(3, -15), DFloatValue(-3, 123456)}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		values = append(values, DFloatValue(int32(random.Intn(60)-40), random.Int63()>>uint(random.Intn(63))*int64(random.Intn(3)-1)))
+	}
+	return values
+}
+
+func TestEncodeToArray(t *testing.T) {
+	for _, value := range append(noAllocTestValues(), DFloatValue(-2147483647, 1), DFloatValue(2147483647, -1)) {
+		encoded, length := EncodeToArray(value)
+		if expected := AppendEncode(nil, value); !bytes.Equal(encoded[:length], expected) {
+			t.Errorf("Value %v: Expected %v but got %v", value, expected, encoded[:length])
+		}
+	}
+}
+
+func TestDecodeFromBytesNoAlloc(t *testing.T) {
+	for _, value := range append(noAllocTestValues(), DFloatValue(-2147483647, 1), DFloatValue(2147483647, -1)) {
+		encoded := AppendEncode(nil, value)
+		if value.Coefficient == -9223372036854775808 {
+			// The magnitude doesn't fit into a DFloat coefficient
+			if _, _, err := DecodeFromBytesNoAlloc(encoded); err != ErrorValueTooLarge {
+				t.Errorf("Value %v: Expected ErrorValueTooLarge but got %v", value, err)
+			}
+			continue
+		}
+		actual, bytesDecoded, err := DecodeFromBytesNoAlloc(append(encoded, 0xff))
+		if err != nil {
+			t.Errorf("Value %v: %v", value, err)
+			continue
+		}
+		if expected := value.minimized(); actual != expected && !(value.IsZero() && actual.IsZero()) || bytesDecoded != len(encoded) {
+			t.Errorf("Value %v: Expected %v (%v bytes) but got %v (%v bytes)", value, expected, len(encoded), actual, bytesDecoded)
+		}
+	}
+
+	// Padded with empty groups, but still fits
+	if actual, _, err := DecodeFromBytesNoAlloc([]byte{0x86, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00, 0x0f}); err != nil || actual != DFloatValue(-1, 15) {
+		t.Errorf("Expected %v but got %v, %v", DFloatValue(-1, 15), actual, err)
+	}
+
+	assertDecodeNoAllocError := func(encoded []byte, expected error) {
+		if _, _, err := DecodeFromBytesNoAlloc(encoded); err != expected {
+			t.Errorf("Encoded %v: Expected %v but got %v", encoded, expected, err)
+		}
+	}
+	assertDecodeNoAllocError([]byte{}, ErrorIncomplete)
+	assertDecodeNoAllocError([]byte{0x88, 0x9c, 0x01, 0xa3, 0xbf}, ErrorIncomplete)
+	assertDecodeNoAllocError([]byte{0x00, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}, ErrorValueTooLarge)
+	assertDecodeNoAllocError([]byte{0x00, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x02}, ErrorValueTooLarge)
+	assertDecodeNoAllocError([]byte{0x80, 0x80, 0x80, 0x80, 0x20, 0x01}, ErrorExponentTooLarge)
+	assertDecodeNoAllocError([]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}, ErrorExponentTooLarge)
+}
+
+func TestTextToBytes(t *testing.T) {
+	var buffer [64]byte
+	for _, value := range noAllocTestValues() {
+		for _, format := range []byte{'e', 'E', 'f', 'g', 'G', 'x'} {
+			expected := value.Text(format)
+			if length := value.TextLength(format); length != len(expected) {
+				t.Errorf("Value %v format %c: Expected length %v but got %v", value, format, len(expected), length)
+			}
+			length, ok := value.TextToBytes(format, buffer[:])
+			if !ok || string(buffer[:length]) != expected {
+				t.Errorf("Value %v format %c: Expected %v but got %v", value, format, expected, string(buffer[:length]))
+			}
+		}
+	}
+
+	if length := DFloatValue(1000000, -1).TextLength('f'); length != 1000002 {
+		t.Errorf("Expected length 1000002 but got %v", length)
+	}
+	if length, ok := DFloatValue(100, 1).TextToBytes('f', buffer[:]); ok || length != 0 {
+		t.Errorf("Expected TextToBytes to fail but got %v, %v", length, ok)
+	}
+}
+
+func TestNoAllocations(t *testing.T) {
+	values := noAllocTestValues()
+	var encoded [][]byte
+	for _, value := range values {
+		encoded = append(encoded, AppendEncode(nil, value))
+	}
+	var buffer [64]byte
+
+	allocs := testing.AllocsPerRun(10, func() {
+		for i, value := range values {
+			EncodeToArray(value)
+			DecodeFromBytesNoAlloc(encoded[i])
+			value.TextToBytes('g', buffer[:])
+			value.TextToBytes('f', buffer[:])
+		}
+		DecodeFromBytesNoAlloc([]byte{0x00, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x02})
+		DecodeFromBytesNoAlloc([]byte{0x80, 0x80, 0x80, 0x80, 0x20, 0x01})
+		DecodeFromBytesNoAlloc([]byte{0x88, 0x9c})
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations but got %v", allocs)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+package compact_float
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/cockroachdb/apd/v2"
+)
+
+// Parquet stores DECIMAL values as an unscaled integer with a declared
+// precision (total number of digits) and scale (digits after the decimal
+// point), such that value = unscaled * 10^-scale. The unscaled integer is
+// stored as INT32 (precision <= 9), INT64 (precision <= 18), or as a
+// big-endian two's complement FIXED_LEN_BYTE_ARRAY.
+//
+// Conversions to parquet take a rounding mode (apd.RoundHalfEven,
+// apd.RoundDown, etc) that is used when the value has more fractional digits
+// than the scale allows. If rounding occurs, the returned error will be
+// RoundingError, and the rounded value is returned.
+
+const (
+	parquetMaxInt32Precision = 9
+	parquetMaxInt64Precision = 18
+)
+
+// Convert a parquet INT32 DECIMAL to DFloat.
+func DFloatFromParquetInt32(unscaled int32, scale int32) DFloat {
+	return DFloatValue(-scale, int64(unscaled))
+}
+
+// Convert a parquet INT64 DECIMAL to DFloat.
+func DFloatFromParquetInt64(unscaled int64, scale int32) DFloat {
+	return DFloatValue(-scale, unscaled)
+}
+
+// Convert a parquet FIXED_LEN_BYTE_ARRAY DECIMAL to DFloat. If the value is
+// too big to fit, its lower significant digits will be rounded
+// (half-to-even) and RoundingError will be returned along with the rounded
+// value.
+func DFloatFromParquetFixedLenByteArray(data []byte, scale int32) (DFloat, error) {
+	unscaled := new(big.Int).SetBytes(data)
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(data)*8)))
+	}
+	return DFloatFromAPD(apd.NewWithBigInt(unscaled, -scale))
+}
+
+// Convert this value to a parquet INT32 DECIMAL with the given precision and
+// scale, rounding as necessary using the specified rounding mode.
+func (this DFloat) ParquetInt32(precision int, scale int32, rounding string) (unscaled int32, err error) {
+	if precision < 1 || precision > parquetMaxInt32Precision {
+		err = fmt.Errorf("%v: Invalid precision for parquet INT32 DECIMAL", precision)
+		return
+	}
+	asBig, err := this.parquetUnscaled(precision, scale, rounding)
+	if asBig != nil {
+		unscaled = int32(asBig.Int64())
+	}
+	return
+}
+
+// Convert this value to a parquet INT64 DECIMAL with the given precision and
+// scale, rounding as necessary using the specified rounding mode.
+func (this DFloat) ParquetInt64(precision int, scale int32, rounding string) (unscaled int64, err error) {
+	if precision < 1 || precision > parquetMaxInt64Precision {
+		err = fmt.Errorf("%v: Invalid precision for parquet INT64 DECIMAL", precision)
+		return
+	}
+	asBig, err := this.parquetUnscaled(precision, scale, rounding)
+	if asBig != nil {
+		unscaled = asBig.Int64()
+	}
+	return
+}
+
+// Convert this value to a parquet FIXED_LEN_BYTE_ARRAY DECIMAL of the given
+// length, precision and scale, rounding as necessary using the specified
+// rounding mode.
+func (this DFloat) ParquetFixedLenByteArray(length int, precision int, scale int32, rounding string) (data []byte, err error) {
+	if length < 1 || precision < 1 || precision > parquetMaxFixedLenPrecision(length) {
+		err = fmt.Errorf("%v: Invalid precision for parquet FIXED_LEN_BYTE_ARRAY(%v) DECIMAL", precision, length)
+		return
+	}
+	asBig, err := this.parquetUnscaled(precision, scale, rounding)
+	if asBig == nil {
+		return
+	}
+
+	if asBig.Sign() < 0 {
+		asBig.Add(asBig, new(big.Int).Lsh(big.NewInt(1), uint(length*8)))
+	}
+	data = make([]byte, length)
+	asBytes := asBig.Bytes()
+	copy(data[length-len(asBytes):], asBytes)
+	return
+}
+
+// Returns the maximum number of digits that fit into a parquet
+// FIXED_LEN_BYTE_ARRAY of the given length.
+func parquetMaxFixedLenPrecision(length int) int {
+	maxValue := new(big.Int).Lsh(big.NewInt(1), uint(length*8-1))
+	maxValue.Sub(maxValue, big.NewInt(1))
+	return len(maxValue.String()) - 1
+}
+
+func (this DFloat) parquetUnscaled(precision int, scale int32, rounding string) (*big.Int, error) {
+	if this.IsSpecial() && !this.IsZero() {
+		return nil, fmt.Errorf("%v cannot be represented as a parquet DECIMAL", this)
+	}
+
+	context := apd.BaseContext.WithPrecision(uint32(precision))
+	context.Rounding = rounding
+	result := new(apd.Decimal)
+	condition, err := context.Quantize(result, this.APD(), -scale)
+	if err != nil {
+		return nil, fmt.Errorf("%v cannot fit into parquet DECIMAL(%v, %v): %v", this, precision, scale, err)
+	}
+
+	unscaled := new(big.Int).Set(&result.Coeff)
+	if result.Negative {
+		unscaled.Neg(unscaled)
+	}
+	if condition.Inexact() {
+		original := this.APD()
+		roundedAway := new(apd.Decimal).Abs(result).Cmp(new(apd.Decimal).Abs(original)) > 0
+		return unscaled, newRoundingError(original.Text('g'), int(-scale-original.Exponent), roundedAway)
+	}
+	return unscaled, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/cockroachdb/apd/v2"
+)
+
+func assertParquetInt64(t *testing.T, strValue string, precision int, scale int32, rounding string, expected int64, expectedErr error) {
+	value := assertDFloatFromString(t, strValue, nil)
+	actual, err := value.ParquetInt64(precision, scale, rounding)
+	if !errors.Is(err, expectedErr) {
+		t.Errorf("Value %v: Expected error %v but got %v", value, expectedErr, err)
+		return
+	}
+	if actual != expected {
+		t.Errorf("Value %v: Expected unscaled %v but got %v", value, expected, actual)
+	}
+}
+
+func assertParquetInt64Fails(t *testing.T, strValue string, precision int, scale int32) {
+	value := assertDFloatFromString(t, strValue, nil)
+	if _, err := value.ParquetInt64(precision, scale, apd.RoundHalfEven); err == nil || errors.Is(err, RoundingError()) {
+		t.Errorf("Value %v: Expected parquet DECIMAL(%v, %v) conversion to fail", value, precision, scale)
+	}
+}
+
+func assertParquetFixedLen(t *testing.T, strValue string, length int, precision int, scale int32, expected []byte) {
+	value := assertDFloatFromString(t, strValue, nil)
+	actual, err := value.ParquetFixedLenByteArray(length, precision, scale, apd.RoundHalfEven)
+	if err != nil {
+		t.Errorf("Value %v: %v", value, err)
+		return
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("Value %v: Expected %v but got %v", value, expected, actual)
+		return
+	}
+	decoded, err := DFloatFromParquetFixedLenByteArray(actual, scale)
+	if err != nil {
+		t.Errorf("Value %v: %v", value, err)
+		return
+	}
+	if decoded != value {
+		t.Errorf("Expected %v but got %v", value, decoded)
+	}
+}
+
+func TestParquetInt(t *testing.T) {
+	assertParquetInt64(t, "1.5", 10, 2, apd.RoundHalfEven, 150, nil)
+	assertParquetInt64(t, "-1.5", 10, 0, apd.RoundHalfEven, -2, RoundingError())
+	assertParquetInt64(t, "2.5", 10, 0, apd.RoundHalfEven, 2, RoundingError())
+	assertParquetInt64(t, "2.5", 10, 0, apd.RoundHalfUp, 3, RoundingError())
+	assertParquetInt64(t, "2.59", 10, 1, apd.RoundDown, 25, RoundingError())
+	assertParquetInt64(t, "-0", 10, 2, apd.RoundHalfEven, 0, nil)
+	assertParquetInt64(t, "1e5", 10, -2, apd.RoundHalfEven, 1000, nil)
+
+	assertParquetInt64Fails(t, "123.45", 4, 2)
+	assertParquetInt64Fails(t, "inf", 10, 2)
+	assertParquetInt64Fails(t, "nan", 10, 2)
+
+	value, err := DFloatValue(-3, 1234).ParquetInt32(9, 3, apd.RoundHalfEven)
+	if err != nil || value != 1234 {
+		t.Errorf("Expected 1234 but got %v (%v)", value, err)
+	}
+	if _, err := DFloatValue(0, 1).ParquetInt32(10, 0, apd.RoundHalfEven); err == nil {
+		t.Errorf("Expected precision 10 to be rejected for INT32")
+	}
+
+	if actual := DFloatFromParquetInt32(-1234, 3); actual != DFloatValue(-3, -1234) {
+		t.Errorf("Expected -1.234 but got %v", actual)
+	}
+	if actual := DFloatFromParquetInt64(1500, 2); actual != DFloatValue(0, 15) {
+		t.Errorf("Expected 15 but got %v", actual)
+	}
+}
+
+func TestParquetFixedLen(t *testing.T) {
+	assertParquetFixedLen(t, "1.5", 4, 9, 2, []byte{0x00, 0x00, 0x00, 0x96})
+	assertParquetFixedLen(t, "-1.5", 4, 9, 2, []byte{0xff, 0xff, 0xff, 0x6a})
+	assertParquetFixedLen(t, "-1", 1, 2, 0, []byte{0xff})
+	assertParquetFixedLen(t, "9223372036854775807", 16, 38, 0,
+		[]byte{0, 0, 0, 0, 0, 0, 0, 0, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	if _, err := DFloatValue(0, 1).ParquetFixedLenByteArray(1, 3, 0, apd.RoundHalfEven); err == nil {
+		t.Errorf("Expected precision 3 to be rejected for FIXED_LEN_BYTE_ARRAY(1)")
+	}
+}
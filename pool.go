@@ -0,0 +1,81 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"io"
+	"sync"
+)
+
+// Buffers bigger than this are dropped rather than returned to the pool, so
+// that one huge value doesn't pin a large allocation for the pool's lifetime.
+const maxPooledBufferSize = 4096
+
+// BufferPool supplies the scratch buffers used when encoding and decoding from
+// a sync.Pool, so that highly concurrent callers don't pay an allocation per
+// value. It is opt-in: the package-level functions allocate as before. A
+// BufferPool is safe for concurrent use, and its zero value is ready to use.
+type BufferPool struct {
+	pool sync.Pool
+}
+
+func NewBufferPool() *BufferPool {
+	return &BufferPool{}
+}
+
+// Get a buffer of at least size bytes. Return it with put() when done.
+func (this *BufferPool) get(size int) *[]byte {
+	if pooled, ok := this.pool.Get().(*[]byte); ok {
+		if cap(*pooled) >= size {
+			*pooled = (*pooled)[:size]
+			return pooled
+		}
+		this.pool.Put(pooled)
+	}
+	if size < MaxEncodeLength() {
+		size = MaxEncodeLength()
+	}
+	buffer := make([]byte, size)
+	return &buffer
+}
+
+func (this *BufferPool) put(buffer *[]byte) {
+	if cap(*buffer) <= maxPooledBufferSize {
+		this.pool.Put(buffer)
+	}
+}
+
+// Encodes a DFloat to a writer using a pooled buffer.
+func (this *BufferPool) Encode(value DFloat, writer io.Writer) (bytesEncoded int, err error) {
+	buffer := this.get(MaxEncodeLength())
+	bytesEncoded, err = EncodeWithBuffer(value, writer, *buffer)
+	this.put(buffer)
+	return
+}
+
+// Decode a float using a pooled buffer.
+// bigValue will be nil unless the decoded value is too big to fit into a DFloat.
+func (this *BufferPool) Decode(reader io.Reader) (value DFloat, bigValue *BigDecimal, bytesDecoded int, err error) {
+	buffer := this.get(1)
+	value, bigValue, bytesDecoded, err = DecodeWithByteBuffer(reader, *buffer)
+	this.put(buffer)
+	return
+}
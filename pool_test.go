@@ -0,0 +1,84 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+package compact_float
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/cockroachdb/apd/v2"
+)
+
+func TestBufferPool(t *testing.T) {
+	pool := NewBufferPool()
+	bigValue, _, _ := apd.NewFromString("1.234567890123456789012345678901234567890e-1000")
+	values := []DFloat{DFloatValue(-5, 123456), Zero(), NegativeInfinity(), QuietNaN()}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for _, value := range values {
+					buffer := &bytes.Buffer{}
+					if _, err := pool.Encode(value, buffer); err != nil {
+						t.Error(err)
+						return
+					}
+					decoded, _, _, err := pool.Decode(buffer)
+					if err != nil || decoded != value {
+						t.Errorf("Expected %v but got %v (%v)", value, decoded, err)
+						return
+					}
+				}
+
+				buffer := &bytes.Buffer{}
+				if _, err := pool.EncodeBig(bigValue, buffer); err != nil {
+					t.Error(err)
+					return
+				}
+				_, decoded, _, err := pool.Decode(buffer)
+				if err != nil || decoded == nil || decoded.Cmp(bigValue) != 0 {
+					t.Errorf("Expected %v but got %v (%v)", bigValue, decoded, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestBufferPoolAllocations(t *testing.T) {
+	pool := NewBufferPool()
+	value := DFloatValue(-5, 123456)
+	allocs := testing.AllocsPerRun(100, func() {
+		pool.Encode(value, ioutil.Discard)
+	})
+	if allocs >= 1 {
+		t.Errorf("Expected no allocations per encode but got %v", allocs)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"fmt"
+	"math"
+)
+
+// Returns 10^n as an exact DFloat, for building scales, tick sizes and unit
+// conversions without parsing strings.
+// Panics if n is ExpSpecial, which is not a valid exponent.
+func Pow10(n int32) DFloat {
+	if n == ExpSpecial {
+		panic(fmt.Errorf("%v: exponent is out of range", n))
+	}
+	return DFloat{Exponent: n, Coefficient: 1}
+}
+
+// Returns 10^n as a uint64. ok will be false if n is outside of the range that
+// a uint64 can hold (0 to 19).
+func Pow10Uint64(n int) (value uint64, ok bool) {
+	if n < 0 || n >= len(exponentMultipliers) {
+		return 0, false
+	}
+	return exponentMultipliers[n], true
+}
+
+// Returns this value multiplied by 10^n, which is exact and only changes the
+// exponent. The coefficient (including any trailing zeros) is kept as is,
+// unless the exponent would be out of range: then only as many trailing zeros
+// as needed are stripped from it (or added to it) to bring the exponent back
+// into range. Fails with ErrorExponentTooLarge if the result can't be
+// represented. Zero, infinity and NaN are returned unchanged.
+func (this DFloat) ScaleByPow10(n int32) (DFloat, error) {
+	if this.IsZero() || this.IsSpecial() {
+		return this, nil
+	}
+
+	const maxExponent = int64(math.MaxInt32)
+	exponent := int64(this.Exponent) + int64(n)
+	coefficient := this.Coefficient
+	for exponent < -maxExponent && coefficient%10 == 0 {
+		coefficient /= 10
+		exponent++
+	}
+	for exponent > maxExponent && coefficient <= math.MaxInt64/10 && coefficient >= math.MinInt64/10 {
+		coefficient *= 10
+		exponent--
+	}
+	if exponent < -maxExponent || exponent > maxExponent {
+		return this, fmt.Errorf("%w: %v scaled by 10^%v", ErrorExponentTooLarge, this, n)
+	}
+	return DFloat{Exponent: int32(exponent), Coefficient: coefficient}, nil
+}
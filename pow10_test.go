@@ -0,0 +1,91 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPow10(t *testing.T) {
+	assertPow10 := func(n int32, str string) {
+		t.Helper()
+		if expected, err := DFloatFromString(str); Pow10(n) != expected || err != nil {
+			t.Errorf("10^%v: expected %v but got %v (%v)", n, expected, Pow10(n), err)
+		}
+	}
+	assertPow10(0, "1")
+	assertPow10(1, "10")
+	assertPow10(-1, "0.1")
+	assertPow10(18, "1000000000000000000")
+	assertPow10(-30, "1e-30")
+	assertPow10(math.MaxInt32, "1e2147483647")
+	assertPow10(-math.MaxInt32, "1e-2147483647")
+	if Pow10(3).String() != "1e+3" || Pow10(-3).String() != "0.001" {
+		t.Errorf("Unexpected text %v, %v", Pow10(3), Pow10(-3))
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected an exponent of ExpSpecial to panic")
+		}
+	}()
+	Pow10(ExpSpecial)
+}
+
+func TestPow10Uint64(t *testing.T) {
+	expected := uint64(1)
+	for n := 0; n <= 19; n++ {
+		if value, ok := Pow10Uint64(n); value != expected || !ok {
+			t.Errorf("10^%v: expected %v but got %v, %v", n, expected, value, ok)
+		}
+		expected *= 10
+	}
+	for _, n := range []int{-1, 20, 100} {
+		if value, ok := Pow10Uint64(n); ok {
+			t.Errorf("10^%v: expected failure but got %v", n, value)
+		}
+	}
+}
+
+func TestScaleByPow10(t *testing.T) {
+	assertScale := func(value DFloat, n int32, expected DFloat, expectedErr error) {
+		t.Helper()
+		actual, err := value.ScaleByPow10(n)
+		if actual != expected || !errors.Is(err, expectedErr) || (err != nil && expectedErr == nil) {
+			t.Errorf("%v scaled by 10^%v: expected %v (%v) but got %v (%v)", value, n, expected, expectedErr, actual, err)
+		}
+	}
+	assertScale(DFloatValue(-2, 125), 3, DFloatValue(1, 125), nil)
+	assertScale(DFloatValue(-2, -125), -4, DFloatValue(-6, -125), nil)
+	assertScale(DFloatValuePreserving(-2, 100), 0, DFloatValuePreserving(-2, 100), nil)
+	assertScale(DFloatValuePreserving(-2, 1000), 5, DFloat{Exponent: 3, Coefficient: 1000}, nil)
+	assertScale(DFloatValuePreserving(-math.MaxInt32+2, 1000), -3, DFloat{Exponent: -math.MaxInt32, Coefficient: 100}, nil)
+	assertScale(DFloatValue(math.MaxInt32, 5), 2, DFloat{Exponent: math.MaxInt32, Coefficient: 500}, nil)
+	assertScale(DFloatValuePreserving(-math.MaxInt32+1, -5000), -3, DFloat{Exponent: -math.MaxInt32, Coefficient: -50}, nil)
+	assertScale(DFloatValue(math.MaxInt32, 5), 20, DFloatValue(math.MaxInt32, 5), ErrorExponentTooLarge)
+	assertScale(DFloatValue(-math.MaxInt32, 5), -1, DFloatValue(-math.MaxInt32, 5), ErrorExponentTooLarge)
+	assertScale(DFloatValue(1, math.MinInt64), math.MaxInt32, DFloatValue(1, math.MinInt64), ErrorExponentTooLarge)
+	assertScale(NegativeZero(), 5, NegativeZero(), nil)
+	assertScale(Infinity(), -5, Infinity(), nil)
+	assertScale(QuietNaN(), 5, QuietNaN(), nil)
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+)
+
+var generatedSpecials = []DFloat{dfloatZero, dfloatNegativeZero, dfloatInfinity, dfloatNegativeInfinity,
+	dfloatNaN, dfloatSignalingNaN, dfloatNegativeNaN, dfloatNegativeSignalingNaN}
+
+var generatedExtremes = []DFloat{
+	{Exponent: math.MaxInt32, Coefficient: math.MaxInt64},
+	{Exponent: math.MaxInt32, Coefficient: -math.MaxInt64},
+	{Exponent: -math.MaxInt32, Coefficient: math.MaxInt64},
+	{Exponent: -math.MaxInt32, Coefficient: -math.MaxInt64},
+	{Exponent: math.MaxInt32, Coefficient: 1},
+	{Exponent: math.MaxInt32, Coefficient: -1},
+	{Exponent: -math.MaxInt32, Coefficient: 1},
+	{Exponent: -math.MaxInt32, Coefficient: -1},
+	{Exponent: 0, Coefficient: math.MaxInt64},
+	{Exponent: 0, Coefficient: -math.MaxInt64},
+}
+
+// Generates a random DFloat (implements testing/quick.Generator), so that
+// property based tests can use testing/quick with DFloat arguments.
+//
+// Roughly 10% of values are special (zero, infinity, NaN), 10% are extremes of
+// the exponent and coefficient ranges, and the rest are finite values whose
+// coefficients are spread evenly across bit lengths. Exponents are usually
+// kept within ±size, but can occasionally be anywhere in the exponent range.
+// Generated values are always minimized, and always round trip through
+// encoding.
+func (this DFloat) Generate(rand *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(generateDFloat(rand, size))
+}
+
+func generateDFloat(rand *rand.Rand, size int) DFloat {
+	switch choice := rand.Intn(10); choice {
+	case 0:
+		return generatedSpecials[rand.Intn(len(generatedSpecials))]
+	case 1:
+		return generatedExtremes[rand.Intn(len(generatedExtremes))]
+	}
+
+	// Leave room for minimization to increment the exponent.
+	const maxExponent = math.MaxInt32 - 19
+	var exponent int64
+	if size < 1 || rand.Intn(10) == 0 {
+		exponent = rand.Int63n(2*maxExponent+1) - maxExponent
+	} else {
+		exponent = rand.Int63n(int64(2*size+1)) - int64(size)
+	}
+
+	coefficient := rand.Int63() >> uint(rand.Intn(63))
+	if coefficient == 0 {
+		coefficient = 1
+	}
+	if rand.Intn(2) == 0 {
+		coefficient = -coefficient
+	}
+	return DFloatValue(int32(exponent), coefficient)
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+func TestGenerateRoundTrip(t *testing.T) {
+	roundTrip := func(value DFloat) bool {
+		decoded, bigValue, _, err := DecodeFromBytes(AppendEncode(nil, value))
+		return err == nil && bigValue == nil && decoded == value
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 10000}); err != nil {
+		t.Error(err)
+	}
+
+	encodeTwice := func(value DFloat) bool {
+		return bytes.Equal(AppendEncode(nil, value), AppendEncode(nil, value))
+	}
+	if err := quick.Check(encodeTwice, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGenerateDistribution(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	specials, extremes, finite := 0, 0, 0
+	seen := map[DFloat]bool{}
+	for i := 0; i < 10000; i++ {
+		value := DFloat{}.Generate(random, 50).Interface().(DFloat)
+		seen[value] = true
+		switch {
+		case value.IsZero() || value.IsSpecial():
+			specials++
+		case value.Exponent > 1000 || value.Exponent < -1000 ||
+			value.Coefficient > 1<<62 || value.Coefficient < -1<<62:
+			extremes++
+		default:
+			finite++
+		}
+		if value.Exponent != ExpSpecial && value != value.minimized() {
+			t.Errorf("Expected generated value %v to be minimized", value)
+		}
+	}
+	for _, special := range generatedSpecials {
+		if !seen[special] {
+			t.Errorf("Expected %v to be generated", special)
+		}
+	}
+	if specials < 500 || extremes < 500 || finite < 5000 {
+		t.Errorf("Expected a mix of values but got %v specials, %v extremes, %v finite", specials, extremes, finite)
+	}
+}
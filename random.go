@@ -0,0 +1,119 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Which signs RandDFloat() may generate.
+type RandSign int
+
+const (
+	RandAnySign RandSign = iota
+	RandPositive
+	RandNegative
+)
+
+var randPositiveSpecials = []DFloat{dfloatZero, dfloatInfinity, dfloatNaN, dfloatSignalingNaN}
+var randNegativeSpecials = []DFloat{dfloatNegativeZero, dfloatNegativeInfinity, dfloatNegativeNaN, dfloatNegativeSignalingNaN}
+
+// Constraints for RandDFloat(). The zero value generates positive and negative
+// values with exponent 0 and 1 to 18 digits, and no special values.
+type RandOpts struct {
+	// Range of exponents to generate (inclusive).
+	MinExponent int32
+	MaxExponent int32
+
+	// Range of coefficient digit counts to generate (inclusive, 1 - 19).
+	// 0 selects the defaults of 1 and 18 respectively.
+	MinDigits int
+	MaxDigits int
+
+	// Which signs to generate (applies to special values as well).
+	Sign RandSign
+
+	// Probability (0 - 1) of generating a special value (zero, infinity, NaN)
+	// instead of a finite value.
+	SpecialProbability float64
+}
+
+// Generates a random DFloat within the constraints of opts, for building
+// benchmark corpora and statistical test data that need realistic decimal
+// values rather than uniformly random bits. The digit count is chosen uniformly
+// from the allowed range and the coefficient uniformly among values with that
+// many digits, so values such as 1.50 keep their trailing zeros. The exponent
+// is chosen uniformly from the allowed range.
+//
+// Panics if a minimum is greater than its maximum, or if the digit counts are
+// out of range.
+func RandDFloat(rng *rand.Rand, opts RandOpts) DFloat {
+	minDigits, maxDigits := opts.MinDigits, opts.MaxDigits
+	if minDigits == 0 {
+		minDigits = 1
+	}
+	if maxDigits == 0 {
+		maxDigits = 18
+	}
+	if minDigits < 1 || maxDigits > 19 || minDigits > maxDigits {
+		panic("RandDFloat: digit counts must be within 1 - 19, with MinDigits <= MaxDigits")
+	}
+	if opts.MinExponent > opts.MaxExponent {
+		panic("RandDFloat: MinExponent must be <= MaxExponent")
+	}
+
+	isNegative := false
+	switch opts.Sign {
+	case RandAnySign:
+		isNegative = rng.Intn(2) == 0
+	case RandNegative:
+		isNegative = true
+	}
+
+	if opts.SpecialProbability > 0 && rng.Float64() < opts.SpecialProbability {
+		if isNegative {
+			return randNegativeSpecials[rng.Intn(len(randNegativeSpecials))]
+		}
+		return randPositiveSpecials[rng.Intn(len(randPositiveSpecials))]
+	}
+
+	digits := minDigits + rng.Intn(maxDigits-minDigits+1)
+	low := int64(1)
+	for i := 1; i < digits; i++ {
+		low *= 10
+	}
+	high := int64(math.MaxInt64)
+	if digits < 19 {
+		high = low*10 - 1
+	}
+	coefficient := low + rng.Int63n(high-low+1)
+	if isNegative {
+		coefficient = -coefficient
+	}
+
+	exponentRange := int64(opts.MaxExponent) - int64(opts.MinExponent) + 1
+	exponent := int64(opts.MinExponent) + rng.Int63n(exponentRange)
+	if exponent == int64(ExpSpecial) {
+		exponent++
+	}
+	return DFloat{Exponent: int32(exponent), Coefficient: coefficient}
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func assertRandDFloatConstraints(t *testing.T, opts RandOpts, minDigits int, maxDigits int) {
+	rng := rand.New(rand.NewSource(1))
+	const count = 10000
+	specialCount := 0
+	for i := 0; i < count; i++ {
+		value := RandDFloat(rng, opts)
+		if opts.Sign == RandPositive && (value.Coefficient < 0 || value.IsNegativeZero() || value.IsNegativeInfinity() || value.IsNegativeNan()) {
+			t.Errorf("%+v: Expected a positive value but got %v", opts, value)
+			return
+		}
+		if opts.Sign == RandNegative && !(value.Coefficient < 0 || value.IsNegativeZero() || value.IsNegativeInfinity() || value.IsNegativeNan()) {
+			t.Errorf("%+v: Expected a negative value but got %v", opts, value)
+			return
+		}
+		if value.IsSpecial() || (value.Exponent == 0 && value.Coefficient == 0) {
+			specialCount++
+			continue
+		}
+		if value.Exponent < opts.MinExponent || value.Exponent > opts.MaxExponent {
+			t.Errorf("%+v: Exponent of %v out of range", opts, value)
+			return
+		}
+		magnitude := value.Coefficient
+		if magnitude < 0 {
+			magnitude = -magnitude
+		}
+		if digits := len(strconv.FormatInt(magnitude, 10)); digits < minDigits || digits > maxDigits {
+			t.Errorf("%+v: Digit count of %v out of range", opts, value)
+			return
+		}
+	}
+
+	expectedSpecials := opts.SpecialProbability * count
+	if math.Abs(float64(specialCount)-expectedSpecials) > count/50 {
+		t.Errorf("%+v: Expected about %v special values but got %v", opts, expectedSpecials, specialCount)
+	}
+}
+
+func TestRandDFloat(t *testing.T) {
+	assertRandDFloatConstraints(t, RandOpts{}, 1, 18)
+	assertRandDFloatConstraints(t, RandOpts{MinExponent: -2, MaxExponent: -2, MinDigits: 3, MaxDigits: 5, Sign: RandPositive}, 3, 5)
+	assertRandDFloatConstraints(t, RandOpts{MinExponent: -10, MaxExponent: 10, MaxDigits: 19, Sign: RandNegative, SpecialProbability: 0.3}, 1, 19)
+	assertRandDFloatConstraints(t, RandOpts{MinExponent: math.MinInt32, MaxExponent: math.MaxInt32, MinDigits: 19, MaxDigits: 19, SpecialProbability: 1}, 19, 19)
+}
+
+func TestRandDFloatRoundTrips(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	opts := RandOpts{MinExponent: math.MinInt32, MaxExponent: math.MaxInt32, MaxDigits: 19, SpecialProbability: 0.1}
+	for i := 0; i < 1000; i++ {
+		if err := VerifyRoundTrip(RandDFloat(rng, opts)); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+}
+
+func TestRandDFloatInvalidOpts(t *testing.T) {
+	for _, opts := range []RandOpts{
+		{MinExponent: 1, MaxExponent: 0},
+		{MinDigits: 5, MaxDigits: 4},
+		{MaxDigits: 20},
+		{MinDigits: -1},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected %+v to panic", opts)
+				}
+			}()
+			RandDFloat(rand.New(rand.NewSource(1)), opts)
+		}()
+	}
+}
@@ -1,3 +1,6 @@
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
 package compact_float
 
 import (
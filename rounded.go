@@ -0,0 +1,173 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// RoundingMode selects how digits are dropped when rounding. The modes have the
+// same names as apd's (apd.RoundHalfEven, apd.RoundDown, etc), so apd's
+// constants can be used as well.
+type RoundingMode string
+
+const (
+	// Round toward zero (truncate).
+	RoundDown RoundingMode = "down"
+	// Round to nearest, with ties away from zero.
+	RoundHalfUp RoundingMode = "half_up"
+	// Round to nearest, with ties to the even digit.
+	RoundHalfEven RoundingMode = "half_even"
+	// Round toward positive infinity.
+	RoundCeiling RoundingMode = "ceiling"
+	// Round toward negative infinity.
+	RoundFloor RoundingMode = "floor"
+	// Round to nearest, with ties toward zero.
+	RoundHalfDown RoundingMode = "half_down"
+	// Round away from zero.
+	RoundUp RoundingMode = "up"
+	// Round toward zero, unless the last kept digit would be 0 or 5, in which
+	// case round away from zero.
+	Round05Up RoundingMode = "05up"
+)
+
+// Returned (wrapped) when a rounding mode is not one of the RoundingMode
+// constants.
+var ErrorUnknownRoundingMode = errors.New("Unknown rounding mode")
+
+// Encodes a DFloat to a writer, first rounding it (half to even) to the
+// specified number of significant digits. This allows values to be
+// down-sampled for transmission. A significantDigits value of 0 or less encodes
+// the value unchanged. If rounding occurs, the rounded value is encoded and the
+// returned error will be RoundingError.
+func EncodeRounded(value DFloat, significantDigits int, writer io.Writer) (bytesEncoded int, err error) {
+	return EncodeRoundedWithMode(value, significantDigits, string(RoundHalfEven), writer)
+}
+
+// Encodes a DFloat to a writer, first rounding it to the specified number of
+// significant digits using the specified rounding mode (apd.RoundHalfEven,
+// apd.RoundDown, etc). A significantDigits value of 0 or less encodes the value
+// unchanged. If rounding occurs, the rounded value is encoded and the returned
+// error will be RoundingError.
+// Panics if the rounding mode is unknown.
+func EncodeRoundedWithMode(value DFloat, significantDigits int, rounding string, writer io.Writer) (bytesEncoded int, err error) {
+	rounded, roundingErr := roundToDigits(value, significantDigits, RoundingMode(rounding))
+	if roundingErr != nil && !errors.Is(roundingErr, roundingError) {
+		err = roundingErr
+		return
+	}
+	if bytesEncoded, err = Encode(rounded, writer); err == nil {
+		err = roundingErr
+	}
+	return
+}
+
+// Returns this value rounded to the specified number of significant digits
+// using the specified rounding mode, for reducing the precision of stored
+// values before transmission. A significantDigits value of 0 or less returns
+// the value unchanged. If rounding occurs, the rounded value is returned along
+// with RoundingError. Fails with ErrorExponentTooLarge if the rounded exponent
+// wouldn't fit, and with ErrorUnknownRoundingMode if the rounding mode is
+// unknown.
+func (this DFloat) Reduce(significantDigits int, rounding RoundingMode) (DFloat, error) {
+	if !isKnownRoundingMode(rounding) {
+		return this, fmt.Errorf("%w: %q", ErrorUnknownRoundingMode, rounding)
+	}
+	return roundToDigits(this, significantDigits, rounding)
+}
+
+// Rounds value to the specified number of significant digits, returning the
+// minimized result, along with RoundingError if any non-zero digits were
+// dropped.
+func roundToDigits(value DFloat, significantDigits int, rounding RoundingMode) (DFloat, error) {
+	if !isKnownRoundingMode(rounding) {
+		panic(fmt.Errorf("%q: unknown rounding mode", rounding))
+	}
+	if significantDigits < 1 || value.IsZero() || value.IsSpecial() {
+		return value, nil
+	}
+
+	isNegative := value.Coefficient < 0
+	magnitude := uint64(value.Coefficient)
+	if isNegative {
+		magnitude = -magnitude
+	}
+	dropped := decimalDigitCount(magnitude) - significantDigits
+	if dropped <= 0 {
+		return value, nil
+	}
+	if int64(value.Exponent)+int64(dropped) > math.MaxInt32 {
+		return value, fmt.Errorf("%w: %v rounded to %v digits", ErrorExponentTooLarge, value, significantDigits)
+	}
+
+	divisor := uint64(1)
+	for i := 0; i < dropped; i++ {
+		divisor *= 10
+	}
+	quotient, remainder := magnitude/divisor, magnitude%divisor
+	roundedAway := remainder != 0 && shouldRoundAway(quotient, remainder, divisor, isNegative, rounding)
+	if roundedAway {
+		quotient++
+	}
+	coefficient := int64(quotient)
+	if isNegative {
+		coefficient = -coefficient
+	}
+	rounded := DFloat{Exponent: value.Exponent + int32(dropped), Coefficient: coefficient}.minimized()
+	if remainder == 0 {
+		return rounded, nil
+	}
+	return rounded, newRoundingError(value.String(), dropped, roundedAway)
+}
+
+func isKnownRoundingMode(rounding RoundingMode) bool {
+	switch rounding {
+	case RoundDown, RoundHalfUp, RoundHalfEven, RoundCeiling, RoundFloor, RoundHalfDown, RoundUp, Round05Up:
+		return true
+	}
+	return false
+}
+
+// Decides whether a truncated magnitude (quotient) with a non-zero remainder
+// (out of divisor, a power of 10) should be incremented.
+func shouldRoundAway(quotient, remainder, divisor uint64, isNegative bool, rounding RoundingMode) bool {
+	half := divisor / 2
+	switch rounding {
+	case RoundHalfUp:
+		return remainder >= half
+	case RoundHalfEven:
+		return remainder > half || (remainder == half && quotient&1 == 1)
+	case RoundHalfDown:
+		return remainder > half
+	case RoundCeiling:
+		return !isNegative
+	case RoundFloor:
+		return isNegative
+	case RoundUp:
+		return true
+	case Round05Up:
+		return quotient%10 == 0 || quotient%10 == 5
+	}
+	return false
+}
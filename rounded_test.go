@@ -0,0 +1,129 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncodeRounded(t *testing.T) {
+	assertRounded := func(value DFloat, significantDigits int, expected DFloat, expectRounding bool) {
+		buffer := &bytes.Buffer{}
+		_, err := EncodeRounded(value, significantDigits, buffer)
+		if expectRounding != errors.Is(err, RoundingError()) {
+			t.Errorf("%v to %v digits: unexpected error %v", value, significantDigits, err)
+		}
+		if err != nil && !errors.Is(err, RoundingError()) {
+			return
+		}
+		if !bytes.Equal(buffer.Bytes(), AppendEncode(nil, expected)) {
+			t.Errorf("%v to %v digits: expected %x but got %x", value, significantDigits, AppendEncode(nil, expected), buffer.Bytes())
+		}
+	}
+	pi := DFloatValue(-8, 314159265)
+	assertRounded(pi, 0, pi, false)
+	assertRounded(pi, 9, pi, false)
+	assertRounded(pi, 20, pi, false)
+	assertRounded(pi, 8, DFloatValue(-7, 31415926), true)
+	assertRounded(pi, 5, DFloatValue(-4, 31416), true)
+	assertRounded(pi, 1, DFloatValue(0, 3), true)
+	assertRounded(DFloatValue(0, -995), 2, DFloatValue(3, -1), true)
+	assertRounded(DFloatValue(0, 1200), 2, DFloatValue(2, 12), false)
+	assertRounded(NegativeZero(), 1, NegativeZero(), false)
+	assertRounded(QuietNaN(), 1, QuietNaN(), false)
+}
+
+func TestEncodeRoundedWithMode(t *testing.T) {
+	assertMode := func(coefficient int64, rounding string, expected int64) {
+		buffer := &bytes.Buffer{}
+		_, err := EncodeRoundedWithMode(DFloatValue(-1, coefficient), 1, rounding, buffer)
+		if !errors.Is(err, RoundingError()) {
+			t.Errorf("%v %v: expected RoundingError but got %v", coefficient, rounding, err)
+		}
+		decoded, _, _, err := DecodeFromBytes(buffer.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.minimized() != DFloatValue(0, expected).minimized() {
+			t.Errorf("%v %v: expected %v but got %v", coefficient, rounding, expected, decoded)
+		}
+	}
+	assertMode(25, "half_even", 2)
+	assertMode(35, "half_even", 4)
+	assertMode(25, "half_up", 3)
+	assertMode(25, "half_down", 2)
+	assertMode(-21, "down", -2)
+	assertMode(-21, "up", -3)
+	assertMode(-21, "ceiling", -2)
+	assertMode(-21, "floor", -3)
+	assertMode(21, "ceiling", 3)
+	assertMode(21, "floor", 2)
+	assertMode(51, "05up", 6)
+	assertMode(41, "05up", 4)
+	assertMode(-95, "half_even", -10)
+
+	var details *RoundingErrorDetails
+	_, err := EncodeRoundedWithMode(DFloatValue(0, 1234), 2, "up", &bytes.Buffer{})
+	if !errors.As(err, &details) || details.DigitsDropped != 2 || details.Direction != RoundedAwayFromZero {
+		t.Errorf("Unexpected rounding details %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected an unknown rounding mode to panic")
+		}
+	}()
+	EncodeRoundedWithMode(DFloatValue(0, 1234), 2, "sideways", &bytes.Buffer{})
+}
+
+func TestReduce(t *testing.T) {
+	assertReduce := func(value DFloat, digits int, rounding RoundingMode, expected DFloat, expectRounding bool) {
+		t.Helper()
+		actual, err := value.Reduce(digits, rounding)
+		if actual != expected {
+			t.Errorf("%v to %v digits %v: expected %v but got %v", value, digits, rounding, expected, actual)
+		}
+		if expectRounding != errors.Is(err, RoundingError()) || (err != nil && !expectRounding) {
+			t.Errorf("%v to %v digits %v: unexpected error %v", value, digits, rounding, err)
+		}
+	}
+	assertReduce(DFloatValue(-4, 123456), 3, RoundHalfEven, DFloatValue(-1, 123), true)
+	assertReduce(DFloatValue(-4, 123456), 3, RoundUp, DFloatValue(-1, 124), true)
+	assertReduce(DFloatValue(-4, -123456), 4, RoundFloor, DFloatValue(-2, -1235), true)
+	assertReduce(DFloatValue(-4, 999999), 2, RoundHalfUp, DFloatValue(2, 1), true)
+	assertReduce(DFloatValue(-4, 123000), 3, RoundHalfEven, DFloatValue(-1, 123), false)
+	assertReduce(DFloatValue(-4, 123456), 0, RoundHalfEven, DFloatValue(-4, 123456), false)
+	assertReduce(DFloatValue(-4, 123456), 10, RoundHalfEven, DFloatValue(-4, 123456), false)
+	assertReduce(NegativeInfinity(), 1, RoundHalfEven, NegativeInfinity(), false)
+
+	if _, err := DFloatValue(0x7fffffff, 123).Reduce(1, RoundHalfEven); !errors.Is(err, ErrorExponentTooLarge) {
+		t.Errorf("Expected ErrorExponentTooLarge but got %v", err)
+	}
+	// apd's untyped constants work as well.
+	if value, err := DFloatValue(-4, 123456).Reduce(3, "ceiling"); value != DFloatValue(-1, 124) || !errors.Is(err, RoundingError()) {
+		t.Errorf("Expected 12.4 but got %v (%v)", value, err)
+	}
+	if value, err := DFloatValue(0, 1234).Reduce(2, "sideways"); value != DFloatValue(0, 1234) || !errors.Is(err, ErrorUnknownRoundingMode) {
+		t.Errorf("Expected ErrorUnknownRoundingMode but got %v (%v)", value, err)
+	}
+}
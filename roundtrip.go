@@ -0,0 +1,88 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrorRoundTripMismatch = errors.New("Value did not survive an encode/decode round trip")
+
+// Encodes a value, decodes it again, and checks that the result is the same
+// value (comparing canonical forms, so 1.50 and 1.5 match). It also checks the
+// canonical encoding property: the canonical form of the value must encode to
+// bytes that IsCanonicalEncoding() accepts and DecodeCanonical() decodes, and
+// any other encoding of the value must not be reported as canonical.
+// Failures wrap ErrorRoundTripMismatch.
+//
+// This is intended as a single correctness oracle for integration tests and
+// fuzzers.
+func VerifyRoundTrip(value DFloat) error {
+	encoded := AppendEncode(nil, value)
+	if len(encoded) != EncodedSize(value) {
+		return fmt.Errorf("%w: %v encoded to %v bytes, but EncodedSize() reports %v",
+			ErrorRoundTripMismatch, value, len(encoded), EncodedSize(value))
+	}
+
+	decoded, bigValue, err := DecodeExact(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: %v encoded to %x, which failed to decode: %v", ErrorRoundTripMismatch, value, encoded, err)
+	}
+	if bigValue != nil {
+		if !bigValueMatches(bigValue, value) {
+			return fmt.Errorf("%w: %v encoded to %x, which decoded to %v", ErrorRoundTripMismatch, value, encoded, bigValue)
+		}
+	} else if decoded.minimized() != value.minimized() {
+		return fmt.Errorf("%w: %v encoded to %x, which decoded to %v", ErrorRoundTripMismatch, value, encoded, decoded)
+	}
+
+	canonical := value.minimized()
+	// A value at the top of the exponent range can't move its trailing zeros
+	// into the exponent, and so has no canonical encoding.
+	hasCanonicalForm := canonical.Exponent < math.MaxInt32 || canonical.Coefficient%10 != 0
+	return verifyCanonicalEncoding(value, encoded, AppendEncode(nil, canonical), hasCanonicalForm)
+}
+
+// Checks the canonical encoding property for a value, given its encoding and
+// the encoding of its canonical form.
+func verifyCanonicalEncoding(value interface{}, encoded []byte, canonical []byte, hasCanonicalForm bool) error {
+	if hasCanonicalForm {
+		isCanonical, _, err := IsCanonicalEncoding(canonical)
+		if err != nil || !isCanonical {
+			return fmt.Errorf("%w: Canonical encoding %x of %v is not recognized as canonical (%v)",
+				ErrorRoundTripMismatch, canonical, value, err)
+		}
+		if _, _, _, err = DecodeCanonical(bytes.NewReader(canonical)); err != nil {
+			return fmt.Errorf("%w: Canonical encoding %x of %v failed to decode canonically: %v",
+				ErrorRoundTripMismatch, canonical, value, err)
+		}
+	}
+	if !bytes.Equal(encoded, canonical) {
+		if isCanonical, _, _ := IsCanonicalEncoding(encoded); isCanonical {
+			return fmt.Errorf("%w: Non-canonical encoding %x of %v is reported as canonical (canonical is %x)",
+				ErrorRoundTripMismatch, encoded, value, canonical)
+		}
+	}
+	return nil
+}
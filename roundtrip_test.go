@@ -0,0 +1,103 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+package compact_float
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"testing/quick"
+
+	"github.com/cockroachdb/apd/v2"
+)
+
+func TestVerifyRoundTrip(t *testing.T) {
+	for _, value := range []DFloat{
+		Zero(),
+		NegativeZero(),
+		Infinity(),
+		NegativeInfinity(),
+		QuietNaN(),
+		SignalingNaN(),
+		NegativeQuietNaN(),
+		NegativeSignalingNaN(),
+		{Exponent: 5, Coefficient: 0},
+		{Exponent: -1, Coefficient: 15},
+		{Exponent: -2, Coefficient: 150},
+		{Exponent: math.MaxInt32, Coefficient: 10},
+		{Exponent: math.MinInt32 + 1, Coefficient: math.MaxInt64},
+		{Exponent: 0, Coefficient: math.MinInt64},
+	} {
+		if err := VerifyRoundTrip(value); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := quick.Check(func(value DFloat) bool {
+		if err := VerifyRoundTrip(value); err != nil {
+			t.Error(err)
+			return false
+		}
+		return true
+	}, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerifyRoundTripBig(t *testing.T) {
+	for _, str := range []string{
+		"0",
+		"-0",
+		"0.000",
+		"Infinity",
+		"-Infinity",
+		"NaN",
+		"-sNaN",
+		"1.500",
+		"-9.4452837206285466345998345667683453466347345e-5000",
+		"123456789012345678901234567890E+100",
+	} {
+		value, _, err := apd.NewFromString(str)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if err := VerifyRoundTripBig(value); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestVerifyCanonicalEncodingMismatch(t *testing.T) {
+	value := DFloat{Exponent: -1, Coefficient: 15}
+	encoded := AppendEncode(nil, value)
+	nonCanonical := AppendEncode(nil, DFloat{Exponent: -2, Coefficient: 150})
+
+	if err := verifyCanonicalEncoding(value, encoded, nonCanonical, true); !errors.Is(err, ErrorRoundTripMismatch) {
+		t.Errorf("Expected ErrorRoundTripMismatch but got %v", err)
+	}
+	if err := verifyCanonicalEncoding(value, encoded, []byte{0x0a}, false); !errors.Is(err, ErrorRoundTripMismatch) {
+		t.Errorf("Expected ErrorRoundTripMismatch but got %v", err)
+	}
+}
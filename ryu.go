@@ -0,0 +1,242 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+)
+
+// Shortest decimal conversion of float64 values, based on the Ryu algorithm
+// by Ulf Adams (https://github.com/ulfjack/ryu). The multiplier tables are
+// generated at init time rather than being embedded.
+
+const (
+	float64MantissaBits = 52
+	float64ExponentBits = 11
+	float64ExponentBias = 1023
+
+	ryuPow5InvBitCount  = 125
+	ryuPow5BitCount     = 125
+	ryuPow5InvTableSize = 342
+	ryuPow5TableSize    = 326
+)
+
+var ryuPow5InvSplit [ryuPow5InvTableSize][2]uint64
+var ryuPow5Split [ryuPow5TableSize][2]uint64
+
+func init() {
+	mask64 := new(big.Int).SetUint64(math.MaxUint64)
+	split := func(value *big.Int) (result [2]uint64) {
+		result[0] = new(big.Int).And(value, mask64).Uint64()
+		result[1] = new(big.Int).Rsh(value, 64).Uint64()
+		return
+	}
+
+	one := big.NewInt(1)
+	five := big.NewInt(5)
+	pow := big.NewInt(1)
+	for i := 0; i < ryuPow5InvTableSize; i++ {
+		pow5Len := pow.BitLen()
+		if i < ryuPow5TableSize {
+			shift := pow5Len - ryuPow5BitCount
+			value := new(big.Int)
+			if shift >= 0 {
+				value.Rsh(pow, uint(shift))
+			} else {
+				value.Lsh(pow, uint(-shift))
+			}
+			ryuPow5Split[i] = split(value)
+		}
+		j := pow5Len - 1 + ryuPow5InvBitCount
+		inv := new(big.Int).Lsh(one, uint(j))
+		inv.Quo(inv, pow)
+		inv.Add(inv, one)
+		ryuPow5InvSplit[i] = split(inv)
+		pow.Mul(pow, five)
+	}
+}
+
+// Returns ceil(log2(5^e)) for 0 <= e <= 3528.
+func ryuPow5Bits(e int32) int32 {
+	return int32((uint32(e)*1217359)>>19) + 1
+}
+
+// Returns floor(log10(2^e)) for 0 <= e <= 1650.
+func ryuLog10Pow2(e int32) int32 {
+	return int32((uint32(e) * 78913) >> 18)
+}
+
+// Returns floor(log10(5^e)) for 0 <= e <= 2620.
+func ryuLog10Pow5(e int32) int32 {
+	return int32((uint32(e) * 732923) >> 20)
+}
+
+func ryuPow5Factor(value uint64) int32 {
+	count := int32(0)
+	for value%5 == 0 {
+		value /= 5
+		count++
+	}
+	return count
+}
+
+func ryuMultipleOfPowerOf5(value uint64, p int32) bool {
+	return ryuPow5Factor(value) >= p
+}
+
+func ryuMultipleOfPowerOf2(value uint64, p int32) bool {
+	return value&((uint64(1)<<uint(p))-1) == 0
+}
+
+// Returns (m * mul) >> j, where mul is a 128-bit value and j >= 64.
+func ryuMulShift64(m uint64, mul [2]uint64, j int32) uint64 {
+	high0, _ := bits.Mul64(m, mul[0])
+	high2, low2 := bits.Mul64(m, mul[1])
+	sum, carry := bits.Add64(high0, low2, 0)
+	high2 += carry
+	shift := uint(j - 64)
+	if shift == 0 {
+		return sum
+	}
+	return (sum >> shift) | (high2 << (64 - shift))
+}
+
+// Converts a finite, nonzero float64 magnitude to the shortest decimal
+// coefficient and exponent that round-trips back to the same value.
+func float64ToShortestDecimal(value float64) (coefficient uint64, exponent int32) {
+	asBits := math.Float64bits(value)
+	ieeeMantissa := asBits & (uint64(1)<<float64MantissaBits - 1)
+	ieeeExponent := int32((asBits >> float64MantissaBits) & (uint64(1)<<float64ExponentBits - 1))
+
+	var e2 int32
+	var m2 uint64
+	if ieeeExponent == 0 {
+		e2 = 1 - float64ExponentBias - float64MantissaBits - 2
+		m2 = ieeeMantissa
+	} else {
+		e2 = ieeeExponent - float64ExponentBias - float64MantissaBits - 2
+		m2 = uint64(1)<<float64MantissaBits | ieeeMantissa
+	}
+	acceptBounds := m2&1 == 0
+
+	mv := 4 * m2
+	mmShift := uint64(0)
+	if ieeeMantissa != 0 || ieeeExponent <= 1 {
+		mmShift = 1
+	}
+
+	var vr, vp, vm uint64
+	var e10 int32
+	vmIsTrailingZeros := false
+	vrIsTrailingZeros := false
+	if e2 >= 0 {
+		q := ryuLog10Pow2(e2)
+		if e2 > 3 {
+			q--
+		}
+		e10 = q
+		k := ryuPow5InvBitCount + ryuPow5Bits(q) - 1
+		i := -e2 + q + k
+		vr = ryuMulShift64(mv, ryuPow5InvSplit[q], i)
+		vp = ryuMulShift64(mv+2, ryuPow5InvSplit[q], i)
+		vm = ryuMulShift64(mv-1-mmShift, ryuPow5InvSplit[q], i)
+		if q <= 21 {
+			if mv%5 == 0 {
+				vrIsTrailingZeros = ryuMultipleOfPowerOf5(mv, q)
+			} else if acceptBounds {
+				vmIsTrailingZeros = ryuMultipleOfPowerOf5(mv-1-mmShift, q)
+			} else if ryuMultipleOfPowerOf5(mv+2, q) {
+				vp--
+			}
+		}
+	} else {
+		q := ryuLog10Pow5(-e2)
+		if -e2 > 1 {
+			q--
+		}
+		e10 = q + e2
+		i := -e2 - q
+		k := ryuPow5Bits(i) - ryuPow5BitCount
+		j := q - k
+		vr = ryuMulShift64(mv, ryuPow5Split[i], j)
+		vp = ryuMulShift64(mv+2, ryuPow5Split[i], j)
+		vm = ryuMulShift64(mv-1-mmShift, ryuPow5Split[i], j)
+		if q <= 1 {
+			vrIsTrailingZeros = true
+			if acceptBounds {
+				vmIsTrailingZeros = mmShift == 1
+			} else {
+				vp--
+			}
+		} else if q < 63 {
+			vrIsTrailingZeros = ryuMultipleOfPowerOf2(mv, q)
+		}
+	}
+
+	removed := int32(0)
+	lastRemovedDigit := uint64(0)
+	if vmIsTrailingZeros || vrIsTrailingZeros {
+		for vp/10 > vm/10 {
+			vmIsTrailingZeros = vmIsTrailingZeros && vm%10 == 0
+			vrIsTrailingZeros = vrIsTrailingZeros && lastRemovedDigit == 0
+			lastRemovedDigit = vr % 10
+			vr /= 10
+			vp /= 10
+			vm /= 10
+			removed++
+		}
+		if vmIsTrailingZeros {
+			for vm%10 == 0 {
+				vrIsTrailingZeros = vrIsTrailingZeros && lastRemovedDigit == 0
+				lastRemovedDigit = vr % 10
+				vr /= 10
+				vp /= 10
+				vm /= 10
+				removed++
+			}
+		}
+		if vrIsTrailingZeros && lastRemovedDigit == 5 && vr%2 == 0 {
+			// Round even if the exact number is .....50..0.
+			lastRemovedDigit = 4
+		}
+		coefficient = vr
+		if (vr == vm && (!acceptBounds || !vmIsTrailingZeros)) || lastRemovedDigit >= 5 {
+			coefficient++
+		}
+	} else {
+		roundUp := false
+		for vp/10 > vm/10 {
+			roundUp = vr%10 >= 5
+			vr /= 10
+			vp /= 10
+			vm /= 10
+			removed++
+		}
+		coefficient = vr
+		if vr == vm || roundUp {
+			coefficient++
+		}
+	}
+	exponent = e10 + removed
+	return
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build !compactfloat_nobig
+// +build !compactfloat_nobig
+
+package compact_float
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func assertShortestDecimal(t *testing.T, value float64) {
+	expected, err := DFloatFromString(strconv.FormatFloat(value, 'g', -1, 64))
+	if err != nil {
+		t.Errorf("Unexpected error converting %v via string: %v", value, err)
+		return
+	}
+	actual, err := DFloatFromFloat64(value, 0)
+	if err != nil {
+		t.Errorf("Unexpected error converting %v: %v", value, err)
+		return
+	}
+	if actual != expected {
+		t.Errorf("Expected %v to convert to %v but got %v", value, expected, actual)
+	}
+}
+
+func TestShortestDecimal(t *testing.T) {
+	assertShortestDecimal(t, 1)
+	assertShortestDecimal(t, 0.1)
+	assertShortestDecimal(t, -0.3)
+	assertShortestDecimal(t, 1e22)
+	assertShortestDecimal(t, 1e23)
+	assertShortestDecimal(t, 123456789012345680)
+	assertShortestDecimal(t, math.MaxFloat64)
+	assertShortestDecimal(t, -math.MaxFloat64)
+	assertShortestDecimal(t, math.SmallestNonzeroFloat64)
+	assertShortestDecimal(t, 2.2250738585072014e-308)
+	assertShortestDecimal(t, 2.2250738585072009e-308)
+	assertShortestDecimal(t, 5e-324*3)
+	assertShortestDecimal(t, 9007199254740993)
+	assertShortestDecimal(t, 1.7976931348623157e+308/3)
+
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		value := math.Float64frombits(random.Uint64())
+		if math.IsNaN(value) || math.IsInf(value, 0) || value == 0 {
+			continue
+		}
+		assertShortestDecimal(t, value)
+	}
+}
+
+func TestFloat64SignificantDigits(t *testing.T) {
+	assertConvertToString(t, assertDFloatFromFloat64(t, 100, 1, nil), "1e+2")
+	assertConvertToString(t, assertDFloatFromFloat64(t, 0.125, 2, RoundingError()), "0.12")
+	assertConvertToString(t, assertDFloatFromFloat64(t, 0.135, 2, RoundingError()), "0.14")
+	assertConvertToString(t, assertDFloatFromFloat64(t, -1.594375, 6, RoundingError()), "-1.59438")
+	assertConvertToString(t, assertDFloatFromFloat64(t, 9.99, 2, RoundingError()), "1e+1")
+	assertConvertToString(t, assertDFloatFromFloat64(t, 1.5, 20, nil), "1.5")
+}
+
+func BenchmarkDFloatFromFloat64(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		DFloatFromFloat64(1.2345678901234567e-120, 0)
+	}
+}
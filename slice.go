@@ -0,0 +1,209 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+package compact_float
+
+import (
+	"fmt"
+	"io"
+)
+
+// Slices are encoded as a ULEB128 count, followed by that many encoded values.
+
+// When decoding a slice, don't trust the count for preallocation beyond this.
+const maxSlicePreallocation = 1024
+
+// Encodes a slice of DFloat values, prefixed by a count.
+func EncodeSlice(values []DFloat, writer io.Writer) (bytesEncoded int, err error) {
+	buffer := make([]byte, 0, maxULEB128Uint64Length+len(values)*MaxEncodeLength())
+	buffer = appendULEB128(buffer, uint64(len(values)))
+	for _, value := range values {
+		buffer = AppendEncode(buffer, value)
+	}
+	return writer.Write(buffer)
+}
+
+// Decodes a count-prefixed slice of DFloat values. If any value is too big to
+// fit into a DFloat, the returned error will be ErrorValueTooLarge.
+func DecodeSlice(reader io.Reader) (values []DFloat, err error) {
+	buffer := []byte{0}
+	count, asBig, byteCount, err := decodeULEB128(reader, buffer)
+	if err != nil {
+		err = incompleteIfTruncated(err, byteCount)
+		return
+	}
+	if asBig != nil {
+		err = fmt.Errorf("%w: Slice count %v is too big", ErrorMalformed, asBig)
+		return
+	}
+
+	preallocation := count
+	if preallocation > maxSlicePreallocation {
+		preallocation = maxSlicePreallocation
+	}
+	if values, err = appendDecodeValues(make([]DFloat, 0, preallocation), reader, count, buffer); err != nil {
+		values = nil
+	}
+	return
+}
+
+// Decodes a count-prefixed slice of DFloat values, appending them to dst (so
+// that its storage can be reused across batches) and returning the extended
+// slice. If any value is too big to fit into a DFloat, the returned error will
+// be ErrorValueTooLarge.
+func AppendDecodeSlice(dst []DFloat, reader io.Reader) (values []DFloat, err error) {
+	buffer := []byte{0}
+	count, asBig, byteCount, err := decodeULEB128(reader, buffer)
+	if err != nil {
+		return dst, incompleteIfTruncated(err, byteCount)
+	}
+	if asBig != nil {
+		return dst, fmt.Errorf("%w: Slice count %v is too big", ErrorMalformed, asBig)
+	}
+	return appendDecodeValues(dst, reader, count, buffer)
+}
+
+func appendDecodeValues(dst []DFloat, reader io.Reader, count uint64, buffer []byte) (values []DFloat, err error) {
+	values = dst
+	for i := uint64(0); i < count; i++ {
+		value, bigValue, _, decodeErr := DecodeWithByteBuffer(reader, buffer)
+		if decodeErr != nil {
+			// The count promised more values, so running out is truncation.
+			return dst, incompleteIfTruncated(decodeErr, 1)
+		}
+		if bigValue != nil {
+			return dst, ErrorValueTooLarge
+		}
+		values = append(values, value)
+	}
+	return
+}
+
+// Decodes back-to-back values (with no count prefix) into dst until it is
+// full, returning the number of values decoded. If the reader ends cleanly
+// before dst is full, the returned error will be io.EOF. If any value is too
+// big to fit into a DFloat, the returned error will be ErrorValueTooLarge.
+func DecodeSliceInto(reader io.Reader, dst []DFloat) (n int, err error) {
+	buffer := []byte{0}
+	for n < len(dst) {
+		value, bigValue, _, decodeErr := DecodeWithByteBuffer(reader, buffer)
+		if decodeErr != nil {
+			return n, decodeErr
+		}
+		if bigValue != nil {
+			return n, ErrorValueTooLarge
+		}
+		dst[n] = value
+		n++
+	}
+	return
+}
+
+// Columnar encoding stores a count-prefixed slice as two streams: first the
+// exponent fields of all values, then the coefficients of all values. Special
+// values (zero, infinity, NaN) are fully described by their exponent field,
+// and so have no entry in the coefficient stream. Grouping similar bytes
+// together this way compresses much better than the interleaved layout.
+
+// Encodes a slice of DFloat values in columnar form.
+func EncodeColumns(values []DFloat, writer io.Writer) (bytesEncoded int, err error) {
+	exponents := make([]byte, 0, maxULEB128Uint64Length+len(values)*5)
+	coefficients := make([]byte, 0, len(values)*maxULEB128Uint64Length)
+	exponents = appendULEB128(exponents, uint64(len(values)))
+	for _, value := range values {
+		if value.IsZero() || value.IsSpecial() {
+			exponents = AppendEncode(exponents, value)
+			continue
+		}
+		exponentField, coefficient := splitDFloat(value)
+		exponents = appendULEB128(exponents, exponentField)
+		coefficients = appendULEB128(coefficients, coefficient)
+	}
+
+	if bytesEncoded, err = writer.Write(exponents); err != nil {
+		return
+	}
+	coefficientBytes, err := writer.Write(coefficients)
+	bytesEncoded += coefficientBytes
+	return
+}
+
+// Decodes a slice of DFloat values in columnar form. If any value is too big to
+// fit into a DFloat, the returned error will be ErrorValueTooLarge. Field
+// lengths are limited as they are in Decode(). Returns io.EOF if there is no
+// data, or ErrorIncomplete if the data ends partway through.
+func DecodeColumns(reader io.Reader) (values []DFloat, err error) {
+	buffer := []byte{0}
+	count, asBig, byteCount, err := decodeULEB128(reader, buffer)
+	if err != nil {
+		err = incompleteIfTruncated(err, byteCount)
+		return
+	}
+	if asBig != nil {
+		err = fmt.Errorf("%w: Slice count %v is too big", ErrorMalformed, asBig)
+		return
+	}
+
+	preallocation := count
+	if preallocation > maxSlicePreallocation {
+		preallocation = maxSlicePreallocation
+	}
+	values = make([]DFloat, 0, preallocation)
+	// Sign of each value's coefficient, or 0 if it has no coefficient.
+	coefficientSigns := make([]int8, 0, preallocation)
+	for i := uint64(0); i < count; i++ {
+		exponentField, asBig, byteCount, decodeErr := decodeULEB128Limited(reader, buffer, maxExponentFieldLength, ErrorExponentTooLarge)
+		if decodeErr != nil {
+			return nil, incompleteIfTruncated(decodeErr, 1)
+		}
+		if asBig != nil {
+			return nil, fmt.Errorf("%w: %v", ErrorExponentTooLarge, asBig)
+		}
+		if value, isSpecial := decodeSpecialValue(exponentField, byteCount); isSpecial {
+			values = append(values, value)
+			coefficientSigns = append(coefficientSigns, 0)
+			continue
+		}
+		exponent, negative, decodeErr := decodeExponentField(exponentField)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		values = append(values, DFloat{Exponent: exponent})
+		if negative {
+			coefficientSigns = append(coefficientSigns, -1)
+		} else {
+			coefficientSigns = append(coefficientSigns, 1)
+		}
+	}
+
+	for i, sign := range coefficientSigns {
+		if sign == 0 {
+			continue
+		}
+		coefficient, asBig, _, decodeErr := decodeULEB128Limited(reader, buffer, MaxCoefficientLength, ErrorCoefficientTooLong)
+		if decodeErr != nil {
+			return nil, incompleteIfTruncated(decodeErr, 1)
+		}
+		if asBig != nil || coefficient&0x8000000000000000 != 0 {
+			return nil, ErrorValueTooLarge
+		}
+		values[i].Coefficient = int64(coefficient) * int64(sign)
+	}
+	return
+}
@@ -0,0 +1,210 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/kstenerud/go-describe"
+)
+
+func TestSlice(t *testing.T) {
+	values := []DFloat{DFloatValue(-1, 15), Zero(), NegativeInfinity(), DFloatValue(4994, 9445283)}
+	buffer := &bytes.Buffer{}
+	bytesEncoded, err := EncodeSlice(values, buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := []byte{0x04, 0x06, 0x0f, 0x02, 0x83, 0x00, 0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04}
+	if bytesEncoded != len(expected) || !bytes.Equal(buffer.Bytes(), expected) {
+		t.Errorf("Expected encoded %v but got %v", describe.D(expected), describe.D(buffer.Bytes()))
+		return
+	}
+
+	decoded, err := DecodeSlice(buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(decoded) != len(values) {
+		t.Errorf("Expected %v but got %v", values, decoded)
+		return
+	}
+	for i, value := range decoded {
+		if value != values[i] {
+			t.Errorf("Expected %v but got %v", values, decoded)
+			return
+		}
+	}
+}
+
+func TestSliceErrors(t *testing.T) {
+	if _, err := DecodeSlice(bytes.NewBuffer([]byte{0x02, 0x06, 0x0f})); err == nil {
+		t.Errorf("Expected truncated slice to fail")
+	}
+	big := []byte{0x01, 0x00, 0x80, 0x80, 0xc0, 0x98, 0xd6, 0xc5, 0xd7, 0xe3, 0xeb, 0x0a}
+	if _, err := DecodeSlice(bytes.NewBuffer(big)); err != ErrorValueTooLarge {
+		t.Errorf("Expected ErrorValueTooLarge but got %v", err)
+	}
+	values, err := DecodeSlice(bytes.NewBuffer([]byte{0x00}))
+	if err != nil || len(values) != 0 {
+		t.Errorf("Expected empty slice but got %v (%v)", values, err)
+	}
+}
+
+func TestSliceTruncated(t *testing.T) {
+	encoded := []byte{0x03, 0x06, 0x0f, 0x02, 0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04}
+	for _, length := range []int{1, 3, 4, 6, 8} {
+		if _, err := DecodeSlice(bytes.NewBuffer(encoded[:length])); err != ErrorIncomplete {
+			t.Errorf("Expected ErrorIncomplete for %v but got %v", describe.D(encoded[:length]), err)
+		}
+	}
+}
+
+func TestSliceTruncatedCount(t *testing.T) {
+	if _, err := DecodeSlice(bytes.NewBuffer([]byte{0x80})); err != ErrorIncomplete {
+		t.Errorf("DecodeSlice: Expected ErrorIncomplete for a truncated count but got %v", err)
+	}
+	dst := []DFloat{Infinity()}
+	decoded, err := AppendDecodeSlice(dst, bytes.NewBuffer([]byte{0x80}))
+	if err != ErrorIncomplete || len(decoded) != 1 {
+		t.Errorf("AppendDecodeSlice: Expected ErrorIncomplete for a truncated count but got %v (%v)", decoded, err)
+	}
+	if _, err := AppendDecodeSlice(dst, bytes.NewBuffer([]byte{0x03, 0x06, 0x0f})); err != ErrorIncomplete {
+		t.Errorf("AppendDecodeSlice: Expected ErrorIncomplete for missing values but got %v", err)
+	}
+	if _, err := AppendDecodeSlice(dst, bytes.NewBuffer(nil)); err != io.EOF {
+		t.Errorf("AppendDecodeSlice: Expected io.EOF for empty data but got %v", err)
+	}
+	if _, err := DecodeSlice(bytes.NewBuffer(nil)); err != io.EOF {
+		t.Errorf("DecodeSlice: Expected io.EOF for empty data but got %v", err)
+	}
+}
+
+func TestAppendDecodeSlice(t *testing.T) {
+	encoded := []byte{0x04, 0x06, 0x0f, 0x02, 0x83, 0x00, 0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04}
+	expected := []DFloat{Infinity(), DFloatValue(-1, 15), Zero(), NegativeInfinity(), DFloatValue(4994, 9445283)}
+	dst := make([]DFloat, 1, 10)
+	dst[0] = Infinity()
+	decoded, err := AppendDecodeSlice(dst, bytes.NewBuffer(encoded))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if fmt.Sprint(decoded) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v but got %v", expected, decoded)
+	}
+	if &decoded[0] != &dst[0] {
+		t.Errorf("Expected the destination storage to be reused")
+	}
+
+	if _, err := AppendDecodeSlice(dst, bytes.NewBuffer(encoded[:5])); err == nil {
+		t.Errorf("Expected truncated slice to fail")
+	}
+}
+
+func TestDecodeSliceInto(t *testing.T) {
+	encoded := []byte{0x06, 0x0f, 0x02, 0x83, 0x00, 0x88, 0x9c, 0x01, 0xa3, 0xbf, 0xc0, 0x04}
+	expected := []DFloat{DFloatValue(-1, 15), Zero(), NegativeInfinity(), DFloatValue(4994, 9445283)}
+	reader := bytes.NewBuffer(encoded)
+
+	dst := make([]DFloat, 3)
+	n, err := DecodeSliceInto(reader, dst)
+	if err != nil || n != 3 || fmt.Sprint(dst) != fmt.Sprint(expected[:3]) {
+		t.Errorf("Expected %v but got %v, %v (%v)", expected[:3], n, dst, err)
+	}
+	n, err = DecodeSliceInto(reader, dst)
+	if err != io.EOF || n != 1 || dst[0] != expected[3] {
+		t.Errorf("Expected 1 value then EOF but got %v, %v (%v)", n, dst, err)
+	}
+
+	n, err = DecodeSliceInto(bytes.NewBuffer(encoded[:6]), make([]DFloat, 4))
+	if !errors.Is(err, ErrorIncomplete) || n != 3 {
+		t.Errorf("Expected 3 values then ErrorIncomplete but got %v (%v)", n, err)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	values := []DFloat{DFloatValue(-1, 15), Zero(), NegativeInfinity(), DFloatValue(4994, 9445283), DFloatValue(0, -1)}
+	buffer := &bytes.Buffer{}
+	bytesEncoded, err := EncodeColumns(values, buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := []byte{0x05, 0x06, 0x02, 0x83, 0x00, 0x88, 0x9c, 0x01, 0x01, 0x0f, 0xa3, 0xbf, 0xc0, 0x04, 0x01}
+	if bytesEncoded != len(expected) || !bytes.Equal(buffer.Bytes(), expected) {
+		t.Errorf("Expected encoded %v but got %v", describe.D(expected), describe.D(buffer.Bytes()))
+		return
+	}
+
+	decoded, err := DecodeColumns(buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(decoded) != len(values) {
+		t.Errorf("Expected %v but got %v", values, decoded)
+		return
+	}
+	for i, value := range decoded {
+		if value != values[i] {
+			t.Errorf("Expected %v but got %v", values, decoded)
+			return
+		}
+	}
+}
+
+func TestColumnsTruncated(t *testing.T) {
+	encoded := []byte{0x05, 0x06, 0x02, 0x83, 0x00, 0x88, 0x9c, 0x01, 0x01, 0x0f, 0xa3, 0xbf, 0xc0, 0x04, 0x01}
+	for length := 1; length < len(encoded); length++ {
+		if _, err := DecodeColumns(bytes.NewBuffer(encoded[:length])); err != ErrorIncomplete {
+			t.Errorf("Expected ErrorIncomplete for %v but got %v", describe.D(encoded[:length]), err)
+		}
+	}
+	if _, err := DecodeColumns(bytes.NewBuffer([]byte{0x80})); err != ErrorIncomplete {
+		t.Errorf("Expected ErrorIncomplete for a truncated count but got %v", err)
+	}
+	if _, err := DecodeColumns(bytes.NewBuffer(nil)); err != io.EOF {
+		t.Errorf("Expected io.EOF for empty data but got %v", err)
+	}
+}
+
+func TestColumnsTooLong(t *testing.T) {
+	longExponent := append([]byte{0x01}, bytes.Repeat([]byte{0x80}, maxExponentFieldLength+1)...)
+	longExponent = append(longExponent, 0x01)
+	if _, err := DecodeColumns(bytes.NewBuffer(longExponent)); !errors.Is(err, ErrorExponentTooLarge) {
+		t.Errorf("Expected ErrorExponentTooLarge but got %v", err)
+	}
+
+	oldMax := MaxCoefficientLength
+	defer func() { MaxCoefficientLength = oldMax }()
+	MaxCoefficientLength = 20
+	longCoefficient := append([]byte{0x01, 0x06}, bytes.Repeat([]byte{0x80}, 100)...)
+	longCoefficient = append(longCoefficient, 0x01)
+	if _, err := DecodeColumns(bytes.NewBuffer(longCoefficient)); !errors.Is(err, ErrorCoefficientTooLong) {
+		t.Errorf("Expected ErrorCoefficientTooLong but got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"sort"
+)
+
+// Compares two values using a total ordering, returning -1, 0 or 1. This
+// follows the IEEE 754 totalOrder predicate:
+//
+//	-NaN < -sNaN < -Infinity < negative values < -0 < 0 < positive values < Infinity < sNaN < NaN
+//
+// Values that are numerically equal but have different exponents (such as 1.0
+// and 1.00 with trailing zeros preserved) are ordered by exponent: the smaller
+// exponent comes first for positive values, and last for negative values.
+// Only identical values compare as 0.
+func CompareTotalOrder(a DFloat, b DFloat) int {
+	if rankA, rankB := nanRank(a), nanRank(b); rankA != rankB || rankA != 0 {
+		return compareInt64(int64(rankA), int64(rankB))
+	}
+	if result := compareDFloat(a, b); result != 0 {
+		return result
+	}
+
+	isNegativeA, isNegativeB := hasNegativeSign(a), hasNegativeSign(b)
+	if isNegativeA != isNegativeB {
+		if isNegativeA {
+			return -1
+		}
+		return 1
+	}
+	result := compareInt64(int64(a.Exponent), int64(b.Exponent))
+	if isNegativeA {
+		return -result
+	}
+	return result
+}
+
+// Sorts values in ascending order according to CompareTotalOrder(), placing
+// negative NaNs first and positive NaNs last.
+func SortSlice(values []DFloat) {
+	sort.Slice(values, func(i, j int) bool {
+		return CompareTotalOrder(values[i], values[j]) < 0
+	})
+}
+
+// Returns true if values are sorted in ascending order according to
+// CompareTotalOrder().
+func IsSorted(values []DFloat) bool {
+	for i := 1; i < len(values); i++ {
+		if CompareTotalOrder(values[i-1], values[i]) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Searches values (which must be sorted as by SortSlice()) for value, returning
+// the index of the first element that is not less than it according to
+// CompareTotalOrder(). This is the index of value if it's present, or where it
+// would be inserted otherwise (which may be len(values)).
+func SearchSorted(values []DFloat, value DFloat) int {
+	return sort.Search(len(values), func(i int) bool {
+		return CompareTotalOrder(values[i], value) >= 0
+	})
+}
+
+// Returns the position of a NaN in the total ordering relative to all other
+// values (-2 for -NaN, -1 for -sNaN, 1 for sNaN, 2 for NaN), or 0 if the value
+// is not NaN.
+func nanRank(value DFloat) int {
+	switch value {
+	case dfloatNegativeNaN:
+		return -2
+	case dfloatNegativeSignalingNaN:
+		return -1
+	case dfloatSignalingNaN:
+		return 1
+	case dfloatNaN:
+		return 2
+	}
+	return 0
+}
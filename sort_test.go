@@ -0,0 +1,102 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+var totallyOrdered = []DFloat{
+	NegativeQuietNaN(),
+	NegativeSignalingNaN(),
+	NegativeInfinity(),
+	DFloatValue(5, -1),
+	DFloatValue(0, -1),
+	DFloatValuePreserving(-1, -10),
+	DFloatValuePreserving(-2, -100),
+	DFloatValue(-100, -1),
+	NegativeZero(),
+	Zero(),
+	DFloatValuePreserving(3, 0),
+	DFloatValue(-100, 1),
+	DFloatValuePreserving(-2, 100),
+	DFloatValuePreserving(-1, 10),
+	DFloatValue(0, 1),
+	DFloatValue(-1, 15),
+	DFloatValue(5, 1),
+	DFloatValue(0, 9223372036854775807),
+	Infinity(),
+	SignalingNaN(),
+	QuietNaN(),
+}
+
+func TestCompareTotalOrder(t *testing.T) {
+	for i, a := range totallyOrdered {
+		for j, b := range totallyOrdered {
+			if actual, expected := CompareTotalOrder(a, b), compareInt64(int64(i), int64(j)); actual != expected {
+				t.Errorf("Comparing %v to %v: expected %v but got %v", a, b, expected, actual)
+			}
+		}
+	}
+}
+
+func TestSortSlice(t *testing.T) {
+	values := append([]DFloat(nil), totallyOrdered...)
+	random := rand.New(rand.NewSource(1))
+	random.Shuffle(len(values), func(i, j int) {
+		values[i], values[j] = values[j], values[i]
+	})
+	if IsSorted(values) {
+		t.Errorf("Expected %v to not be sorted", values)
+	}
+	SortSlice(values)
+	if !reflect.DeepEqual(values, totallyOrdered) {
+		t.Errorf("Expected %v but got %v", totallyOrdered, values)
+	}
+	if !IsSorted(values) || !IsSorted(nil) {
+		t.Errorf("Expected %v to be sorted", values)
+	}
+}
+
+func TestSearchSorted(t *testing.T) {
+	for i, value := range totallyOrdered {
+		if actual := SearchSorted(totallyOrdered, value); actual != i {
+			t.Errorf("Searching for %v: expected %v but got %v", value, i, actual)
+		}
+	}
+	values := []DFloat{DFloatValue(0, 1), DFloatValue(0, 3), DFloatValue(0, 5)}
+	assertSearch := func(value DFloat, expected int) {
+		if actual := SearchSorted(values, value); actual != expected {
+			t.Errorf("Searching for %v: expected %v but got %v", value, expected, actual)
+		}
+	}
+	assertSearch(DFloatValue(0, -1), 0)
+	assertSearch(DFloatValue(0, 2), 1)
+	assertSearch(DFloatValue(0, 5), 2)
+	assertSearch(DFloatValue(0, 6), 3)
+	assertSearch(QuietNaN(), 3)
+	assertSearch(NegativeQuietNaN(), 0)
+	if actual := SearchSorted(nil, Zero()); actual != 0 {
+		t.Errorf("Expected 0 but got %v", actual)
+	}
+}
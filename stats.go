@@ -0,0 +1,475 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"math"
+	"math/big"
+)
+
+// StatsAccumulator maintains summary statistics (count, min, max, sum and mean)
+// over a stream of values, so that compact float telemetry can be summarized
+// without converting each sample to float64. The sum is kept exactly as long as
+// its digits span no more than maxSumDigits (100) decimal places. Digits of a
+// value (or of the running sum) that fall further below the leading digit
+// than that are rounded (half-to-even) away, so that adding values with wildly
+// different exponents stays cheap. Sum() and Mean() report any such rounding.
+//
+// NaNs are counted separately (see NaNCount()) and otherwise ignored.
+// Infinities are included, so for example the sum of +Infinity and -Infinity is
+// NaN. The zero value is an empty accumulator ready for use.
+type StatsAccumulator struct {
+	count            uint64
+	nanCount         uint64
+	finiteCount      uint64
+	positiveInfinity bool
+	negativeInfinity bool
+	min              unpackedDecimal
+	max              unpackedDecimal
+	sum              unpackedDecimal
+	decoded          unpackedDecimal
+	scratch          big.Int
+	// Digits rounded away from the sum to keep it within maxSumDigits, and
+	// the direction of the most recent such rounding.
+	sumDigitsDropped int
+	sumRoundedAway   bool
+}
+
+// The number of decimal places that the running sum of a StatsAccumulator
+// spans at most.
+const maxSumDigits = 100
+
+// Adds a value.
+func (this *StatsAccumulator) Add(value DFloat) {
+	this.AddDecimal(value)
+}
+
+// Adds any Decimal value.
+func (this *StatsAccumulator) AddDecimal(value Decimal) {
+	exponent, coefficient := value.Unpack(&this.scratch)
+	if exponent == ExpSpecial {
+		switch special := (DFloat{Exponent: exponent, Coefficient: coefficient.Int64()}); {
+		case special.IsNan():
+			this.nanCount++
+			return
+		case special.IsNegativeInfinity():
+			this.count++
+			this.negativeInfinity = true
+			return
+		case special.IsInfinity():
+			this.count++
+			this.positiveInfinity = true
+			return
+		}
+		// Negative zero
+		exponent = 0
+	}
+
+	this.count++
+	this.finiteCount++
+	if this.finiteCount == 1 {
+		this.min.set(exponent, coefficient)
+		this.max.set(exponent, coefficient)
+		this.sum.set(exponent, coefficient)
+		return
+	}
+	if compareUnpacked(exponent, coefficient, this.min.exponent, &this.min.coefficient) < 0 {
+		this.min.set(exponent, coefficient)
+	}
+	if compareUnpacked(exponent, coefficient, this.max.exponent, &this.max.coefficient) > 0 {
+		this.max.set(exponent, coefficient)
+	}
+	if digitsDropped, roundedAway := this.sum.add(exponent, coefficient); digitsDropped > 0 {
+		this.sumDigitsDropped += digitsDropped
+		this.sumRoundedAway = roundedAway
+	}
+}
+
+// Decodes and adds all of the encoded values in data. On error, the values
+// before the failing one will have been added, and bytesDecoded will be the
+// offset of the failing value.
+func (this *StatsAccumulator) AddEncoded(data []byte) (bytesDecoded int, err error) {
+	for bytesDecoded < len(data) {
+		byteCount, err := DecodeDecimalFromBytes(data[bytesDecoded:], &this.decoded)
+		if err != nil {
+			return bytesDecoded, err
+		}
+		this.AddDecimal(&this.decoded)
+		bytesDecoded += byteCount
+	}
+	return
+}
+
+// Returns the number of values added, not including NaNs.
+func (this *StatsAccumulator) Count() uint64 {
+	return this.count
+}
+
+// Returns the number of NaN values that were added (and ignored).
+func (this *StatsAccumulator) NaNCount() uint64 {
+	return this.nanCount
+}
+
+// Stores the smallest value into dst (NaN if no values were added). If it
+// doesn't fit into dst, the rounded value is stored and RoundingError is
+// returned.
+func (this *StatsAccumulator) Min(dst MutableDecimal) error {
+	switch {
+	case this.negativeInfinity:
+		return packSpecial(dst, dfloatNegativeInfinity)
+	case this.finiteCount > 0:
+		return dst.Pack(this.min.exponent, &this.min.coefficient)
+	case this.positiveInfinity:
+		return packSpecial(dst, dfloatInfinity)
+	}
+	return packSpecial(dst, dfloatNaN)
+}
+
+// Stores the largest value into dst (NaN if no values were added). If it
+// doesn't fit into dst, the rounded value is stored and RoundingError is
+// returned.
+func (this *StatsAccumulator) Max(dst MutableDecimal) error {
+	switch {
+	case this.positiveInfinity:
+		return packSpecial(dst, dfloatInfinity)
+	case this.finiteCount > 0:
+		return dst.Pack(this.max.exponent, &this.max.coefficient)
+	case this.negativeInfinity:
+		return packSpecial(dst, dfloatNegativeInfinity)
+	}
+	return packSpecial(dst, dfloatNaN)
+}
+
+// Stores the sum of all values into dst (0 if no values were added). If it
+// doesn't fit into dst, or digits had to be rounded away from the running sum
+// (see StatsAccumulator), the rounded value is stored and RoundingError is
+// returned. Its DigitsDropped includes the digits rounded away from the running
+// sum.
+func (this *StatsAccumulator) Sum(dst MutableDecimal) error {
+	if special, isSpecial := this.infiniteResult(); isSpecial {
+		return packSpecial(dst, special)
+	}
+	if this.finiteCount == 0 {
+		return packSpecial(dst, dfloatZero)
+	}
+	return this.withSumRounding(dst.Pack(this.sum.exponent, &this.sum.coefficient), this.sum.String())
+}
+
+// Stores the mean of all values into dst (NaN if no values were added). A mean
+// whose decimal expansion terminates is calculated exactly. Otherwise (as with
+// 1/3) it's calculated to at least 40 significant digits. If it can't be
+// represented exactly in dst, the rounded (half-to-even) value is stored and
+// RoundingError is returned. For a mean that doesn't terminate, DigitsDropped
+// counts the digits dropped from that 40+ digit working value (plus one for
+// the rest of the expansion), along with any digits rounded away from the
+// running sum.
+func (this *StatsAccumulator) Mean(dst MutableDecimal) error {
+	if special, isSpecial := this.infiniteResult(); isSpecial {
+		return packSpecial(dst, special)
+	}
+	if this.finiteCount == 0 {
+		return packSpecial(dst, dfloatNaN)
+	}
+
+	const meanDigits = 40
+	const minExponent = int64(-0x7fffffff)
+	count := new(big.Int).SetUint64(this.finiteCount)
+	original := this.sum.String() + "/" + count.String()
+	scale := int64(meanDigits) - bigDigitCount(&this.sum.coefficient) + bigDigitCount(count)
+	if exactScale := terminatingScale(&this.sum.coefficient, count); exactScale > scale {
+		scale = exactScale
+	}
+	if scale < 0 {
+		scale = 0
+	}
+	if int64(this.sum.exponent)-scale < minExponent {
+		scale = int64(this.sum.exponent) - minExponent
+	}
+	quotient := pow10BigInt(scale)
+	quotient.Mul(quotient, &this.sum.coefficient)
+	remainder := new(big.Int)
+	quotient.QuoRem(quotient, count, remainder)
+	exponent := int64(this.sum.exponent) - scale
+	if remainder.Sign() == 0 {
+		return this.withSumRounding(dst.Pack(int32(exponent), quotient), original)
+	}
+
+	// Append a non-zero digit to stand in for the rest of the expansion, so
+	// that dst rounds the working value the same way it would the exact mean.
+	if exponent > minExponent {
+		working := new(big.Int).Mul(quotient, big.NewInt(10))
+		working.Add(working, big.NewInt(int64(quotient.Sign()|this.sum.coefficient.Sign())))
+		if err := dst.Pack(int32(exponent-1), working); err != nil {
+			return this.withSumRounding(err, original)
+		}
+	}
+
+	// dst kept every digit (or the stand-in digit doesn't fit), so round the
+	// rest of the expansion away here.
+	doubleRemainder := new(big.Int).Lsh(new(big.Int).Abs(remainder), 1)
+	roundedAway := doubleRemainder.Cmp(count) > 0 || (doubleRemainder.Cmp(count) == 0 && quotient.Bit(0) == 1)
+	if roundedAway {
+		quotient.Add(quotient, big.NewInt(int64(this.sum.coefficient.Sign())))
+	}
+	err := dst.Pack(int32(exponent), quotient)
+	if err == nil {
+		err = newRoundingError(original, 1, roundedAway)
+	}
+	return this.withSumRounding(err, original)
+}
+
+// Adds the digits rounded away from the running sum to a RoundingError (or
+// creates one if digits were dropped), and reports original as the value
+// that was rounded.
+func (this *StatsAccumulator) withSumRounding(err error, original string) error {
+	var details *RoundingErrorDetails
+	if errors.As(err, &details) {
+		details.Original = original
+		details.DigitsDropped += this.sumDigitsDropped
+		return details
+	}
+	if err == nil && this.sumDigitsDropped > 0 {
+		return newRoundingError(original, this.sumDigitsDropped, this.sumRoundedAway)
+	}
+	return err
+}
+
+// Returns the smallest scale s for which coefficient * 10^s is divisible by
+// divisor, or -1 if there isn't one (the quotient's decimal expansion doesn't
+// terminate).
+func terminatingScale(coefficient *big.Int, divisor *big.Int) int64 {
+	denominator := new(big.Int).Quo(divisor, new(big.Int).GCD(nil, nil, new(big.Int).Abs(coefficient), divisor))
+	twos := int64(denominator.TrailingZeroBits())
+	denominator.Rsh(denominator, uint(twos))
+	fives := int64(0)
+	five := big.NewInt(5)
+	remainder := new(big.Int)
+	for denominator.Cmp(big.NewInt(1)) > 0 {
+		quotient, _ := new(big.Int).QuoRem(denominator, five, remainder)
+		if remainder.Sign() != 0 {
+			return -1
+		}
+		denominator = quotient
+		fives++
+	}
+	if twos > fives {
+		return twos
+	}
+	return fives
+}
+
+// Removes all values from the accumulator.
+func (this *StatsAccumulator) Reset() {
+	*this = StatsAccumulator{}
+}
+
+// Returns the result of sums and means when infinities are involved.
+func (this *StatsAccumulator) infiniteResult() (result DFloat, isSpecial bool) {
+	switch {
+	case this.positiveInfinity && this.negativeInfinity:
+		return dfloatNaN, true
+	case this.positiveInfinity:
+		return dfloatInfinity, true
+	case this.negativeInfinity:
+		return dfloatNegativeInfinity, true
+	}
+	return
+}
+
+func packSpecial(dst MutableDecimal, value DFloat) error {
+	return dst.Pack(value.Exponent, big.NewInt(value.Coefficient))
+}
+
+// An exactly represented decimal value of any size, in the form used by
+// Decimal.Unpack().
+type unpackedDecimal struct {
+	exponent    int32
+	coefficient big.Int
+}
+
+func (this *unpackedDecimal) set(exponent int32, coefficient *big.Int) {
+	this.exponent = exponent
+	this.coefficient.Set(coefficient)
+}
+
+// Adds a finite value to this finite value. The result is exact unless digits
+// of either operand fall more than maxSumDigits below the leading digit of the
+// result, in which case they are rounded (half-to-even) away. Returns the
+// number of non-zero digits dropped, and whether the last rounding moved away
+// from zero.
+func (this *unpackedDecimal) add(exponent int32, coefficient *big.Int) (digitsDropped int, roundedAway bool) {
+	if coefficient.Sign() == 0 {
+		if this.coefficient.Sign() == 0 && exponent < this.exponent {
+			this.exponent = exponent
+		}
+		return
+	}
+	if this.coefficient.Sign() == 0 {
+		this.set(exponent, coefficient)
+		return
+	}
+
+	// Once every digit below minExponent is rounded away, the exponents are at
+	// most maxSumDigits apart, which bounds the cost of scaling.
+	top := int64(this.exponent) + bigDigitCount(&this.coefficient)
+	if valueTop := int64(exponent) + bigDigitCount(coefficient); valueTop > top {
+		top = valueTop
+	}
+	minExponent := top - maxSumDigits
+	if minExponent > math.MaxInt32 {
+		minExponent = math.MaxInt32
+	}
+	operand := new(big.Int).Set(coefficient)
+	operandExponent := int64(exponent)
+	if int64(this.exponent) < minExponent {
+		digitsDropped, roundedAway = roundBigCoefficient(&this.coefficient, minExponent-int64(this.exponent))
+		this.exponent = int32(minExponent)
+	}
+	if operandExponent < minExponent {
+		dropped, away := roundBigCoefficient(operand, minExponent-operandExponent)
+		if dropped > 0 {
+			digitsDropped += dropped
+			roundedAway = away
+		}
+		operandExponent = minExponent
+	}
+
+	if operandExponent < int64(this.exponent) {
+		this.coefficient.Mul(&this.coefficient, pow10BigInt(int64(this.exponent)-operandExponent))
+		this.exponent = int32(operandExponent)
+	}
+	if operandExponent > int64(this.exponent) {
+		operand.Mul(operand, pow10BigInt(operandExponent-int64(this.exponent)))
+	}
+	this.coefficient.Add(&this.coefficient, operand)
+	return
+}
+
+// Returns the number of decimal digits in value's magnitude.
+func bigDigitCount(value *big.Int) int64 {
+	text := value.Text(10)
+	if value.Sign() < 0 {
+		return int64(len(text) - 1)
+	}
+	return int64(len(text))
+}
+
+// Divides coefficient by 10^digits in place, rounding half-to-even. Returns
+// the number of digits dropped (0 if they were all zero), and whether the
+// coefficient was rounded away from zero.
+func roundBigCoefficient(coefficient *big.Int, digits int64) (digitsDropped int, roundedAway bool) {
+	magnitude := new(big.Int).Abs(coefficient)
+	if digitCount := bigDigitCount(magnitude); digits > digitCount {
+		// Less than a tenth of the new unit, so it rounds to zero.
+		coefficient.SetInt64(0)
+		return int(digitCount), false
+	}
+	divisor := pow10BigInt(digits)
+	remainder := new(big.Int)
+	magnitude.QuoRem(magnitude, divisor, remainder)
+	if remainder.Sign() != 0 {
+		digitsDropped = int(digits)
+		doubleRemainder := remainder.Lsh(remainder, 1)
+		roundedAway = doubleRemainder.Cmp(divisor) > 0 || (doubleRemainder.Cmp(divisor) == 0 && magnitude.Bit(0) == 1)
+		if roundedAway {
+			magnitude.Add(magnitude, big.NewInt(1))
+		}
+	}
+	if coefficient.Sign() < 0 {
+		magnitude.Neg(magnitude)
+	}
+	coefficient.Set(magnitude)
+	return
+}
+
+func (this *unpackedDecimal) String() string {
+	return this.coefficient.String() + "e" + big.NewInt(int64(this.exponent)).String()
+}
+
+func (this *unpackedDecimal) Unpack(dst *big.Int) (exponent int32, coefficient *big.Int) {
+	return this.exponent, dst.Set(&this.coefficient)
+}
+
+func (this *unpackedDecimal) EncodedSize() int {
+	if this.exponent == ExpSpecial || this.coefficient.Sign() == 0 {
+		return EncodedSize(DFloat{Exponent: this.exponent, Coefficient: this.coefficient.Int64()})
+	}
+	exponentField, _ := splitDFloat(DFloat{Exponent: this.exponent})
+	return encodedSizeULEB128Uint64(exponentField) + encodedSizeULEB128(&this.coefficient)
+}
+
+func (this *unpackedDecimal) EncodeToBytes(buffer []byte) (bytesEncoded int) {
+	if this.exponent == ExpSpecial || this.coefficient.Sign() == 0 {
+		return EncodeToBytes(DFloat{Exponent: this.exponent, Coefficient: this.coefficient.Int64()}, buffer)
+	}
+	exponentField, _ := splitDFloat(DFloat{Exponent: this.exponent})
+	if this.coefficient.Sign() < 0 {
+		exponentField |= 1
+	}
+	bytesEncoded = encodeULEB128Uint64(exponentField, buffer)
+	bytesEncoded += encodeULEB128(&this.coefficient, buffer[bytesEncoded:])
+	return
+}
+
+func (this *unpackedDecimal) Pack(exponent int32, coefficient *big.Int) error {
+	this.set(exponent, coefficient)
+	return nil
+}
+
+// Compares two finite unpacked values, returning -1, 0 or 1.
+func compareUnpacked(exponentA int32, coefficientA *big.Int, exponentB int32, coefficientB *big.Int) int {
+	if signA, signB := coefficientA.Sign(), coefficientB.Sign(); signA != signB || signA == 0 {
+		switch {
+		case signA < signB:
+			return -1
+		case signA > signB:
+			return 1
+		}
+		return 0
+	}
+
+	// Compare magnitudes by their adjusted exponents first, so that values
+	// with very different exponents are never scaled.
+	digitsA := int64(len(new(big.Int).Abs(coefficientA).Text(10)))
+	digitsB := int64(len(new(big.Int).Abs(coefficientB).Text(10)))
+	adjustedA := int64(exponentA) + digitsA
+	adjustedB := int64(exponentB) + digitsB
+	result := 0
+	switch {
+	case adjustedA < adjustedB:
+		result = -1
+	case adjustedA > adjustedB:
+		result = 1
+	default:
+		// With equal adjusted exponents, the exponents differ by no more than
+		// the number of digits in the longer coefficient, which bounds the
+		// cost of scaling.
+		scaledA, scaledB := new(big.Int).Abs(coefficientA), new(big.Int).Abs(coefficientB)
+		if exponentA > exponentB {
+			scaledA.Mul(scaledA, pow10BigInt(int64(exponentA)-int64(exponentB)))
+		} else if exponentB > exponentA {
+			scaledB.Mul(scaledB, pow10BigInt(int64(exponentB)-int64(exponentA)))
+		}
+		result = scaledA.Cmp(scaledB)
+	}
+	return result * coefficientA.Sign()
+}
@@ -0,0 +1,194 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func assertStat(t *testing.T, name string, get func(MutableDecimal) error, expected DFloat, expectRounding bool) {
+	var actual DFloat
+	err := get(&actual)
+	if expectRounding {
+		if !errors.Is(err, RoundingError()) {
+			t.Errorf("%v: Expected RoundingError but got %v", name, err)
+		}
+	} else if err != nil {
+		t.Errorf("%v: %v", name, err)
+		return
+	}
+	if actual.minimized() != expected.minimized() {
+		t.Errorf("%v: Expected %v but got %v", name, expected, actual)
+	}
+}
+
+func TestStatsAccumulator(t *testing.T) {
+	var stats StatsAccumulator
+	assertStat(t, "empty min", stats.Min, QuietNaN(), false)
+	assertStat(t, "empty max", stats.Max, QuietNaN(), false)
+	assertStat(t, "empty sum", stats.Sum, Zero(), false)
+	assertStat(t, "empty mean", stats.Mean, QuietNaN(), false)
+
+	for _, str := range []string{"1.5", "-2.25", "100", "0.1", "-0", "NaN"} {
+		value, _ := DFloatFromString(str)
+		stats.Add(value)
+	}
+	if stats.Count() != 5 || stats.NaNCount() != 1 {
+		t.Errorf("Expected 5 values and 1 NaN but got %v and %v", stats.Count(), stats.NaNCount())
+	}
+	assertStat(t, "min", stats.Min, DFloat{Exponent: -2, Coefficient: -225}, false)
+	assertStat(t, "max", stats.Max, DFloat{Exponent: 2, Coefficient: 1}, false)
+	assertStat(t, "sum", stats.Sum, DFloat{Exponent: -2, Coefficient: 9935}, false)
+	assertStat(t, "mean", stats.Mean, DFloat{Exponent: -3, Coefficient: 19870}, false)
+
+	stats.Add(DFloat{Exponent: 0, Coefficient: 1})
+	// 100.35 / 6 = 16.725
+	assertStat(t, "mean", stats.Mean, DFloat{Exponent: -3, Coefficient: 16725}, false)
+	stats.Add(DFloat{Exponent: 0, Coefficient: 1})
+	// 101.35 / 7 = 14.47857142857142857142857...
+	assertStat(t, "mean", stats.Mean, DFloat{Exponent: -17, Coefficient: 1447857142857142857}, true)
+
+	stats.Add(Infinity())
+	assertStat(t, "max", stats.Max, Infinity(), false)
+	assertStat(t, "sum", stats.Sum, Infinity(), false)
+	stats.Add(NegativeInfinity())
+	assertStat(t, "min", stats.Min, NegativeInfinity(), false)
+	assertStat(t, "sum", stats.Sum, QuietNaN(), false)
+
+	stats.Reset()
+	stats.Add(Infinity())
+	assertStat(t, "min", stats.Min, Infinity(), false)
+	assertStat(t, "mean", stats.Mean, Infinity(), false)
+}
+
+func TestStatsAccumulatorExactSum(t *testing.T) {
+	var stats StatsAccumulator
+	stats.Add(DFloat{Exponent: 30, Coefficient: 1})
+	stats.Add(DFloat{Exponent: -30, Coefficient: 1})
+	stats.Add(DFloat{Exponent: 30, Coefficient: -1})
+	assertStat(t, "sum", stats.Sum, DFloat{Exponent: -30, Coefficient: 1}, false)
+	assertStat(t, "min", stats.Min, DFloat{Exponent: 30, Coefficient: -1}, false)
+	assertStat(t, "max", stats.Max, DFloat{Exponent: 30, Coefficient: 1}, false)
+
+	stats.Reset()
+	stats.Add(DFloat{Coefficient: math.MaxInt64})
+	stats.Add(DFloat{Coefficient: math.MaxInt64})
+	var sum DFloat128
+	if err := stats.Sum(&sum); err != nil || sum.Coefficient != (Int128{Low: math.MaxUint64 - 1}) {
+		t.Errorf("Expected 2*MaxInt64 but got %v (%v)", sum, err)
+	}
+	assertStat(t, "sum", stats.Sum, DFloat{Exponent: 1, Coefficient: 1844674407370955161}, true)
+}
+
+func TestStatsAccumulatorBoundedSum(t *testing.T) {
+	var stats StatsAccumulator
+	stats.Add(DFloat{Exponent: -2000000000, Coefficient: 1})
+	stats.Add(DFloat{Exponent: 2000000000, Coefficient: 1})
+	assertStat(t, "sum", stats.Sum, DFloat{Exponent: 2000000000, Coefficient: 1}, true)
+	assertStat(t, "mean", stats.Mean, DFloat{Exponent: 1999999999, Coefficient: 5}, true)
+
+	stats.Reset()
+	stats.Add(DFloat{Exponent: 0, Coefficient: 1})
+	stats.Add(DFloat{Exponent: -100, Coefficient: 6})
+	stats.Add(DFloat{Exponent: 0, Coefficient: -1})
+	assertStat(t, "sum", stats.Sum, DFloat{Exponent: -99, Coefficient: 1}, true)
+	var sum DFloat
+	var details *RoundingErrorDetails
+	if err := stats.Sum(&sum); !errors.As(err, &details) || details.DigitsDropped != 1 || details.Direction != RoundedAwayFromZero {
+		t.Errorf("Expected the sum to report digits rounded away from zero but got %v", err)
+	}
+}
+
+func TestStatsAccumulatorMeanDigitsDropped(t *testing.T) {
+	var stats StatsAccumulator
+	stats.Add(DFloat{Coefficient: 1})
+	stats.Add(DFloat{Coefficient: 0})
+	stats.Add(DFloat{Coefficient: 0})
+	var mean DFloat
+	var details *RoundingErrorDetails
+	err := stats.Mean(&mean)
+	if !errors.As(err, &details) || details.DigitsDropped == 0 || details.Original != "1e0/3" {
+		t.Errorf("Expected a RoundingError for 1/3 but got %v", err)
+	}
+
+	// x/1024 terminates, but only after more digits than a DFloat holds.
+	stats.Reset()
+	stats.Add(DFloat{Coefficient: 1234567890123456789})
+	for i := 0; i < 1023; i++ {
+		stats.Add(DFloat{Coefficient: 0})
+	}
+	var mean128 DFloat128
+	if err := stats.Mean(&mean128); err != nil {
+		t.Errorf("Expected x/1024 to be exact but got %v", err)
+	}
+	err = stats.Mean(&mean)
+	if !errors.As(err, &details) || details.DigitsDropped != 7 || details.Original != "1234567890123456789e0/1024" {
+		t.Errorf("Expected 7 digits dropped from x/1024 but got %v", err)
+	}
+}
+
+func TestStatsAccumulatorEncoded(t *testing.T) {
+	var data []byte
+	for _, value := range []DFloat{{Exponent: -1, Coefficient: 15}, {Exponent: 0, Coefficient: -3}, QuietNaN()} {
+		data = AppendEncode(data, value)
+	}
+	var stats StatsAccumulator
+	if bytesDecoded, err := stats.AddEncoded(data); err != nil || bytesDecoded != len(data) {
+		t.Errorf("Expected to decode %v bytes but got %v (%v)", len(data), bytesDecoded, err)
+	}
+	assertStat(t, "sum", stats.Sum, DFloat{Exponent: -1, Coefficient: -15}, false)
+	if stats.Count() != 2 || stats.NaNCount() != 1 {
+		t.Errorf("Expected 2 values and 1 NaN but got %v and %v", stats.Count(), stats.NaNCount())
+	}
+
+	truncated := append(AppendEncode(nil, DFloat{Coefficient: 7}), 0x0d)
+	if bytesDecoded, err := stats.AddEncoded(truncated); !errors.Is(err, ErrorIncomplete) || bytesDecoded != 2 {
+		t.Errorf("Expected ErrorIncomplete at offset 2 but got %v at %v", err, bytesDecoded)
+	}
+	if stats.Count() != 3 {
+		t.Errorf("Expected the value before the error to be added")
+	}
+}
+
+func TestCompareUnpacked(t *testing.T) {
+	for _, test := range []struct {
+		a, b     DFloat
+		expected int
+	}{
+		{DFloat{0, 1}, DFloat{0, 1}, 0},
+		{DFloat{0, 10}, DFloat{1, 1}, 0},
+		{DFloat{0, 0}, DFloat{5, 0}, 0},
+		{DFloat{0, -1}, DFloat{0, 0}, -1},
+		{DFloat{math.MaxInt32, 1}, DFloat{-math.MaxInt32, math.MaxInt64}, 1},
+		{DFloat{math.MaxInt32, -1}, DFloat{-math.MaxInt32, -math.MaxInt64}, -1},
+		{DFloat{-1, 15}, DFloat{0, 2}, -1},
+		{DFloat{-1, -15}, DFloat{0, -2}, 1},
+	} {
+		exponentA, coefficientA := test.a.Unpack(new(big.Int))
+		exponentB, coefficientB := test.b.Unpack(new(big.Int))
+		if actual := compareUnpacked(exponentA, coefficientA, exponentB, coefficientB); actual != test.expected {
+			t.Errorf("Expected compare(%v, %v) to be %v but got %v", test.a, test.b, test.expected, actual)
+		}
+	}
+}
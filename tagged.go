@@ -0,0 +1,201 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The tagged value format allows a stream to mix decimals with other kinds of
+// values (for example a column that holds integers, binary floats and nulls).
+// Each record is a one-byte type tag followed by its payload:
+//
+//     TagNull:    no payload
+//     TagDecimal: compact float
+//     TagInt:     ULEB128: zigzag encoded int64
+//     TagFloat64: 8 bytes: IEEE 754 binary64 bits, little endian
+//
+// Binary floats are passed through bit-for-bit rather than converted to
+// decimal, so that they come back exactly as they went in.
+
+// The type tag that precedes each record in a tagged value stream.
+type ValueTag byte
+
+const (
+	TagNull ValueTag = iota
+	TagDecimal
+	TagInt
+	TagFloat64
+)
+
+var ErrorUnknownTag = errors.New("Unknown tagged value type tag")
+
+// A value in a tagged value stream. Only the field(s) matching Tag are used.
+type TaggedValue struct {
+	Tag     ValueTag
+	Decimal DFloat
+	// Set instead of Decimal when a decoded decimal is too big to fit into a
+	// DFloat. Values to be encoded must use Decimal.
+	BigDecimal *BigDecimal
+	Int        int64
+	Float64    float64
+}
+
+// Returns a tagged null value.
+func NullValue() TaggedValue {
+	return TaggedValue{Tag: TagNull}
+}
+
+// Returns a tagged decimal value.
+func DecimalValue(value DFloat) TaggedValue {
+	return TaggedValue{Tag: TagDecimal, Decimal: value}
+}
+
+// Returns a tagged integer value.
+func IntValue(value int64) TaggedValue {
+	return TaggedValue{Tag: TagInt, Int: value}
+}
+
+// Returns a tagged binary float value.
+func Float64Value(value float64) TaggedValue {
+	return TaggedValue{Tag: TagFloat64, Float64: value}
+}
+
+func (this TaggedValue) String() string {
+	switch this.Tag {
+	case TagNull:
+		return "null"
+	case TagDecimal:
+		if this.BigDecimal != nil {
+			return fmt.Sprint(this.BigDecimal)
+		}
+		return this.Decimal.String()
+	case TagInt:
+		return fmt.Sprint(this.Int)
+	case TagFloat64:
+		return fmt.Sprint(this.Float64)
+	}
+	return fmt.Sprintf("<unknown tag %v>", byte(this.Tag))
+}
+
+// Appends the tagged encoding of value to dst, returning the extended slice.
+// Panics if value has an unknown tag.
+func AppendTagged(dst []byte, value TaggedValue) []byte {
+	switch value.Tag {
+	case TagNull:
+		return append(dst, byte(TagNull))
+	case TagDecimal:
+		return AppendEncode(append(dst, byte(TagDecimal)), value.Decimal)
+	case TagInt:
+		return appendULEB128(append(dst, byte(TagInt)), zigzagEncode(value.Int))
+	case TagFloat64:
+		var bits [8]byte
+		binary.LittleEndian.PutUint64(bits[:], math.Float64bits(value.Float64))
+		return append(append(dst, byte(TagFloat64)), bits[:]...)
+	}
+	panic(fmt.Errorf("AppendTagged: unknown tag %v", byte(value.Tag)))
+}
+
+// Decodes a tagged value from the start of data.
+func DecodeTaggedFromBytes(data []byte) (value TaggedValue, bytesDecoded int, err error) {
+	if len(data) == 0 {
+		err = ErrorIncomplete
+		return
+	}
+	value.Tag = ValueTag(data[0])
+	payload := data[1:]
+	switch value.Tag {
+	case TagNull:
+	case TagDecimal:
+		value.Decimal, value.BigDecimal, bytesDecoded, err = DecodeFromBytes(payload)
+	case TagInt:
+		var asUint uint64
+		asUint, bytesDecoded, err = decodeULEB128Uint64FromBytes(payload, ErrorValueTooLarge)
+		value.Int = zigzagDecode(asUint)
+	case TagFloat64:
+		if len(payload) < 8 {
+			err = ErrorIncomplete
+			return
+		}
+		value.Float64 = math.Float64frombits(binary.LittleEndian.Uint64(payload))
+		bytesDecoded = 8
+	default:
+		err = fmt.Errorf("%w: %v", ErrorUnknownTag, data[0])
+		return
+	}
+	bytesDecoded++
+	return
+}
+
+// Encode a tagged value, returning the number of bytes it encoded to.
+// Panics if value has an unknown tag, or if checksums are enabled (checksummed
+// blocks only hold compact floats).
+func (this *Encoder) EncodeTagged(value TaggedValue) (bytesEncoded int, err error) {
+	if this.checksumInterval > 0 {
+		panic("EncodeTagged: tagged values cannot be checksummed")
+	}
+	start := len(this.buffer)
+	this.buffer = AppendTagged(this.buffer, value)
+	bytesEncoded = len(this.buffer) - start
+	err = this.flushIfFull()
+	return
+}
+
+// Decode the next tagged value from the stream. The decoder's maximum value
+// size, canonical and conformance settings apply to decimal payloads.
+// Returns io.EOF if the stream ended cleanly before the value began, or
+// ErrorIncomplete if the stream ended partway through the value.
+// Panics if checksum verification is enabled (checksummed blocks only hold
+// compact floats).
+func (this *Decoder) DecodeTagged() (value TaggedValue, err error) {
+	if this.verifyChecksums {
+		panic("DecodeTagged: tagged values cannot be checksummed")
+	}
+	tag, err := this.reader.ReadByte()
+	if err != nil {
+		return
+	}
+	value.Tag = ValueTag(tag)
+	switch value.Tag {
+	case TagNull:
+	case TagDecimal:
+		value.Decimal, value.BigDecimal, err = this.decodeFrom(&this.reader)
+	case TagInt:
+		asUint, asBig, _, decodeErr := decodeULEB128(&this.reader, this.buffer[:])
+		if err = decodeErr; err == nil && asBig != nil {
+			err = ErrorValueTooLarge
+		}
+		value.Int = zigzagDecode(asUint)
+	case TagFloat64:
+		var bits [8]byte
+		if _, err = io.ReadFull(&this.reader, bits[:]); err == nil {
+			value.Float64 = math.Float64frombits(binary.LittleEndian.Uint64(bits[:]))
+		}
+	default:
+		err = fmt.Errorf("%w: %v", ErrorUnknownTag, tag)
+	}
+	err = incompleteIfTruncated(err, 1)
+	return
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestTaggedRoundTrip(t *testing.T) {
+	values := []TaggedValue{
+		NullValue(),
+		DecimalValue(DFloatValue(-2, 1995)),
+		DecimalValue(NegativeZero()),
+		IntValue(0),
+		IntValue(-1),
+		IntValue(math.MinInt64),
+		IntValue(math.MaxInt64),
+		Float64Value(0.1),
+		Float64Value(math.Inf(-1)),
+	}
+
+	var encoded []byte
+	for _, value := range values {
+		encoded = AppendTagged(encoded, value)
+	}
+
+	offset := 0
+	for _, expected := range values {
+		actual, bytesDecoded, err := DecodeTaggedFromBytes(encoded[offset:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Errorf("Expected %v but got %v", expected, actual)
+		}
+		offset += bytesDecoded
+	}
+	if offset != len(encoded) {
+		t.Errorf("Expected to decode %v bytes but decoded %v", len(encoded), offset)
+	}
+
+	buffer := &bytes.Buffer{}
+	encoder := NewEncoder(buffer)
+	for _, value := range values {
+		if _, err := encoder.EncodeTagged(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buffer.Bytes(), encoded) {
+		t.Errorf("Expected %x but got %x", encoded, buffer.Bytes())
+	}
+
+	decoder := NewDecoder(buffer)
+	for _, expected := range values {
+		actual, err := decoder.DecodeTagged()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Errorf("Expected %v but got %v", expected, actual)
+		}
+	}
+	if _, err := decoder.DecodeTagged(); err != io.EOF {
+		t.Errorf("Expected io.EOF but got %v", err)
+	}
+}
+
+func TestTaggedEncoding(t *testing.T) {
+	assertEncoded := func(value TaggedValue, expected []byte) {
+		if actual := AppendTagged(nil, value); !bytes.Equal(actual, expected) {
+			t.Errorf("%v: expected %x but got %x", value, expected, actual)
+		}
+	}
+	assertEncoded(NullValue(), []byte{0x00})
+	assertEncoded(DecimalValue(Zero()), []byte{0x01, 0x02})
+	assertEncoded(IntValue(-2), []byte{0x02, 0x03})
+	assertEncoded(Float64Value(1), []byte{0x03, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f})
+}
+
+func TestTaggedErrors(t *testing.T) {
+	for _, encoded := range [][]byte{{}, {0x01}, {0x01, 0x06}, {0x02, 0x80}, {0x03, 0, 0, 0}} {
+		if _, _, err := DecodeTaggedFromBytes(encoded); !errors.Is(err, ErrorIncomplete) {
+			t.Errorf("%x: expected ErrorIncomplete but got %v", encoded, err)
+		}
+		if len(encoded) == 0 {
+			continue
+		}
+		if _, err := NewDecoder(bytes.NewBuffer(encoded)).DecodeTagged(); !errors.Is(err, ErrorIncomplete) {
+			t.Errorf("%x: expected ErrorIncomplete from decoder but got %v", encoded, err)
+		}
+	}
+
+	tooLarge := []byte{0x02, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x02}
+	if _, _, err := DecodeTaggedFromBytes(tooLarge); !errors.Is(err, ErrorValueTooLarge) {
+		t.Errorf("Expected ErrorValueTooLarge but got %v", err)
+	}
+	if _, err := NewDecoder(bytes.NewBuffer(tooLarge)).DecodeTagged(); !errors.Is(err, ErrorValueTooLarge) {
+		t.Errorf("Expected ErrorValueTooLarge from decoder but got %v", err)
+	}
+
+	if _, _, err := DecodeTaggedFromBytes([]byte{0x04}); !errors.Is(err, ErrorUnknownTag) {
+		t.Errorf("Expected ErrorUnknownTag but got %v", err)
+	}
+	if _, err := NewDecoder(bytes.NewBuffer([]byte{0x04})).DecodeTagged(); !errors.Is(err, ErrorUnknownTag) {
+		t.Errorf("Expected ErrorUnknownTag from decoder but got %v", err)
+	}
+}
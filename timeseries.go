@@ -0,0 +1,241 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// The time-series block format stores (timestamp, value) points in
+// self-describing blocks:
+//
+//     count         ULEB128: number of points
+//     body length   ULEB128: number of bytes in the body
+//     min           compact float: smallest non-NaN value (NaN if none)
+//     max           compact float: largest non-NaN value (NaN if none)
+//     body          count timestamps, then count values
+//
+// Timestamps are stored as zigzag ULEB128 differences from the previous
+// timestamp (the first from 0), so regularly sampled series take 1 or 2 bytes
+// per timestamp. Values are delta encoded (see DeltaEncoder), starting from
+// zero in each block. Readers can use the header to skip blocks whose range
+// isn't of interest without decoding the body.
+
+// The header of a time-series block.
+type BlockHeader struct {
+	// The number of points in the block.
+	Count int
+	// The smallest and largest non-NaN values in the block (NaN if there are
+	// none).
+	Min DFloat
+	Max DFloat
+}
+
+// BlockWriter buffers (timestamp, value) points and writes them out as
+// time-series blocks.
+type BlockWriter struct {
+	writer            io.Writer
+	maxPointsPerBlock int
+	timestamps        []int64
+	values            []DFloat
+	body              bytes.Buffer
+	header            []byte
+}
+
+// Create a new block writer that writes a block to writer every time
+// maxPointsPerBlock points have been buffered.
+// Panics if maxPointsPerBlock is less than 1.
+func NewBlockWriter(writer io.Writer, maxPointsPerBlock int) *BlockWriter {
+	if maxPointsPerBlock < 1 {
+		panic("NewBlockWriter: maxPointsPerBlock must be at least 1")
+	}
+	return &BlockWriter{
+		writer:            writer,
+		maxPointsPerBlock: maxPointsPerBlock,
+	}
+}
+
+// Add a point, writing out a block if the buffer is full (in which case
+// bytesEncoded is the size of the block).
+func (this *BlockWriter) Write(timestamp int64, value DFloat) (bytesEncoded int, err error) {
+	this.timestamps = append(this.timestamps, timestamp)
+	this.values = append(this.values, value)
+	if len(this.timestamps) >= this.maxPointsPerBlock {
+		return this.Flush()
+	}
+	return
+}
+
+// Write out any buffered points as a block. Does nothing if there are no
+// buffered points.
+func (this *BlockWriter) Flush() (bytesEncoded int, err error) {
+	if len(this.timestamps) == 0 {
+		return
+	}
+
+	this.body.Reset()
+	var buffer [maxULEB128Uint64Length]byte
+	previousTimestamp := int64(0)
+	for _, timestamp := range this.timestamps {
+		byteCount := encodeULEB128Uint64(zigzagEncode(int64(uint64(timestamp)-uint64(previousTimestamp))), buffer[:])
+		this.body.Write(buffer[:byteCount])
+		previousTimestamp = timestamp
+	}
+	encoder := NewDeltaEncoder(&this.body)
+	min, max := dfloatNaN, dfloatNaN
+	for _, value := range this.values {
+		encoder.Encode(value)
+		if value.IsNan() {
+			continue
+		}
+		if min.IsNan() || compareDFloat(value, min) < 0 {
+			min = value
+		}
+		if max.IsNan() || compareDFloat(value, max) > 0 {
+			max = value
+		}
+	}
+
+	this.header = appendULEB128(this.header[:0], uint64(len(this.timestamps)))
+	this.header = appendULEB128(this.header, uint64(this.body.Len()))
+	this.header = AppendEncode(this.header, min)
+	this.header = AppendEncode(this.header, max)
+	this.timestamps = this.timestamps[:0]
+	this.values = this.values[:0]
+
+	if bytesEncoded, err = this.writer.Write(this.header); err != nil {
+		return
+	}
+	byteCount, err := this.writer.Write(this.body.Bytes())
+	bytesEncoded += byteCount
+	return
+}
+
+// BlockReader reads time-series blocks written by a BlockWriter.
+type BlockReader struct {
+	reader       io.Reader
+	buffer       [1]byte
+	bodyLength   uint64
+	count        int
+	haveBody     bool
+	body         bytes.Buffer
+	bytesDecoded int
+}
+
+// Create a new block reader that reads from the specified reader.
+func NewBlockReader(reader io.Reader) *BlockReader {
+	return &BlockReader{reader: reader}
+}
+
+// Read the header of the next block, skipping the body of the current block if
+// it wasn't read. Returns io.EOF if there are no more blocks.
+func (this *BlockReader) NextBlock() (header BlockHeader, err error) {
+	if this.haveBody {
+		this.haveBody = false
+		if _, err = io.CopyN(ioutil.Discard, this.reader, int64(this.bodyLength)); err != nil {
+			err = incompleteIfTruncated(err, 1)
+			return
+		}
+	}
+
+	count, asBig, byteCount, err := decodeULEB128(this.reader, this.buffer[:])
+	if err != nil {
+		err = incompleteIfTruncated(err, byteCount)
+		return
+	}
+	if asBig != nil || count > uint64(maxInt) {
+		err = fmt.Errorf("%w: Block point count is too big", ErrorMalformed)
+		return
+	}
+	bodyLength, asBig, _, err := decodeULEB128(this.reader, this.buffer[:])
+	if err != nil {
+		err = incompleteIfTruncated(err, 1)
+		return
+	}
+	// Each point occupies at most 3 ULEB128 values.
+	if asBig != nil || bodyLength/3 < count || bodyLength/(3*maxULEB128Uint64Length) > count {
+		err = fmt.Errorf("%w: Block body length %v doesn't match point count %v", ErrorMalformed, bodyLength, count)
+		return
+	}
+	if header.Min, _, err = DecodeSmall(this.reader); err != nil {
+		err = incompleteIfTruncated(err, 1)
+		return
+	}
+	if header.Max, _, err = DecodeSmall(this.reader); err != nil {
+		err = incompleteIfTruncated(err, 1)
+		return
+	}
+
+	header.Count = int(count)
+	this.count = int(count)
+	this.bodyLength = bodyLength
+	this.haveBody = true
+	return
+}
+
+// Read the points of the block whose header was just read by NextBlock(),
+// appending them to timestamps and values.
+func (this *BlockReader) ReadPoints(timestamps []int64, values []DFloat) ([]int64, []DFloat, error) {
+	if !this.haveBody {
+		panic("ReadPoints: NextBlock() must be called first")
+	}
+	this.haveBody = false
+
+	// Copy rather than allocating bodyLength up front, so that a corrupt
+	// length can't trigger a huge allocation.
+	this.body.Reset()
+	if _, err := io.CopyN(&this.body, this.reader, int64(this.bodyLength)); err != nil {
+		return timestamps, values, incompleteIfTruncated(err, 1)
+	}
+	body := this.body.Bytes()
+
+	timestamp := int64(0)
+	offset := 0
+	for i := 0; i < this.count; i++ {
+		delta, asBig, byteCount, err := decodeULEB128FromBytes(body[offset:])
+		if err != nil {
+			return timestamps, values, fmt.Errorf("%w: Truncated timestamp in block body", ErrorMalformed)
+		}
+		if asBig != nil {
+			return timestamps, values, fmt.Errorf("%w: Timestamp delta %v is too big", ErrorMalformed, asBig)
+		}
+		timestamp = int64(uint64(timestamp) + uint64(zigzagDecode(delta)))
+		timestamps = append(timestamps, timestamp)
+		offset += byteCount
+	}
+
+	valueReader := bytes.NewReader(body[offset:])
+	decoder := NewDeltaDecoder(valueReader)
+	for i := 0; i < this.count; i++ {
+		value, _, err := decoder.Decode()
+		if err != nil {
+			return timestamps, values, fmt.Errorf("%w: Invalid value in block body: %v", ErrorMalformed, err)
+		}
+		values = append(values, value)
+	}
+	if valueReader.Len() != 0 {
+		return timestamps, values, fmt.Errorf("%w: %v unused bytes in block body", ErrorMalformed, valueReader.Len())
+	}
+	return timestamps, values, nil
+}
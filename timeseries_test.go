@@ -0,0 +1,165 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestBlockRoundTrip(t *testing.T) {
+	timestamps := []int64{1000, 1010, 1020, 1030, 1025, math.MaxInt64, math.MinInt64}
+	values := []DFloat{DFloatValue(-2, 1050), DFloatValue(-2, 1049), QuietNaN(), DFloatValue(-2, -7), Infinity(), NegativeZero(), DFloatValue(100, 1)}
+
+	buffer := &bytes.Buffer{}
+	writer := NewBlockWriter(buffer, 3)
+	for i := range timestamps {
+		if _, err := writer.Write(timestamps[i], values[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedHeaders := []BlockHeader{
+		{Count: 3, Min: DFloatValue(-2, 1049), Max: DFloatValue(-2, 1050)},
+		{Count: 3, Min: DFloatValue(-2, -7), Max: Infinity()},
+		{Count: 1, Min: DFloatValue(100, 1), Max: DFloatValue(100, 1)},
+	}
+	reader := NewBlockReader(buffer)
+	var actualTimestamps []int64
+	var actualValues []DFloat
+	for _, expectedHeader := range expectedHeaders {
+		header, err := reader.NextBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header != expectedHeader {
+			t.Errorf("Expected header %+v but got %+v", expectedHeader, header)
+		}
+		if actualTimestamps, actualValues, err = reader.ReadPoints(actualTimestamps, actualValues); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := reader.NextBlock(); err != io.EOF {
+		t.Errorf("Expected io.EOF but got %v", err)
+	}
+	if !reflect.DeepEqual(actualTimestamps, timestamps) {
+		t.Errorf("Expected timestamps %v but got %v", timestamps, actualTimestamps)
+	}
+	if !reflect.DeepEqual(actualValues, values) {
+		t.Errorf("Expected values %v but got %v", values, actualValues)
+	}
+}
+
+func TestBlockSkip(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer := NewBlockWriter(buffer, 2)
+	for i := int64(0); i < 5; i++ {
+		writer.Write(i*60, DFloatValue(0, i))
+	}
+	writer.Flush()
+
+	reader := NewBlockReader(buffer)
+	for i := 0; i < 2; i++ {
+		if _, err := reader.NextBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	header, err := reader.NextBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Count != 1 || header.Min != DFloatValue(0, 4) {
+		t.Errorf("Unexpected header %+v", header)
+	}
+	timestamps, values, err := reader.ReadPoints(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(timestamps, []int64{240}) || !reflect.DeepEqual(values, []DFloat{DFloatValue(0, 4)}) {
+		t.Errorf("Unexpected points %v %v", timestamps, values)
+	}
+}
+
+func TestBlockEmptyFlush(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	if bytesEncoded, err := NewBlockWriter(buffer, 10).Flush(); err != nil || bytesEncoded != 0 || buffer.Len() != 0 {
+		t.Errorf("Expected an empty flush to write nothing but got %v bytes (%v)", bytesEncoded, err)
+	}
+}
+
+func TestBlockAllNaN(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer := NewBlockWriter(buffer, 10)
+	writer.Write(1, QuietNaN())
+	writer.Flush()
+	header, err := NewBlockReader(buffer).NextBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !header.Min.IsNan() || !header.Max.IsNan() {
+		t.Errorf("Expected NaN min and max but got %+v", header)
+	}
+}
+
+func TestBlockMalformed(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer := NewBlockWriter(buffer, 10)
+	writer.Write(1, DFloatValue(0, 1))
+	writer.Write(2, DFloatValue(0, 2))
+	writer.Flush()
+	encoded := buffer.Bytes()
+
+	// Truncated anywhere
+	for length := 1; length < len(encoded); length++ {
+		reader := NewBlockReader(bytes.NewReader(encoded[:length]))
+		_, err := reader.NextBlock()
+		if err == nil {
+			_, _, err = reader.ReadPoints(nil, nil)
+		}
+		if !errors.Is(err, ErrorIncomplete) {
+			t.Errorf("Length %v: Expected ErrorIncomplete but got %v", length, err)
+		}
+	}
+
+	// Body length inconsistent with the count
+	for _, header := range [][]byte{{0x02, 0x05}, {0x01, 0x40}} {
+		if _, err := NewBlockReader(bytes.NewReader(header)).NextBlock(); !errors.Is(err, ErrorMalformed) {
+			t.Errorf("%x: Expected ErrorMalformed but got %v", header, err)
+		}
+	}
+
+	// Body with unused bytes
+	malformed := []byte{0x01, 0x04, 0x02, 0x02, 0x02, 0x00, 0x00, 0x00}
+	reader := NewBlockReader(bytes.NewReader(malformed))
+	if _, err := reader.NextBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := reader.ReadPoints(nil, nil); !errors.Is(err, ErrorMalformed) {
+		t.Errorf("Expected ErrorMalformed but got %v", err)
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"math/bits"
+)
+
+// ULEB128 encoding and decoding, specialized for compact float fields:
+// https://en.wikipedia.org/wiki/LEB128
+
+// The maximum number of bytes a uint64 occupies when ULEB128 encoded.
+const maxULEB128Uint64Length = 10
+
+// Returns the number of bytes required to ULEB128 encode value.
+func encodedSizeULEB128Uint64(value uint64) int {
+	return (bits.Len64(value|1) + 6) / 7
+}
+
+// Returns the number of bytes required to ULEB128 encode value (the sign is
+// ignored).
+func encodedSizeULEB128(value *big.Int) int {
+	bitCount := value.BitLen()
+	if bitCount == 0 {
+		return 1
+	}
+	return (bitCount + 6) / 7
+}
+
+// ULEB128 encode value into buffer, returning the number of bytes encoded.
+// Assumes that there's enough room in buffer (see maxULEB128Uint64Length).
+func encodeULEB128Uint64(value uint64, buffer []byte) (bytesEncoded int) {
+	if bytesEncoded, ok := encodeULEB128Fast(value, buffer); ok {
+		return bytesEncoded
+	}
+	for value >= 0x80 {
+		buffer[bytesEncoded] = byte(value) | 0x80
+		value >>= 7
+		bytesEncoded++
+	}
+	buffer[bytesEncoded] = byte(value)
+	return bytesEncoded + 1
+}
+
+// ULEB128 encode value (ignoring its sign) into buffer, returning the number of
+// bytes encoded. Assumes that there's enough room in buffer (see
+// encodedSizeULEB128()).
+func encodeULEB128(value *big.Int, buffer []byte) (bytesEncoded int) {
+	if value.IsUint64() {
+		return encodeULEB128Uint64(value.Uint64(), buffer)
+	}
+
+	words := value.Bits()
+	byteCount := encodedSizeULEB128(value)
+	last := byteCount - 1
+	for i := 0; i < byteCount; i++ {
+		b := byte(extractULEB128Payload(words, uint(i)*7))
+		if i < last {
+			b |= 0x80
+		}
+		buffer[i] = b
+	}
+	return byteCount
+}
+
+// Returns the 7 bits of words starting at bitIndex.
+func extractULEB128Payload(words []big.Word, bitIndex uint) big.Word {
+	wordIndex := int(bitIndex / wordBits)
+	bitOffset := bitIndex % wordBits
+	payload := words[wordIndex] >> bitOffset
+	if bitOffset > wordBits-7 && wordIndex+1 < len(words) {
+		payload |= words[wordIndex+1] << (wordBits - bitOffset)
+	}
+	return payload & 0x7f
+}
+
+// ORs a 7-bit payload into words at bitIndex, growing words as needed.
+func orULEB128Payload(words []big.Word, bitIndex uint, payload big.Word) []big.Word {
+	wordIndex := int(bitIndex / wordBits)
+	bitOffset := bitIndex % wordBits
+	for len(words) <= wordIndex+1 {
+		words = append(words, 0)
+	}
+	words[wordIndex] |= payload << bitOffset
+	if bitOffset > wordBits-7 {
+		words[wordIndex+1] |= payload >> (wordBits - bitOffset)
+	}
+	return words
+}
+
+// Decode a ULEB128 value using the supplied 1-byte buffer (to avoid extra
+// allocations). If the result is small enough to fit into type uint64, asBig
+// will be nil and asUint will contain the result.
+// If reader is an io.ByteReader, it is read using ReadByte() and buffer is not
+// used.
+func decodeULEB128(reader io.Reader, buffer []byte) (asUint uint64, asBig *big.Int, byteCount int, err error) {
+	if byteReader, ok := reader.(io.ByteReader); ok {
+		return decodeULEB128FromByteReader(byteReader, 0)
+	}
+	buffer = buffer[:1]
+	bitIndex := uint(0)
+	for {
+		if _, err = reader.Read(buffer); err != nil {
+			return
+		}
+		byteCount++
+		b := buffer[0]
+		payload := uint64(b & 0x7f)
+		if bitIndex < 63 || (bitIndex == 63 && payload <= 1) {
+			asUint |= payload << bitIndex
+		} else if payload != 0 {
+			return decodeULEB128Big(reader, buffer, asUint, bitIndex, b, byteCount)
+		}
+		if b < 0x80 {
+			return
+		}
+		bitIndex += 7
+	}
+}
+
+// Decode a ULEB128 value using ReadByte(), failing with ErrorTooLong if it
+// occupies more than maxBytes bytes (0 = no limit).
+func decodeULEB128FromByteReader(reader io.ByteReader, maxBytes int) (asUint uint64, asBig *big.Int, byteCount int, err error) {
+	bitIndex := uint(0)
+	for {
+		if maxBytes > 0 && byteCount >= maxBytes {
+			err = ErrorTooLong
+			return
+		}
+		var b byte
+		if b, err = reader.ReadByte(); err != nil {
+			return
+		}
+		byteCount++
+		payload := uint64(b & 0x7f)
+		if bitIndex < 63 || (bitIndex == 63 && payload <= 1) {
+			asUint |= payload << bitIndex
+		} else if payload != 0 {
+			var remaining io.Reader = byteReaderAdapter{reader}
+			if maxBytes > 0 {
+				remaining = &limitedReader{reader: remaining, remaining: maxBytes - byteCount}
+			}
+			return decodeULEB128Big(remaining, []byte{0}, asUint, bitIndex, b, byteCount)
+		}
+		if b < 0x80 {
+			return
+		}
+		bitIndex += 7
+	}
+}
+
+// Adapts an io.ByteReader to io.Reader, for the (rare) big value path.
+type byteReaderAdapter struct {
+	reader io.ByteReader
+}
+
+func (this byteReaderAdapter) Read(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return
+	}
+	if p[0], err = this.reader.ReadByte(); err != nil {
+		return
+	}
+	return 1, nil
+}
+
+// Continue decoding a ULEB128 value that has grown too big for a uint64.
+// b is the most recently read byte, whose payload belongs at bitIndex.
+func decodeULEB128Big(reader io.Reader, buffer []byte, low uint64, bitIndex uint, b byte, byteCount int) (asUint uint64, asBig *big.Int, count int, err error) {
+	words := appendUint64Words(make([]big.Word, 0, 4), low)
+	for {
+		words = orULEB128Payload(words, bitIndex, big.Word(b&0x7f))
+		if b < 0x80 {
+			return 0, new(big.Int).SetBits(words), byteCount, nil
+		}
+		if _, err = reader.Read(buffer); err != nil {
+			return 0, nil, byteCount, err
+		}
+		byteCount++
+		b = buffer[0]
+		bitIndex += 7
+	}
+}
+
+// Decode a ULEB128 value directly from a byte slice.
+// If the result is small enough to fit into type uint64, asBig will be nil
+// and asUint will contain the result.
+func decodeULEB128FromBytes(data []byte) (asUint uint64, asBig *big.Int, bytesDecoded int, err error) {
+	if value, byteCount, ok := decodeULEB128Fast(data); ok {
+		return value, nil, byteCount, nil
+	}
+	for bytesDecoded < len(data) {
+		b := data[bytesDecoded]
+		bytesDecoded++
+		if b&0x80 == 0 {
+			break
+		}
+		if bytesDecoded == len(data) {
+			err = ErrorIncomplete
+			return
+		}
+	}
+	if bytesDecoded == 0 {
+		err = ErrorIncomplete
+		return
+	}
+
+	const maxUint64Bytes = 10
+	if bytesDecoded < maxUint64Bytes || (bytesDecoded == maxUint64Bytes && data[maxUint64Bytes-1] <= 1) {
+		for i := bytesDecoded - 1; i >= 0; i-- {
+			asUint = asUint<<7 | uint64(data[i]&0x7f)
+		}
+		return
+	}
+
+	words := make([]big.Word, 0, bytesDecoded*7/wordBits+2)
+	for i := 0; i < bytesDecoded; i++ {
+		words = orULEB128Payload(words, uint(i)*7, big.Word(data[i]&0x7f))
+	}
+	asBig = new(big.Int).SetBits(words)
+	if asBig.IsUint64() {
+		asUint = asBig.Uint64()
+		asBig = nil
+	}
+	return
+}
+
+// Returns the length of the ULEB128 value at the start of data.
+func uleb128FromBytesLength(data []byte) (length int, err error) {
+	for i, b := range data {
+		if b&0x80 == 0 {
+			return i + 1, nil
+		}
+	}
+	return 0, ErrorIncomplete
+}
+
+// A ULEB128 encoding is minimal if it doesn't end in an empty group.
+func isMinimalULEB128(encoded []byte) bool {
+	return len(encoded) == 1 || encoded[len(encoded)-1] != 0
+}
+
+// Returns the ULEB128 encoded value modulo 10, using the fact that
+// 128^n mod 10 cycles through 8, 4, 2, 6 for n >= 1.
+func uleb128Mod10(encoded []byte) int {
+	groupMultipliers := [4]int{6, 8, 4, 2}
+	result := int(encoded[0] & 0x7f)
+	for i := 1; i < len(encoded); i++ {
+		result += int(encoded[i]&0x7f) * groupMultipliers[i%4]
+	}
+	return result % 10
+}
+
+// Decode a ULEB128 value from a reader, failing with tooLongErr if it occupies
+// more than maxBytes bytes (0 = no limit).
+func decodeULEB128Limited(reader io.Reader, buffer []byte, maxBytes int, tooLongErr error) (asUint uint64, asBig *big.Int, byteCount int, err error) {
+	if byteReader, ok := reader.(io.ByteReader); ok {
+		asUint, asBig, byteCount, err = decodeULEB128FromByteReader(byteReader, maxBytes)
+		if err == ErrorTooLong {
+			err = fmt.Errorf("%w: Longer than %v bytes", tooLongErr, maxBytes)
+		}
+		return
+	}
+	if maxBytes <= 0 {
+		return decodeULEB128(reader, buffer)
+	}
+	limited := limitedReader{reader: reader, remaining: maxBytes}
+	asUint, asBig, byteCount, err = decodeULEB128(&limited, buffer)
+	if err == ErrorTooLong && limited.remaining <= 0 {
+		err = fmt.Errorf("%w: Longer than %v bytes", tooLongErr, maxBytes)
+	}
+	return
+}
+
+// Checks that the ULEB128 value at the start of data doesn't occupy more than
+// maxBytes bytes (0 = no limit), failing with tooLongErr if it does.
+func checkULEB128Length(data []byte, maxBytes int, tooLongErr error) error {
+	if maxBytes <= 0 || len(data) <= maxBytes {
+		return nil
+	}
+	if _, err := uleb128FromBytesLength(data[:maxBytes]); err != nil {
+		return fmt.Errorf("%w: Longer than %v bytes", tooLongErr, maxBytes)
+	}
+	return nil
+}
+
+// Decode a ULEB128 value into dst, reusing its existing word storage.
+// Fails with ErrorCoefficientTooLong if the value occupies more than maxBytes
+// bytes (0 = no limit).
+func decodeULEB128Into(reader io.Reader, buffer []byte, maxBytes int, dst *big.Int) (byteCount int, err error) {
+	buffer = buffer[:1]
+	words := dst.Bits()[:0]
+	word := big.Word(0)
+	bitIndex := uint(0)
+	for {
+		if maxBytes > 0 && byteCount >= maxBytes {
+			err = fmt.Errorf("%w: Longer than %v bytes", ErrorCoefficientTooLong, maxBytes)
+			return
+		}
+		if _, err = reader.Read(buffer); err != nil {
+			return
+		}
+		byteCount++
+		payload := big.Word(buffer[0] & 0x7f)
+		word |= payload << bitIndex
+		bitIndex += 7
+		if bitIndex >= wordBits {
+			words = append(words, word)
+			bitIndex -= wordBits
+			word = payload >> (7 - bitIndex)
+		}
+		if buffer[0]&0x80 == 0 {
+			break
+		}
+	}
+	words = append(words, word)
+	dst.SetBits(words)
+	return
+}
+
+// Encodes a ULEB128 value using exactly len(buffer) bytes, adding empty
+// groups as necessary. Assumes the buffer is big enough for the minimal
+// encoding.
+func encodePaddedULEB128(value uint64, buffer []byte) {
+	last := len(buffer) - 1
+	for i := 0; i < last; i++ {
+		buffer[i] = byte(value&0x7f) | 0x80
+		value >>= 7
+	}
+	buffer[last] = byte(value)
+}
+
+func appendULEB128(dst []byte, value uint64) []byte {
+	dst, buffer := growForAppend(dst, maxULEB128Uint64Length)
+	bytesEncoded := encodeULEB128Uint64(value, buffer)
+	return dst[:len(dst)+bytesEncoded]
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build amd64 || arm64
+// +build amd64 arm64
+
+package compact_float
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// SWAR (SIMD within a register) ULEB128 codec for architectures with cheap
+// unaligned 64-bit little endian loads and stores. Values of up to 8 encoded
+// bytes (56 bits) are handled with a single load or store and a few shifts
+// and masks instead of a loop per byte.
+
+const (
+	swarContinuationBits = 0x8080808080808080
+	swarPayloadBits      = 0x7f7f7f7f7f7f7f7f
+	swarMaxValue         = 1<<56 - 1
+)
+
+// Decode a ULEB128 value from the start of data, returning false if the fast
+// path doesn't apply (fewer than 8 bytes available, or the value is longer
+// than 8 bytes).
+func decodeULEB128Fast(data []byte) (value uint64, byteCount int, ok bool) {
+	if len(data) < 8 {
+		return
+	}
+	word := binary.LittleEndian.Uint64(data)
+	terminators := ^word & swarContinuationBits
+	if terminators == 0 {
+		return
+	}
+	byteCount = bits.TrailingZeros64(terminators)/8 + 1
+	word &= swarPayloadBits
+	if byteCount < 8 {
+		word &= uint64(1)<<(uint(byteCount)*8) - 1
+	}
+
+	// Squeeze out the continuation bit positions: 7-bit groups in 8-bit lanes
+	// become 14 bits in 16, then 28 bits in 32, then 56 bits.
+	word = (word & 0x007f007f007f007f) | ((word & 0x7f007f007f007f00) >> 1)
+	word = (word & 0x00003fff00003fff) | ((word & 0x3fff00003fff0000) >> 2)
+	word = (word & 0x000000000fffffff) | ((word & 0x0fffffff00000000) >> 4)
+	return word, byteCount, true
+}
+
+// ULEB128 encode value into buffer, returning false if the fast path doesn't
+// apply (buffer shorter than 8 bytes, or value needs more than 8 bytes).
+func encodeULEB128Fast(value uint64, buffer []byte) (bytesEncoded int, ok bool) {
+	if value > swarMaxValue || len(buffer) < 8 {
+		return
+	}
+	bytesEncoded = encodedSizeULEB128Uint64(value)
+
+	// The reverse of decoding: spread 56 bits into 28 bits per 32, then 14
+	// bits per 16, then 7 bits per 8.
+	word := (value & 0x000000000fffffff) | ((value & 0x00fffffff0000000) << 4)
+	word = (word & 0x00003fff00003fff) | ((word & 0x0fffc0000fffc000) << 2)
+	word = (word & 0x007f007f007f007f) | ((word & 0x3f803f803f803f80) << 1)
+	word |= swarContinuationBits & (uint64(1)<<(uint(bytesEncoded-1)*8) - 1)
+	if bytesEncoded < 8 {
+		// Preserve whatever follows the encoded bytes.
+		word |= binary.LittleEndian.Uint64(buffer) &^ (uint64(1)<<(uint(bytesEncoded)*8) - 1)
+	}
+	binary.LittleEndian.PutUint64(buffer, word)
+	return bytesEncoded, true
+}
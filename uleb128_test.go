@@ -0,0 +1,170 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/kstenerud/go-describe"
+)
+
+func assertULEB128(t *testing.T, value *big.Int) {
+	expected := []byte{}
+	remaining := new(big.Int).Set(value)
+	group := new(big.Int)
+	mask := big.NewInt(0x7f)
+	for {
+		b := byte(group.And(remaining, mask).Uint64())
+		remaining.Rsh(remaining, 7)
+		if remaining.Sign() == 0 {
+			expected = append(expected, b)
+			break
+		}
+		expected = append(expected, b|0x80)
+	}
+
+	if size := encodedSizeULEB128(value); size != len(expected) {
+		t.Errorf("Value %v: Expected encoded size %v but got %v", value, len(expected), size)
+	}
+	buffer := make([]byte, len(expected))
+	if bytesEncoded := encodeULEB128(value, buffer); !bytes.Equal(buffer[:bytesEncoded], expected) {
+		t.Errorf("Value %v: Expected encoding %v but got %v", value, describe.D(expected), describe.D(buffer[:bytesEncoded]))
+	}
+	if value.IsUint64() {
+		if size := encodedSizeULEB128Uint64(value.Uint64()); size != len(expected) {
+			t.Errorf("Value %v: Expected uint64 encoded size %v but got %v", value, len(expected), size)
+		}
+		if bytesEncoded := encodeULEB128Uint64(value.Uint64(), buffer); !bytes.Equal(buffer[:bytesEncoded], expected) {
+			t.Errorf("Value %v: Expected uint64 encoding %v but got %v", value, describe.D(expected), describe.D(buffer[:bytesEncoded]))
+		}
+	}
+
+	assertDecoded := func(source string, asUint uint64, asBig *big.Int, byteCount int, err error) {
+		if err != nil {
+			t.Errorf("Value %v (%v): Unexpected error %v", value, source, err)
+			return
+		}
+		if byteCount != len(expected) {
+			t.Errorf("Value %v (%v): Expected to decode %v bytes but got %v", value, source, len(expected), byteCount)
+		}
+		if value.IsUint64() {
+			if asBig != nil || asUint != value.Uint64() {
+				t.Errorf("Value %v (%v): Expected uint64 result but got %v, %v", value, source, asUint, asBig)
+			}
+		} else if asBig == nil || asBig.Cmp(value) != 0 {
+			t.Errorf("Value %v (%v): Expected big result but got %v, %v", value, source, asUint, asBig)
+		}
+	}
+	asUint, asBig, byteCount, err := decodeULEB128(bytes.NewBuffer(expected), []byte{0})
+	assertDecoded("reader", asUint, asBig, byteCount, err)
+	asUint, asBig, byteCount, err = decodeULEB128FromBytes(expected)
+	assertDecoded("bytes", asUint, asBig, byteCount, err)
+}
+
+func TestULEB128(t *testing.T) {
+	assertULEB128(t, big.NewInt(0))
+	assertULEB128(t, big.NewInt(0x7f))
+	assertULEB128(t, big.NewInt(0x80))
+	assertULEB128(t, new(big.Int).SetUint64(0xffffffffffffffff))
+	assertULEB128(t, new(big.Int).Lsh(big.NewInt(1), 64))
+	assertULEB128(t, new(big.Int).Lsh(big.NewInt(1), 63))
+
+	random := rand.New(rand.NewSource(1))
+	for bitCount := 1; bitCount < 300; bitCount++ {
+		value := new(big.Int).Rand(random, new(big.Int).Lsh(big.NewInt(1), uint(bitCount)))
+		value.SetBit(value, bitCount-1, 1)
+		assertULEB128(t, value)
+	}
+}
+
+func TestULEB128Padded(t *testing.T) {
+	encoded := []byte{0x85, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}
+	asUint, asBig, byteCount, err := decodeULEB128(bytes.NewBuffer(encoded), []byte{0})
+	if err != nil || asBig != nil || asUint != 5 || byteCount != len(encoded) {
+		t.Errorf("Expected padded value to decode to 5 but got %v, %v, %v, %v", asUint, asBig, byteCount, err)
+	}
+}
+
+func TestULEB128FastPath(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		value := random.Uint64() >> uint(random.Intn(64))
+		expected := make([]byte, 0, maxULEB128Uint64Length)
+		for remaining := value; ; remaining >>= 7 {
+			if remaining < 0x80 {
+				expected = append(expected, byte(remaining))
+				break
+			}
+			expected = append(expected, byte(remaining)|0x80)
+		}
+
+		buffer := bytes.Repeat([]byte{0xa5}, 16)
+		bytesEncoded := encodeULEB128Uint64(value, buffer)
+		if !bytes.Equal(buffer[:bytesEncoded], expected) {
+			t.Fatalf("Value %x: Expected encoding %v but got %v", value, describe.D(expected), describe.D(buffer[:bytesEncoded]))
+		}
+		for _, b := range buffer[bytesEncoded:] {
+			if b != 0xa5 {
+				t.Fatalf("Value %x: Encoding clobbered bytes past its end: %v", value, describe.D(buffer))
+			}
+		}
+
+		asUint, asBig, byteCount, err := decodeULEB128FromBytes(buffer)
+		if err != nil || asBig != nil || asUint != value || byteCount != len(expected) {
+			t.Fatalf("Value %x: Decoded %x, %v, %v, %v", value, asUint, asBig, byteCount, err)
+		}
+	}
+}
+
+func BenchmarkEncodeColumns(b *testing.B) {
+	values := make([]DFloat, 10000)
+	random := rand.New(rand.NewSource(1))
+	for i := range values {
+		values[i] = DFloatValue(int32(random.Intn(20)-10), random.Int63n(1<<40))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		EncodeColumns(values, ioutil.Discard)
+	}
+}
+
+func BenchmarkDecodeFromBytes(b *testing.B) {
+	values := make([]DFloat, 10000)
+	random := rand.New(rand.NewSource(1))
+	for i := range values {
+		values[i] = DFloatValue(int32(random.Intn(20)-10), random.Int63n(1<<40))
+	}
+	var encoded []byte
+	for _, value := range values {
+		encoded = AppendEncode(encoded, value)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for data := encoded; len(data) > 0; {
+			_, _, bytesDecoded, _ := DecodeFromBytes(data)
+			data = data[bytesDecoded:]
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+)
+
+// Test vectors describe a value in three equivalent forms (decimal text,
+// encoded bytes, and DFloat exponent and coefficient), so that other
+// implementations of compact float can validate against this one and vice
+// versa. They can be exchanged as JSON:
+//
+//	[{"decimal": "1.5", "encoded": "060f", "exponent": -1, "coefficient": "15"}]
+//
+// or as CSV (with a header row):
+//
+//	decimal,encoded,exponent,coefficient
+//	1.5,060f,-1,15
+//
+// The encoded bytes are in hex, and the JSON coefficient is a string because
+// many JSON implementations can't represent all int64 values as numbers.
+// Special values have an exponent of ExpSpecial and a coefficient of one of the
+// CoeffXYZ codes.
+type TestVector struct {
+	Decimal string
+	Encoded []byte
+	Value   DFloat
+}
+
+var ErrorTestVectorMismatch = errors.New("Test vector forms do not match")
+
+// Builds the test vector for a value.
+func NewTestVector(value DFloat) TestVector {
+	return TestVector{
+		Decimal: value.String(),
+		Encoded: AppendEncode(nil, value),
+		Value:   value,
+	}
+}
+
+// Returns test vectors for the special values and the extremes of the exponent
+// and coefficient ranges, followed by count pseudo-random values generated
+// from seed (the same seed always produces the same vectors).
+func GenerateTestVectors(count int, seed int64) (vectors []TestVector) {
+	for _, value := range generatedSpecials {
+		vectors = append(vectors, NewTestVector(value))
+	}
+	for _, value := range generatedExtremes {
+		vectors = append(vectors, NewTestVector(value))
+	}
+	random := rand.New(rand.NewSource(seed))
+	for i := 0; i < count; i++ {
+		vectors = append(vectors, NewTestVector(generateDFloat(random, 50)))
+	}
+	return
+}
+
+// Checks that all forms of this test vector agree with each other. If they
+// don't, the returned error will be ErrorTestVectorMismatch.
+func (this TestVector) Verify() error {
+	if encoded := AppendEncode(nil, this.Value); !bytes.Equal(encoded, this.Encoded) {
+		return fmt.Errorf("%w: %v encodes to %x, not %x", ErrorTestVectorMismatch, this.Value, encoded, this.Encoded)
+	}
+	decoded, bigValue, err := DecodeExact(this.Encoded)
+	if err != nil {
+		return fmt.Errorf("%w: %x failed to decode: %v", ErrorTestVectorMismatch, this.Encoded, err)
+	}
+	if bigValue != nil || decoded != this.Value {
+		return fmt.Errorf("%w: %x decodes to %v, not %v", ErrorTestVectorMismatch, this.Encoded, decoded, this.Value)
+	}
+	parsed, err := DFloatFromString(this.Decimal)
+	if err != nil {
+		return fmt.Errorf("%w: %v failed to parse: %v", ErrorTestVectorMismatch, this.Decimal, err)
+	}
+	if parsed != this.Value {
+		return fmt.Errorf("%w: %v parses to %v, not %v", ErrorTestVectorMismatch, this.Decimal, parsed, this.Value)
+	}
+	return nil
+}
+
+type testVectorJSON struct {
+	Decimal     string `json:"decimal"`
+	Encoded     string `json:"encoded"`
+	Exponent    int32  `json:"exponent"`
+	Coefficient int64  `json:"coefficient,string"`
+}
+
+// Writes test vectors as a JSON array.
+func WriteTestVectorsJSON(vectors []TestVector, writer io.Writer) error {
+	asJSON := make([]testVectorJSON, 0, len(vectors))
+	for _, vector := range vectors {
+		asJSON = append(asJSON, testVectorJSON{
+			Decimal:     vector.Decimal,
+			Encoded:     hex.EncodeToString(vector.Encoded),
+			Exponent:    vector.Value.Exponent,
+			Coefficient: vector.Value.Coefficient,
+		})
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(asJSON)
+}
+
+// Reads test vectors from a JSON array.
+func ReadTestVectorsJSON(reader io.Reader) (vectors []TestVector, err error) {
+	var asJSON []testVectorJSON
+	if err = json.NewDecoder(reader).Decode(&asJSON); err != nil {
+		return
+	}
+	for i, vector := range asJSON {
+		encoded, err := hex.DecodeString(vector.Encoded)
+		if err != nil {
+			return nil, fmt.Errorf("Test vector %v: %v", i, err)
+		}
+		vectors = append(vectors, TestVector{
+			Decimal: vector.Decimal,
+			Encoded: encoded,
+			Value:   DFloat{Exponent: vector.Exponent, Coefficient: vector.Coefficient},
+		})
+	}
+	return
+}
+
+var testVectorCSVHeader = []string{"decimal", "encoded", "exponent", "coefficient"}
+
+// Writes test vectors as CSV, with a header row.
+func WriteTestVectorsCSV(vectors []TestVector, writer io.Writer) error {
+	csvWriter := csv.NewWriter(writer)
+	if err := csvWriter.Write(testVectorCSVHeader); err != nil {
+		return err
+	}
+	for _, vector := range vectors {
+		if err := csvWriter.Write([]string{
+			vector.Decimal,
+			hex.EncodeToString(vector.Encoded),
+			strconv.FormatInt(int64(vector.Value.Exponent), 10),
+			strconv.FormatInt(vector.Value.Coefficient, 10),
+		}); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// Reads test vectors from CSV, with a header row.
+func ReadTestVectorsCSV(reader io.Reader) (vectors []TestVector, err error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = len(testVectorCSVHeader)
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("Missing test vector CSV header")
+	}
+	for i, record := range records[1:] {
+		encoded, err := hex.DecodeString(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("Test vector %v: %v", i, err)
+		}
+		exponent, err := strconv.ParseInt(record[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Test vector %v: %v", i, err)
+		}
+		coefficient, err := strconv.ParseInt(record[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Test vector %v: %v", i, err)
+		}
+		vectors = append(vectors, TestVector{
+			Decimal: record[0],
+			Encoded: encoded,
+			Value:   DFloat{Exponent: int32(exponent), Coefficient: coefficient},
+		})
+	}
+	return
+}
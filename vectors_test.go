@@ -0,0 +1,143 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var updateVectors = flag.Bool("update-vectors", false, "Regenerate the test vector fixtures in testdata")
+
+const (
+	testVectorCount = 200
+	testVectorSeed  = 1
+)
+
+func TestTestVectorsVerify(t *testing.T) {
+	for _, vector := range GenerateTestVectors(1000, 2) {
+		if err := vector.Verify(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	vector := NewTestVector(DFloatValue(-1, 15))
+	vector.Encoded = []byte{0x06, 0x0e}
+	if err := vector.Verify(); !errors.Is(err, ErrorTestVectorMismatch) {
+		t.Errorf("Expected ErrorTestVectorMismatch but got %v", err)
+	}
+	vector = NewTestVector(DFloatValue(-1, 15))
+	vector.Decimal = "1.6"
+	if err := vector.Verify(); !errors.Is(err, ErrorTestVectorMismatch) {
+		t.Errorf("Expected ErrorTestVectorMismatch but got %v", err)
+	}
+}
+
+func TestTestVectorsRoundTrip(t *testing.T) {
+	vectors := GenerateTestVectors(100, 3)
+
+	buffer := &bytes.Buffer{}
+	if err := WriteTestVectorsJSON(vectors, buffer); err != nil {
+		t.Error(err)
+		return
+	}
+	fromJSON, err := ReadTestVectorsJSON(buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(fromJSON, vectors) {
+		t.Errorf("Expected JSON vectors to round trip")
+	}
+
+	buffer.Reset()
+	if err := WriteTestVectorsCSV(vectors, buffer); err != nil {
+		t.Error(err)
+		return
+	}
+	fromCSV, err := ReadTestVectorsCSV(buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(fromCSV, vectors) {
+		t.Errorf("Expected CSV vectors to round trip")
+	}
+}
+
+// Run with -update-vectors to regenerate the fixtures.
+func TestTestVectorFixtures(t *testing.T) {
+	vectors := GenerateTestVectors(testVectorCount, testVectorSeed)
+	for _, fixture := range []struct {
+		name  string
+		write func([]TestVector, *bytes.Buffer) error
+		read  func(*bytes.Buffer) ([]TestVector, error)
+	}{
+		{"test-vectors.json",
+			func(v []TestVector, b *bytes.Buffer) error { return WriteTestVectorsJSON(v, b) },
+			func(b *bytes.Buffer) ([]TestVector, error) { return ReadTestVectorsJSON(b) }},
+		{"test-vectors.csv",
+			func(v []TestVector, b *bytes.Buffer) error { return WriteTestVectorsCSV(v, b) },
+			func(b *bytes.Buffer) ([]TestVector, error) { return ReadTestVectorsCSV(b) }},
+	} {
+		path := filepath.Join("testdata", fixture.name)
+		generated := &bytes.Buffer{}
+		if err := fixture.write(vectors, generated); err != nil {
+			t.Error(err)
+			return
+		}
+		if *updateVectors {
+			if err := os.MkdirAll("testdata", 0755); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := ioutil.WriteFile(path, generated.Bytes(), 0644); err != nil {
+				t.Error(err)
+			}
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !bytes.Equal(contents, generated.Bytes()) {
+			t.Errorf("%v is out of date (run with -update-vectors)", path)
+		}
+		loaded, err := fixture.read(bytes.NewBuffer(contents))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		for _, vector := range loaded {
+			if err := vector.Verify(); err != nil {
+				t.Errorf("%v: %v", path, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+//go:build 386 || arm || mips || mipsle
+// +build 386 arm mips mipsle
+
+package compact_float
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// The size of a big.Word in bits.
+const wordBits = 32
+
+// Fails to compile if wordBits doesn't match the platform's actual word size.
+var _ [wordBits - bits.UintSize]struct{}
+var _ [bits.UintSize - wordBits]struct{}
+
+// Appends a uint64 to a little endian big.Word slice.
+func appendUint64Words(words []big.Word, value uint64) []big.Word {
+	return append(words, big.Word(value), big.Word(value>>32))
+}
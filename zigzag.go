@@ -0,0 +1,246 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"fmt"
+	"math"
+)
+
+// Zigzag-exponent encoding is a NON-STANDARD extension to compact float, and
+// data encoded this way can't be read by compact float decoders (or by any of
+// the other decoding functions in this package). It must only be used where
+// both ends agree on it.
+//
+// The standard exponent field stores the exponent magnitude above separate
+// exponent and coefficient sign bits, which wastes the "negative zero
+// exponent" encodings on just the two zero values. This mode instead stores
+// the zigzag encoded exponent above the coefficient sign bit:
+//
+//     exponent field: ULEB128: zigzag(exponent) << 1 | coefficient sign
+//     coefficient:    ULEB128: coefficient magnitude (as in the standard)
+//
+// Each exponent field length then covers one more negative exponent (1 byte
+// covers exponents -32 to 31 rather than -31 to 31, 2 bytes -4096 to 4095
+// rather than -4095 to 4095, and so on), saving a byte for values with those
+// exponents. In exchange, zero and negative zero are stored as a coefficient
+// of 0 (00 00 and 01 00), costing an extra byte. Infinity and NaN use the same
+// 2-byte encodings as the standard.
+
+// Returns the number of bytes value will occupy in zigzag-exponent encoding.
+func EncodedSizeZigzag(value DFloat) int {
+	if value.IsSpecial() || value.IsZero() {
+		return 2
+	}
+	exponentField, coefficient := splitDFloatZigzag(value)
+	return encodedSizeULEB128Uint64(exponentField) + encodedSizeULEB128Uint64(coefficient)
+}
+
+// Appends the zigzag-exponent encoding of value to dst, returning the extended
+// slice. No allocations occur if dst has enough capacity.
+func AppendEncodeZigzag(dst []byte, value DFloat) []byte {
+	switch {
+	case value.IsZero():
+		sign := byte(0)
+		if value.IsNegativeZero() {
+			sign = 1
+		}
+		return append(dst, sign, 0)
+	case value.IsSpecial():
+		return AppendEncode(dst, value)
+	}
+	dst, buffer := growForAppend(dst, 2*maxULEB128Uint64Length)
+	exponentField, coefficient := splitDFloatZigzag(value)
+	bytesEncoded := encodeULEB128Uint64(exponentField, buffer)
+	bytesEncoded += encodeULEB128Uint64(coefficient, buffer[bytesEncoded:])
+	return dst[:len(dst)+bytesEncoded]
+}
+
+// Decodes a zigzag-exponent encoded value from the start of data.
+// bigValue will be nil unless the decoded value is too big to fit into a DFloat.
+func DecodeZigzagFromBytes(data []byte) (value DFloat, bigValue *BigDecimal, bytesDecoded int, err error) {
+	exponentField, offset, err := decodeExponentFieldBytes(data)
+	if err != nil {
+		return
+	}
+	var isSpecial bool
+	if offset == 2 {
+		if value, isSpecial = decodeSpecialValue(exponentField, offset); isSpecial {
+			bytesDecoded = offset
+			return
+		}
+	}
+
+	if err = checkULEB128Length(data[offset:], MaxCoefficientLength, ErrorCoefficientTooLong); err != nil {
+		return
+	}
+	asUint, asBig, bytesDecoded, err := decodeULEB128FromBytes(data[offset:])
+	if err != nil {
+		return
+	}
+	bytesDecoded += offset
+
+	isNegative := exponentField&1 == 1
+	if asUint == 0 && asBig == nil {
+		value = dfloatZero
+		if isNegative {
+			value = dfloatNegativeZero
+		}
+		return
+	}
+	exponent, err := zigzagFieldExponent(exponentField)
+	if err != nil {
+		return
+	}
+	value, bigValue, err = decodedValue(exponent, isNegative, asUint, asBig)
+	return
+}
+
+// Converts a sequence of standard compact float encodings in src to
+// zigzag-exponent encodings, appending them to dst. Only the exponent fields
+// are rewritten, so coefficients of any size are supported.
+func TranscodeToZigzag(dst []byte, src []byte) ([]byte, error) {
+	for offset := 0; offset < len(src); {
+		exponentField, fieldLength, err := decodeExponentFieldBytes(src[offset:])
+		if err != nil {
+			return dst, err
+		}
+		if value, isSpecial := decodeSpecialValue(exponentField, fieldLength); isSpecial {
+			dst = AppendEncodeZigzag(dst, value)
+			offset += fieldLength
+			continue
+		}
+		exponent, isNegative, err := decodeExponentField(exponentField)
+		if err != nil {
+			return dst, err
+		}
+		coefficient, err := coefficientBytes(src[offset+fieldLength:])
+		if err != nil {
+			return dst, err
+		}
+		dst = appendULEB128(dst, zigzagExponentField(exponent, isNegative))
+		dst = append(dst, coefficient...)
+		offset += fieldLength + len(coefficient)
+	}
+	return dst, nil
+}
+
+// Converts a sequence of zigzag-exponent encodings in src to standard compact
+// float encodings, appending them to dst. Only the exponent fields are
+// rewritten, so coefficients of any size are supported.
+func TranscodeFromZigzag(dst []byte, src []byte) ([]byte, error) {
+	for offset := 0; offset < len(src); {
+		exponentField, fieldLength, err := decodeExponentFieldBytes(src[offset:])
+		if err != nil {
+			return dst, err
+		}
+		if fieldLength == 2 {
+			if value, isSpecial := decodeSpecialValue(exponentField, fieldLength); isSpecial {
+				dst = AppendEncode(dst, value)
+				offset += fieldLength
+				continue
+			}
+		}
+		coefficient, err := coefficientBytes(src[offset+fieldLength:])
+		if err != nil {
+			return dst, err
+		}
+		offset += fieldLength + len(coefficient)
+
+		isNegative := exponentField&1 == 1
+		if isULEB128Zero(coefficient) {
+			if isNegative {
+				dst = AppendEncode(dst, dfloatNegativeZero)
+			} else {
+				dst = AppendEncode(dst, dfloatZero)
+			}
+			continue
+		}
+		exponent, err := zigzagFieldExponent(exponentField)
+		if err != nil {
+			return dst, err
+		}
+		standardField, _ := splitDFloat(DFloat{Exponent: exponent, Coefficient: 1})
+		if isNegative {
+			standardField |= 1
+		}
+		dst = appendULEB128(dst, standardField)
+		dst = append(dst, coefficient...)
+	}
+	return dst, nil
+}
+
+func splitDFloatZigzag(value DFloat) (exponentField uint64, coefficient uint64) {
+	coefficient = uint64(value.Coefficient)
+	if value.Coefficient < 0 {
+		coefficient = -coefficient
+	}
+	return zigzagExponentField(value.Exponent, value.Coefficient < 0), coefficient
+}
+
+func zigzagExponentField(exponent int32, isNegative bool) uint64 {
+	exponentField := zigzagEncode(int64(exponent)) << 1
+	if isNegative {
+		exponentField |= 1
+	}
+	return exponentField
+}
+
+func zigzagFieldExponent(exponentField uint64) (exponent int32, err error) {
+	asInt64 := zigzagDecode(exponentField >> 1)
+	if asInt64 <= math.MinInt32 || asInt64 > math.MaxInt32 {
+		err = fmt.Errorf("%w: %v", ErrorExponentTooLarge, asInt64)
+		return
+	}
+	return int32(asInt64), nil
+}
+
+func decodeExponentFieldBytes(data []byte) (exponentField uint64, fieldLength int, err error) {
+	if err = checkULEB128Length(data, maxExponentFieldLength, ErrorExponentTooLarge); err != nil {
+		return
+	}
+	exponentField, asBig, fieldLength, err := decodeULEB128FromBytes(data)
+	if err == nil && asBig != nil {
+		err = fmt.Errorf("%w: %v", ErrorExponentTooLarge, asBig)
+	}
+	return
+}
+
+// Returns the ULEB128 encoded coefficient at the start of data.
+func coefficientBytes(data []byte) ([]byte, error) {
+	if err := checkULEB128Length(data, MaxCoefficientLength, ErrorCoefficientTooLong); err != nil {
+		return nil, err
+	}
+	length, err := uleb128FromBytesLength(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:length], nil
+}
+
+func isULEB128Zero(encoded []byte) bool {
+	for _, b := range encoded {
+		if b&0x7f != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 Karl Stenerud
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package compact_float
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func assertZigzagEncoding(t *testing.T, value DFloat, expected ...byte) {
+	encoded := AppendEncodeZigzag(nil, value)
+	if !bytes.Equal(encoded, expected) {
+		t.Errorf("%v: Expected %x but got %x", value, expected, encoded)
+	}
+	if size := EncodedSizeZigzag(value); size != len(expected) {
+		t.Errorf("%v: Expected size %v but got %v", value, len(expected), size)
+	}
+	decoded, _, bytesDecoded, err := DecodeZigzagFromBytes(encoded)
+	if err != nil {
+		t.Errorf("%v: %v", value, err)
+		return
+	}
+	if decoded != value.minimized() || bytesDecoded != len(expected) {
+		t.Errorf("%v: Decoded %v (%v bytes)", value, decoded, bytesDecoded)
+	}
+}
+
+func TestZigzagEncoding(t *testing.T) {
+	assertZigzagEncoding(t, DFloatValue(0, 1), 0x00, 0x01)
+	assertZigzagEncoding(t, DFloatValue(0, -1), 0x01, 0x01)
+	assertZigzagEncoding(t, DFloatValue(-1, 15), 0x02, 0x0f)
+	assertZigzagEncoding(t, DFloatValue(1, 1), 0x04, 0x01)
+	assertZigzagEncoding(t, DFloatValue(-32, -1), 0x7f, 0x01)
+	assertZigzagEncoding(t, DFloatValue(32, 1), 0x80, 0x01, 0x01)
+	assertZigzagEncoding(t, DFloatValue(math.MaxInt32, 1), 0xfc, 0xff, 0xff, 0xff, 0x1f, 0x01)
+	assertZigzagEncoding(t, DFloatValue(-math.MaxInt32, 1), 0xfa, 0xff, 0xff, 0xff, 0x1f, 0x01)
+	assertZigzagEncoding(t, Zero(), 0x00, 0x00)
+	assertZigzagEncoding(t, NegativeZero(), 0x01, 0x00)
+	assertZigzagEncoding(t, Infinity(), 0x82, 0x00)
+	assertZigzagEncoding(t, NegativeInfinity(), 0x83, 0x00)
+	assertZigzagEncoding(t, QuietNaN(), 0x80, 0x00)
+	assertZigzagEncoding(t, NegativeSignalingNaN(), 0x85, 0x00)
+}
+
+func TestZigzagSavesByte(t *testing.T) {
+	value := DFloatValue(-32, 1)
+	if standard, zigzag := EncodedSize(value), EncodedSizeZigzag(value); zigzag != standard-1 {
+		t.Errorf("Expected zigzag encoding to be 1 byte smaller than %v but got %v", standard, zigzag)
+	}
+}
+
+func TestZigzagTranscode(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	values := []DFloat{Zero(), NegativeZero(), Infinity(), NegativeInfinity(), QuietNaN(), SignalingNaN(), NegativeQuietNaN(), NegativeSignalingNaN()}
+	for i := 0; i < 1000; i++ {
+		values = append(values, RandDFloat(rng, RandOpts{MinExponent: -10000, MaxExponent: 10000, MaxDigits: 19}))
+	}
+
+	var standard, zigzag []byte
+	for _, value := range values {
+		standard = AppendEncode(standard, value)
+		zigzag = AppendEncodeZigzag(zigzag, value)
+	}
+
+	transcoded, err := TranscodeToZigzag(nil, standard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(transcoded, zigzag) {
+		t.Errorf("TranscodeToZigzag doesn't match AppendEncodeZigzag")
+	}
+	transcoded, err = TranscodeFromZigzag(nil, zigzag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(transcoded, standard) {
+		t.Errorf("TranscodeFromZigzag doesn't match AppendEncode")
+	}
+}
+
+func TestZigzagTranscodeBigCoefficient(t *testing.T) {
+	standard := []byte{0x0b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	zigzag, err := TranscodeToZigzag(nil, standard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := append([]byte{0x07}, standard[1:]...); !bytes.Equal(zigzag, expected) {
+		t.Errorf("Expected %x but got %x", expected, zigzag)
+	}
+	roundTripped, err := TranscodeFromZigzag(nil, zigzag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(roundTripped, standard) {
+		t.Errorf("Expected %x but got %x", standard, roundTripped)
+	}
+}
+
+func TestZigzagErrors(t *testing.T) {
+	for _, data := range [][]byte{{}, {0x04}, {0x84}, {0x04, 0x81}} {
+		if _, _, _, err := DecodeZigzagFromBytes(data); !errors.Is(err, ErrorIncomplete) {
+			t.Errorf("%x: Expected ErrorIncomplete but got %v", data, err)
+		}
+		if _, err := TranscodeFromZigzag(nil, data); len(data) > 0 && !errors.Is(err, ErrorIncomplete) {
+			t.Errorf("%x: Expected ErrorIncomplete but got %v", data, err)
+		}
+		if _, err := TranscodeToZigzag(nil, data); len(data) > 0 && !errors.Is(err, ErrorIncomplete) {
+			t.Errorf("%x: Expected ErrorIncomplete but got %v", data, err)
+		}
+	}
+	// Exponent -2^31 is ExpSpecial
+	if _, _, _, err := DecodeZigzagFromBytes([]byte{0xfe, 0xff, 0xff, 0xff, 0x1f, 0x01}); !errors.Is(err, ErrorExponentTooLarge) {
+		t.Errorf("Expected ErrorExponentTooLarge but got %v", err)
+	}
+}